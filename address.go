@@ -2,15 +2,337 @@ package junglebus
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/GorillaPool/go-junglebus/models"
 )
 
+const (
+	// DefaultAddressPageSize is the default number of transactions in a page of GetAddressTransactionsPage
+	DefaultAddressPageSize = 1000
+
+	// addressStreamRateLimitRetries is how many times StreamAddressTransactions waits for a rate limited page
+	addressStreamRateLimitRetries = 5
+
+	// addressStreamRateLimitDelay is how long to wait for a rate limited page when the server did not say
+	addressStreamRateLimitDelay = time.Second
+
+	// maxAddressPageLimit caps the limit GetAddressTransactionsPage requests when the transactions are all up to
+	// the cursor
+	maxAddressPageLimit = 100 * DefaultAddressPageSize
+)
+
+// AddressOption is used for the options of address lookups
+type AddressOption func(o *addressOptions)
+
+type addressOptions struct {
+	fromHeight uint32
+	pageSize   uint
+	cursor     string
+	hydrate    bool
+}
+
+// WithFromHeight will only return the transactions from the block height
+func WithFromHeight(height uint32) AddressOption {
+	return func(o *addressOptions) {
+		o.fromHeight = height
+	}
+}
+
+// WithPageSize will set the number of transactions in a page (DefaultAddressPageSize is default),
+// a size of 0 is ignored
+func WithPageSize(size uint) AddressOption {
+	return func(o *addressOptions) {
+		if size > 0 {
+			o.pageSize = size
+		}
+	}
+}
+
+// WithCursor will continue after the page the cursor was returned with, see AddressTransactionsPage
+func WithCursor(cursor string) AddressOption {
+	return func(o *addressOptions) {
+		o.cursor = cursor
+	}
+}
+
+// WithHydrate will also fetch the full transaction of every transaction of StreamAddressTransactions
+func WithHydrate() AddressOption {
+	return func(o *addressOptions) {
+		o.hydrate = true
+	}
+}
+
+// AddressTransactionsPage is a page of the transactions of an address
+type AddressTransactionsPage struct {
+	Transactions []*models.AddressTx // ordered by block height and block index
+	NextCursor   string              // pass to WithCursor for the next page, empty on the last page
+}
+
 // GetAddressTransactions get transaction meta data for the given address
 func (jb *Client) GetAddressTransactions(ctx context.Context, address string) ([]*models.Address, error) {
 	return jb.transport.GetAddressTransactions(ctx, address)
 }
 
+// GetAddressTransactionsPage get a page of the transactions of the address, ordered by block height and block
+// index. Invalid addresses are rejected with ErrInvalidAddress before sending a request.
+//
+// The server only pages by block height, the transactions of the block of the cursor up to the cursor are requested
+// again and skipped. ErrAddressCursorStuck is returned when no transaction after the cursor is returned, even with a
+// larger page.
+func (jb *Client) GetAddressTransactionsPage(ctx context.Context, address string,
+	opts ...AddressOption) (*AddressTransactionsPage, error) {
+
+	if err := ValidateAddress(address); err != nil {
+		return nil, err
+	}
+	options := addressOptions{pageSize: DefaultAddressPageSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	fromHeight := options.fromHeight
+	var afterHeight uint32
+	var afterIndex uint64
+	hasCursor := options.cursor != ""
+	if hasCursor {
+		var err error
+		if afterHeight, afterIndex, err = parseAddressCursor(options.cursor); err != nil {
+			return nil, err
+		}
+		fromHeight = afterHeight
+	}
+
+	// transactions up to the cursor are requested again, the limit grows when a whole page is skipped as long as
+	// the server returns transactions further on and up to maxAddressPageLimit
+	limit := options.pageSize
+	var skippedHeight uint32
+	var skippedIndex uint64
+	for {
+		transactions, err := jb.transport.GetAddressTransactionsFrom(ctx, address, fromHeight, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		page := &AddressTransactionsPage{Transactions: make([]*models.AddressTx, 0, len(transactions))}
+		for _, tx := range transactions {
+			if hasCursor && (tx.BlockHeight < afterHeight || (tx.BlockHeight == afterHeight && tx.BlockIndex <= afterIndex)) {
+				continue
+			}
+			page.Transactions = append(page.Transactions, tx)
+		}
+		full := uint(len(transactions)) >= limit
+		if full && len(page.Transactions) == 0 {
+			last := transactions[len(transactions)-1]
+			if limit >= maxAddressPageLimit || limit > options.pageSize &&
+				!addressAfter(last.BlockHeight, last.BlockIndex, skippedHeight, skippedIndex) {
+				return nil, fmt.Errorf("%w: no transaction after %s in %d transactions from height %d",
+					ErrAddressCursorStuck, options.cursor, len(transactions), fromHeight)
+			}
+			skippedHeight, skippedIndex = last.BlockHeight, last.BlockIndex
+			if limit *= 2; limit > maxAddressPageLimit {
+				limit = maxAddressPageLimit
+			}
+			continue
+		}
+		if full {
+			last := page.Transactions[len(page.Transactions)-1]
+			page.NextCursor = addressCursor(last.BlockHeight, last.BlockIndex)
+		}
+		return page, nil
+	}
+}
+
+// StreamAddressTransactions streams all transactions of the address from the block height, walking the pages
+// of GetAddressTransactionsPage until the last one. Transactions are sent once, ordered by block height and block
+// index. With WithHydrate the full transaction of every entry is fetched with GetTransactions; WithPageSize sets
+// the page size, WithFromHeight and WithCursor are ignored.
+//
+// Both channels are closed when the stream ends. A failure or the cancellation of the context stops the stream and
+// is sent on the error channel before it is closed. Rate limited pages are requested again after the Retry-After
+// of the server.
+func (jb *Client) StreamAddressTransactions(ctx context.Context, address string, fromHeight uint32,
+	opts ...AddressOption) (<-chan *models.AddressTx, <-chan error) {
+
+	options := addressOptions{pageSize: DefaultAddressPageSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	transactions := make(chan *models.AddressTx, options.pageSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(transactions)
+		if err := jb.streamAddressTransactions(ctx, address, fromHeight, options, transactions); err != nil {
+			errs <- err
+		}
+	}()
+
+	return transactions, errs
+}
+
+func (jb *Client) streamAddressTransactions(ctx context.Context, address string, fromHeight uint32,
+	options addressOptions, transactions chan<- *models.AddressTx) error {
+
+	var lastHeight uint32
+	var lastIndex uint64
+	seen := make(map[string]struct{}) // transactions of the last block height, the pages may overlap
+	pageOpts := []AddressOption{WithFromHeight(fromHeight), WithPageSize(options.pageSize)}
+	for {
+		page, err := jb.getAddressTransactionsPageWaiting(ctx, address, pageOpts)
+		if err != nil {
+			return err
+		}
+
+		page.Transactions = dedupeAddressTransactions(page.Transactions, seen, &lastHeight, &lastIndex)
+		if options.hydrate && len(page.Transactions) > 0 {
+			if err = jb.hydrateAddressTransactions(ctx, page.Transactions); err != nil {
+				return err
+			}
+		}
+
+		for _, tx := range page.Transactions {
+			select {
+			case transactions <- tx:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		if err = checkAddressCursor(pageOpts, page.NextCursor); err != nil {
+			return err
+		}
+		pageOpts = []AddressOption{WithPageSize(options.pageSize), WithCursor(page.NextCursor)}
+	}
+}
+
+// getAddressTransactionsPageWaiting gets the page, waiting for the rate limit of the server between attempts
+func (jb *Client) getAddressTransactionsPageWaiting(ctx context.Context, address string,
+	opts []AddressOption) (*AddressTransactionsPage, error) {
+
+	for attempt := 0; ; attempt++ {
+		page, err := jb.GetAddressTransactionsPage(ctx, address, opts...)
+		var rateLimitErr *RateLimitError
+		if err == nil || !errors.As(err, &rateLimitErr) || attempt >= addressStreamRateLimitRetries {
+			return page, err
+		}
+
+		delay := rateLimitErr.RetryAfter
+		if delay <= 0 {
+			delay = addressStreamRateLimitDelay
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dedupeAddressTransactions removes the transactions that were already streamed
+func dedupeAddressTransactions(transactions []*models.AddressTx, seen map[string]struct{},
+	lastHeight *uint32, lastIndex *uint64) []*models.AddressTx {
+
+	unique := transactions[:0]
+	for _, tx := range transactions {
+		if len(seen) > 0 && (tx.BlockHeight < *lastHeight || (tx.BlockHeight == *lastHeight && tx.BlockIndex < *lastIndex)) {
+			continue
+		}
+		if tx.BlockHeight != *lastHeight {
+			for txID := range seen {
+				delete(seen, txID)
+			}
+		}
+		if _, ok := seen[tx.TransactionID]; ok {
+			continue
+		}
+		seen[tx.TransactionID] = struct{}{}
+		*lastHeight, *lastIndex = tx.BlockHeight, tx.BlockIndex
+		unique = append(unique, tx)
+	}
+	return unique
+}
+
+// hydrateAddressTransactions sets the full transaction of the transactions
+func (jb *Client) hydrateAddressTransactions(ctx context.Context, transactions []*models.AddressTx) error {
+	txIDs := make([]string, len(transactions))
+	for i, tx := range transactions {
+		txIDs[i] = tx.TransactionID
+	}
+	hydrated, err := jb.GetTransactions(ctx, txIDs)
+	if err != nil {
+		return err
+	}
+	for i, tx := range transactions {
+		tx.Transaction = hydrated[i]
+	}
+	return nil
+}
+
+// addressAfter returns whether the transaction at the block height and block index comes after the other one
+func addressAfter(height uint32, index uint64, otherHeight uint32, otherIndex uint64) bool {
+	return height > otherHeight || height == otherHeight && index > otherIndex
+}
+
+// checkAddressCursor returns ErrAddressCursorStuck when the next cursor of a page is not after the cursor of the
+// options the page was requested with
+func checkAddressCursor(opts []AddressOption, next string) error {
+	var options addressOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.cursor == "" {
+		return nil
+	}
+	height, index, err := parseAddressCursor(options.cursor)
+	if err != nil {
+		return err
+	}
+	nextHeight, nextIndex, err := parseAddressCursor(next)
+	if err != nil {
+		return err
+	}
+	if !addressAfter(nextHeight, nextIndex, height, index) {
+		return fmt.Errorf("%w: next cursor %s after %s", ErrAddressCursorStuck, next, options.cursor)
+	}
+	return nil
+}
+
+// parseAddressCursor returns the block height and block index of the last transaction of the page
+func parseAddressCursor(cursor string) (uint32, uint64, error) {
+	parts := strings.Split(cursor, ":")
+	if len(parts) == 2 {
+		height, err := strconv.ParseUint(parts[0], 10, 32)
+		if err == nil {
+			var index uint64
+			if index, err = strconv.ParseUint(parts[1], 10, 64); err == nil {
+				return uint32(height), index, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid address cursor %q", cursor)
+}
+
+// GetAddressDetails get the state of the address, like whether its history is complete. ErrNotFound is returned
+// for unknown addresses.
+func (jb *Client) GetAddressDetails(ctx context.Context, address string) (*models.AddressDetails, error) {
+	if err := ValidateAddress(address); err != nil {
+		return nil, err
+	}
+	return jb.transport.GetAddressDetails(ctx, address)
+}
+
 // GetAddressTransactionDetails get full transaction data for the given address
 func (jb *Client) GetAddressTransactionDetails(ctx context.Context, address string) ([]*models.Transaction, error) {
 	return jb.transport.GetAddressTransactionDetails(ctx, address)
@@ -0,0 +1,288 @@
+package junglebus
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultAddressPollInterval is how often SubscribeAddresses looks up new transactions, see WithAddressPollInterval
+const DefaultAddressPollInterval = 10 * time.Second
+
+// AddressSubscription streams the transactions of a set of addresses, see Client.SubscribeAddresses
+type AddressSubscription struct {
+	client       *Client
+	eventHandler EventHandler
+	fromBlock    uint32
+	dedup        *dedupCache
+	wake         chan struct{}
+	done         chan struct{}
+	stopOnce     sync.Once
+
+	mu        sync.Mutex
+	addresses map[string]string // the cursor of every address followed, empty until a transaction was seen
+}
+
+// SubscribeAddresses streams the mined transactions paying to or spending from the addresses from fromBlock to
+// OnTransaction of the event handler, without a subscription in the JungleBus dashboard, until ctx is done or it is
+// unsubscribed. JungleBus has no address channels, the transactions of every address are looked up every
+// WithAddressPollInterval and their full transactions fetched with GetTransactions. The transactions found for all
+// addresses are merged, a transaction of several addresses is passed on once, ordered by block height and block index.
+//
+// Invalid addresses are rejected with ErrInvalidAddress before sending a request and an event handler without
+// OnTransaction returns ErrNoHandlers, the other handlers are not called. Failed lookups are sent to OnError and
+// retried at the next poll. Reorgs are not followed.
+func (jb *Client) SubscribeAddresses(ctx context.Context, addresses []string, fromBlock uint64,
+	eventHandler EventHandler) (*AddressSubscription, error) {
+
+	if eventHandler.OnTransaction == nil {
+		return nil, ErrNoHandlers
+	}
+	for _, address := range addresses {
+		if err := ValidateAddress(address); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &AddressSubscription{
+		client:       jb,
+		eventHandler: eventHandler,
+		fromBlock:    uint32(fromBlock),
+		dedup:        newDedupCache(DefaultDedupSize, 0),
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		addresses:    make(map[string]string, len(addresses)),
+	}
+	for _, address := range addresses {
+		s.addresses[address] = ""
+	}
+	go s.run(ctx)
+	return s, nil
+}
+
+// AddAddress follows the transactions of the address too, from the block of SubscribeAddresses. The transactions are
+// looked up right away, transactions already passed on for other addresses are not passed on again.
+func (s *AddressSubscription) AddAddress(address string) error {
+	if err := ValidateAddress(address); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isStopped() {
+		return ErrNotSubscribed
+	}
+	if _, ok := s.addresses[address]; !ok {
+		s.addresses[address] = ""
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// RemoveAddress stops following the transactions of the address, removing an address that is not followed does
+// nothing. Transactions of the address are not passed on anymore, unless they are of another address followed.
+func (s *AddressSubscription) RemoveAddress(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isStopped() {
+		return ErrNotSubscribed
+	}
+	delete(s.addresses, address)
+	return nil
+}
+
+// Addresses returns the addresses followed
+func (s *AddressSubscription) Addresses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addresses := make([]string, 0, len(s.addresses))
+	for address := range s.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// Unsubscribe stops looking up transactions, ErrNotSubscribed is returned when it was stopped already
+func (s *AddressSubscription) Unsubscribe() error {
+	stopped := false
+	s.stopOnce.Do(func() {
+		close(s.done)
+		stopped = true
+	})
+	if !stopped {
+		return ErrNotSubscribed
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the subscription was stopped
+func (s *AddressSubscription) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *AddressSubscription) isStopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// run polls the addresses until ctx is done or the subscription is stopped
+func (s *AddressSubscription) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+			_ = s.Unsubscribe()
+		}
+	}()
+
+	ticker := time.NewTicker(s.client.addressPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.poll(ctx); err != nil && ctx.Err() == nil {
+			logEvent(s.client.logger, levelWarn, "polling address transactions failed", "error", err)
+			if s.eventHandler.OnError != nil {
+				s.eventHandler.OnError(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll looks up the new transactions of the addresses and passes them on, the cursors are only moved on once the
+// transactions were fetched: an address that failed is looked up again from the same cursor at the next poll
+func (s *AddressSubscription) poll(ctx context.Context) error {
+	s.mu.Lock()
+	cursors := make(map[string]string, len(s.addresses))
+	for address, cursor := range s.addresses {
+		cursors[address] = cursor
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	var found []*models.AddressTx
+	owners := make(map[string][]string) // the addresses of the transactions found
+	for address, cursor := range cursors {
+		transactions, next, err := s.lookup(ctx, address, cursor)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			delete(cursors, address)
+			continue
+		}
+		cursors[address] = next
+		for _, tx := range transactions {
+			if _, ok := owners[tx.TransactionID]; !ok {
+				found = append(found, tx)
+			}
+			owners[tx.TransactionID] = append(owners[tx.TransactionID], address)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].BlockHeight < found[j].BlockHeight ||
+			found[i].BlockHeight == found[j].BlockHeight && found[i].BlockIndex < found[j].BlockIndex
+	})
+	if len(found) > 0 {
+		if err := s.client.hydrateAddressTransactions(ctx, found); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	for address, cursor := range cursors {
+		if _, ok := s.addresses[address]; ok {
+			s.addresses[address] = cursor
+		}
+	}
+	s.mu.Unlock()
+
+	for _, tx := range found {
+		if s.isStopped() {
+			return nil
+		}
+		if !s.follows(owners[tx.TransactionID]) {
+			continue
+		}
+		// passed on for an address before, like one that was removed and added again
+		if s.dedup.seen(channelMain, tx.TransactionID) {
+			continue
+		}
+		s.eventHandler.OnTransaction(&models.TransactionResponse{
+			Id:          tx.TransactionID,
+			BlockHash:   tx.BlockHash,
+			BlockHeight: tx.BlockHeight,
+			BlockIndex:  tx.BlockIndex,
+			BlockTime:   tx.Transaction.BlockTime,
+			Transaction: tx.Transaction.Transaction,
+			Merkle:      tx.Transaction.MerkleProof,
+		})
+	}
+	return firstErr
+}
+
+// follows returns whether one of the addresses is still followed
+func (s *AddressSubscription) follows(addresses []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, address := range addresses {
+		if _, ok := s.addresses[address]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the transactions of the address after the cursor, or from the block of the subscription without
+// one, and the cursor of the last transaction
+func (s *AddressSubscription) lookup(ctx context.Context, address, cursor string) ([]*models.AddressTx, string,
+	error) {
+
+	opts := []AddressOption{WithFromHeight(s.fromBlock)}
+	if cursor != "" {
+		opts = []AddressOption{WithCursor(cursor)}
+	}
+	var transactions []*models.AddressTx
+	for {
+		page, err := s.client.getAddressTransactionsPageWaiting(ctx, address, opts)
+		if err != nil {
+			return nil, cursor, err
+		}
+		transactions = append(transactions, page.Transactions...)
+		if len(page.Transactions) > 0 {
+			last := page.Transactions[len(page.Transactions)-1]
+			cursor = addressCursor(last.BlockHeight, last.BlockIndex)
+		}
+		if page.NextCursor == "" {
+			return transactions, cursor, nil
+		}
+		if err = checkAddressCursor(opts, page.NextCursor); err != nil {
+			return nil, cursor, err
+		}
+		opts = []AddressOption{WithCursor(page.NextCursor)}
+	}
+}
+
+// addressCursor returns the cursor continuing after the transaction at the block height and block index, see
+// parseAddressCursor
+func addressCursor(height uint32, index uint64) string {
+	return strconv.FormatUint(uint64(height), 10) + ":" + strconv.FormatUint(index, 10)
+}
@@ -0,0 +1,113 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient_SubscribeAddresses will test streaming the transactions of a set of addresses
+func TestClient_SubscribeAddresses(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const (
+			otherAddress = "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"
+			thirdAddress = "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+		)
+		server := newFakeServer(t)
+		var mu sync.Mutex
+		history := map[string][]*models.AddressTx{}
+		mine := func(txID string, height uint32, index uint64, addresses ...string) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, address := range addresses {
+				history[address] = append(history[address], &models.AddressTx{
+					TransactionID: txID, BlockHeight: height, BlockIndex: index,
+				})
+			}
+		}
+		server.HandleFunc("/v1/address/get/", func(w http.ResponseWriter, req *http.Request) {
+			from, _ := strconv.ParseUint(req.URL.Query().Get("from_height"), 10, 32)
+			limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+			mu.Lock()
+			records := []*models.AddressTx{}
+			for _, tx := range history[strings.TrimPrefix(req.URL.Path, "/v1/address/get/")] {
+				if tx.BlockHeight >= uint32(from) && len(records) < limit {
+					records = append(records, tx)
+				}
+			}
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(records)
+		})
+		server.HandleFunc("/v1/transaction/get/", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"id":"`+strings.TrimPrefix(req.URL.Path, "/v1/transaction/get/")+`","block_time":1700000000}`)
+		})
+		client := server.newClient(WithAddressPollInterval(20 * time.Millisecond))
+
+		_, err := client.SubscribeAddresses(context.Background(), []string{testAddress, "not-an-address"}, 100,
+			EventHandler{OnTransaction: func(*models.TransactionResponse) {}})
+		assert.ErrorIs(t, err, ErrInvalidAddress)
+		_, err = client.SubscribeAddresses(context.Background(), []string{testAddress}, 100, EventHandler{})
+		assert.ErrorIs(t, err, ErrNoHandlers)
+
+		mine("tx-old", 99, 0, testAddress)
+		mine("tx-1", 100, 0, testAddress)
+		mine("tx-shared", 101, 2, testAddress, otherAddress, thirdAddress)
+		mine("tx-2", 102, 1, otherAddress)
+
+		recorder := &txRecorder{}
+		var blockTime uint32
+		subscription, err := client.SubscribeAddresses(context.Background(), []string{testAddress, otherAddress}, 100,
+			EventHandler{OnTransaction: func(tx *models.TransactionResponse) {
+				mu.Lock()
+				blockTime = tx.BlockTime
+				mu.Unlock()
+				recorder.onTransaction(tx)
+			}})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		assert.Equal(t, []string{testAddress, otherAddress}, subscription.Addresses())
+
+		waitReceived := func(expected ...string) {
+			require.Eventually(t, func() bool {
+				return len(recorder.received()) >= len(expected)
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, expected, recorder.received())
+		}
+		waitReceived("tx-1", "tx-shared", "tx-2")
+		mu.Lock()
+		assert.Equal(t, uint32(1700000000), blockTime)
+		mu.Unlock()
+
+		mine("tx-3", 103, 0, testAddress)
+		waitReceived("tx-1", "tx-shared", "tx-2", "tx-3")
+
+		// the history of an added address is looked up, without the transactions passed on already
+		mine("tx-4", 104, 0, thirdAddress)
+		require.NoError(t, subscription.AddAddress(thirdAddress))
+		waitReceived("tx-1", "tx-shared", "tx-2", "tx-3", "tx-4")
+		assert.ErrorIs(t, subscription.AddAddress("not-an-address"), ErrInvalidAddress)
+
+		require.NoError(t, subscription.RemoveAddress(otherAddress))
+		mine("tx-5", 105, 0, otherAddress)
+		mine("tx-6", 106, 0, testAddress)
+		waitReceived("tx-1", "tx-shared", "tx-2", "tx-3", "tx-4", "tx-6")
+
+		require.NoError(t, subscription.Unsubscribe())
+		<-subscription.Done()
+		assert.ErrorIs(t, subscription.Unsubscribe(), ErrNotSubscribed)
+		assert.ErrorIs(t, subscription.AddAddress(otherAddress), ErrNotSubscribed)
+	})
+}
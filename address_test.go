@@ -0,0 +1,325 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAddress = "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"
+
+// TestValidateAddress will test validating addresses before sending a request
+func TestValidateAddress(t *testing.T) {
+	for _, address := range []string{testAddress, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"} {
+		assert.NoError(t, ValidateAddress(address), address)
+	}
+	for _, address := range []string{"", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", "1A1zP1eP5QGefi2DMPTfTL5SLmv7Divf0a", "1A1zP1eP", "hello"} {
+		assert.ErrorIs(t, ValidateAddress(address), ErrInvalidAddress, address)
+	}
+}
+
+// serveAddressTransactions serves the address endpoint for an address with transactions per block
+// from height 1 up to the tip height
+func serveAddressTransactions(server *fakeServer, perBlock, tip uint32) *int32 {
+	var requests int32
+	server.HandleFunc("/v1/address/get/", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		address := strings.TrimPrefix(req.URL.Path, "/v1/address/get/")
+		from, _ := strconv.ParseUint(req.URL.Query().Get("from_height"), 10, 32)
+		limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+		if from == 0 {
+			from = 1
+		}
+
+		records := []map[string]interface{}{}
+		for height := uint32(from); height <= tip && len(records) < limit; height++ {
+			for index := uint32(0); index < perBlock && len(records) < limit; index++ {
+				records = append(records, map[string]interface{}{
+					"address":        address,
+					"transaction_id": "tx-" + strconv.Itoa(int(height)) + "-" + strconv.Itoa(int(index)),
+					"block_height":   height,
+					"block_index":    index,
+				})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(records)
+	})
+	return &requests
+}
+
+// TestClient_GetAddressTransactionsPage will test paging through the transactions of an address
+func TestClient_GetAddressTransactionsPage(t *testing.T) {
+	t.Run("decode", func(t *testing.T) {
+		response, err := os.ReadFile("testdata/address_transactions.json")
+		require.NoError(t, err)
+		server := newFakeServer(t)
+		server.handleJSON("/v1/address/get/"+testAddress, http.StatusOK, string(response))
+
+		page, err := server.newClient().GetAddressTransactionsPage(context.Background(), testAddress)
+		require.NoError(t, err)
+		assert.Equal(t, []*models.AddressTx{{
+			TransactionID: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+			BlockHash:     "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		}, {
+			TransactionID: "d7b1b6cb6d4a4c1f8e3a5c4d7b2e9f0a1c3e5d7f9b1a3c5e7d9f1b3a5c7e9d1f",
+			BlockHash:     "00000000000000000a4bbe2d7b8e1c4fae5b1c2d3e4f5a6b7c8d9e0f1a2b3c4d",
+			BlockHeight:   625000,
+			BlockIndex:    12,
+		}}, page.Transactions)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		server := newFakeServer(t)
+		serveAddressTransactions(server, 3, 10)
+		client := server.newClient()
+
+		var txIDs []string
+		opts := []AddressOption{WithFromHeight(2), WithPageSize(4)}
+		for {
+			page, err := client.GetAddressTransactionsPage(context.Background(), testAddress, opts...)
+			require.NoError(t, err)
+			for _, tx := range page.Transactions {
+				txIDs = append(txIDs, tx.TransactionID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts = []AddressOption{WithPageSize(4), WithCursor(page.NextCursor)}
+		}
+		require.Len(t, txIDs, 27)
+		assert.Equal(t, "tx-2-0", txIDs[0])
+		assert.Equal(t, "tx-3-0", txIDs[3])
+		assert.Equal(t, "tx-10-2", txIDs[26])
+	})
+
+	t.Run("more transactions in a block than a page", func(t *testing.T) {
+		server := newFakeServer(t)
+		serveAddressTransactions(server, 5, 2)
+		client := server.newClient()
+
+		page, err := client.GetAddressTransactionsPage(context.Background(), testAddress, WithPageSize(2), WithCursor("1:4"))
+		require.NoError(t, err)
+		require.NotEmpty(t, page.Transactions)
+		assert.Equal(t, "tx-2-0", page.Transactions[0].TransactionID)
+	})
+
+	t.Run("stuck cursor", func(t *testing.T) {
+		// a block with more transactions than a page, index is the index of the transactions or 0 for all
+		serveBlock := func(server *fakeServer, index func(i int) uint64) *int32 {
+			var requests int32
+			server.HandleFunc("/v1/address/get/", func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+				records := make([]*models.AddressTx, limit)
+				for i := range records {
+					records[i] = &models.AddressTx{TransactionID: "tx-" + strconv.Itoa(i), BlockHeight: 1, BlockIndex: index(i)}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(records)
+			})
+			return &requests
+		}
+
+		server := newFakeServer(t)
+		requests := serveBlock(server, func(int) uint64 { return 0 })
+		_, err := server.newClient().GetAddressTransactionsPage(context.Background(), testAddress, WithPageSize(2),
+			WithCursor("1:0"))
+		require.ErrorIs(t, err, ErrAddressCursorStuck)
+		assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+
+		server = newFakeServer(t)
+		requests = serveBlock(server, func(i int) uint64 { return uint64(i) })
+		_, err = server.newClient().GetAddressTransactionsPage(context.Background(), testAddress,
+			WithPageSize(maxAddressPageLimit/2), WithCursor("1:"+strconv.Itoa(maxAddressPageLimit)))
+		require.ErrorIs(t, err, ErrAddressCursorStuck)
+		assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+
+		assert.NoError(t, checkAddressCursor([]AddressOption{WithCursor("1:2")}, "1:3"))
+		assert.ErrorIs(t, checkAddressCursor([]AddressOption{WithCursor("1:2")}, "1:2"), ErrAddressCursorStuck)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		server := newFakeServer(t)
+		requests := serveAddressTransactions(server, 1, 1)
+
+		_, err := server.newClient().GetAddressTransactionsPage(context.Background(), "not-an-address")
+		require.ErrorIs(t, err, ErrInvalidAddress)
+		_, err = server.newClient().GetAddressTransactionsPage(context.Background(), testAddress, WithCursor("x"))
+		require.Error(t, err)
+		assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+	})
+}
+
+// TestClient_StreamAddressTransactions will test streaming all transactions of an address
+func TestClient_StreamAddressTransactions(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		collect := func(transactions <-chan *models.AddressTx, errs <-chan error) ([]*models.AddressTx, error) {
+			var all []*models.AddressTx
+			for tx := range transactions {
+				all = append(all, tx)
+			}
+			return all, <-errs
+		}
+
+		t.Run("all pages", func(t *testing.T) {
+			server := newFakeServer(t)
+			serveAddressTransactions(server, 3, 10)
+
+			transactions, err := collect(server.newClient().StreamAddressTransactions(context.Background(), testAddress, 4, WithPageSize(5)))
+			require.NoError(t, err)
+			require.Len(t, transactions, 21)
+			assert.Equal(t, "tx-4-0", transactions[0].TransactionID)
+			assert.Equal(t, "tx-10-2", transactions[20].TransactionID)
+			assert.Nil(t, transactions[0].Transaction)
+			txIDs := make(map[string]struct{})
+			for _, tx := range transactions {
+				txIDs[tx.TransactionID] = struct{}{}
+			}
+			assert.Len(t, txIDs, 21)
+		})
+
+		t.Run("hydrate", func(t *testing.T) {
+			server := newFakeServer(t)
+			serveAddressTransactions(server, 2, 3)
+			server.HandleFunc("/v1/transaction/get/", func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				mustWrite(w, `{"id":"`+strings.TrimPrefix(req.URL.Path, "/v1/transaction/get/")+`"}`)
+			})
+
+			transactions, err := collect(server.newClient().StreamAddressTransactions(context.Background(), testAddress, 0,
+				WithPageSize(4), WithHydrate()))
+			require.NoError(t, err)
+			require.Len(t, transactions, 6)
+			for _, tx := range transactions {
+				require.NotNil(t, tx.Transaction)
+				assert.Equal(t, tx.TransactionID, tx.Transaction.ID)
+			}
+		})
+
+		t.Run("rate limited", func(t *testing.T) {
+			server := newFakeServer(t)
+			var limited int32
+			serveAddressTransactions(server, 1, 3)
+			handler := server.Config.Handler
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if strings.HasPrefix(req.URL.Path, "/v1/address/get/") && atomic.AddInt32(&limited, 1) == 2 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				handler.ServeHTTP(w, req)
+			})
+
+			client := server.newClient(WithRetryPolicy(transports.NoRetryPolicy))
+			transactions, err := collect(client.StreamAddressTransactions(context.Background(), testAddress, 0, WithPageSize(2)))
+			require.NoError(t, err)
+			assert.Len(t, transactions, 3)
+		})
+
+		t.Run("cancelled", func(t *testing.T) {
+			server := newFakeServer(t)
+			serveAddressTransactions(server, 1, 100)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			transactions, errs := server.newClient().StreamAddressTransactions(ctx, testAddress, 0, WithPageSize(1))
+			<-transactions
+			cancel()
+			_, err := collect(transactions, errs)
+			assert.ErrorIs(t, err, context.Canceled)
+		})
+
+		t.Run("invalid address", func(t *testing.T) {
+			server := newFakeServer(t)
+			_, err := collect(server.newClient().StreamAddressTransactions(context.Background(), "not-an-address", 0))
+			assert.ErrorIs(t, err, ErrInvalidAddress)
+		})
+	})
+}
+
+// TestDedupeAddressTransactions will test removing the transactions of overlapping pages
+func TestDedupeAddressTransactions(t *testing.T) {
+	seen := make(map[string]struct{})
+	var lastHeight uint32
+	var lastIndex uint64
+
+	first := dedupeAddressTransactions([]*models.AddressTx{
+		{TransactionID: "a", BlockHeight: 1, BlockIndex: 0},
+		{TransactionID: "b", BlockHeight: 2, BlockIndex: 1},
+		{TransactionID: "b", BlockHeight: 2, BlockIndex: 1},
+	}, seen, &lastHeight, &lastIndex)
+	second := dedupeAddressTransactions([]*models.AddressTx{
+		{TransactionID: "a", BlockHeight: 1, BlockIndex: 0},
+		{TransactionID: "b", BlockHeight: 2, BlockIndex: 1},
+		{TransactionID: "c", BlockHeight: 2, BlockIndex: 5},
+		{TransactionID: "d", BlockHeight: 3, BlockIndex: 0},
+	}, seen, &lastHeight, &lastIndex)
+
+	ids := func(transactions []*models.AddressTx) (txIDs []string) {
+		for _, tx := range transactions {
+			txIDs = append(txIDs, tx.TransactionID)
+		}
+		return txIDs
+	}
+	assert.Equal(t, []string{"a", "b"}, ids(first))
+	assert.Equal(t, []string{"c", "d"}, ids(second))
+}
+
+// TestClient_GetAddressDetails will test getting the state of an address
+func TestClient_GetAddressDetails(t *testing.T) {
+	server := newFakeServer(t)
+	for _, name := range []string{"crawled", "crawling"} {
+		response, err := os.ReadFile("testdata/address_details_" + name + ".json")
+		require.NoError(t, err)
+		var details map[string]interface{}
+		require.NoError(t, json.Unmarshal(response, &details))
+		server.handleJSON("/v1/address/details/"+details["address"].(string), http.StatusOK, string(response))
+	}
+	server.handleJSON("/v1/address/details/mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", http.StatusNotFound, `{"error":"not found"}`)
+	client := server.newClient()
+
+	t.Run("crawled", func(t *testing.T) {
+		details, err := client.GetAddressDetails(context.Background(), testAddress)
+		require.NoError(t, err)
+		assert.Equal(t, &models.AddressDetails{
+			Address:          testAddress,
+			FirstSeenTime:    1231006505,
+			TransactionCount: 4123,
+			Crawled:          true,
+			CrawledHeight:    812345,
+		}, details)
+	})
+
+	t.Run("crawling", func(t *testing.T) {
+		details, err := client.GetAddressDetails(context.Background(), "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy")
+		require.NoError(t, err)
+		assert.Equal(t, &models.AddressDetails{
+			Address:          "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy",
+			FirstSeenHeight:  640113,
+			FirstSeenTime:    1592819412,
+			TransactionCount: 87,
+			CrawledHeight:    700000,
+		}, details)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.GetAddressDetails(context.Background(), "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		_, err := client.GetAddressDetails(context.Background(), "not-an-address")
+		assert.ErrorIs(t, err, ErrInvalidAddress)
+	})
+}
@@ -0,0 +1,59 @@
+package junglebus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// addressVersions are the version bytes of P2PKH and P2SH addresses on mainnet and testnet
+var addressVersions = map[byte]bool{0x00: true, 0x05: true, 0x6f: true, 0xc4: true}
+
+// ValidateAddress returns an error wrapping ErrInvalidAddress when the address is not a valid
+// base58check encoded P2PKH or P2SH address
+func ValidateAddress(address string) error {
+	_, err := decodeAddress(address)
+	return err
+}
+
+// decodeAddress returns the version byte, hash and checksum of a base58check encoded P2PKH or P2SH address
+func decodeAddress(address string) ([]byte, error) {
+	if address == "" {
+		return nil, fmt.Errorf("%w: address is empty", ErrInvalidAddress)
+	}
+
+	n := new(big.Int)
+	base := big.NewInt(58)
+	for _, r := range address {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q is not a base58 character", ErrInvalidAddress, r)
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	decoded := n.Bytes()
+	for _, r := range address {
+		if r != '1' {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+
+	if len(decoded) != 25 {
+		return nil, fmt.Errorf("%w: decoded length is %d, expected 25", ErrInvalidAddress, len(decoded))
+	}
+	first := sha256.Sum256(decoded[:21])
+	checksum := sha256.Sum256(first[:])
+	if !bytes.Equal(checksum[:4], decoded[21:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidAddress)
+	}
+	if !addressVersions[decoded[0]] {
+		return nil, fmt.Errorf("%w: unknown version 0x%02x", ErrInvalidAddress, decoded[0])
+	}
+	return decoded, nil
+}
@@ -0,0 +1,45 @@
+package junglebus
+
+import (
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultMaxBatchSize is the default maximum number of transactions passed to OnBlock at once
+const DefaultMaxBatchSize = 10000
+
+// addToBatch collects a mined transaction for OnBlock, passing on the batch once it reached the max batch size
+func (s *Subscription) addToBatch(tx *models.TransactionResponse) {
+	s.batchMu.Lock()
+	if len(s.batch) > 0 && s.batch[0].BlockHeight != tx.BlockHeight {
+		// the block done message of the previous block was missed
+		s.flushBatchLocked()
+	}
+	s.batch = append(s.batch, tx)
+	if len(s.batch) >= s.maxBatchSize {
+		s.flushBatchLocked()
+	}
+	s.batchMu.Unlock()
+}
+
+// flushBatch passes the collected transactions to OnBlock
+func (s *Subscription) flushBatch() {
+	s.batchMu.Lock()
+	s.flushBatchLocked()
+	s.batchMu.Unlock()
+}
+
+func (s *Subscription) flushBatchLocked() {
+	if len(s.batch) == 0 {
+		return
+	}
+	batch := s.batch
+	s.batch = nil
+	s.EventHandler.OnBlock(batch[0].BlockHeight, batch)
+}
+
+// discardBatch drops the collected transactions of a block that is rolled back
+func (s *Subscription) discardBatch() {
+	s.batchMu.Lock()
+	s.batch = nil
+	s.batchMu.Unlock()
+}
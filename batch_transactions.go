@@ -0,0 +1,80 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultBatchConcurrency is the default number of transactions GetTransactions fetches at the same time
+const DefaultBatchConcurrency = 8
+
+// BatchOption is used for the options of batch requests
+type BatchOption func(b *batchOptions)
+
+type batchOptions struct {
+	concurrency int
+}
+
+// WithBatchConcurrency will set how many requests a batch sends at the same time (DefaultBatchConcurrency
+// is default), sizes less than 1 are ignored
+func WithBatchConcurrency(n int) BatchOption {
+	return func(b *batchOptions) {
+		if n > 0 {
+			b.concurrency = n
+		}
+	}
+}
+
+// GetTransactions get the transactions by their txids from JungleBus, fetching them with a bounded number of
+// requests at the same time. The results are in the order of the txids.
+//
+// Transactions that could not be fetched are nil in the results and reported in a *BatchError, the other
+// transactions are still returned. Once the context is cancelled no new requests are sent, the remaining
+// transactions fail with the error of the context. The requests pass through the rate limiter of the client.
+func (jb *Client) GetTransactions(ctx context.Context, txIDs []string, opts ...BatchOption) ([]*models.Transaction, error) {
+	options := batchOptions{concurrency: DefaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	transactions := make([]*models.Transaction, len(txIDs))
+	errs := make([]error, len(txIDs))
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, options.concurrency)
+	for i, txID := range txIDs {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(txIDs); j++ {
+				errs[j] = err
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, txID string) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			transactions[i], errs[i] = jb.transport.GetTransaction(ctx, txID)
+		}(i, txID)
+	}
+	wg.Wait()
+
+	batchErr := &BatchError{}
+	for i, err := range errs {
+		if err != nil {
+			batchErr.Errors = append(batchErr.Errors, &BatchItemError{Index: i, TxID: txIDs[i], Err: err})
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return transactions, batchErr
+	}
+	return transactions, nil
+}
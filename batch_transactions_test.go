@@ -0,0 +1,95 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient_GetTransactions will test fetching a batch of transactions
+func TestClient_GetTransactions(t *testing.T) {
+	var running, maxRunning int32
+	server := newFakeServer(t)
+	server.HandleFunc("/v1/transaction/get/", func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		id := strings.TrimPrefix(req.URL.Path, "/v1/transaction/get/")
+		if strings.HasPrefix(id, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"id":"`+id+`"}`)
+	})
+	client := server.newClient(WithRetryPolicy(transports.NoRetryPolicy))
+
+	t.Run("in order with bounded concurrency", func(t *testing.T) {
+		txIDs := make([]string, 40)
+		for i := range txIDs {
+			txIDs[i] = "tx-" + strconv.Itoa(i)
+		}
+
+		transactions, err := client.GetTransactions(context.Background(), txIDs, WithBatchConcurrency(4))
+		require.NoError(t, err)
+		require.Len(t, transactions, len(txIDs))
+		for i, transaction := range transactions {
+			assert.Equal(t, txIDs[i], transaction.ID)
+		}
+		assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(4))
+		assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1))
+	})
+
+	t.Run("partial results", func(t *testing.T) {
+		transactions, err := client.GetTransactions(context.Background(), []string{"tx-0", "missing-1", "tx-2", "missing-3"})
+		var batchErr *BatchError
+		require.True(t, errors.As(err, &batchErr))
+		require.Len(t, batchErr.Errors, 2)
+		assert.Equal(t, 1, batchErr.Errors[0].Index)
+		assert.Equal(t, "missing-3", batchErr.Errors[1].TxID)
+		assert.ErrorIs(t, batchErr.Errors[0], ErrNotFound)
+		// the matching of errors.Is and errors.As before Go 1.20
+		assert.True(t, batchErr.Is(ErrNotFound))
+		assert.False(t, batchErr.Is(context.Canceled))
+		var itemErr *BatchItemError
+		require.True(t, batchErr.As(&itemErr))
+		assert.Equal(t, "missing-1", itemErr.TxID)
+
+		assert.Equal(t, "tx-0", transactions[0].ID)
+		assert.Nil(t, transactions[1])
+		assert.Equal(t, "tx-2", transactions[2].ID)
+		assert.Nil(t, transactions[3])
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		txIDs := make([]string, 100)
+		for i := range txIDs {
+			txIDs[i] = "tx-" + strconv.Itoa(i)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		transactions, err := client.GetTransactions(ctx, txIDs, WithBatchConcurrency(1))
+		var batchErr *BatchError
+		require.True(t, errors.As(err, &batchErr))
+		assert.NotNil(t, transactions[0])
+		assert.Nil(t, transactions[len(txIDs)-1])
+		assert.ErrorIs(t, batchErr.Errors[len(batchErr.Errors)-1], context.DeadlineExceeded)
+	})
+}
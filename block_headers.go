@@ -0,0 +1,209 @@
+package junglebus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultHeaderPollInterval is how often SubscribeBlockHeaders checks the chain tip, see WithHeaderPollInterval
+const DefaultHeaderPollInterval = 10 * time.Second
+
+// headerHistory is the number of recent headers kept to find where a reorg forked off, and of stale blocks
+const headerHistory = 100
+
+// SubscribeBlockHeaders keeps the best block header of the client in sync with JungleBus until ctx is done, see
+// CurrentTip and Confirmations. The handler is called with the current tip, and then with every new header of the
+// best chain in order. JungleBus has no block header channel, the chain tip is polled every
+// WithHeaderPollInterval and only the new headers are fetched.
+//
+// On a reorg the handler is called with the headers of the competing chain from where it forked off, the headers
+// they replace are marked stale, see IsStaleBlock. The handler may be nil to only keep the tip of the client.
+// An error is returned when the current tip could not be fetched, later failures are logged and retried.
+func (jb *Client) SubscribeBlockHeaders(ctx context.Context, handler func(header *models.BlockHeader)) error {
+	follower := &headerFollower{client: jb, recent: make(map[uint32]*models.BlockHeader)}
+	if err := follower.poll(ctx, handler); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(jb.headerPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := follower.poll(ctx, handler); err != nil && ctx.Err() == nil {
+				logEvent(jb.logger, levelWarn, "polling block headers failed", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// CurrentTip returns the best block header kept by SubscribeBlockHeaders, nil before it was fetched
+func (jb *Client) CurrentTip() *models.BlockHeader {
+	jb.headersMu.Lock()
+	defer jb.headersMu.Unlock()
+	if jb.currentTip == nil {
+		return nil
+	}
+	tip := *jb.currentTip
+	return &tip
+}
+
+// Confirmations returns the number of confirmations of a block at the height by the tip of CurrentTip, 1 for the
+// tip itself. It returns 0 without a tip or for a height above it.
+func (jb *Client) Confirmations(blockHeight uint32) uint32 {
+	tip := jb.CurrentTip()
+	if tip == nil || blockHeight > tip.Height {
+		return 0
+	}
+	return tip.Height - blockHeight + 1
+}
+
+// IsStaleBlock returns whether SubscribeBlockHeaders saw the block with the hash replaced by a reorg, the last
+// blocks that went stale are remembered
+func (jb *Client) IsStaleBlock(hash string) bool {
+	jb.headersMu.Lock()
+	defer jb.headersMu.Unlock()
+	for _, stale := range jb.staleBlocks {
+		if stale == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// setTip makes the header the best block header of the client, marking the replaced headers stale
+func (jb *Client) setTip(tip *models.BlockHeader, stale []*models.BlockHeader) {
+	jb.headersMu.Lock()
+	defer jb.headersMu.Unlock()
+	for _, header := range stale {
+		jb.staleBlocks = append(jb.staleBlocks, header.Hash)
+	}
+	if len(jb.staleBlocks) > headerHistory {
+		jb.staleBlocks = append(jb.staleBlocks[:0], jb.staleBlocks[len(jb.staleBlocks)-headerHistory:]...)
+	}
+	jb.currentTip = tip
+}
+
+// headerFollower follows the best chain for SubscribeBlockHeaders
+type headerFollower struct {
+	client *Client
+	last   *models.BlockHeader
+	recent map[uint32]*models.BlockHeader // the recent headers of the best chain by height
+}
+
+// poll fetches the chain tip and passes the headers up to it that were not seen yet on to the handler
+func (f *headerFollower) poll(ctx context.Context, handler func(header *models.BlockHeader)) error {
+	tip, err := f.client.getChainTip(ctx)
+	if err != nil {
+		return err
+	}
+	if f.last != nil && f.last.Hash == tip.Hash {
+		return nil
+	}
+
+	headers := []*models.BlockHeader{tip}
+	var stale []*models.BlockHeader
+	if f.last != nil {
+		fork, err := f.fork(ctx, tip)
+		if err != nil {
+			return err
+		}
+		if headers, err = f.headers(ctx, fork+1, tip); err != nil {
+			return err
+		}
+		for height, header := range f.recent {
+			if height > fork {
+				stale = append(stale, header)
+				delete(f.recent, height)
+			}
+		}
+	}
+	if len(headers) == 0 {
+		// the chain of the tip is shorter than the one seen, its tip was seen already
+		headers = []*models.BlockHeader{tip}
+	}
+
+	for _, header := range headers {
+		f.last = header
+		f.recent[header.Height] = header
+		delete(f.recent, header.Height-headerHistory)
+	}
+	f.client.setTip(f.last, stale)
+	if handler != nil {
+		for _, header := range headers {
+			handler(header)
+		}
+	}
+	return nil
+}
+
+// fork returns the height of the last header seen that is part of the chain of the tip
+func (f *headerFollower) fork(ctx context.Context, tip *models.BlockHeader) (uint32, error) {
+	height := f.last.Height
+	if tip.Height < height {
+		height = tip.Height
+	}
+	for height > 0 {
+		known, ok := f.recent[height]
+		if !ok {
+			// forked off before the recent headers, the headers after them are fetched again
+			return height, nil
+		}
+		header, err := f.client.GetBlockHeaderByHeight(ctx, height)
+		if err != nil {
+			return 0, err
+		}
+		if header.Hash == known.Hash {
+			return height, nil
+		}
+		height--
+	}
+	return 0, nil
+}
+
+// headers fetches the headers from the height up to the tip, every header must follow the previous one
+func (f *headerFollower) headers(ctx context.Context, height uint32, tip *models.BlockHeader) ([]*models.BlockHeader,
+	error) {
+
+	var headers []*models.BlockHeader
+	previous := f.recent[height-1]
+	for height <= tip.Height {
+		limit := uint(tip.Height-height) + 1
+		if limit > DefaultSyncPageSize {
+			limit = DefaultSyncPageSize
+		}
+		page, err := f.client.GetBlockHeadersByHeight(ctx, height, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return nil, fmt.Errorf("%w: no block headers from height %d, chain tip is %d", ErrBlockHeaderGap, height,
+				tip.Height)
+		}
+		for _, header := range page {
+			if header.Height > tip.Height {
+				break
+			}
+			if header.Height != height {
+				return nil, fmt.Errorf("%w: expected height %d, got %d", ErrBlockHeaderGap, height, header.Height)
+			}
+			if previous != nil {
+				if err = checkHeaderLink(header, previous); err != nil {
+					return nil, err
+				}
+			}
+			headers = append(headers, header)
+			previous = header
+			height++
+		}
+	}
+	return headers, nil
+}
@@ -0,0 +1,140 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChain is a chain of block headers served by the fake server, it can be extended and reorged while served
+type testChain struct {
+	mu      sync.Mutex
+	headers []*models.BlockHeader // by height
+}
+
+// extend adds blocks to the chain
+func (c *testChain) extend(blocks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extendLocked(blocks, "")
+}
+
+// reorg replaces the blocks from the height with competing ones, up to blocks more
+func (c *testChain) reorg(height uint32, blocks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = c.headers[:height]
+	c.extendLocked(blocks, "-competing")
+}
+
+func (c *testChain) extendLocked(blocks int, suffix string) {
+	for i := 0; i < blocks; i++ {
+		height := uint32(len(c.headers))
+		header := &models.BlockHeader{Hash: "hash-" + strconv.Itoa(int(height)) + suffix, Height: height}
+		if height > 0 {
+			header.PrevHash = c.headers[height-1].Hash
+		}
+		c.headers = append(c.headers, header)
+	}
+}
+
+func (c *testChain) serve(server *fakeServer) {
+	respond := func(w http.ResponseWriter, response interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}
+	server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, _ *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		respond(w, c.headers[len(c.headers)-1])
+	})
+	server.HandleFunc("/v1/block_header/get/", func(w http.ResponseWriter, req *http.Request) {
+		height, _ := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/v1/block_header/get/"))
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		respond(w, c.headers[height])
+	})
+	server.HandleFunc("/v1/block_header/list/", func(w http.ResponseWriter, req *http.Request) {
+		from, _ := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/v1/block_header/list/"))
+		limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		headers := []*models.BlockHeader{}
+		for height := from; height < from+limit && height < len(c.headers); height++ {
+			headers = append(headers, c.headers[height])
+		}
+		respond(w, headers)
+	})
+}
+
+// TestClient_SubscribeBlockHeaders will test keeping the tip of the client in sync with the best chain
+func TestClient_SubscribeBlockHeaders(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		chain := &testChain{}
+		chain.extend(101)
+		chain.serve(server)
+		client := server.newClient(WithHeaderPollInterval(5 * time.Millisecond))
+		assert.Nil(t, client.CurrentTip())
+		assert.Zero(t, client.Confirmations(100))
+
+		var mu sync.Mutex
+		var received []string
+		hashes := func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), received...)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, client.SubscribeBlockHeaders(ctx, func(header *models.BlockHeader) {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, header.Hash)
+		}))
+		assert.Equal(t, []string{"hash-100"}, hashes())
+		assert.Equal(t, uint32(100), client.CurrentTip().Height)
+		assert.Equal(t, uint32(3), client.Confirmations(98))
+		assert.Zero(t, client.Confirmations(101))
+
+		t.Run("new blocks", func(t *testing.T) {
+			chain.extend(3)
+			require.Eventually(t, func() bool {
+				return len(hashes()) == 4
+			}, 5*time.Second, 5*time.Millisecond)
+			assert.Equal(t, []string{"hash-100", "hash-101", "hash-102", "hash-103"}, hashes())
+			assert.Equal(t, "hash-103", client.CurrentTip().Hash)
+		})
+
+		t.Run("reorg", func(t *testing.T) {
+			chain.reorg(102, 3)
+			require.Eventually(t, func() bool {
+				return len(hashes()) == 7
+			}, 5*time.Second, 5*time.Millisecond)
+			assert.Equal(t, []string{"hash-102-competing", "hash-103-competing", "hash-104-competing"}, hashes()[4:])
+			assert.Equal(t, "hash-104-competing", client.CurrentTip().Hash)
+			assert.True(t, client.IsStaleBlock("hash-102"))
+			assert.True(t, client.IsStaleBlock("hash-103"))
+			assert.False(t, client.IsStaleBlock("hash-101"))
+			assert.Equal(t, uint32(4), client.Confirmations(101))
+		})
+
+		t.Run("chain tip failing", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusUnauthorized, `{}`)
+			client := server.newClient()
+			err := client.SubscribeBlockHeaders(context.Background(), nil)
+			require.ErrorIs(t, err, ErrUnauthorized)
+			assert.Nil(t, client.CurrentTip())
+		})
+	})
+}
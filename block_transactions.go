@@ -0,0 +1,69 @@
+package junglebus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// GetBlockTransactions gets the transactions of the block at the height that the subscription matches, the same
+// transactions the subscription delivers for the block. The pages of the block are fetched one by one while the
+// transactions are read from the channel, in the order of the block.
+//
+// Both channels are closed when all transactions were sent, a page without result, like a null response, is the
+// last one. A failure or the cancellation of the context stops fetching the pages and is sent on the error channel
+// before it is closed.
+func (jb *Client) GetBlockTransactions(ctx context.Context, subscriptionID string,
+	height uint32) (<-chan *models.TransactionResponse, <-chan error) {
+
+	transactions := make(chan *models.TransactionResponse, DefaultSyncPageSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(transactions)
+		if err := jb.getBlockTransactions(ctx, subscriptionID, height, transactions); err != nil {
+			errs <- err
+		}
+	}()
+
+	return transactions, errs
+}
+
+func (jb *Client) getBlockTransactions(ctx context.Context, subscriptionID string, height uint32,
+	transactions chan<- *models.TransactionResponse) error {
+
+	var page uint64
+	for {
+		result, err := jb.transport.GetBlockTransactions(ctx, subscriptionID, height, page)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return nil
+		}
+
+		for _, transaction := range result.Transactions {
+			if transaction == nil {
+				continue
+			}
+			if err = jb.inflate(transaction); err != nil {
+				return err
+			}
+			select {
+			case transactions <- transaction:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if result.NextPage == 0 {
+			return nil
+		}
+		if result.NextPage <= page {
+			return fmt.Errorf("next page %d of block %d does not follow page %d", result.NextPage, height, page)
+		}
+		page = result.NextPage
+	}
+}
@@ -0,0 +1,114 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient_GetBlockTransactions will test getting the transactions of a subscription in a block
+func TestClient_GetBlockTransactions(t *testing.T) {
+	server := newFakeServer(t)
+	server.HandleFunc("/v1/subscription/", func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/v1/subscription/"), "/")
+		if len(parts) != 4 || parts[0] != testSubscriptionID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		height, _ := strconv.ParseUint(parts[2], 10, 32)
+		page, _ := strconv.ParseUint(parts[3], 10, 64)
+
+		// three pages of two transactions
+		result := &models.BlockTransactionsPage{}
+		for i := page * 2; i < page*2+2; i++ {
+			result.Transactions = append(result.Transactions, &models.TransactionResponse{
+				Id:          "tx-" + strconv.FormatUint(i, 10),
+				BlockHeight: uint32(height),
+				BlockIndex:  i,
+				Transaction: []byte{byte(i)},
+			})
+		}
+		if page < 2 {
+			result.NextPage = page + 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+	client := server.newClient()
+
+	t.Run("all pages", func(t *testing.T) {
+		var transactions []*models.TransactionResponse
+		txs, errs := client.GetBlockTransactions(context.Background(), testSubscriptionID, 800000)
+		for tx := range txs {
+			transactions = append(transactions, tx)
+		}
+		require.NoError(t, <-errs)
+		require.Len(t, transactions, 6)
+		for i, tx := range transactions {
+			assert.Equal(t, "tx-"+strconv.Itoa(i), tx.Id)
+			assert.Equal(t, uint32(800000), tx.BlockHeight)
+			assert.Equal(t, uint64(i), tx.BlockIndex)
+			assert.Equal(t, []byte{byte(i)}, tx.Transaction)
+		}
+	})
+
+	t.Run("unknown subscription", func(t *testing.T) {
+		txs, errs := client.GetBlockTransactions(context.Background(), "unknown", 800000)
+		for range txs {
+			t.Fatal("unexpected transaction")
+		}
+		assert.ErrorIs(t, <-errs, ErrNotFound)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/subscription/"+testSubscriptionID+"/block/800000/0", http.StatusOK, `null`)
+		server.handleJSON("/v1/subscription/"+testSubscriptionID+"/block/800001/0", http.StatusOK,
+			`{"transactions":[null,{"id":"tx-0"}]}`)
+		client := server.newClient()
+
+		txs, errs := client.GetBlockTransactions(context.Background(), testSubscriptionID, 800000)
+		for range txs {
+			t.Fatal("unexpected transaction")
+		}
+		assert.NoError(t, <-errs)
+
+		var transactions []string
+		txs, errs = client.GetBlockTransactions(context.Background(), testSubscriptionID, 800001)
+		for tx := range txs {
+			transactions = append(transactions, tx.Id)
+		}
+		assert.NoError(t, <-errs)
+		assert.Equal(t, []string{"tx-0"}, transactions)
+
+		// transports other than the HTTP one may return no page at all
+		client, err := New(WithTransport(&transports.Mock{
+			GetBlockTransactionsFunc: func(context.Context, string, uint32, uint64) (*models.BlockTransactionsPage, error) {
+				return nil, nil
+			},
+		}))
+		require.NoError(t, err)
+		txs, errs = client.GetBlockTransactions(context.Background(), testSubscriptionID, 800000)
+		for range txs {
+			t.Fatal("unexpected transaction")
+		}
+		assert.NoError(t, <-errs)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		txs, errs := client.GetBlockTransactions(ctx, testSubscriptionID, 800000)
+		for range txs {
+		}
+		assert.ErrorIs(t, <-errs, context.Canceled)
+	})
+}
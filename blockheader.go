@@ -2,16 +2,240 @@ package junglebus
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/GorillaPool/go-junglebus/models"
 )
 
-// GetBlockHeader get a block header from JungleBus
+// DefaultSyncPageSize is the number of block headers SyncBlockHeaders requests at once
+const DefaultSyncPageSize = 1000
+
+// GetBlockHeader get a block header from JungleBus by the block hash or the block height (as a string)
+// ErrNotFound is returned for unknown blocks
 func (jb *Client) GetBlockHeader(ctx context.Context, block string) (*models.BlockHeader, error) {
 	return jb.transport.GetBlockHeader(ctx, block)
 }
 
-// GetBlockHeaders get a list of block headers from JungleBus
+// GetBlockHeaderByHeight get the block header at the height from JungleBus, ErrNotFound is returned for unknown blocks
+func (jb *Client) GetBlockHeaderByHeight(ctx context.Context, height uint32) (*models.BlockHeader, error) {
+	return jb.transport.GetBlockHeader(ctx, strconv.FormatUint(uint64(height), 10))
+}
+
+// GetChainTip get the block header of the current best block from JungleBus, ErrChainTipNotFound is returned
+// when the server did not return one. The chain tip is cached when WithChainTipCache is set, concurrent callers then
+// share a single request and each waits for it until its own context is done.
+func (jb *Client) GetChainTip(ctx context.Context) (*models.BlockHeader, error) {
+	if jb.chainTipTTL <= 0 {
+		return jb.getChainTip(ctx)
+	}
+
+	jb.chainTipMu.Lock()
+	if jb.chainTip != nil && time.Since(jb.chainTipAt) < jb.chainTipTTL {
+		tip := *jb.chainTip
+		jb.chainTipMu.Unlock()
+		return &tip, nil
+	}
+	// concurrent callers wait for the request of the first one instead of sending their own
+	refresh := jb.chainTipRefresh
+	if refresh == nil {
+		refresh = jb.refreshChainTip()
+	}
+	refresh.waiters++
+	jb.chainTipMu.Unlock()
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		jb.leaveChainTipRefresh(refresh)
+		return nil, ctx.Err()
+	}
+	if refresh.err != nil {
+		return nil, refresh.err
+	}
+	tip := *refresh.tip
+	return &tip, nil
+}
+
+// chainTipRefresh is a request of the chain tip for the cache, done is closed once tip or err is set
+type chainTipRefresh struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // the callers of GetChainTip waiting for the request, it is cancelled when none are left
+	tip     *models.BlockHeader
+	err     error
+}
+
+// refreshChainTip starts a request of the chain tip caching the result, it is not bound to the context of a caller
+// but cancelled once all callers stopped waiting. Must be called with chainTipMu held.
+func (jb *Client) refreshChainTip() *chainTipRefresh {
+	ctx, cancel := context.WithCancel(context.Background())
+	refresh := &chainTipRefresh{done: make(chan struct{}), cancel: cancel}
+	jb.chainTipRefresh = refresh
+	go func() {
+		defer cancel()
+		tip, err := jb.getChainTip(ctx)
+
+		jb.chainTipMu.Lock()
+		defer jb.chainTipMu.Unlock()
+		// a request all callers left is not cached, a newer one may have replaced it
+		if jb.chainTipRefresh == refresh {
+			jb.chainTipRefresh = nil
+			if err == nil {
+				jb.chainTip, jb.chainTipAt = tip, time.Now()
+			}
+		}
+		refresh.tip, refresh.err = tip, err
+		close(refresh.done)
+	}()
+	return refresh
+}
+
+// leaveChainTipRefresh stops waiting for the request, cancelling it when no other caller waits for it
+func (jb *Client) leaveChainTipRefresh(refresh *chainTipRefresh) {
+	jb.chainTipMu.Lock()
+	defer jb.chainTipMu.Unlock()
+	refresh.waiters--
+	if refresh.waiters == 0 && jb.chainTipRefresh == refresh {
+		jb.chainTipRefresh = nil
+		refresh.cancel()
+	}
+}
+
+func (jb *Client) getChainTip(ctx context.Context) (*models.BlockHeader, error) {
+	tip, err := jb.transport.GetChainTip(ctx)
+	if err != nil {
+		return nil, err
+	} else if tip == nil {
+		return nil, ErrChainTipNotFound
+	}
+	return tip, nil
+}
+
+// GetBlockHeaders get a list of block headers from JungleBus, starting at the block hash or the block
+// height (as a string)
 func (jb *Client) GetBlockHeaders(ctx context.Context, block string, limit uint) ([]*models.BlockHeader, error) {
 	return jb.transport.GetBlockHeaders(ctx, block, limit)
 }
+
+// GetBlockHeadersByHeight get up to limit consecutive block headers from JungleBus, starting at the height
+func (jb *Client) GetBlockHeadersByHeight(ctx context.Context, fromHeight uint32, limit uint) ([]*models.BlockHeader, error) {
+	return jb.transport.GetBlockHeaders(ctx, strconv.FormatUint(uint64(fromHeight), 10), limit)
+}
+
+// SyncBlockHeaders sends all block headers from the height up to the current chain tip on the returned
+// channel, fetching them a page of DefaultSyncPageSize headers at a time. Every header must follow the
+// previous one, otherwise an error wrapping ErrBlockHeaderGap is sent on the error channel. Headers returned
+// without the hash of the previous block are checked by hashing them with the hash of the previous header.
+//
+// Both channels are closed when the sync stopped, after at most one error. The sync stops when the context
+// is cancelled, the headers should be received until the channel is closed.
+func (jb *Client) SyncBlockHeaders(ctx context.Context, fromHeight uint32) (<-chan *models.BlockHeader, <-chan error) {
+	headers := make(chan *models.BlockHeader, DefaultSyncPageSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(headers)
+		if err := jb.syncBlockHeaders(ctx, fromHeight, headers); err != nil {
+			errs <- err
+		}
+	}()
+
+	return headers, errs
+}
+
+func (jb *Client) syncBlockHeaders(ctx context.Context, height uint32, headers chan<- *models.BlockHeader) error {
+	tip, err := jb.getChainTip(ctx)
+	if err != nil {
+		return err
+	}
+
+	var previous *models.BlockHeader
+	for height <= tip.Height {
+		page, err := jb.GetBlockHeadersByHeight(ctx, height, DefaultSyncPageSize)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return fmt.Errorf("%w: no block headers from height %d, chain tip is %d", ErrBlockHeaderGap, height, tip.Height)
+		}
+
+		for _, header := range page {
+			if header.Height > tip.Height {
+				return nil
+			}
+			if header.Height != height {
+				return fmt.Errorf("%w: expected height %d, got %d", ErrBlockHeaderGap, height, header.Height)
+			}
+			if previous != nil {
+				if err = checkHeaderLink(header, previous); err != nil {
+					return err
+				}
+			}
+
+			select {
+			case headers <- header:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			previous = header
+			height++
+		}
+	}
+	return nil
+}
+
+// checkHeaderLink returns an error wrapping ErrBlockHeaderGap when the header does not follow the previous one. When
+// the server did not return the hash of the previous block, the header is hashed with the hash of the previous one
+// instead, a header that can not be hashed fails.
+func checkHeaderLink(header, previous *models.BlockHeader) error {
+	if header.PrevHash != "" {
+		if header.PrevHash != previous.Hash {
+			return fmt.Errorf("%w: block %d does not follow block %s", ErrBlockHeaderGap, header.Height, previous.Hash)
+		}
+		return nil
+	}
+	hash, err := blockHeaderHash(header, previous.Hash)
+	if err != nil {
+		return fmt.Errorf("%w: block %d has no previous hash: %v", ErrBlockHeaderGap, header.Height, err)
+	}
+	if hash != header.Hash {
+		return fmt.Errorf("%w: block %d does not follow block %s", ErrBlockHeaderGap, header.Height, previous.Hash)
+	}
+	return nil
+}
+
+// blockHeaderHash returns the hash of the 80 byte serialization of the header following the block of prevHash
+func blockHeaderHash(header *models.BlockHeader, prevHash string) (string, error) {
+	raw := make([]byte, 80)
+	binary.LittleEndian.PutUint32(raw[0:4], header.Version)
+	for _, field := range []struct {
+		name  string
+		value string
+		to    []byte
+	}{
+		{"previous hash", prevHash, raw[4:36]},
+		{"merkle root", header.MerkleRoot, raw[36:68]},
+	} {
+		hash, err := hex.DecodeString(field.value)
+		if err != nil || len(hash) != sha256.Size {
+			return "", fmt.Errorf("invalid %s %q", field.name, field.value)
+		}
+		copy(field.to, reverseBytes(hash))
+	}
+	binary.LittleEndian.PutUint32(raw[68:72], header.Time)
+	bits, err := strconv.ParseUint(header.Bits, 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid bits %q", header.Bits)
+	}
+	binary.LittleEndian.PutUint32(raw[72:76], uint32(bits))
+	binary.LittleEndian.PutUint32(raw[76:80], header.Nonce)
+	first := sha256.Sum256(raw)
+	hash := sha256.Sum256(first[:])
+	return hex.EncodeToString(reverseBytes(hash[:])), nil
+}
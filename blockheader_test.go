@@ -0,0 +1,303 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient_GetBlockHeader will test decoding the block header response of testdata/blockheader.json
+func TestClient_GetBlockHeader(t *testing.T) {
+	response, err := os.ReadFile("testdata/blockheader.json")
+	require.NoError(t, err)
+
+	const hash = "000000000000000004a288072ebb35e37233f419918f9783d499979cb6ac33eb"
+	server := newFakeServer(t)
+	server.handleJSON("/v1/block_header/get/"+hash, http.StatusOK, string(response))
+	server.handleJSON("/v1/block_header/get/575191", http.StatusOK, string(response))
+	server.handleJSON("/v1/block_header/get/999999999", http.StatusNotFound, ``)
+	server.handleJSON("/v1/block_header/get/0", http.StatusOK, `{}`)
+	client := server.newClient(WithRetryPolicy(transports.NoRetryPolicy))
+
+	expected := &models.BlockHeader{
+		Hash:       hash,
+		Coin:       1,
+		Height:     575191,
+		Time:       1553416668,
+		Nonce:      87919397,
+		Version:    536870912,
+		MerkleRoot: "ca8f94a2be1d3e8c86cb9eb7bbfa1c0faf0c8f0a0d8b2f1c48f4a8e1c2b7e8d3",
+		Bits:       "180f0c1e",
+		Synced:     1,
+	}
+
+	t.Run("by hash", func(t *testing.T) {
+		blockHeader, err := client.GetBlockHeader(context.Background(), hash)
+		require.NoError(t, err)
+		assert.Equal(t, expected, blockHeader)
+	})
+
+	t.Run("by height", func(t *testing.T) {
+		blockHeader, err := client.GetBlockHeaderByHeight(context.Background(), 575191)
+		require.NoError(t, err)
+		assert.Equal(t, expected, blockHeader)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.GetBlockHeaderByHeight(context.Background(), 999999999)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = client.GetBlockHeaderByHeight(context.Background(), 0)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// serveBlockHeaders serves the list and tip endpoints for a chain of headers up to the tip, breaking the link
+// of the header at brokenHeight
+func serveBlockHeaders(server *fakeServer, tip, brokenHeight uint32) *int32 {
+	header := func(height uint32) *models.BlockHeader {
+		prevHash := "hash-" + strconv.FormatUint(uint64(height-1), 10)
+		if height == brokenHeight {
+			prevHash = "other"
+		}
+		return &models.BlockHeader{Hash: "hash-" + strconv.FormatUint(uint64(height), 10), PrevHash: prevHash, Height: height}
+	}
+
+	var pages int32
+	server.HandleFunc("/v1/block_header/list/", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&pages, 1)
+		from, _ := strconv.ParseUint(strings.TrimPrefix(req.URL.Path, "/v1/block_header/list/"), 10, 32)
+		limit, _ := strconv.ParseUint(req.URL.Query().Get("limit"), 10, 32)
+		headers := []*models.BlockHeader{}
+		for height := uint32(from); height < uint32(from+limit) && height <= tip; height++ {
+			headers = append(headers, header(height))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(headers)
+	})
+	server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(header(tip))
+	})
+	return &pages
+}
+
+// TestClient_SyncBlockHeaders will test paging through the block headers up to the chain tip
+func TestClient_SyncBlockHeaders(t *testing.T) {
+	t.Run("up to the tip", func(t *testing.T) {
+		server := newFakeServer(t)
+		pages := serveBlockHeaders(server, 2500, 0)
+
+		headers, errs := server.newClient().SyncBlockHeaders(context.Background(), 10)
+		height := uint32(10)
+		for header := range headers {
+			require.Equal(t, height, header.Height)
+			height++
+		}
+		require.NoError(t, <-errs)
+		assert.Equal(t, uint32(2501), height)
+		assert.Equal(t, int32(3), atomic.LoadInt32(pages))
+	})
+
+	t.Run("gap", func(t *testing.T) {
+		server := newFakeServer(t)
+		serveBlockHeaders(server, 2500, 1500)
+
+		headers, errs := server.newClient().SyncBlockHeaders(context.Background(), 10)
+		var last uint32
+		for header := range headers {
+			last = header.Height
+		}
+		require.ErrorIs(t, <-errs, ErrBlockHeaderGap)
+		assert.Equal(t, uint32(1499), last)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		server := newFakeServer(t)
+		serveBlockHeaders(server, 1000000, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		headers, errs := server.newClient().SyncBlockHeaders(ctx, 0)
+		<-headers
+		cancel()
+		for range headers {
+		}
+		require.ErrorIs(t, <-errs, context.Canceled)
+	})
+}
+
+// TestCheckHeaderLink will test checking that a block header follows the previous one, with and without the hash
+// of the previous block
+func TestCheckHeaderLink(t *testing.T) {
+	genesis := &models.BlockHeader{
+		Hash:       "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		MerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		Version:    1, Time: 1231006505, Bits: "1d00ffff", Nonce: 2083236893,
+	}
+	block1 := &models.BlockHeader{
+		Hash:       "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+		MerkleRoot: "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",
+		Height:     1, Version: 1, Time: 1231469665, Bits: "1d00ffff", Nonce: 2573394689,
+	}
+	block2 := &models.BlockHeader{
+		Hash:       "000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd",
+		MerkleRoot: "9b0fc92260312ce44e74ef369f5c66bbb85848f2eddd5a7a1cde251e54ccfdd5",
+		Height:     2, Version: 1, Time: 1231469744, Bits: "1d00ffff", Nonce: 1639830024,
+	}
+
+	// derived from the fields of the header
+	require.NoError(t, checkHeaderLink(block1, genesis))
+	require.NoError(t, checkHeaderLink(block2, block1))
+	assert.ErrorIs(t, checkHeaderLink(block2, genesis), ErrBlockHeaderGap)
+	tampered := *block1
+	tampered.Nonce++
+	assert.ErrorIs(t, checkHeaderLink(&tampered, genesis), ErrBlockHeaderGap)
+	incomplete := *block1
+	incomplete.MerkleRoot = ""
+	assert.ErrorIs(t, checkHeaderLink(&incomplete, genesis), ErrBlockHeaderGap)
+
+	// returned by the server
+	linked := *block2
+	linked.PrevHash = block1.Hash
+	require.NoError(t, checkHeaderLink(&linked, block1))
+	assert.ErrorIs(t, checkHeaderLink(&linked, genesis), ErrBlockHeaderGap)
+}
+
+// TestClient_GetChainTip will test getting and caching the chain tip
+func TestClient_GetChainTip(t *testing.T) {
+	var requests int32
+	server := newFakeServer(t)
+	server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, _ *http.Request) {
+		height := 800000 + atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"hash":"tip","height":`+strconv.Itoa(int(height))+`}`)
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		client := server.newClient()
+		tip, err := client.GetChainTip(context.Background())
+		require.NoError(t, err)
+		next, err := client.GetChainTip(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, tip.Height+1, next.Height)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		client := server.newClient(WithChainTipCache(50 * time.Millisecond))
+		tip, err := client.GetChainTip(context.Background())
+		require.NoError(t, err)
+		tip.Height = 0 // the cache is not affected by callers
+
+		sent := atomic.LoadInt32(&requests)
+		cached, err := client.GetChainTip(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, uint32(800000+sent), cached.Height)
+		assert.Equal(t, sent, atomic.LoadInt32(&requests))
+
+		time.Sleep(60 * time.Millisecond)
+		refreshed, err := client.GetChainTip(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, cached.Height+1, refreshed.Height)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/block_header/tip", http.StatusUnauthorized, `{}`)
+		_, err := server.newClient().GetChainTip(context.Background())
+		require.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	// slowTip serves the chain tip once release is closed, the started and cancelled channels receive the requests
+	type slowTip struct {
+		*fakeServer
+		requests  int32
+		started   chan struct{}
+		release   chan struct{}
+		cancelled chan struct{}
+	}
+	newSlowTip := func(t *testing.T) *slowTip {
+		server := &slowTip{
+			fakeServer: newFakeServer(t),
+			started:    make(chan struct{}, 10),
+			release:    make(chan struct{}),
+			cancelled:  make(chan struct{}, 10),
+		}
+		server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, req *http.Request) {
+			height := 800000 + atomic.AddInt32(&server.requests, 1)
+			server.started <- struct{}{}
+			select {
+			case <-server.release:
+			case <-req.Context().Done():
+				server.cancelled <- struct{}{}
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"hash":"tip","height":`+strconv.Itoa(int(height))+`}`)
+		})
+		return server
+	}
+
+	t.Run("concurrent refresh", func(t *testing.T) {
+		server := newSlowTip(t)
+		client := server.newClient(WithChainTipCache(time.Minute), WithRetryPolicy(transports.NoRetryPolicy))
+
+		type result struct {
+			tip *models.BlockHeader
+			err error
+		}
+		results := make(chan result, 3)
+		for i := 0; i < 3; i++ {
+			go func() {
+				tip, err := client.GetChainTip(context.Background())
+				results <- result{tip, err}
+			}()
+		}
+		<-server.started
+
+		// a caller giving up returns right away, the others keep waiting for the request
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := client.GetChainTip(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(server.release)
+		for i := 0; i < 3; i++ {
+			res := <-results
+			require.NoError(t, res.err)
+			assert.Equal(t, uint32(800001), res.tip.Height)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&server.requests))
+	})
+
+	t.Run("refresh cancelled", func(t *testing.T) {
+		server := newSlowTip(t)
+		client := server.newClient(WithChainTipCache(time.Minute), WithRetryPolicy(transports.NoRetryPolicy))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := client.GetChainTip(ctx)
+			errs <- err
+		}()
+		<-server.started
+		cancel()
+		require.ErrorIs(t, <-errs, context.Canceled)
+
+		// the request is cancelled once no caller waits for it
+		select {
+		case <-server.cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("request not cancelled")
+		}
+	})
+}
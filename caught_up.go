@@ -0,0 +1,41 @@
+package junglebus
+
+import (
+	"sync/atomic"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// IsCaughtUp returns whether the subscription reached the chain tip: the server waited for the next block
+// (SubscriptionWait) after all earlier messages were handled. It stays caught up while following the tip, and is no
+// longer caught up when it resumes behind the tip after reconnecting, until the server waits again.
+func (s *Subscription) IsCaughtUp() bool {
+	return atomic.LoadInt32(&s.caughtUp) == 1
+}
+
+// trackCaughtUp updates whether the subscription is caught up with a control message, from the queue worker. It
+// returns whether the subscription just caught up.
+func (s *Subscription) trackCaughtUp(controlResponse *models.ControlResponse) bool {
+	code := StatusCode(controlResponse.StatusCode)
+	switch {
+	case code.IsWaiting():
+		atomic.StoreInt32(&s.rearmed, 0)
+		return atomic.CompareAndSwapInt32(&s.caughtUp, 0, 1)
+	case code.IsBlockDone() && atomic.LoadInt32(&s.rearmed) == 1:
+		// a block beyond the one the connection resumed at was mined while disconnected
+		if controlResponse.Block > atomic.LoadUint32(&s.rearmBlock) && atomic.CompareAndSwapInt32(&s.caughtUp, 1, 0) {
+			atomic.StoreInt32(&s.rearmed, 0)
+			s.log(levelInfo, "fell behind the chain tip", "block", controlResponse.Block)
+		}
+	}
+	return false
+}
+
+// rearmCaughtUp marks a caught up subscription as having reconnected, it falls behind once a block beyond the
+// block it resumed at is done
+func (s *Subscription) rearmCaughtUp() {
+	if s.IsCaughtUp() {
+		atomic.StoreUint32(&s.rearmBlock, uint32(s.LastBlock()))
+		atomic.StoreInt32(&s.rearmed, 1)
+	}
+}
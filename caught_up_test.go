@@ -0,0 +1,94 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribe_OnCaughtUp will test detecting the subscription reaching the chain tip, across reconnects
+func TestSubscribe_OnCaughtUp(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		var mu sync.Mutex
+		var caughtUp []uint32
+		heights := func() []uint32 {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]uint32(nil), caughtUp...)
+		}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnCaughtUp: func(height uint32) {
+				mu.Lock()
+				defer mu.Unlock()
+				caughtUp = append(caughtUp, height)
+			},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(controlChannel)
+		control := func(code StatusCode, block uint32) {
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(code), Block: block})
+		}
+		reconnect := func() {
+			reconnects := subscription.Stats().Reconnects
+			server.DisconnectAll()
+			require.Eventually(t, func() bool {
+				return subscription.Stats().Reconnects > reconnects && subscription.IsConnected() &&
+					server.subscribed(controlChannel)
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+
+		control(SubscriptionBlockDone, 100)
+		require.Eventually(t, func() bool {
+			return subscription.LastBlock() == 100
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.False(t, subscription.IsCaughtUp())
+
+		control(SubscriptionWait, 101)
+		require.Eventually(t, subscription.IsCaughtUp, 5*time.Second, 10*time.Millisecond)
+		control(SubscriptionBlockDone, 101)
+		control(SubscriptionWait, 102)
+		require.Eventually(t, func() bool {
+			return subscription.LastBlock() == 102
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.True(t, subscription.IsCaughtUp())
+		assert.Equal(t, []uint32{101}, heights())
+
+		t.Run("still caught up after reconnecting", func(t *testing.T) {
+			reconnect()
+			control(SubscriptionBlockDone, 102)
+			control(SubscriptionWait, 103)
+			require.Eventually(t, func() bool {
+				return subscription.LastBlock() == 103
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.True(t, subscription.IsCaughtUp())
+			assert.Equal(t, []uint32{101}, heights())
+		})
+
+		t.Run("caught up again after falling behind", func(t *testing.T) {
+			reconnect()
+			control(SubscriptionBlockDone, 104)
+			require.Eventually(t, func() bool {
+				return subscription.LastBlock() == 104
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.False(t, subscription.IsCaughtUp())
+
+			control(SubscriptionBlockDone, 105)
+			control(SubscriptionWait, 106)
+			require.Eventually(t, subscription.IsCaughtUp, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []uint32{101, 106}, heights())
+		})
+	})
+}
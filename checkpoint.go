@@ -0,0 +1,94 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// CheckpointStore persists the progress of subscriptions, a subscription resumes from its checkpoint after a restart
+type CheckpointStore interface {
+	// Save stores the block and page the subscription resumes from, the block after the last one that is done
+	Save(ctx context.Context, subscriptionID string, block uint64, page uint64) error
+	// Load returns the stored block and page, or ErrCheckpointNotFound when nothing was stored yet
+	Load(ctx context.Context, subscriptionID string) (block, page uint64, err error)
+}
+
+// FileCheckpointStore is a CheckpointStore keeping a JSON file per subscription in a directory
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a checkpoint store in the given directory, creating it if needed
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// Save writes the checkpoint without a stream position, see SaveCheckpoint
+func (f *FileCheckpointStore) Save(ctx context.Context, subscriptionID string, block uint64, page uint64) error {
+	return f.SaveCheckpoint(ctx, subscriptionID, Checkpoint{Block: block, Page: page})
+}
+
+// SaveCheckpoint writes the checkpoint to a temporary file first, so a crash never leaves a partial checkpoint behind
+func (f *FileCheckpointStore) SaveCheckpoint(_ context.Context, subscriptionID string, checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(subscriptionID))
+}
+
+// Load reads the block and page of the checkpoint of the subscription
+func (f *FileCheckpointStore) Load(ctx context.Context, subscriptionID string) (block, page uint64, err error) {
+	checkpoint, err := f.LoadCheckpoint(ctx, subscriptionID)
+	return checkpoint.Block, checkpoint.Page, err
+}
+
+// LoadCheckpoint reads the checkpoint of the subscription, including its stream position
+func (f *FileCheckpointStore) LoadCheckpoint(_ context.Context, subscriptionID string) (Checkpoint, error) {
+	data, err := os.ReadFile(f.path(subscriptionID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = ErrCheckpointNotFound
+		}
+		return Checkpoint{}, err
+	}
+
+	var checkpoint Checkpoint
+	if err = json.Unmarshal(data, &checkpoint); err != nil {
+		return Checkpoint{}, err
+	}
+	return checkpoint, nil
+}
+
+// path returns the file of the subscription, the ID is escaped to always stay inside the directory
+func (f *FileCheckpointStore) path(subscriptionID string) string {
+	return filepath.Join(f.dir, url.PathEscape(subscriptionID)+".json")
+}
@@ -0,0 +1,70 @@
+package junglebus
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileCheckpointStore will test saving and loading checkpoints from files
+func TestFileCheckpointStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	store, err := NewFileCheckpointStore(dir)
+	require.NoError(t, err)
+
+	t.Run("not found", func(t *testing.T) {
+		_, _, err = store.Load(context.Background(), testSubscriptionID)
+		assert.ErrorIs(t, err, ErrCheckpointNotFound)
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		require.NoError(t, store.Save(context.Background(), testSubscriptionID, 100, 2))
+		require.NoError(t, store.Save(context.Background(), testSubscriptionID, 101, 0))
+
+		block, page, loadErr := store.Load(context.Background(), testSubscriptionID)
+		require.NoError(t, loadErr)
+		assert.Equal(t, uint64(101), block)
+		assert.Equal(t, uint64(0), page)
+	})
+
+	t.Run("stream position", func(t *testing.T) {
+		checkpoint := Checkpoint{Block: 102, Page: 1, Position: &StreamPosition{
+			Channel: "query:" + testSubscriptionID + ":102:1", Offset: 7, Epoch: "epoch",
+		}}
+		require.NoError(t, store.SaveCheckpoint(context.Background(), testSubscriptionID, checkpoint))
+
+		loaded, loadErr := store.LoadCheckpoint(context.Background(), testSubscriptionID)
+		require.NoError(t, loadErr)
+		assert.Equal(t, checkpoint, loaded)
+		block, page, loadErr := store.Load(context.Background(), testSubscriptionID)
+		require.NoError(t, loadErr)
+		assert.Equal(t, []uint64{102, 1}, []uint64{block, page})
+	})
+
+	t.Run("no temporary files are left", func(t *testing.T) {
+		entries, readErr := os.ReadDir(dir)
+		require.NoError(t, readErr)
+		require.Len(t, entries, 1)
+		assert.Equal(t, testSubscriptionID+".json", entries[0].Name())
+	})
+
+	t.Run("subscription ids stay inside the directory", func(t *testing.T) {
+		require.NoError(t, store.Save(context.Background(), "../escape", 1, 0))
+		_, statErr := os.Stat(filepath.Join(filepath.Dir(dir), "escape.json"))
+		assert.ErrorIs(t, statErr, os.ErrNotExist)
+
+		block, _, loadErr := store.Load(context.Background(), "../escape")
+		require.NoError(t, loadErr)
+		assert.Equal(t, uint64(1), block)
+	})
+
+	t.Run("corrupt checkpoint", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "corrupt.json"), []byte("{"), 0o600))
+		_, _, err = store.Load(context.Background(), "corrupt")
+		assert.Error(t, err)
+	})
+}
@@ -1,12 +1,18 @@
 package junglebus
 
 import (
+	"crypto/tls"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/GorillaPool/go-junglebus/transports"
 )
 
-// WithHTTP will overwrite the default server url (junglebus.gorillapool.io)
+// WithHTTP will overwrite the default server url (DefaultServer). The url is a host with an optional port and base
+// path, like junglebus.example.com or https://example.com/junglebus for a server behind a reverse proxy. SSL is used
+// unless the scheme is http:// or ws://, trailing slashes are removed. New fails with ErrInvalidServerURL for a url
+// that can not be parsed.
 func WithHTTP(serverURL string) ClientOps {
 	return func(c *Client) {
 		if c != nil {
@@ -24,6 +30,349 @@ func WithHTTPClient(serverURL string, httpClient *http.Client) ClientOps {
 	}
 }
 
+// WithCustomHTTPClient will set the client used for all REST requests, including fetching and refreshing the
+// subscription token, without changing the server url. Its transport, redirect policy and timeout are used as is.
+func WithCustomHTTPClient(httpClient *http.Client) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithCustomHTTPClient(httpClient))
+		}
+	}
+}
+
+// WithTLSConfig will set the TLS configuration of the REST requests and the websocket connections, like the
+// certificate pool of a private CA
+func WithTLSConfig(tlsConfig *tls.Config) ClientOps {
+	return func(c *Client) {
+		if c != nil && tlsConfig != nil {
+			c.tlsConfig = tlsConfig
+			c.transportOptions = append(c.transportOptions, transports.WithTLSConfig(tlsConfig))
+		}
+	}
+}
+
+// WithInsecureSkipVerify will turn off verifying the certificate of the server, for local development only.
+// It is added to the TLS configuration set with WithTLSConfig, when given before this option.
+func WithInsecureSkipVerify() ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			tlsConfig := &tls.Config{}
+			if c.tlsConfig != nil {
+				tlsConfig = c.tlsConfig.Clone()
+			}
+			tlsConfig.InsecureSkipVerify = true
+			WithTLSConfig(tlsConfig)(c)
+		}
+	}
+}
+
+// WithProxy will send the REST requests and the websocket connections through the proxy returned by proxy,
+// instead of the proxy of the environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY) used by default. Websocket
+// connections are tunneled with a CONNECT request, the user info of the proxy url is used for basic proxy
+// authorization. The proxy of the environment is not used for either, it can stay set for other clients.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOps {
+	return func(c *Client) {
+		if c != nil && proxy != nil {
+			c.proxy = proxy
+			c.transportOptions = append(c.transportOptions, transports.WithProxy(proxy))
+		}
+	}
+}
+
+// WithWebsocketTimeouts will set the timeouts of the websocket connections (DefaultWebsocketTimeouts is default),
+// New fails with ErrInvalidTimeout when a timeout is not positive
+func WithWebsocketTimeouts(timeouts WebsocketTimeouts) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			if err := timeouts.validate(); err != nil {
+				if c.optionErr == nil {
+					c.optionErr = err
+				}
+				return
+			}
+			c.websocket = timeouts
+		}
+	}
+}
+
+// WithWebsocketURL will set the URL of the websocket connections of subscriptions, REST requests keep using the
+// server URL. The URL can be a host like ws.example.com, using wss unless SSL is turned off, or a URL with one of the
+// schemes ws, wss, http or https. The path defaults to /connection/websocket. New fails with ErrInvalidWebsocketURL
+// for URLs that can not be parsed or have another scheme.
+func WithWebsocketURL(websocketURL string) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			u, err := parseWebsocketURL(websocketURL)
+			if err != nil {
+				if c.optionErr == nil {
+					c.optionErr = err
+				}
+				return
+			}
+			c.websocketURL = u
+		}
+	}
+}
+
+// WithServers will send the REST requests and the websocket connections to the first server, failing over to the next
+// one after it failed DefaultFailoverThreshold times in a row (see WithFailoverThreshold), and back to the first after
+// the last. Requests without a response, responses with a 5xx status and connections that can not be opened are
+// failures. Subscriptions connect to the next server with a new token and resume from their checkpoint, after a
+// StatusFailover status. The servers are given like the server url of WithHTTP, with the same base path. New fails
+// with ErrNoServers without servers. Failing over does not apply to a transport injected with WithTransport, or to
+// the websocket connections when WithWebsocketURL is set.
+func WithServers(serverURLs ...string) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			if len(serverURLs) == 0 {
+				if c.optionErr == nil {
+					c.optionErr = ErrNoServers
+				}
+				return
+			}
+			for _, serverURL := range serverURLs {
+				if _, _, err := transports.ParseServerURL(serverURL); err != nil {
+					if c.optionErr == nil {
+						c.optionErr = err
+					}
+					return
+				}
+			}
+			c.failover.servers = append([]string(nil), serverURLs...)
+			c.transportOptions = append(c.transportOptions, transports.WithHTTP(serverURLs[0]))
+		}
+	}
+}
+
+// WithFailoverThreshold will set the number of failures in a row after which the client fails over to the next server
+// of WithServers (DefaultFailoverThreshold is default), New fails with ErrInvalidFailoverThreshold when it is not positive
+func WithFailoverThreshold(threshold int) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			if threshold <= 0 {
+				if c.optionErr == nil {
+					c.optionErr = ErrInvalidFailoverThreshold
+				}
+				return
+			}
+			c.failover.threshold = threshold
+		}
+	}
+}
+
+// WithHeaders will add the headers to all REST requests and the websocket upgrade requests. A token header is
+// only used for REST requests when no token is set, the token of the client is never overridden.
+func WithHeaders(headers map[string]string) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			if c.headers == nil {
+				c.headers = http.Header{}
+			}
+			for key, value := range headers {
+				c.headers.Set(key, value)
+			}
+			c.transportOptions = append(c.transportOptions, transports.WithHeaders(headers))
+		}
+	}
+}
+
+// WithUserAgent will set the user agent of all REST requests and the websocket upgrade requests
+// (transports.JungleBusUserAgent is default)
+func WithUserAgent(userAgent string) ClientOps {
+	return func(c *Client) {
+		if c != nil && userAgent != "" {
+			c.userAgent = userAgent
+			c.transportOptions = append(c.transportOptions, transports.WithUserAgent(userAgent))
+		}
+	}
+}
+
+// WithRetryPolicy will set how REST requests failing with a connection error, a 5xx or a 429 status are retried
+// (transports.DefaultRetryPolicy is default, transports.NoRetryPolicy disables retrying)
+func WithRetryPolicy(retryPolicy transports.RetryPolicy) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithRetryPolicy(retryPolicy))
+		}
+	}
+}
+
+// WithAutoRateLimit will make REST requests that are rate limited by the server wait for the Retry-After duration
+// and send them again, for as long as the context of the request allows. Otherwise a *RateLimitError is returned
+// once the retry policy gives up.
+func WithAutoRateLimit(autoRateLimit bool) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithAutoRateLimit(autoRateLimit))
+		}
+	}
+}
+
+// WithRateLimit will limit the REST requests to rps requests per second with bursts of burst requests
+//
+// Requests wait for the limiter while respecting their context, a request that would not be allowed before
+// the deadline of its context returns context.DeadlineExceeded right away. Every attempt of the retry policy
+// and of WithAutoRateLimit is a request and waits for the limiter as well.
+func WithRateLimit(rps float64, burst int) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithRateLimit(rps, burst))
+		}
+	}
+}
+
+// WithCircuitBreaker will make REST requests fail fast with ErrCircuitOpen after consecutive failures,
+// until a probe request succeeds after the cooldown, see transports.CircuitBreaker
+func WithCircuitBreaker(breaker transports.CircuitBreaker) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithCircuitBreaker(breaker))
+		}
+	}
+}
+
+// WithTransportMiddleware will add middlewares wrapping every REST request, including the subscription token
+// requests of Subscribe. Middlewares are called in the order they were added, see transports.Middleware
+func WithTransportMiddleware(middleware ...transports.Middleware) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithMiddleware(middleware...))
+		}
+	}
+}
+
+// WithRequestHooks will set the hooks called around every REST request with the name of the endpoint
+// and the duration of the request
+func WithRequestHooks(hooks transports.RequestHooks) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.transportOptions = append(c.transportOptions, transports.WithRequestHooks(hooks))
+		}
+	}
+}
+
+// WithTransport will use the transport for the REST requests and the subscription tokens, like a
+// transports.Mock in tests. The options configuring the REST requests do not apply to an injected transport.
+func WithTransport(transport transports.Transport) ClientOps {
+	return func(c *Client) {
+		if c != nil && transport != nil {
+			c.customTransport = transport
+		}
+	}
+}
+
+// WithChainTipCache will cache the chain tip returned by GetChainTip for the ttl, so callers needing the best
+// height often do not each send a request (disabled by default)
+func WithChainTipCache(ttl time.Duration) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.chainTipTTL = ttl
+		}
+	}
+}
+
+// WithHeaderPollInterval will check the chain tip for new block headers every interval in SubscribeBlockHeaders
+// (DefaultHeaderPollInterval by default)
+func WithHeaderPollInterval(interval time.Duration) ClientOps {
+	return func(c *Client) {
+		if c != nil && interval > 0 {
+			c.headerPollInterval = interval
+		}
+	}
+}
+
+// WithAddressPollInterval will look up new transactions of the addresses of SubscribeAddresses every interval
+// (DefaultAddressPollInterval by default)
+func WithAddressPollInterval(interval time.Duration) ClientOps {
+	return func(c *Client) {
+		if c != nil && interval > 0 {
+			c.addressPollInterval = interval
+		}
+	}
+}
+
+// WithTokenProvider will get the tokens of subscriptions from the provider instead of JungleBus, for the first
+// connection and every time the connection asks for a new token. A token set with WithToken is replaced by the
+// token of the provider when subscribing.
+func WithTokenProvider(provider TokenProvider) ClientOps {
+	return func(c *Client) {
+		if c != nil && provider != nil {
+			c.tokenProvider = provider
+		}
+	}
+}
+
+// WithTokenRefreshLeeway will set how long before it expires the token of a connection is refreshed at the latest,
+// to allow for clock skew with the server (DefaultTokenRefreshLeeway is default). Tokens are JWTs refreshed at 80%
+// of their lifetime, the connection is then replaced with one using the new token.
+func WithTokenRefreshLeeway(leeway time.Duration) ClientOps {
+	return func(c *Client) {
+		if c != nil && leeway >= 0 {
+			c.tokenRefreshLeeway = leeway
+		}
+	}
+}
+
+// WithTokenStore will persist the tokens of subscriptions in the store, so a restarted process reuses the token
+// instead of getting a new one. A stored token is used when subscribing unless it expired, every new token is
+// saved. Failing saves are sent to OnError of the subscription.
+func WithTokenStore(store TokenStore) ClientOps {
+	return func(c *Client) {
+		if c != nil && store != nil {
+			c.tokenStore = store
+		}
+	}
+}
+
+// WithNoAuth will connect subscriptions without a token, for deployments allowing anonymous access to public
+// subscriptions. No subscription token is requested and tokens are never refreshed. A subscription rejected by
+// the server for the missing token stops with ErrAuthRequired.
+func WithNoAuth() ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.noAuth = true
+		}
+	}
+}
+
+// WithJSONProtocol will connect subscriptions using the JSON protocol of centrifuge instead of protobuf, for
+// proxies and older JungleBus deployments that do not support protobuf. Publications are decoded from JSON into
+// the same models, the callbacks of the event handler behave the same in both modes.
+func WithJSONProtocol() ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.jsonProtocol = true
+		}
+	}
+}
+
+// WithCompression will negotiate permessage-deflate on the websocket connections of subscriptions, when the server
+// supports it. REST requests always accept gzip compressed responses.
+func WithCompression() ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.compression = true
+		}
+	}
+}
+
+// WithMaxMessageSize will limit the size of REST responses and publications once decompressed, including gzip
+// compressed raw transactions, so a decompression bomb can not exhaust memory. REST requests of larger responses
+// fail with a *MessageTooLargeError, larger publications are sent to OnError as a DecodeError wrapping it. The
+// default is DefaultMaxMessageSize, zero or negative is no limit.
+//
+// The websocket connection itself is not limited: centrifuge-go does not expose the read limit of the connection,
+// so a publication is read into memory, and inflated when WithCompression is used, before its size is checked.
+// Only the raw transactions it carries are decompressed within the limit.
+func WithMaxMessageSize(size int64) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.maxMessageSize = size
+			c.transportOptions = append(c.transportOptions, transports.WithMaxResponseSize(size))
+		}
+	}
+}
+
 // WithToken will set the token to use in all requests
 func WithToken(token string) ClientOps {
 	return func(c *Client) {
@@ -42,6 +391,28 @@ func WithDebugging(debug bool) ClientOps {
 	}
 }
 
+// WithLogger will set the logger for the internal logging of the client, nothing is logged by default
+func WithLogger(logger Logger) ClientOps {
+	return func(c *Client) {
+		if c != nil && logger != nil {
+			c.logger = logger
+			c.transportOptions = append(c.transportOptions, transports.WithLogger(logger))
+		}
+	}
+}
+
+// WithTracer will trace the REST requests and every call of the event handlers of subscriptions, nothing is
+// traced by default. Requests are traced in the context they are made with, handler calls in the context the
+// subscription was started with.
+func WithTracer(tracer Tracer) ClientOps {
+	return func(c *Client) {
+		if c != nil && tracer != nil {
+			c.tracer = tracer
+			c.transportOptions = append(c.transportOptions, transports.WithTracer(tracer))
+		}
+	}
+}
+
 // WithSSL will set whether to use SSL in all communications or not
 func WithSSL(useSSL bool) ClientOps {
 	return func(c *Client) {
@@ -59,3 +430,34 @@ func WithVersion(version string) ClientOps {
 		}
 	}
 }
+
+// WithReconnectBackoff will set the delay between reconnect attempts, starting at min and growing by factor up to max
+func WithReconnectBackoff(min, max time.Duration, factor float64) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.reconnectPolicy.minDelay = min
+			c.reconnectPolicy.maxDelay = max
+			c.reconnectPolicy.factor = factor
+		}
+	}
+}
+
+// WithWatchPollBackoff will set the delay between looking up a transaction watched without subscriptions, starting
+// at min and doubling up to max (DefaultWatchPollMinDelay and DefaultWatchPollMaxDelay are default)
+func WithWatchPollBackoff(min, max time.Duration) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.watchPolicy.minDelay = min
+			c.watchPolicy.maxDelay = max
+		}
+	}
+}
+
+// WithMaxReconnectAttempts will set the number of failed reconnects after which a subscription stops (0 is unlimited, default)
+func WithMaxReconnectAttempts(attempts int) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.reconnectPolicy.maxAttempts = attempts
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Command junglebus streams subscriptions and queries the JungleBus API from the command line
+//
+// Usage:
+//
+//	junglebus tail [flags] --subscription <id> [--from <block>]
+//	junglebus tx [flags] <txid>
+//	junglebus header [flags] <height or hash>
+//	junglebus address [flags] <address>
+//
+// tail prints every message of the subscription as a line of JSON, in the format of sinks.NDJSONFile, so the output
+// can be replayed with junglebus.Replay. The other commands print the response as JSON. Flags go before the
+// arguments, run a command with -h for its flags.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/sinks"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: junglebus <command> [flags] [arguments]
+
+Commands:
+  tail     stream a subscription as NDJSON
+  tx       get a transaction by its txid
+  header   get a block header by its height or hash
+  address  get the transactions of an address
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command of the arguments, returning the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("junglebus "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	server := flags.String("server", junglebus.DefaultServer, "the JungleBus server")
+	token := flags.String("token", "", "the token to authenticate with")
+
+	var err error
+	switch command {
+	case "tail":
+		subscriptionID := flags.String("subscription", "", "the ID of the subscription to stream")
+		fromBlock := flags.Uint64("from", 0, "the block to stream from")
+		untilBlock := flags.Uint64("until", 0, "the block to stop after, 0 streams until interrupted")
+		lite := flags.Bool("lite", false, "stream the transactions in lite mode, without their raw transaction")
+		if code, ok := parse(flags, args[1:], 0); !ok {
+			return code
+		}
+		if *subscriptionID == "" {
+			_, _ = fmt.Fprintln(stderr, "missing --subscription")
+			flags.Usage()
+			return exitUsage
+		}
+		var opts []junglebus.SubscribeOption
+		if *untilBlock > 0 {
+			opts = append(opts, junglebus.WithUntilBlock(*untilBlock))
+		}
+		if *lite {
+			opts = append(opts, junglebus.WithLiteMode())
+		}
+		err = withClient(*server, *token, func(client *junglebus.Client) error {
+			return tail(ctx, client, *subscriptionID, *fromBlock, stdout, stderr, opts...)
+		})
+	case "tx":
+		if code, ok := parse(flags, args[1:], 1); !ok {
+			return code
+		}
+		err = withClient(*server, *token, func(client *junglebus.Client) error {
+			response, err := client.GetTransaction(ctx, flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(stdout).Encode(response)
+		})
+	case "header":
+		if code, ok := parse(flags, args[1:], 1); !ok {
+			return code
+		}
+		err = withClient(*server, *token, func(client *junglebus.Client) error {
+			response, err := client.GetBlockHeader(ctx, flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(stdout).Encode(response)
+		})
+	case "address":
+		if code, ok := parse(flags, args[1:], 1); !ok {
+			return code
+		}
+		err = withClient(*server, *token, func(client *junglebus.Client) error {
+			response, err := client.GetAddressTransactions(ctx, flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(stdout).Encode(response)
+		})
+	case "-h", "-help", "--help", "help":
+		_, _ = fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		_, _ = fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return exitUsage
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "junglebus "+command+":", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// parse parses the flags of a command taking n arguments, returning the exit code when the command cannot run
+func parse(flags *flag.FlagSet, args []string, n int) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if flags.NArg() != n {
+		_, _ = fmt.Fprintf(flags.Output(), "expected %d argument(s), got %d\n", n, flags.NArg())
+		flags.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// withClient runs fn with a client of the server
+func withClient(server, token string, fn func(client *junglebus.Client) error) error {
+	opts := []junglebus.ClientOps{junglebus.WithHTTP(server)}
+	if token != "" {
+		opts = append(opts, junglebus.WithToken(token))
+	}
+	client, err := junglebus.New(opts...)
+	if err != nil {
+		return err
+	}
+	return fn(client)
+}
+
+// tail streams the subscription to stdout until ctx is cancelled or the until block is done. Errors of the stream
+// are printed to stderr, the error the subscription stopped with is returned.
+func tail(ctx context.Context, client *junglebus.Client, subscriptionID string, fromBlock uint64,
+	stdout, stderr io.Writer, opts ...junglebus.SubscribeOption) error {
+
+	eventHandler := junglebus.EventHandler{
+		// the messages are written by the sink, the callbacks subscribe to the channels
+		OnTransaction: func(*models.TransactionResponse) {},
+		OnMempool:     func(*models.TransactionResponse) {},
+		OnStatus:      func(*models.ControlResponse) {},
+		OnError: func(err error) {
+			_, _ = fmt.Fprintln(stderr, "error:", err)
+		},
+	}
+	sink := &writerSink{encoder: json.NewEncoder(stdout)}
+	err := client.Run(ctx, subscriptionID, fromBlock, eventHandler, append(opts, junglebus.WithSink(sink))...)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// writerSink is a sink writing the records of sinks.NDJSONFile to a writer
+type writerSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// WriteTransaction writes a transaction record
+func (w *writerSink) WriteTransaction(tx *models.TransactionResponse, mempool bool) error {
+	kind := sinks.KindTransaction
+	if mempool {
+		kind = sinks.KindMempool
+	}
+	return w.write(&sinks.Record{Kind: kind, Time: time.Now().UTC(), Transaction: tx})
+}
+
+// WriteStatus writes a status record
+func (w *writerSink) WriteStatus(status *models.ControlResponse) error {
+	return w.write(&sinks.Record{Kind: sinks.KindStatus, Time: time.Now().UTC(), Status: status})
+}
+
+// write writes the record as a line of JSON
+func (w *writerSink) write(record *sinks.Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encoder.Encode(record)
+}
+
+// Close does nothing, the writer is not owned by the sink
+func (w *writerSink) Close() error {
+	return nil
+}
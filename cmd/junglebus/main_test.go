@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/junglebustest"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestRun_tail will test streaming a subscription as NDJSON until the until block is done
+func TestRun_tail(t *testing.T) {
+	server := junglebustest.NewServer()
+	defer server.Close()
+
+	stdout, stderr := &syncBuffer{}, &syncBuffer{}
+	codes := make(chan int, 1)
+	go func() {
+		codes <- run(context.Background(), []string{"tail", "--server", server.URL, "--subscription", "sub",
+			"--from", "100", "--until", "100"}, stdout, stderr)
+	}()
+	require.True(t, server.WaitSubscribed(junglebustest.MainChannel("sub", 100), 5*time.Second))
+	require.NoError(t, server.PublishTransaction(junglebustest.MainChannel("sub", 100),
+		&models.TransactionResponse{Id: "tx-1", BlockHeight: 100}))
+	require.NoError(t, server.PublishControl(junglebustest.ControlChannel("sub"), &models.ControlResponse{
+		StatusCode: uint32(junglebus.SubscriptionBlockDone),
+		Block:      100,
+	}))
+	select {
+	case code := <-codes:
+		require.Equal(t, exitOK, code, stderr.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("tail did not stop at the until block")
+	}
+
+	reader, err := sinks.NewReader(strings.NewReader(stdout.String()))
+	require.NoError(t, err)
+	var transactions []string
+	blockDone := false
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		switch record.Kind {
+		case sinks.KindTransaction:
+			transactions = append(transactions, record.Transaction.Id)
+		case sinks.KindStatus:
+			blockDone = blockDone || junglebus.StatusCode(record.Status.StatusCode).IsBlockDone()
+		}
+	}
+	assert.Equal(t, []string{"tx-1"}, transactions)
+	assert.True(t, blockDone)
+}
+
+// TestRun_tailCancelled will test stopping the stream on an interrupt without failing
+func TestRun_tailCancelled(t *testing.T) {
+	server := junglebustest.NewServer()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	codes := make(chan int, 1)
+	go func() {
+		codes <- run(ctx, []string{"tail", "--server", server.URL, "--subscription", "sub"}, io.Discard, io.Discard)
+	}()
+	require.True(t, server.WaitSubscribed(junglebustest.ControlChannel("sub"), 5*time.Second))
+	cancel()
+	select {
+	case code := <-codes:
+		assert.Equal(t, exitOK, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("tail did not stop")
+	}
+}
+
+// TestRun_queries will test the commands printing a response of the API
+func TestRun_queries(t *testing.T) {
+	server := junglebustest.NewServer()
+	defer server.Close()
+	server.HandleJSON("/v1/transaction/get/txid", http.StatusOK, `{"id":"txid","block_height":100}`)
+	server.HandleJSON("/v1/block_header/get/100", http.StatusOK, `{"hash":"hash","height":100}`)
+	server.HandleJSON("/v1/transaction/get/missing", http.StatusNotFound, `{"error":"not found"}`)
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+	}{
+		{name: "tx", args: []string{"tx", "--server", server.URL, "txid"}, code: exitOK, stdout: `"id":"txid"`},
+		{name: "header", args: []string{"header", "--server", server.URL, "100"}, code: exitOK, stdout: `"hash":"hash"`},
+		{name: "not found", args: []string{"tx", "--server", server.URL, "missing"}, code: exitError},
+		{name: "missing argument", args: []string{"tx", "--server", server.URL}, code: exitUsage},
+		{name: "missing subscription", args: []string{"tail", "--server", server.URL}, code: exitUsage},
+		{name: "unknown command", args: []string{"blocks"}, code: exitUsage},
+		{name: "no command", code: exitUsage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			code := run(context.Background(), test.args, stdout, io.Discard)
+			assert.Equal(t, test.code, code)
+			assert.Contains(t, stdout.String(), test.stdout)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package junglebus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultMaxMessageSize is the default limit of WithMaxMessageSize
+const DefaultMaxMessageSize = 64 << 20
+
+// gzipMagic starts gzip compressed data, a raw transaction starts with its version instead
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// checkMessageSize returns a *MessageTooLargeError when the message is larger than WithMaxMessageSize
+func (jb *Client) checkMessageSize(data []byte) error {
+	if jb.maxMessageSize > 0 && int64(len(data)) > jb.maxMessageSize {
+		return &MessageTooLargeError{Limit: jb.maxMessageSize}
+	}
+	return nil
+}
+
+// inflate decompresses the raw transaction of a transaction message when it is gzip compressed, within the limit
+// of WithMaxMessageSize
+func (jb *Client) inflate(message proto.Message) error {
+	tx, ok := message.(*models.TransactionResponse)
+	if !ok || !bytes.HasPrefix(tx.Transaction, gzipMagic) {
+		return nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(tx.Transaction))
+	if err != nil {
+		return fmt.Errorf("failed to decompress transaction %s: %w", tx.Id, err)
+	}
+	var body io.Reader = reader
+	if jb.maxMessageSize > 0 {
+		body = io.LimitReader(reader, jb.maxMessageSize+1)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to decompress transaction %s: %w", tx.Id, err)
+	}
+	if err = jb.checkMessageSize(raw); err != nil {
+		return err
+	}
+	tx.Transaction = raw
+	return nil
+}
@@ -0,0 +1,152 @@
+package junglebus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gzipped returns the data gzip compressed
+func gzipped(t testing.TB, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// TestSubscribe_Compression will test compressed connections and decompressing gzip compressed transactions
+func TestSubscribe_Compression(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient(WithCompression(), WithMaxMessageSize(1<<10))
+
+		recorder := &statusRecorder{}
+		received := make(chan *models.TransactionResponse, 2)
+		errs := make(chan error, 2)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { received <- tx },
+			OnStatus:      recorder.onStatus,
+			OnError:       func(err error) { errs <- err },
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		assert.Contains(t, server.requestHeaders(websocketPath).Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+		raw := newRawTransaction(testOutput{1000, testOpReturn})
+		require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+			Id: "compressed", Transaction: gzipped(t, raw),
+		}))
+		require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+			Id: "plain", Transaction: raw,
+		}))
+		for _, id := range []string{"compressed", "plain"} {
+			select {
+			case tx := <-received:
+				assert.Equal(t, id, tx.Id)
+				assert.Equal(t, raw, tx.Transaction)
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+			}
+		}
+
+		t.Run("too large", func(t *testing.T) {
+			// compresses well below the limit
+			require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+				Id: "bomb", Transaction: gzipped(t, make([]byte, 1<<20)),
+			}))
+			require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+				Id: "large", Transaction: make([]byte, 2<<10),
+			}))
+			for i := 0; i < 2; i++ {
+				select {
+				case err := <-errs:
+					var decodeErr *DecodeError
+					require.ErrorAs(t, err, &decodeErr)
+					var tooLarge *MessageTooLargeError
+					require.ErrorAs(t, err, &tooLarge)
+					assert.Equal(t, int64(1<<10), tooLarge.Limit)
+				case <-time.After(5 * time.Second):
+					t.Fatal("error not received")
+				}
+			}
+			assert.Empty(t, received)
+		})
+	})
+}
+
+// TestClient_CompressedResponses will test decompressing REST responses and the transactions of block pages
+func TestClient_CompressedResponses(t *testing.T) {
+	raw := newRawTransaction(testOutput{1000, testOpReturn})
+	server := newFakeServer(t)
+	server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipped(t, []byte(`{"hash":"tip","height":100}`)))
+	})
+	server.HandleFunc("/v1/subscription/"+testSubscriptionID+"/block/100/0", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&models.BlockTransactionsPage{Transactions: []*models.TransactionResponse{
+			{Id: "compressed", BlockHeight: 100, Transaction: gzipped(t, raw)},
+		}})
+	})
+	client := server.newClient(WithMaxMessageSize(1 << 10))
+
+	tip, err := client.GetChainTip(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(100), tip.Height)
+	assert.Equal(t, "gzip", server.requestHeaders("/v1/block_header/tip").Get("Accept-Encoding"))
+
+	txs, errs := client.GetBlockTransactions(context.Background(), testSubscriptionID, 100)
+	var transactions []*models.TransactionResponse
+	for tx := range txs {
+		transactions = append(transactions, tx)
+	}
+	require.NoError(t, <-errs)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, raw, transactions[0].Transaction)
+
+	tx := &models.TransactionResponse{Id: "invalid", Transaction: []byte{0x1f, 0x8b, 0}}
+
+	require.Error(t, client.inflate(tx))
+	assert.Equal(t, []byte{0x1f, 0x8b, 0}, tx.Transaction)
+
+	tx = &models.TransactionResponse{Id: "bomb", Transaction: gzipped(t, make([]byte, 1<<20))}
+	require.ErrorIs(t, client.inflate(tx), ErrMessageTooLarge)
+}
+
+// TestClient_DefaultMaxMessageSize will test limiting messages by default
+func TestClient_DefaultMaxMessageSize(t *testing.T) {
+	server := newFakeServer(t)
+	server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, _ *http.Request) {
+		// rejected on the declared length, before the body is read
+		w.Header().Set("Content-Length", strconv.Itoa(DefaultMaxMessageSize+1))
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"hash":"tip","height":100}`)
+	})
+
+	client := server.newClient()
+	assert.Equal(t, int64(DefaultMaxMessageSize), client.maxMessageSize)
+	_, err := client.GetChainTip(context.Background())
+	var tooLarge *MessageTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, int64(DefaultMaxMessageSize), tooLarge.Limit)
+
+	client = server.newClient(WithMaxMessageSize(0))
+	assert.Zero(t, client.maxMessageSize)
+	_, err = client.GetChainTip(context.Background())
+	assert.NotErrorIs(t, err, ErrMessageTooLarge)
+}
@@ -0,0 +1,59 @@
+package junglebus
+
+import (
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// withConcurrency returns the event handler with the transaction callbacks running on the handler workers of
+// the subscription. Mined transactions are tracked so control messages can wait for them, see waitBlock.
+func (s *Subscription) withConcurrency(eventHandler EventHandler) EventHandler {
+	concurrent := eventHandler
+	if eventHandler.OnTransaction != nil {
+		concurrent.OnTransaction = func(tx *models.TransactionResponse) {
+			s.handleConcurrently(true, func() {
+				eventHandler.OnTransaction(tx)
+			})
+		}
+	}
+	if eventHandler.OnMempool != nil {
+		concurrent.OnMempool = func(tx *models.TransactionResponse) {
+			s.handleConcurrently(false, func() {
+				eventHandler.OnMempool(tx)
+			})
+		}
+	}
+	return concurrent
+}
+
+// handleConcurrently runs fn as soon as a handler worker is available, fn is dropped when the
+// subscription is torn down while waiting. Mined transactions are tracked by blockHandlers.
+func (s *Subscription) handleConcurrently(mined bool, fn func()) {
+	if mined {
+		s.blockHandlers.Add(1)
+	}
+	select {
+	case s.handlerWorkers <- struct{}{}:
+	case <-s.done:
+		if mined {
+			s.blockHandlers.Done()
+		}
+		return
+	}
+
+	go func() {
+		defer func() {
+			<-s.handlerWorkers
+			if mined {
+				s.blockHandlers.Done()
+			}
+		}()
+		fn()
+	}()
+}
+
+// waitBlock waits until the mined transactions received so far have been handled
+func (s *Subscription) waitBlock() {
+	if s.handlerWorkers != nil {
+		s.blockHandlers.Wait()
+	}
+}
@@ -0,0 +1,323 @@
+package junglebus
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultConsumerQueueSize is the default number of messages queued for a consumer, see WithConsumerQueueSize
+const DefaultConsumerQueueSize = 1000
+
+// Consumer is an additional event handler fed from the stream of a subscription, see Subscription.NewConsumer
+type Consumer struct {
+	Name string
+
+	subscription   *Subscription
+	eventHandler   EventHandler
+	filter         Filter
+	queueSize      int
+	overflowPolicy OverflowPolicy
+	queue          *messageQueue
+	done           chan struct{}
+	closeOnce      sync.Once
+	counters       consumerCounters
+	lag            lagTracker
+}
+
+// ConsumerStats is a snapshot of the statistics of a consumer
+type ConsumerStats struct {
+	TransactionsReceived uint64   // mined transactions passed on to the consumer
+	MempoolReceived      uint64   // mempool transactions passed on to the consumer
+	ControlReceived      uint64   // control messages passed on to the consumer
+	Filtered             uint64   // transactions dropped by the filter of WithConsumerFilter
+	DroppedMessages      uint64   // messages dropped by the overflow policy
+	Errors               uint64   // errors sent to OnError of the consumer
+	QueueDepth           int      // messages waiting in the queue of the consumer to be handled
+	Lag                  LagStats // how far the consumer is behind the stream
+}
+
+// consumerCounters are the counters behind ConsumerStats, only accessed atomically
+type consumerCounters struct {
+	transactions uint64
+	mempool      uint64
+	control      uint64
+	filtered     uint64
+	dropped      uint64
+	errors       uint64
+}
+
+// ConsumerOption is an option of a consumer, see Subscription.NewConsumer
+type ConsumerOption func(c *Consumer)
+
+// WithConsumerQueueSize will queue up to size messages for the consumer (DefaultConsumerQueueSize is default)
+func WithConsumerQueueSize(size int) ConsumerOption {
+	return func(c *Consumer) {
+		if size > 0 {
+			c.queueSize = size
+		}
+	}
+}
+
+// WithConsumerOverflowPolicy will set what happens to messages arriving while the queue of the consumer is full,
+// the overflow policy of the subscription is default. Dropped messages are counted in the stats of the consumer.
+func WithConsumerOverflowPolicy(policy OverflowPolicy) ConsumerOption {
+	return func(c *Consumer) {
+		c.overflowPolicy = policy
+	}
+}
+
+// WithConsumerFilter will only pass the transactions the filter passes on to the consumer, on top of the filter of
+// the subscription. Filters given more than once must all pass a transaction.
+func WithConsumerFilter(filter Filter) ConsumerOption {
+	return func(c *Consumer) {
+		if c.filter != nil && filter != nil {
+			c.filter = And(c.filter, filter)
+		} else if filter != nil {
+			c.filter = filter
+		}
+	}
+}
+
+// NewConsumer registers an additional event handler fed from the stream of the subscription, so several components
+// can share one connection. The consumer is passed the transactions the subscription passes on, after its filter
+// and deduplication, and the control messages of the server, on a queue and goroutine of its own: a slow or
+// failing consumer does not hold up the subscription or the other consumers, unless OverflowPolicyBlock is used.
+// Panics of its callbacks are recovered and sent to its OnError.
+//
+// Consumers see the channels the subscription streams: OnMempool is only called when the event handler of the
+// subscription handles the mempool. Consumers must not modify the transactions, they are shared. The name must be
+// unique within the subscription. Consumers can not be used with WithPooledMessages.
+func (s *Subscription) NewConsumer(name string, eventHandler EventHandler, opts ...ConsumerOption) (*Consumer,
+	error) {
+
+	if s == nil || s.isStopped() {
+		return nil, ErrNotSubscribed
+	}
+	if eventHandler.OnTransaction == nil && eventHandler.OnMempool == nil {
+		return nil, ErrNoHandlers
+	}
+	if s.pooled {
+		return nil, ErrConsumerPooled
+	}
+	c := &Consumer{
+		Name:           name,
+		subscription:   s,
+		eventHandler:   eventHandler,
+		queueSize:      DefaultConsumerQueueSize,
+		overflowPolicy: s.overflowPolicy,
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.queue = newMessageQueue(c.queueSize, c.overflowPolicy, func() {
+		atomic.AddUint64(&c.counters.dropped, 1)
+	})
+
+	s.consumersMu.Lock()
+	if _, ok := s.consumers[name]; ok {
+		s.consumersMu.Unlock()
+		return nil, ErrConsumerExists
+	}
+	if s.consumers == nil {
+		s.consumers = make(map[string]*Consumer)
+	}
+	s.consumers[name] = c
+	s.consumersMu.Unlock()
+	if s.isStopped() {
+		// torn down meanwhile, the consumers may have been closed already
+		s.closeConsumers()
+		return nil, ErrNotSubscribed
+	}
+
+	go c.handleQueue()
+	s.log(levelDebug, "consumer added", "consumer", name)
+	return c, nil
+}
+
+// Consumers returns the names of the consumers of the subscription
+func (s *Subscription) Consumers() []string {
+	s.consumersMu.Lock()
+	defer s.consumersMu.Unlock()
+	names := make([]string, 0, len(s.consumers))
+	for name := range s.consumers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Close removes the consumer from its subscription, messages still queued for it are discarded. Closing the last
+// consumer of the subscription unsubscribes it, closing the connection. ErrNotSubscribed is returned when the
+// consumer was closed already.
+func (c *Consumer) Close() error {
+	s := c.subscription
+	s.consumersMu.Lock()
+	if s.consumers[c.Name] != c {
+		s.consumersMu.Unlock()
+		return ErrNotSubscribed
+	}
+	delete(s.consumers, c.Name)
+	last := len(s.consumers) == 0
+	s.consumersMu.Unlock()
+
+	c.stop()
+	s.log(levelDebug, "consumer removed", "consumer", c.Name)
+	if last {
+		if err := s.Unsubscribe(); err != nil && err != ErrNotSubscribed {
+			return err
+		}
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the consumer was closed or its subscription torn down
+func (c *Consumer) Done() <-chan struct{} {
+	return c.done
+}
+
+// Stats returns a snapshot of the statistics of the consumer
+func (c *Consumer) Stats() ConsumerStats {
+	return ConsumerStats{
+		TransactionsReceived: atomic.LoadUint64(&c.counters.transactions),
+		MempoolReceived:      atomic.LoadUint64(&c.counters.mempool),
+		ControlReceived:      atomic.LoadUint64(&c.counters.control),
+		Filtered:             atomic.LoadUint64(&c.counters.filtered),
+		DroppedMessages:      atomic.LoadUint64(&c.counters.dropped),
+		Errors:               atomic.LoadUint64(&c.counters.errors),
+		QueueDepth:           c.queue.len(),
+		Lag:                  c.lag.stats(),
+	}
+}
+
+// ConsumerStats returns a snapshot of the statistics of the consumers of the subscription by name
+func (s *Subscription) ConsumerStats() map[string]ConsumerStats {
+	s.consumersMu.Lock()
+	defer s.consumersMu.Unlock()
+	stats := make(map[string]ConsumerStats, len(s.consumers))
+	for name, c := range s.consumers {
+		stats[name] = c.Stats()
+	}
+	return stats
+}
+
+func (c *Consumer) stop() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// handleQueue calls the event handler of the consumer for the queued messages until it is closed
+func (c *Consumer) handleQueue() {
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		if fn, ok := c.queue.pop(); ok {
+			fn()
+			continue
+		}
+		select {
+		case <-c.done:
+			return
+		case <-c.queue.ready:
+		}
+	}
+}
+
+// call runs the callback of the consumer, recovering a panic of it
+func (c *Consumer) call(handler string, fn func()) {
+	defer c.subscription.recoverPanic(c.Name+"."+handler, c.onError)
+	fn()
+}
+
+func (c *Consumer) onError(err error) {
+	atomic.AddUint64(&c.counters.errors, 1)
+	if c.eventHandler.OnError != nil {
+		defer c.subscription.recoverPanic("OnError", nil)
+		c.eventHandler.OnError(err)
+	}
+}
+
+// enqueue queues a message for the consumer, the overflow policy only drops transactions
+func (c *Consumer) enqueue(fn func(), transaction bool) {
+	c.queue.push(fn, transaction, c.done, nil)
+}
+
+// transaction queues a transaction of the subscription for the consumer
+func (c *Consumer) transaction(ctx TxContext, tx *models.TransactionResponse) {
+	onTransaction := c.eventHandler.OnTransaction
+	if ctx.Mempool {
+		onTransaction = c.eventHandler.OnMempool
+	}
+	if onTransaction == nil {
+		return
+	}
+	if c.filter != nil && !c.filter(tx) {
+		atomic.AddUint64(&c.counters.filtered, 1)
+		return
+	}
+	if ctx.Mempool {
+		atomic.AddUint64(&c.counters.mempool, 1)
+		c.enqueue(func() { c.call("OnMempool", func() { onTransaction(tx) }) }, true)
+		return
+	}
+	atomic.AddUint64(&c.counters.transactions, 1)
+	c.lag.received(ctx.Block, ctx.Offset)
+	c.enqueue(func() {
+		c.call("OnTransaction", func() { onTransaction(tx) })
+		c.lag.processed(ctx.Block, ctx.Offset)
+	}, true)
+}
+
+// control queues a control message of the server for the consumer
+func (c *Consumer) control(controlResponse *models.ControlResponse) {
+	atomic.AddUint64(&c.counters.control, 1)
+	c.lag.received(controlResponse.Block, 0)
+	code := StatusCode(controlResponse.StatusCode)
+	c.enqueue(func() {
+		switch {
+		case code.IsBlockDone() && c.eventHandler.OnBlockDone != nil:
+			c.call("OnBlockDone", func() {
+				c.eventHandler.OnBlockDone(controlResponse.Block, controlResponse.Transactions)
+			})
+		case code.IsReorg() && c.eventHandler.OnReorg != nil:
+			c.call("OnReorg", func() { c.eventHandler.OnReorg(controlResponse.Block) })
+		case c.eventHandler.OnStatus != nil:
+			c.call("OnStatus", func() { c.eventHandler.OnStatus(controlResponse) })
+		}
+		c.lag.processed(controlResponse.Block, 0)
+	}, false)
+}
+
+// fanOut passes a message of the subscription on to its consumers
+func (s *Subscription) fanOut(fn func(c *Consumer)) {
+	s.consumersMu.Lock()
+	if len(s.consumers) == 0 {
+		s.consumersMu.Unlock()
+		return
+	}
+	consumers := make([]*Consumer, 0, len(s.consumers))
+	for _, c := range s.consumers {
+		consumers = append(consumers, c)
+	}
+	s.consumersMu.Unlock()
+
+	// a blocking consumer is unblocked by closing it
+	for _, c := range consumers {
+		fn(c)
+	}
+}
+
+// closeConsumers stops the consumers once the subscription has been torn down
+func (s *Subscription) closeConsumers() {
+	s.consumersMu.Lock()
+	defer s.consumersMu.Unlock()
+	for name, c := range s.consumers {
+		c.stop()
+		delete(s.consumers, name)
+	}
+}
@@ -0,0 +1,143 @@
+package junglebus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscription_NewConsumer will test feeding several consumers from one subscription
+func TestSubscription_NewConsumer(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server := newFakeServer(t)
+		primary := &txRecorder{}
+		subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: primary.onTransaction,
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(mainChannel)
+
+		indexer := &txRecorder{}
+		var blocks []uint32
+		var blocksMu sync.Mutex
+		indexerConsumer, err := subscription.NewConsumer("indexer", EventHandler{
+			OnTransaction: indexer.onTransaction,
+			OnBlockDone: func(height uint32, _ uint64) {
+				blocksMu.Lock()
+				defer blocksMu.Unlock()
+				blocks = append(blocks, height)
+			},
+		})
+		require.NoError(t, err)
+		notifier := &txRecorder{}
+		notifierConsumer, err := subscription.NewConsumer("notifier", EventHandler{
+			OnTransaction: notifier.onTransaction,
+		}, WithConsumerFilter(func(tx *models.TransactionResponse) bool {
+			return tx.Id != "tx-2"
+		}))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"indexer", "notifier"}, subscription.Consumers())
+
+		_, err = subscription.NewConsumer("indexer", EventHandler{OnTransaction: indexer.onTransaction})
+		assert.ErrorIs(t, err, ErrConsumerExists)
+		_, err = subscription.NewConsumer("metrics", EventHandler{})
+		assert.ErrorIs(t, err, ErrNoHandlers)
+
+		for i := 1; i <= 3; i++ {
+			server.publishTransaction(mainChannel, "tx-"+strconv.Itoa(i))
+		}
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+		require.Eventually(t, func() bool {
+			blocksMu.Lock()
+			defer blocksMu.Unlock()
+			return len(primary.received()) == 3 && len(indexer.received()) == 3 && len(notifier.received()) == 2 &&
+				len(blocks) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"tx-1", "tx-2", "tx-3"}, indexer.received())
+		assert.Equal(t, []string{"tx-1", "tx-3"}, notifier.received())
+
+		stats := notifierConsumer.Stats()
+		assert.Equal(t, uint64(2), stats.TransactionsReceived)
+		assert.Equal(t, uint64(1), stats.Filtered)
+		assert.Equal(t, uint64(1), stats.ControlReceived)
+
+		t.Run("slow consumer", func(t *testing.T) {
+			release := make(chan struct{})
+			slow, err := subscription.NewConsumer("slow", EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {
+					<-release
+				},
+			}, WithConsumerQueueSize(1), WithConsumerOverflowPolicy(OverflowPolicyDropNewest))
+			require.NoError(t, err)
+			defer func() {
+				close(release)
+				require.NoError(t, slow.Close())
+			}()
+
+			for i := 4; i <= 8; i++ {
+				server.publishTransaction(mainChannel, "tx-"+strconv.Itoa(i))
+			}
+			require.Eventually(t, func() bool {
+				return len(primary.received()) == 8 && len(indexer.received()) == 8
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.NotZero(t, slow.Stats().DroppedMessages)
+		})
+
+		t.Run("panicking consumer", func(t *testing.T) {
+			errs := make(chan error, 1)
+			failing, err := subscription.NewConsumer("failing", EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {
+					panic("boom")
+				},
+				OnError: func(err error) {
+					errs <- err
+				},
+			})
+			require.NoError(t, err)
+			server.publishTransaction(mainChannel, "tx-9")
+			select {
+			case err := <-errs:
+				var panicErr *PanicError
+				require.ErrorAs(t, err, &panicErr)
+				assert.Equal(t, "boom", panicErr.Value)
+			case <-time.After(5 * time.Second):
+				t.Fatal("panic not reported")
+			}
+			assert.Equal(t, uint64(1), failing.Stats().Errors)
+			require.Eventually(t, func() bool {
+				return len(indexer.received()) == 9
+			}, 5*time.Second, 10*time.Millisecond)
+			require.NoError(t, failing.Close())
+		})
+
+		t.Run("closing the last consumer", func(t *testing.T) {
+			require.NoError(t, notifierConsumer.Close())
+			assert.ErrorIs(t, notifierConsumer.Close(), ErrNotSubscribed)
+			assert.Equal(t, []string{"indexer"}, subscription.Consumers())
+			select {
+			case <-subscription.Done():
+				t.Fatal("the subscription is still used by a consumer")
+			default:
+			}
+
+			require.NoError(t, indexerConsumer.Close())
+			select {
+			case <-subscription.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("subscription not torn down")
+			}
+			<-indexerConsumer.Done()
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package junglebus
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/jpillora/backoff"
+)
+
+// Defaults of the retries of OnTransactionE and OnMempoolE, see WithHandlerRetry
+const (
+	DefaultHandlerRetryAttempts = 3
+	DefaultHandlerRetryMinDelay = 100 * time.Millisecond
+	DefaultHandlerRetryMaxDelay = 5 * time.Second
+)
+
+// handlerRetryPolicy defines how often a failing transaction is handled before it is dead-lettered
+type handlerRetryPolicy struct {
+	attempts int // including the first one
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+// delay returns how long to wait before retrying after the given failed attempt, counting from 0
+func (p handlerRetryPolicy) delay(attempt int) time.Duration {
+	b := &backoff.Backoff{
+		Min:    p.minDelay,
+		Max:    p.maxDelay,
+		Factor: 2,
+	}
+	return b.ForAttempt(float64(attempt))
+}
+
+// withRetry returns the event handler with OnTransactionE and OnMempoolE taking the place of OnTransaction and
+// OnMempool, retrying failing transactions and dead-lettering them once the attempts are used up
+func (s *Subscription) withRetry(eventHandler EventHandler) EventHandler {
+	if onTransaction := eventHandler.OnTransactionE; onTransaction != nil {
+		eventHandler.OnTransaction = func(tx *models.TransactionResponse) {
+			s.handleWithRetry("OnTransactionE", tx, onTransaction)
+		}
+	}
+	if onMempool := eventHandler.OnMempoolE; onMempool != nil {
+		eventHandler.OnMempool = func(tx *models.TransactionResponse) {
+			s.handleWithRetry("OnMempoolE", tx, onMempool)
+		}
+	}
+	return eventHandler
+}
+
+// handleWithRetry calls fn until it succeeds or the attempts of the retry policy are used up, the transaction is then
+// passed to OnDeadLetter. Retrying stops without dead-lettering when the subscription is torn down meanwhile.
+func (s *Subscription) handleWithRetry(handler string, tx *models.TransactionResponse,
+	fn func(tx *models.TransactionResponse) error) {
+
+	policy := s.handlerRetry
+	var err error
+	for attempt := 0; attempt < policy.attempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(policy.delay(attempt - 1))
+			select {
+			case <-s.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if err = tryHandle(handler, tx, fn); err == nil {
+			return
+		}
+		s.log(levelWarn, "handler failed", "handler", handler, "txid", tx.Id, "attempt", attempt+1, "error", err)
+	}
+
+	atomic.AddUint64(&s.counters.deadLettered, 1)
+	s.log(levelError, "dead-lettered transaction", "handler", handler, "txid", tx.Id, "error", err)
+	if s.EventHandler.OnDeadLetter == nil {
+		s.EventHandler.OnError(&DeadLetterError{TxID: tx.Id, Attempts: policy.attempts, Err: err})
+		return
+	}
+	defer s.recoverPanic("OnDeadLetter", s.EventHandler.OnError)
+	s.EventHandler.OnDeadLetter(tx, err)
+}
+
+// tryHandle calls fn, returning a panic of fn as a PanicError
+func tryHandle(handler string, tx *models.TransactionResponse, fn func(tx *models.TransactionResponse) error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Handler: handler, Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return fn(tx)
+}
@@ -0,0 +1,137 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribe_OnDeadLetter will test retrying failing transactions and dead-lettering them before the block is done
+func TestSubscribe_OnDeadLetter(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		errBad := errors.New("bad transaction")
+		var mu sync.Mutex
+		attempts := map[string]int{}
+		var events []string
+		deadLetters := map[string]error{}
+		blockDone := make(chan struct{})
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransactionE: func(tx *models.TransactionResponse) error {
+				mu.Lock()
+				attempts[tx.Id]++
+				attempt := attempts[tx.Id]
+				mu.Unlock()
+				switch {
+				case tx.Id == "bad":
+					return errBad
+				case tx.Id == "panic":
+					panic("boom")
+				case tx.Id == "flaky" && attempt == 1:
+					return errBad
+				}
+				mu.Lock()
+				events = append(events, "handled "+tx.Id)
+				mu.Unlock()
+				return nil
+			},
+			OnDeadLetter: func(tx *models.TransactionResponse, err error) {
+				mu.Lock()
+				events = append(events, "dead-lettered "+tx.Id)
+				deadLetters[tx.Id] = err
+				mu.Unlock()
+			},
+			OnBlockDone: func(uint32, uint64) {
+				mu.Lock()
+				events = append(events, "block done")
+				mu.Unlock()
+				close(blockDone)
+			},
+			OnStatus: recorder.onStatus,
+			OnError:  recorder.onError,
+		}, WithHandlerRetry(3, time.Millisecond, time.Millisecond), WithHandlerConcurrency(4))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		for _, id := range []string{"bad", "flaky", "panic", "good"} {
+			server.publishTransaction(mainChannel, id)
+		}
+		server.publishMessage("query:"+testSubscriptionID+":control", &models.ControlResponse{
+			StatusCode: uint32(SubscriptionBlockDone),
+			Block:      100,
+		})
+
+		select {
+		case <-blockDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("block not done")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		assert.ElementsMatch(t, []string{"dead-lettered bad", "handled flaky", "dead-lettered panic", "handled good",
+			"block done"}, events)
+		assert.Equal(t, "block done", events[len(events)-1])
+		assert.Equal(t, map[string]int{"bad": 3, "flaky": 2, "panic": 3, "good": 1}, attempts)
+		assert.ErrorIs(t, deadLetters["bad"], errBad)
+		var panicErr *PanicError
+		require.ErrorAs(t, deadLetters["panic"], &panicErr)
+		assert.Equal(t, "OnTransactionE", panicErr.Handler)
+		assert.Equal(t, uint64(2), subscription.Stats().DeadLettered)
+		assert.Empty(t, recorder.errors)
+	})
+}
+
+// TestSubscription_handleWithRetry will test dead-lettering without OnDeadLetter and stopping on teardown
+func TestSubscription_handleWithRetry(t *testing.T) {
+	errBad := errors.New("bad transaction")
+	failing := func(*models.TransactionResponse) error { return errBad }
+	client, err := New(WithHTTP("localhost"))
+	require.NoError(t, err)
+
+	t.Run("without OnDeadLetter", func(t *testing.T) {
+		recorder := &statusRecorder{}
+		s := &Subscription{
+			client:       client,
+			EventHandler: EventHandler{OnError: recorder.onError},
+			done:         make(chan struct{}),
+			handlerRetry: handlerRetryPolicy{attempts: 2},
+		}
+		s.handleWithRetry("OnMempoolE", &models.TransactionResponse{Id: "bad"}, failing)
+
+		require.Len(t, recorder.errors, 1)
+		var deadLetterErr *DeadLetterError
+		require.ErrorAs(t, recorder.errors[0], &deadLetterErr)
+		assert.Equal(t, "bad", deadLetterErr.TxID)
+		assert.Equal(t, 2, deadLetterErr.Attempts)
+		assert.ErrorIs(t, deadLetterErr, errBad)
+		assert.Equal(t, uint64(1), s.Stats().DeadLettered)
+	})
+
+	t.Run("torn down while retrying", func(t *testing.T) {
+		recorder := &statusRecorder{}
+		s := &Subscription{
+			client:       client,
+			EventHandler: EventHandler{OnError: recorder.onError},
+			done:         make(chan struct{}),
+			handlerRetry: handlerRetryPolicy{attempts: 3, minDelay: time.Hour, maxDelay: time.Hour},
+		}
+		s.stop()
+		s.handleWithRetry("OnTransactionE", &models.TransactionResponse{Id: "bad"}, failing)
+
+		assert.Empty(t, recorder.errors)
+		assert.Zero(t, s.Stats().DeadLettered)
+	})
+}
@@ -0,0 +1,98 @@
+package junglebus
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// txCache keeps the decoded forms of a transaction for the middlewares and handlers of the transaction
+type txCache struct {
+	mu      sync.Mutex
+	decoded map[reflect.Type]decodedTx
+}
+
+type decodedTx struct {
+	tx  interface{}
+	err error
+}
+
+// DecodeTx returns the raw transaction of tx decoded with decode, like the transaction parser of a Bitcoin SDK.
+// Within the middlewares of WithTxMiddleware the transaction is decoded once for every type T, the decoded form and
+// the error are shared with the following middlewares and handlers of the transaction.
+func DecodeTx[T any](ctx TxContext, tx *models.TransactionResponse, decode func(raw []byte) (T, error)) (T, error) {
+	if ctx.cache == nil {
+		return decode(tx.Transaction)
+	}
+
+	key := reflect.TypeOf((*T)(nil)).Elem()
+	ctx.cache.mu.Lock()
+	defer ctx.cache.mu.Unlock()
+	if decoded, ok := ctx.cache.decoded[key]; ok {
+		return decoded.tx.(T), decoded.err
+	}
+	decoded, err := decode(tx.Transaction)
+	if ctx.cache.decoded == nil {
+		ctx.cache.decoded = map[reflect.Type]decodedTx{}
+	}
+	ctx.cache.decoded[key] = decodedTx{tx: decoded, err: err}
+	return decoded, err
+}
+
+// DecodeOption is used for the options of DecodedHandler
+type DecodeOption func(o *decodeOptions)
+
+type decodeOptions struct {
+	verifyTxID bool
+}
+
+// WithVerifyTxID will check that the raw transaction hashes to the txid of the message before decoding it
+func WithVerifyTxID() DecodeOption {
+	return func(o *decodeOptions) {
+		o.verifyTxID = true
+	}
+}
+
+// DecodedHandler returns a middleware calling handler with the transaction decoded by decode, see DecodeTx, before
+// passing it on. A transaction that fails to decode is sent to OnError as a TxDecodeError and is passed on without
+// calling handler, as are transactions without their raw transaction in lite mode, see WithLiteMode. Package gobt
+// provides it for go-bt transactions, with another Bitcoin SDK it is used like:
+//
+//	junglebus.WithTxMiddleware(junglebus.DecodedHandler(transaction.NewTransactionFromBytes,
+//		func(ctx junglebus.TxContext, tx *transaction.Transaction, meta *models.TransactionResponse) {
+//			// handle the decoded transaction
+//		}))
+func DecodedHandler[T any](decode func(raw []byte) (T, error),
+	handler func(ctx TxContext, tx T, meta *models.TransactionResponse), opts ...DecodeOption) TxMiddleware {
+
+	options := &decodeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return func(next TxHandler) TxHandler {
+		return func(ctx TxContext, meta *models.TransactionResponse) {
+			if len(meta.Transaction) == 0 {
+				next(ctx, meta)
+				return
+			}
+			var err error
+			if options.verifyTxID && !strings.EqualFold(rawTxID(meta.Transaction), meta.Id) {
+				err = ErrChecksumMismatch
+			}
+			var tx T
+			if err == nil {
+				tx, err = DecodeTx(ctx, meta, decode)
+			}
+			if err != nil {
+				if ctx.onError != nil {
+					ctx.onError(&TxDecodeError{TxID: meta.Id, Err: err})
+				}
+			} else {
+				handler(ctx, tx, meta)
+			}
+			next(ctx, meta)
+		}
+	}
+}
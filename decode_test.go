@@ -0,0 +1,150 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDecodedTx is a transaction decoded by testDecoder, standing in for the transaction of a Bitcoin SDK
+type testDecodedTx struct {
+	values []uint64
+}
+
+// testDecoder returns a decoder counting its calls
+func testDecoder(calls *int64) func(raw []byte) (*testDecodedTx, error) {
+	return func(raw []byte) (*testDecodedTx, error) {
+		atomic.AddInt64(calls, 1)
+		tx := &testDecodedTx{}
+		err := models.EachOutput(raw, func(value uint64, _ []byte) bool {
+			tx.values = append(tx.values, value)
+			return true
+		})
+		return tx, err
+	}
+}
+
+func TestRawTxID(t *testing.T) {
+	// the coinbase transaction of the genesis block
+	raw, err := hex.DecodeString("01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d" +
+		"0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c" +
+		"6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61" +
+		"deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000")
+	require.NoError(t, err)
+	assert.Equal(t, "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", rawTxID(raw))
+}
+
+func TestDecodeTx(t *testing.T) {
+	var calls int64
+	decode := testDecoder(&calls)
+	tx := &models.TransactionResponse{Transaction: newRawTransaction(testOutput{1000, testOpReturn})}
+
+	ctx := TxContext{cache: &txCache{}}
+	for i := 0; i < 3; i++ {
+		decoded, err := DecodeTx(ctx, tx, decode)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1000}, decoded.values)
+	}
+	assert.Equal(t, int64(1), calls, "decoded once per context")
+
+	_, err := DecodeTx(TxContext{}, tx, decode)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), calls, "decoded every time without a context")
+
+	ctx = TxContext{cache: &txCache{}}
+	malformed := &models.TransactionResponse{Transaction: []byte{1, 2}}
+	for i := 0; i < 2; i++ {
+		_, err = DecodeTx(ctx, malformed, decode)
+		assert.ErrorIs(t, err, ErrMalformedTransaction)
+	}
+	assert.Equal(t, int64(3), calls, "errors are kept as well")
+}
+
+// TestSubscribe_DecodedHandler will test decoding transactions once for the middlewares and handlers
+func TestSubscribe_DecodedHandler(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		var calls int64
+		decode := testDecoder(&calls)
+		decoded := make(chan []uint64, 3)
+		handled := make(chan string, 3)
+		errs := make(chan error, 3)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { handled <- tx.Id },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(err error) { errs <- err },
+		}, WithTxMiddleware(
+			DecodedHandler(decode, func(ctx TxContext, tx *testDecodedTx, meta *models.TransactionResponse) {
+				decoded <- tx.values
+			}, WithVerifyTxID()),
+			func(next TxHandler) TxHandler {
+				return func(ctx TxContext, tx *models.TransactionResponse) {
+					_, _ = DecodeTx(ctx, tx, decode)
+					next(ctx, tx)
+				}
+			},
+		))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		raw := newRawTransaction(testOutput{1000, testOpReturn}, testOutput{1, testOpReturn})
+		for _, tx := range []*models.TransactionResponse{
+			{Id: rawTxID(raw), Transaction: raw},
+			{Id: "mismatch", Transaction: raw},
+			{Id: rawTxID(raw[:10]), Transaction: raw[:10]},
+		} {
+			require.NoError(t, server.PublishTransaction(mainChannel, tx))
+		}
+
+		for i := 0; i < 3; i++ {
+			select {
+			case <-handled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not passed on")
+			}
+		}
+		assert.Equal(t, []uint64{1000, 1}, <-decoded)
+		assert.Empty(t, decoded)
+		// the mismatched transaction is only decoded by the second middleware
+		assert.Equal(t, int64(3), atomic.LoadInt64(&calls), "a transaction is decoded once")
+
+		for _, expected := range []error{ErrChecksumMismatch, ErrMalformedTransaction} {
+			err := <-errs
+			var decodeErr *TxDecodeError
+			require.True(t, errors.As(err, &decodeErr))
+			assert.ErrorIs(t, err, expected)
+		}
+	})
+}
+
+// BenchmarkDecodedHandler decodes a transaction for a middleware and the decoded handler, reporting the decodes
+func BenchmarkDecodedHandler(b *testing.B) {
+	var calls int64
+	decode := testDecoder(&calls)
+	handler := DecodedHandler(decode, func(TxContext, *testDecodedTx, *models.TransactionResponse) {})(
+		func(ctx TxContext, tx *models.TransactionResponse) {
+			_, _ = DecodeTx(ctx, tx, decode)
+		})
+	tx := &models.TransactionResponse{
+		Transaction: newRawTransaction(testOutput{1000, p2pkhScript(b, testAddress)}, testOutput{0, testOpReturn}),
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler(TxContext{cache: &txCache{}}, tx)
+	}
+	b.ReportMetric(float64(calls)/float64(b.N), "decodes/op")
+}
@@ -0,0 +1,190 @@
+package junglebus
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultDedupSize is the number of txids remembered by WithDedupTTL when WithDedup is not given
+const DefaultDedupSize = 10000
+
+// dedupKey is a txid delivered on a channel
+type dedupKey struct {
+	channel string
+	txID    string
+}
+
+type dedupEntry struct {
+	key dedupKey
+	at  time.Time
+}
+
+// dedupCache remembers the last size txids per channel, and for at most ttl when it is set
+type dedupCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration // 0 keeps txids until they are evicted by newer ones
+	entries map[dedupKey]*list.Element
+	order   *list.List // most recent first
+}
+
+func newDedupCache(size int, ttl time.Duration) *dedupCache {
+	return &dedupCache{size: size, ttl: ttl, entries: map[dedupKey]*list.Element{}, order: list.New()}
+}
+
+// seen returns whether the txid was already delivered on the channel, remembering it otherwise
+func (c *dedupCache) seen(channel, txID string) bool {
+	key := dedupKey{channel: channel, txID: txID}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.containsLocked(key, now) {
+		return true
+	}
+	c.addLocked(key, now)
+	return false
+}
+
+// contains returns whether the txid was delivered on the channel, without remembering it, see add
+func (c *dedupCache) contains(channel, txID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.containsLocked(dedupKey{channel: channel, txID: txID}, time.Now())
+}
+
+// add remembers that the txid was delivered on the channel
+func (c *dedupCache) add(channel, txID string) {
+	key := dedupKey{channel: channel, txID: txID}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.containsLocked(key, now) {
+		c.addLocked(key, now)
+	}
+}
+
+// containsLocked forgets the expired txids and returns whether the key is remembered, refreshing it
+func (c *dedupCache) containsLocked(key dedupKey, now time.Time) bool {
+	if c.ttl > 0 {
+		// expired txids are at the back
+		for back := c.order.Back(); back != nil && now.Sub(back.Value.(*dedupEntry).at) >= c.ttl; back = c.order.Back() {
+			delete(c.entries, c.order.Remove(back).(*dedupEntry).key)
+		}
+	}
+	element, ok := c.entries[key]
+	if ok {
+		element.Value.(*dedupEntry).at = now
+		c.order.MoveToFront(element)
+	}
+	return ok
+}
+
+// addLocked remembers the key, evicting the oldest one when the cache is full
+func (c *dedupCache) addLocked(key dedupKey, now time.Time) {
+	c.entries[key] = c.order.PushFront(&dedupEntry{key: key, at: now})
+	if c.order.Len() > c.size {
+		delete(c.entries, c.order.Remove(c.order.Back()).(*dedupEntry).key)
+	}
+}
+
+// reset forgets all txids
+func (c *dedupCache) reset() {
+	c.mu.Lock()
+	c.entries = map[dedupKey]*list.Element{}
+	c.order.Init()
+	c.mu.Unlock()
+}
+
+// duplicate returns whether the transaction was already handled on the channel of the subscription, counting it.
+// Transactions are remembered once handled, see withDedup.
+func (s *Subscription) duplicate(name, txID string) bool {
+	if s.dedup == nil || !s.dedup.contains(name, txID) {
+		return false
+	}
+	atomic.AddUint64(&s.counters.duplicates, 1)
+	if logEnabled(s.client.logger, levelDebug) {
+		s.log(levelDebug, "duplicate suppressed", "channel", name, "txid", txID)
+	}
+	return true
+}
+
+// withDedup returns the event handler remembering the transactions its callbacks handled for WithDedup. A
+// transaction is only remembered once its callback returned, without an error for OnTransactionE and OnMempoolE: a
+// transaction whose callback failed or panicked is delivered again when it arrives again.
+func (s *Subscription) withDedup(eventHandler EventHandler) EventHandler {
+	handled := eventHandler
+	if onTransaction := eventHandler.OnTransaction; onTransaction != nil {
+		handled.OnTransaction = func(tx *models.TransactionResponse) {
+			txID := tx.Id // the transaction may be released by the callback
+			onTransaction(tx)
+			s.dedup.add(channelMain, txID)
+		}
+	}
+	if onMempool := eventHandler.OnMempool; onMempool != nil {
+		handled.OnMempool = func(tx *models.TransactionResponse) {
+			txID := tx.Id
+			onMempool(tx)
+			s.dedup.add(channelMempool, txID)
+		}
+	}
+	if onTransaction := eventHandler.OnTransactionE; onTransaction != nil {
+		handled.OnTransactionE = func(tx *models.TransactionResponse) error {
+			txID := tx.Id
+			if err := onTransaction(tx); err != nil {
+				return err
+			}
+			s.dedup.add(channelMain, txID)
+			return nil
+		}
+	}
+	if onMempool := eventHandler.OnMempoolE; onMempool != nil {
+		handled.OnMempoolE = func(tx *models.TransactionResponse) error {
+			txID := tx.Id
+			if err := onMempool(tx); err != nil {
+				return err
+			}
+			s.dedup.add(channelMempool, txID)
+			return nil
+		}
+	}
+	if onTransaction := eventHandler.OnTransactionCtx; onTransaction != nil {
+		handled.OnTransactionCtx = func(ctx MessageContext, tx *models.TransactionResponse) {
+			txID := tx.Id
+			onTransaction(ctx, tx)
+			s.dedup.add(channelMain, txID)
+		}
+	}
+	if onMempool := eventHandler.OnMempoolCtx; onMempool != nil {
+		handled.OnMempoolCtx = func(ctx MessageContext, tx *models.TransactionResponse) {
+			txID := tx.Id
+			onMempool(ctx, tx)
+			s.dedup.add(channelMempool, txID)
+		}
+	}
+	if onBlock := eventHandler.OnBlock; onBlock != nil {
+		handled.OnBlock = func(height uint32, transactions []*models.TransactionResponse) {
+			txIDs := make([]string, len(transactions))
+			for i, tx := range transactions {
+				txIDs[i] = tx.Id
+			}
+			onBlock(height, transactions)
+			for _, txID := range txIDs {
+				s.dedup.add(channelMain, txID)
+			}
+		}
+	}
+	return handled
+}
+
+// ResetDedup forgets the txids remembered by WithDedup, they are delivered again when they arrive
+func (s *Subscription) ResetDedup() {
+	if s != nil && s.dedup != nil {
+		s.dedup.reset()
+	}
+}
@@ -0,0 +1,142 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupCache(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		cache := newDedupCache(2, 0)
+		assert.False(t, cache.seen(channelMain, "a"))
+		assert.True(t, cache.seen(channelMain, "a"))
+		assert.False(t, cache.seen(channelMempool, "a"), "channels are kept apart")
+		assert.False(t, cache.seen(channelMain, "b")) // evicts a of the main channel
+		assert.False(t, cache.seen(channelMain, "a"))
+		assert.Len(t, cache.entries, 2)
+
+		cache.reset()
+		assert.False(t, cache.seen(channelMain, "a"))
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		cache := newDedupCache(10, 20*time.Millisecond)
+		assert.False(t, cache.seen(channelMain, "a"))
+		assert.True(t, cache.seen(channelMain, "a"))
+		time.Sleep(30 * time.Millisecond)
+		assert.False(t, cache.seen(channelMain, "a"))
+		assert.Len(t, cache.entries, 1)
+	})
+}
+
+// TestSubscribe_WithDedup will test suppressing transactions delivered again after a reconnect
+func TestSubscribe_WithDedup(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		transactions := make(chan string, 10)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- "mined " + tx.Id },
+			OnMempool:     func(tx *models.TransactionResponse) { transactions <- "mempool " + tx.Id },
+			OnStatus:      func(*models.ControlResponse) {},
+		}, WithDedup(100))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+		next := func() string {
+			select {
+			case tx := <-transactions:
+				return tx
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+				return ""
+			}
+		}
+
+		server.publishTransaction(mempoolChannel, "a")
+		server.publishTransaction(mainChannel, "a")
+		server.publishTransaction(mainChannel, "a")
+		server.publishTransaction(mainChannel, "b")
+		assert.Equal(t, "mempool a", next())
+		assert.Equal(t, "mined a", next())
+		assert.Equal(t, "mined b", next())
+
+		dials := len(server.dialTimes())
+		server.disconnectAll()
+		require.Eventually(t, func() bool {
+			return len(server.dialTimes()) > dials && server.subscribed(mainChannel)
+		}, 5*time.Second, 10*time.Millisecond)
+		server.publishTransaction(mainChannel, "a")
+		server.publishTransaction(mainChannel, "c")
+		assert.Equal(t, "mined c", next(), "a is suppressed after reconnecting")
+		assert.Equal(t, uint64(2), subscription.Stats().DuplicatesSuppressed)
+
+		subscription.ResetDedup()
+		server.publishTransaction(mainChannel, "a")
+		assert.Equal(t, "mined a", next())
+	})
+}
+
+// TestSubscribe_WithDedupFailed will test delivering a transaction again when its handler failed or panicked
+func TestSubscribe_WithDedupFailed(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		transactions := make(chan string, 10)
+		var calls int
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransactionE: func(tx *models.TransactionResponse) error {
+				calls++
+				transactions <- tx.Id
+				switch calls {
+				case 1:
+					return errors.New("handler failed")
+				case 2:
+					panic("handler panicked")
+				}
+				return nil
+			},
+			OnStatus:     func(*models.ControlResponse) {},
+			OnDeadLetter: func(*models.TransactionResponse, error) {},
+		}, WithDedup(100), WithHandlerRetry(1, 0, 0))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		next := func() string {
+			select {
+			case tx := <-transactions:
+				return tx
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+				return ""
+			}
+		}
+
+		for _, txID := range []string{"a", "a", "a", "a", "b"} {
+			server.publishTransaction(mainChannel, txID)
+		}
+		assert.Equal(t, "a", next())
+		assert.Equal(t, "a", next(), "a is delivered again after its handler failed")
+		assert.Equal(t, "a", next(), "a is delivered again after its handler panicked")
+		assert.Equal(t, "b", next(), "a is suppressed once handled")
+		assert.Equal(t, uint64(1), subscription.Stats().DuplicatesSuppressed)
+	})
+}
@@ -0,0 +1,113 @@
+package junglebus
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// drainPollInterval is how often Shutdown checks whether the running publication callbacks returned
+const drainPollInterval = 5 * time.Millisecond
+
+// Shutdown tears the subscription down gracefully: publications arriving from now on are ignored, the messages
+// already received are handled, the checkpoint is saved and only then the connection is closed. It returns the
+// number of messages handled while draining. When ctx expires first, the remaining messages are discarded, the
+// checkpoint is not saved and the context error is returned. The subscription is torn down either way.
+//
+// Transactions collected for OnBlock of a block that is not done yet are not passed on, the checkpoint still
+// points at the start of that block so it is streamed again when resuming.
+func (s *Subscription) Shutdown(ctx context.Context) (int, error) {
+	if s == nil {
+		return 0, ErrNotSubscribed
+	}
+	atomic.StoreInt32(&s.draining, 1)
+	s.log(levelDebug, "draining subscription", "block", s.LastBlock())
+
+	drained, err := s.drain(ctx)
+	if err == nil && s.checkpointStore != nil {
+		checkpoint := s.Checkpoint()
+		if err = s.saveCheckpoint(ctx, checkpoint); err != nil {
+			s.log(levelWarn, "saving checkpoint failed", "block", checkpoint.Block, "error", err)
+		}
+	}
+	if unsubscribeErr := s.Unsubscribe(); err == nil {
+		err = unsubscribeErr
+	}
+	return drained, err
+}
+
+// drain waits until the publication callbacks, the queue and the handler workers are done, in that order, returning
+// the number of messages they handled meanwhile
+func (s *Subscription) drain(ctx context.Context) (int, error) {
+	drained := 0
+	if s.queue == nil {
+		// without a queue the publication callbacks call the event handler themselves
+		drained += int(atomic.LoadInt64(&s.counters.inFlight))
+	}
+	if s.handlerWorkers != nil {
+		drained += len(s.handlerWorkers)
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&s.counters.inFlight) > 0 {
+		select {
+		case <-ctx.Done():
+			return drained, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	if s.queue != nil {
+		// the queue is handled in order, everything queued before the marker is handled once the marker runs
+		queued := make(chan int, 1)
+		marker := func() {
+			queued <- int(atomic.LoadUint64(&s.counters.drained))
+		}
+		if !s.queue.push(marker, false, s.done, ctx.Done()) {
+			if ctx.Err() != nil {
+				return drained + int(atomic.LoadUint64(&s.counters.drained)), ctx.Err()
+			}
+			return drained, nil
+		}
+		select {
+		case n := <-queued:
+			drained += n
+		case <-s.done:
+			return drained + int(atomic.LoadUint64(&s.counters.drained)), nil
+		case <-ctx.Done():
+			return drained + int(atomic.LoadUint64(&s.counters.drained)), ctx.Err()
+		}
+	}
+
+	if s.handlerWorkers != nil {
+		// taking every worker slot waits for the running handlers, the slots are given back right away
+		for i := 0; i < cap(s.handlerWorkers); i++ {
+			select {
+			case s.handlerWorkers <- struct{}{}:
+				defer func() {
+					<-s.handlerWorkers
+				}()
+			case <-ctx.Done():
+				return drained, ctx.Err()
+			}
+		}
+	}
+	return drained, nil
+}
+
+// acceptPublication registers a running publication callback for Shutdown, it returns false once the subscription
+// is draining and the publication has to be ignored. Accepted publications call donePublication when they return.
+func (s *Subscription) acceptPublication() bool {
+	atomic.AddInt64(&s.counters.inFlight, 1)
+	if atomic.LoadInt32(&s.draining) == 1 {
+		atomic.AddInt64(&s.counters.inFlight, -1)
+		return false
+	}
+	return true
+}
+
+// donePublication marks a publication callback accepted by acceptPublication as returned
+func (s *Subscription) donePublication() {
+	atomic.AddInt64(&s.counters.inFlight, -1)
+}
@@ -0,0 +1,152 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscription_Shutdown will test draining the received messages before closing the connection
+func TestSubscription_Shutdown(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		tests := []struct {
+			name    string
+			opts    []SubscribeOption
+			drained int
+		}{
+			{name: "synchronous", drained: 1},
+			{name: "queue", opts: []SubscribeOption{WithQueueSize(10)}, drained: 4},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				server := newFakeServer(t)
+				client := server.newClient()
+
+				store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+				started := make(chan struct{})
+				release := make(chan struct{})
+				var mu sync.Mutex
+				var handled []string
+				recorder := &statusRecorder{}
+				subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+					OnTransaction: func(tx *models.TransactionResponse) {
+						if tx.Id == "tx-1" {
+							close(started)
+							<-release
+						}
+						mu.Lock()
+						handled = append(handled, tx.Id)
+						mu.Unlock()
+					},
+					OnStatus: recorder.onStatus,
+					OnError:  recorder.onError,
+				}, append(test.opts, WithCheckpointStore(store))...)
+				require.NoError(t, err)
+
+				mainChannel := "query:" + testSubscriptionID + ":100"
+				controlChannel := "query:" + testSubscriptionID + ":control"
+				server.waitSubscribed(mainChannel)
+				server.waitSubscribed(controlChannel)
+				server.publishTransaction(mainChannel, "tx-1")
+				<-started
+				if subscription.queue != nil {
+					server.publishTransaction(mainChannel, "tx-2")
+					server.publishTransaction(mainChannel, "tx-3")
+					server.publishMessage(controlChannel, &models.ControlResponse{
+						StatusCode: uint32(SubscriptionWait),
+						Block:      101,
+					})
+					require.Eventually(t, func() bool {
+						return subscription.Stats().QueueDepth == 3
+					}, 5*time.Second, 10*time.Millisecond)
+				}
+
+				type result struct {
+					drained int
+					err     error
+				}
+				shutdown := make(chan result, 1)
+				go func() {
+					drained, err := subscription.Shutdown(context.Background())
+					shutdown <- result{drained, err}
+				}()
+				require.Eventually(t, func() bool {
+					return atomic.LoadInt32(&subscription.draining) == 1
+				}, 5*time.Second, time.Millisecond)
+				select {
+				case <-subscription.Done():
+					t.Fatal("connection closed before the messages were drained")
+				default:
+				}
+				close(release)
+
+				select {
+				case res := <-shutdown:
+					require.NoError(t, res.err)
+					assert.Equal(t, test.drained, res.drained)
+				case <-time.After(5 * time.Second):
+					t.Fatal("shutdown did not return")
+				}
+				<-subscription.Done()
+				mu.Lock()
+				defer mu.Unlock()
+				if subscription.queue != nil {
+					assert.Equal(t, []string{"tx-1", "tx-2", "tx-3"}, handled)
+					assert.Equal(t, Checkpoint{Block: 101}, store.get(testSubscriptionID))
+				} else {
+					assert.Equal(t, []string{"tx-1"}, handled)
+					assert.Equal(t, Checkpoint{Block: 100}, store.get(testSubscriptionID))
+				}
+			})
+		}
+
+		t.Run("context expires", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+
+			store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {
+					close(started)
+					<-release
+				},
+				OnStatus: recorder.onStatus,
+				OnError:  recorder.onError,
+			}, WithCheckpointStore(store), WithHandlerConcurrency(2))
+			require.NoError(t, err)
+
+			mainChannel := "query:" + testSubscriptionID + ":100"
+			server.waitSubscribed(mainChannel)
+			server.publishTransaction(mainChannel, "tx-1")
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			drained, err := subscription.Shutdown(ctx)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Equal(t, 1, drained)
+			assert.Empty(t, store.get(testSubscriptionID))
+			<-subscription.Done()
+		})
+
+		t.Run("ignores new publications", func(t *testing.T) {
+			s := &Subscription{}
+			require.True(t, s.acceptPublication())
+			atomic.StoreInt32(&s.draining, 1)
+			assert.False(t, s.acceptPublication())
+			s.donePublication()
+			assert.Zero(t, atomic.LoadInt64(&s.counters.inFlight))
+		})
+	})
+}
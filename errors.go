@@ -0,0 +1,426 @@
+package junglebus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GorillaPool/go-junglebus/transports"
+)
+
+// ErrAlreadySubscribed is when subscribing to a subscription ID that is already active on the client
+var ErrAlreadySubscribed = errors.New("already subscribed to this subscription id")
+
+// ErrSubscriptionInProgress is when subscribing to a subscription ID while another Subscribe of the ID has not
+// returned yet
+var ErrSubscriptionInProgress = errors.New("subscribing to this subscription id is in progress")
+
+// ErrNoHandlers is when subscribing with an event handler without OnTransaction, OnBlock, OnMempool or their error
+// returning or context variants, nothing would be received
+var ErrNoHandlers = errors.New("event handler has no transaction handlers")
+
+// ErrTokenFetch is when the token of a subscription could not be fetched before connecting, see SubscribeError
+var ErrTokenFetch = errors.New("failed to fetch subscription token")
+
+// ErrSubscribeChannel is when subscribing to a channel of a subscription failed or was rejected by the server, see
+// SubscribeError
+var ErrSubscribeChannel = errors.New("failed to subscribe to channel")
+
+// ErrConnect is when the websocket connection of a subscription could not be established, see SubscribeError
+var ErrConnect = errors.New("failed to connect")
+
+// ErrNotSubscribed is when unsubscribing from a subscription that is not active
+var ErrNotSubscribed = errors.New("not subscribed")
+
+// ErrMaxReconnectAttempts is when a subscription stopped after failing to reconnect the maximum number of attempts
+var ErrMaxReconnectAttempts = errors.New("maximum number of reconnect attempts reached")
+
+// ErrUnhealthy is when Client.Health found a component that is not healthy, see HealthStatus
+var ErrUnhealthy = errors.New("unhealthy")
+
+// ErrStreamGap is when the server no longer streams blocks a subscription resumes from, see GapError
+var ErrStreamGap = errors.New("blocks missing from the stream")
+
+// ErrCheckpointNotFound is when no checkpoint has been stored for a subscription yet
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// ErrChainTipNotFound is when the server did not return a chain tip
+var ErrChainTipNotFound = errors.New("chain tip not found")
+
+// ErrBlockHeaderGap is when consecutive block headers do not link up
+var ErrBlockHeaderGap = errors.New("gap in block headers")
+
+// ErrInvalidAddress is when an address passed to an address lookup is not a valid address
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ErrAddressCursorStuck is when paging through the transactions of an address does not get past a cursor, like for
+// a block with more transactions of the address than the largest page requested
+var ErrAddressCursorStuck = errors.New("address cursor does not advance")
+
+// ErrSubscriptionNotFound is when the subscription ID is not registered on JungleBus
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// ErrInvalidMerkleProof is when a merkle proof is malformed and the merkle root cannot be computed
+var ErrInvalidMerkleProof = errors.New("invalid merkle proof")
+
+// ErrAuthRequired is when the server rejected a subscription connecting without a token, see WithNoAuth
+var ErrAuthRequired = errors.New("authentication required")
+
+// ErrTokenNotFound is when no token has been stored for a subscription yet
+var ErrTokenNotFound = errors.New("token not found")
+
+// ErrTokenRefresh is sent to OnError when the token provider failed to provide a new token for the connection,
+// together with a StatusTokenRefreshFailed status
+var ErrTokenRefresh = errors.New("failed to refresh token")
+
+// ErrUnknownChannel is when a publication arrived on a channel that does not belong to the subscription
+var ErrUnknownChannel = errors.New("publication on unknown channel")
+
+// ErrInvalidServerURL is when the URL given to WithHTTP, WithHTTPClient or WithServers can not be parsed, has an
+// unsupported scheme, no host or a query
+var ErrInvalidServerURL = transports.ErrInvalidServerURL
+
+// ErrInvalidWebsocketURL is when the URL given to WithWebsocketURL can not be parsed or has an unsupported scheme
+var ErrInvalidWebsocketURL = errors.New("invalid websocket url")
+
+// ErrInvalidTimeout is when a timeout given as option is zero or negative
+var ErrInvalidTimeout = errors.New("timeout must be positive")
+
+// ErrSpoolCorrupted is when a record of the disk spool does not match its checksum, see SpoolCorruptionError
+var ErrSpoolCorrupted = errors.New("spool record corrupted")
+
+// ErrNoServers is when WithServers is given without servers
+var ErrNoServers = errors.New("no servers given")
+
+// ErrInvalidFailoverThreshold is when the threshold given to WithFailoverThreshold is zero or negative
+var ErrInvalidFailoverThreshold = errors.New("failover threshold must be positive")
+
+// ErrInvalidUntilBlock is when the block given to WithUntilBlock is before the block the subscription starts from
+var ErrInvalidUntilBlock = errors.New("until block is before the from block")
+
+// ErrInvalidQueueSize is when the size given to WithQueueSize is negative
+var ErrInvalidQueueSize = errors.New("queue size must not be negative")
+
+// ErrOrderingConcurrency is when WithStrictOrdering is combined with WithHandlerConcurrency
+var ErrOrderingConcurrency = errors.New("strict ordering can not be combined with handler concurrency")
+
+// ErrOrderingBufferFull is sent to OnError when WithStrictOrdering held back as many transactions as it can, they are
+// passed on before their block is done
+var ErrOrderingBufferFull = errors.New("ordering buffer full")
+
+// ErrConsumerExists is when a consumer is added with the name of another consumer of the subscription
+var ErrConsumerExists = errors.New("consumer already exists")
+
+// ErrConsumerPooled is when a consumer is added to a subscription with WithPooledMessages
+var ErrConsumerPooled = errors.New("consumers can not be used with pooled messages")
+
+// ErrInvalidSpoolSize is when the maximum size given to WithDiskSpool is zero or negative
+var ErrInvalidSpoolSize = errors.New("spool size must be positive")
+
+// ErrInvalidStreamPosition is when the stream position of WithStreamPosition is not on the main channel of the
+// subscription
+var ErrInvalidStreamPosition = errors.New("stream position is not on the main channel of the subscription")
+
+// ErrInvalidExtraChannel is when the channel of WithExtraChannel is empty, has no callback, or is a channel of the
+// subscription itself
+var ErrInvalidExtraChannel = errors.New("invalid extra channel")
+
+// ErrNotFound is returned by REST requests when the server responded with 404 Not Found
+var ErrNotFound = transports.ErrNotFound
+
+// ErrUnauthorized is returned by REST requests when the server responded with 401 Unauthorized or 403 Forbidden
+var ErrUnauthorized = transports.ErrUnauthorized
+
+// ErrRateLimited is returned by REST requests when the server responded with 429 Too Many Requests
+var ErrRateLimited = transports.ErrRateLimited
+
+// ErrChecksumMismatch is returned by GetRawTransaction when the transaction does not hash to the requested txid
+var ErrChecksumMismatch = transports.ErrChecksumMismatch
+
+// ErrCircuitOpen is returned by REST requests that were not sent because the circuit breaker is open,
+// see WithCircuitBreaker
+var ErrCircuitOpen = transports.ErrCircuitOpen
+
+// ErrMalformedTransaction is returned by BroadcastTransaction when the transaction could not be decoded or is invalid
+var ErrMalformedTransaction = transports.ErrMalformedTransaction
+
+// ErrMessageTooLarge is returned by REST requests and sent to OnError for publications that are larger than the
+// maximum size once decompressed, see WithMaxMessageSize
+var ErrMessageTooLarge = transports.ErrMessageTooLarge
+
+// ErrFeeTooLow is returned by BroadcastTransaction when the fee is below the policy of the node
+var ErrFeeTooLow = transports.ErrFeeTooLow
+
+// ErrAlreadyKnown is returned by BroadcastTransaction when the transaction is already in the mempool or mined
+var ErrAlreadyKnown = transports.ErrAlreadyKnown
+
+// ErrDoubleSpend is returned by BroadcastTransaction when the transaction spends outputs that are already spent
+var ErrDoubleSpend = transports.ErrDoubleSpend
+
+// BroadcastError is returned by BroadcastTransaction when the server rejected the transaction
+type BroadcastError = transports.BroadcastError
+
+// APIError is returned by REST requests when the server responded with a 4xx or 5xx status code
+type APIError = transports.APIError
+
+// RateLimitError is returned by REST requests that were rate limited by the server, see WithAutoRateLimit
+type RateLimitError = transports.RateLimitError
+
+// MessageTooLargeError is returned by REST requests and sent to OnError within a DecodeError for publications that
+// are larger than the maximum size, see WithMaxMessageSize
+type MessageTooLargeError = transports.MessageTooLargeError
+
+// BatchError is returned by batch requests like GetTransactions when some of the items failed
+type BatchError struct {
+	Errors []*BatchItemError // in the order of the items
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Errors) == 1 {
+		return "batch failed for 1 item: " + e.Errors[0].Error()
+	}
+	return fmt.Sprintf("batch failed for %d items, first: %s", len(e.Errors), e.Errors[0])
+}
+
+// Unwrap returns the errors of the failed items
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Is reports whether the error of any failed item matches the target, errors.Is only walks Unwrap() []error from
+// Go 1.20
+func (e *BatchError) Is(target error) bool {
+	return isAny(e.Unwrap(), target)
+}
+
+// As finds the first error of the failed items that matches the target, errors.As only walks Unwrap() []error from
+// Go 1.20
+func (e *BatchError) As(target interface{}) bool {
+	return asAny(e.Unwrap(), target)
+}
+
+// BatchItemError is the error of a single item of a batch request
+type BatchItemError struct {
+	Index int    // index of the item in the request
+	TxID  string // txid of the item
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return e.TxID + ": " + e.Err.Error()
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is sent to OnError when an event handler panicked
+type PanicError struct {
+	Handler string      // name of the callback that panicked
+	Value   interface{} // value passed to panic
+	Stack   []byte      // stack trace of the panic
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v\n%s", e.Handler, e.Value, e.Stack)
+}
+
+// DeadLetterError is sent to OnError for a transaction OnTransactionE or OnMempoolE kept failing for, when no
+// OnDeadLetter callback is set
+type DeadLetterError struct {
+	TxID     string // txid of the transaction
+	Attempts int    // number of times the transaction was handled
+	Err      error  // the error of the last attempt
+}
+
+func (e *DeadLetterError) Error() string {
+	return fmt.Sprintf("transaction %s dead-lettered after %d attempts: %v", e.TxID, e.Attempts, e.Err)
+}
+
+func (e *DeadLetterError) Unwrap() error {
+	return e.Err
+}
+
+// SpoolCorruptionError is sent to OnError when the disk spool of WithDiskSpool ends in a partially written or
+// corrupted record, the spool is truncated at the offset of the record
+type SpoolCorruptionError struct {
+	Path      string // the file of the spool
+	Offset    int64  // offset of the first bad record
+	Truncated int64  // number of bytes cut off
+	Err       error  // io.ErrUnexpectedEOF for a partial record or ErrSpoolCorrupted
+}
+
+func (e *SpoolCorruptionError) Error() string {
+	return fmt.Sprintf("spool %s corrupted at offset %d, truncated %d bytes: %v", e.Path, e.Offset, e.Truncated, e.Err)
+}
+
+func (e *SpoolCorruptionError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is sent to OnError when a publication could not be decoded
+type DecodeError struct {
+	Channel string // name of the channel
+	Offset  uint64 // offset of the publication in the channel
+	Data    []byte // raw payload of the publication
+	Err     error  // why decoding failed
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode publication %d of %s (%d bytes): %s", e.Offset, e.Channel, len(e.Data), e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TxDecodeError is sent to OnError by DecodedHandler when a transaction can not be decoded
+type TxDecodeError struct {
+	TxID string // txid of the message
+	Err  error  // why decoding failed, ErrChecksumMismatch when the txid was verified and does not match
+}
+
+func (e *TxDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode transaction %s: %s", e.TxID, e.Err)
+}
+
+func (e *TxDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownChannelError is sent to OnError for publications on channels that do not belong to the subscription,
+// when no OnUnknownChannel callback is set
+type UnknownChannelError struct {
+	Channel string // name of the channel
+	Data    []byte // raw payload of the publication
+}
+
+func (e *UnknownChannelError) Error() string {
+	return fmt.Sprintf("%s: %s (%d bytes)", ErrUnknownChannel, e.Channel, len(e.Data))
+}
+
+func (e *UnknownChannelError) Unwrap() error {
+	return ErrUnknownChannel
+}
+
+// GapError is when the server no longer streams blocks a subscription resumes from, and they were not fetched
+// because of WithNoGapRepair or fetching them failed. errors.Is matches it with ErrStreamGap and its cause.
+type GapError struct {
+	SubscriptionID string
+	From           uint64 // the first missing block
+	To             uint64 // the last missing block
+	Err            error  // why fetching the blocks failed, nil with WithNoGapRepair
+}
+
+func (e *GapError) Error() string {
+	msg := fmt.Sprintf("blocks %d to %d missing from the stream of subscription %s", e.From, e.To, e.SubscriptionID)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *GapError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrStreamGap
+func (e *GapError) Is(target error) bool {
+	return target == ErrStreamGap
+}
+
+// SubscribeError is when subscribing failed, errors.Is matches it with its Kind and its cause
+type SubscribeError struct {
+	Kind           error  // ErrTokenFetch, ErrSubscribeChannel or ErrConnect
+	SubscriptionID string // the subscription that failed
+	Channel        string // the channel, for ErrSubscribeChannel
+	Endpoint       string // the websocket endpoint, for ErrConnect
+	Err            error  // the cause
+}
+
+func (e *SubscribeError) Error() string {
+	msg := e.Kind.Error() + " for subscription " + e.SubscriptionID
+	switch {
+	case e.Channel != "":
+		msg += " on " + e.Channel
+	case e.Endpoint != "":
+		msg += " at " + e.Endpoint
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *SubscribeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the kind of the error
+func (e *SubscribeError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// isAny reports whether any of the errors matches the target, see errors.Is
+func isAny(errs []error, target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// asAny finds the first of the errors that matches the target, see errors.As
+func asAny(errs []error, target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors returns nil without errors, the error itself for a single error and a multiError otherwise
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &multiError{errs: errs}
+	}
+}
+
+// multiError is a list of errors, errors.Is and errors.As match any of them
+type multiError struct {
+	errs []error
+}
+
+func (e *multiError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors
+func (e *multiError) Unwrap() []error {
+	return e.errs
+}
+
+// Is reports whether any of the errors matches the target, errors.Is only walks Unwrap() []error from Go 1.20
+func (e *multiError) Is(target error) bool {
+	return isAny(e.errs, target)
+}
+
+// As finds the first of the errors that matches the target, errors.As only walks Unwrap() []error from Go 1.20
+func (e *multiError) As(target interface{}) bool {
+	return asAny(e.errs, target)
+}
@@ -0,0 +1,41 @@
+package junglebus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/GorillaPool/go-junglebus"
+)
+
+// ExampleClient_GetBlockHeader gets a block header by its hash
+func ExampleClient_GetBlockHeader() {
+	client, err := junglebus.New(junglebus.WithHTTP("https://junglebus.gorillapool.io"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	blockHeader, err := client.GetBlockHeader(context.Background(),
+		"000000000000000004a288072ebb35e37233f419918f9783d499979cb6ac33eb")
+	if errors.Is(err, junglebus.ErrNotFound) {
+		log.Fatal("unknown block")
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("block %d was mined at %d\n", blockHeader.Height, blockHeader.Time)
+}
+
+// ExampleClient_GetBlockHeaderByHeight gets a block header by its height
+func ExampleClient_GetBlockHeaderByHeight() {
+	client, err := junglebus.New(junglebus.WithHTTP("https://junglebus.gorillapool.io"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	blockHeader, err := client.GetBlockHeaderByHeight(context.Background(), 575191)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("block %d has hash %s\n", blockHeader.Height, blockHeader.Hash)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+func main() {
+	junglebusClient, err := junglebus.New(
+		junglebus.WithHTTP("https://junglebus.gorillapool.io"),
+	)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	argsWithoutProg := os.Args[1:]
+	if len(argsWithoutProg) < 2 {
+		panic("no subscription id or block height given")
+	}
+	subscriptionID := argsWithoutProg[0]
+	var fromBlock uint64
+	if fromBlock, err = strconv.ParseUint(argsWithoutProg[1], 10, 64); err != nil {
+		panic("invalid block height given")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var subscription *junglebus.Subscription
+	if subscription, err = junglebusClient.Subscribe(ctx, subscriptionID, fromBlock, junglebus.EventHandler{
+		OnTransaction: func(tx *models.TransactionResponse) {},
+		OnMempool:     func(tx *models.TransactionResponse) {},
+		OnStatus:      func(status *models.ControlResponse) {},
+		OnError: func(err error) {
+			log.Printf("[ERROR]: %v", err)
+		},
+	}); err != nil {
+		log.Fatalf("ERROR: failed getting subscription %s", err.Error())
+	}
+
+	// print the stats every 10 seconds until interrupted
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-subscription.Done():
+			log.Printf("subscription stopped: %v", subscription.Err())
+			return
+		case <-ticker.C:
+			stats := subscription.Stats()
+			log.Printf("[STATS]: block %d (done at %s), %d transactions, %d mempool, %d control, "+
+				"%d reconnects, %d errors, %d queued, %d dropped",
+				stats.LastBlock, stats.LastBlockTime.Format(time.RFC3339), stats.TransactionsReceived,
+				stats.MempoolReceived, stats.ControlReceived, stats.Reconnects, stats.Errors,
+				stats.QueueDepth, stats.DroppedMessages)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package junglebus
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/centrifugal/centrifuge-go"
+)
+
+// Centrifuge returns the centrifuge client of the current connection, nil while reconnecting. It is meant for
+// centrifuge features the subscription does not wrap, like RPC calls or presence, and is not covered by the
+// compatibility of this package: the client is replaced on every reconnect, and closing it or changing its
+// subscriptions interferes with the subscription. Use WithExtraChannel to subscribe to other channels.
+func (s *Subscription) Centrifuge() *centrifuge.Client {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.centrifugeClient
+}
+
+// RawSubscription returns the centrifuge subscription of a channel on the current connection: "control", "main" or
+// "mempool", or the name of a channel of WithExtraChannel. It returns nil while reconnecting or when the subscription
+// does not subscribe to the channel. Like Centrifuge, it is not covered by the compatibility of this package and
+// the centrifuge subscription is replaced on every reconnect.
+func (s *Subscription) RawSubscription(name string) *centrifuge.Subscription {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.centrifugeClient == nil {
+		return nil
+	}
+	return s.subscriptions[name]
+}
+
+// validateExtraChannel returns ErrInvalidExtraChannel when the channel cannot be subscribed to next to the channels
+// of the subscription
+func (s *Subscription) validateExtraChannel(channel string, onPublication func(data []byte)) error {
+	if onPublication == nil {
+		return fmt.Errorf("%w: no callback for %q", ErrInvalidExtraChannel, channel)
+	}
+	switch channel {
+	case "", channelControl, channelMain, channelMempool:
+		return fmt.Errorf("%w: %q", ErrInvalidExtraChannel, channel)
+	}
+	if _, ok := s.channelName(channel); ok {
+		return fmt.Errorf("%w: %q is a channel of the subscription", ErrInvalidExtraChannel, channel)
+	}
+	return nil
+}
+
+// newExtraChannel subscribes to a channel of WithExtraChannel, passing its publications on through the queue
+func (s *Subscription) newExtraChannel(centrifugeClient *centrifuge.Client, channel string,
+	onPublication func(data []byte), current func() bool) (*centrifuge.Subscription, error) {
+
+	sub, err := centrifugeClient.NewSubscription(channel)
+	if err != nil {
+		return nil, &SubscribeError{Kind: ErrSubscribeChannel, SubscriptionID: s.SubscriptionID, Channel: channel, Err: err}
+	}
+
+	eventHandler := s.dispatched()
+	sub.OnError(s.onChannelError(channel, eventHandler, current))
+	sub.OnPublication(func(e centrifuge.PublicationEvent) {
+		if !current() || !s.acceptPublication() {
+			return
+		}
+		defer s.donePublication()
+		s.touch()
+		if eventHandler.OnRawPublication != nil {
+			eventHandler.OnRawPublication(channel, e.Offset, e.Data)
+		}
+		s.dispatch(func() {
+			if s.panicRecovery {
+				defer s.recoverPanic("WithExtraChannel "+channel, s.EventHandler.OnError)
+			}
+			onPublication(e.Data)
+		})
+	})
+
+	if err = sub.Subscribe(); err != nil {
+		return sub, &SubscribeError{Kind: ErrSubscribeChannel, SubscriptionID: s.SubscriptionID, Channel: channel, Err: err}
+	}
+	return sub, nil
+}
+
+// onChannelError returns the error callback of a channel, reporting a failed subscribe as an ErrSubscribeChannel
+func (s *Subscription) onChannelError(channel string, eventHandler EventHandler,
+	current func() bool) func(e centrifuge.SubscriptionErrorEvent) {
+
+	return func(e centrifuge.SubscriptionErrorEvent) {
+		var subscribeErr centrifuge.SubscriptionSubscribeError
+		if !current() || !errors.As(e.Error, &subscribeErr) {
+			return
+		}
+		s.log(levelError, "subscribing to channel failed", "channel", channel, "error", subscribeErr.Err)
+		eventHandler.OnError(&SubscribeError{
+			Kind:           ErrSubscribeChannel,
+			SubscriptionID: s.SubscriptionID,
+			Channel:        channel,
+			Err:            subscribeErr.Err,
+		})
+	}
+}
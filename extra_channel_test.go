@@ -0,0 +1,82 @@
+package junglebus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/centrifugal/centrifuge-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribe_WithExtraChannel will test subscribing to another channel on the connection of the subscription
+func TestSubscribe_WithExtraChannel(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const extraChannel = "announcements"
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		publications := make(chan string, 2)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+		}, WithExtraChannel(extraChannel, func(data []byte) {
+			publications <- string(data)
+		}))
+		require.NoError(t, err)
+
+		server.waitSubscribed(extraChannel)
+		require.Eventually(t, subscription.IsConnected, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, centrifuge.StateConnected, subscription.Centrifuge().State())
+		assert.NotNil(t, subscription.RawSubscription(extraChannel))
+		assert.NotNil(t, subscription.RawSubscription(channelControl))
+		assert.Nil(t, subscription.RawSubscription(channelMempool))
+
+		server.publish(extraChannel, []byte(`{"message":"first"}`))
+		assert.JSONEq(t, `{"message":"first"}`, <-publications)
+
+		t.Run("subscribed again after reconnecting", func(t *testing.T) {
+			server.DisconnectAll()
+			require.Eventually(t, func() bool {
+				return subscription.Stats().Reconnects > 0 && server.subscribed(extraChannel)
+			}, 5*time.Second, 10*time.Millisecond)
+			server.publish(extraChannel, []byte(`{"message":"second"}`))
+			assert.JSONEq(t, `{"message":"second"}`, <-publications)
+		})
+
+		t.Run("torn down by Unsubscribe", func(t *testing.T) {
+			require.NoError(t, subscription.Unsubscribe())
+			require.Eventually(t, func() bool {
+				return !server.subscribed(extraChannel)
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Nil(t, subscription.Centrifuge())
+			assert.Nil(t, subscription.RawSubscription(extraChannel))
+		})
+	})
+}
+
+// TestSubscribe_WithExtraChannelInvalid will test rejecting extra channels that cannot be subscribed to
+func TestSubscribe_WithExtraChannelInvalid(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		onPublication := func([]byte) {}
+		server := newFakeServer(t)
+		client := server.newClient()
+		for name, opt := range map[string]SubscribeOption{
+			"empty":            WithExtraChannel("", onPublication),
+			"reserved name":    WithExtraChannel(channelControl, onPublication),
+			"own channel":      WithExtraChannel("query:"+testSubscriptionID+":mempool", onPublication),
+			"without callback": WithExtraChannel("announcements", nil),
+		} {
+			_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+			}, opt)
+			assert.ErrorIs(t, err, ErrInvalidExtraChannel, name)
+		}
+		assert.Empty(t, server.dialTimes())
+
+		var subscription *Subscription
+		assert.Nil(t, subscription.Centrifuge())
+		assert.Nil(t, subscription.RawSubscription(channelMain))
+	})
+}
@@ -0,0 +1,124 @@
+package junglebus
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GorillaPool/go-junglebus/transports"
+)
+
+// DefaultFailoverThreshold is the number of failures in a row after which the client fails over to the next server
+const DefaultFailoverThreshold = 3
+
+// failover keeps track of the server in use out of the servers of WithServers
+type failover struct {
+	mu        sync.Mutex
+	servers   []string
+	threshold int
+	active    int // index of the server in use
+	failures  int // of the server in use, in a row
+}
+
+// enabled returns whether there are servers to fail over to
+func (f *failover) enabled() bool {
+	return len(f.servers) > 1
+}
+
+// current returns the index of the server in use, failures and successes are reported for it
+func (f *failover) current() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// succeeded resets the failures of the server, when it is still in use
+func (f *failover) succeeded(index int) {
+	f.mu.Lock()
+	if index == f.active {
+		f.failures = 0
+	}
+	f.mu.Unlock()
+}
+
+// failed counts a failure of the server, when it is still in use. The next server is returned when the threshold is
+// reached, failures of a server that was already failed over from are ignored.
+func (f *failover) failed(index int) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.enabled() || index != f.active {
+		return "", false
+	}
+	f.failures++
+	if f.failures < f.threshold {
+		return "", false
+	}
+	f.failures = 0
+	f.active = (f.active + 1) % len(f.servers)
+	return f.servers[f.active], true
+}
+
+// serverFailed reports a failure of the server with the index, failing over to the next server at the threshold
+func (jb *Client) serverFailed(index int) {
+	if server, ok := jb.failover.failed(index); ok {
+		jb.failedOver(server)
+	}
+}
+
+// failedOver sends the next requests to the server, and tells the subscriptions. Their next connection is made to
+// the server with a new subscription token of the server.
+func (jb *Client) failedOver(server string) {
+	if jb.service == nil {
+		return
+	}
+	jb.service.SetServerURL(server)
+	logEvent(jb.logger, levelWarn, "failing over", "server", server)
+
+	jb.subscriptionsMu.Lock()
+	subscriptions := make([]*Subscription, 0, len(jb.subscriptions))
+	for _, s := range jb.subscriptions {
+		subscriptions = append(subscriptions, s)
+	}
+	jb.subscriptionsMu.Unlock()
+
+	for _, s := range subscriptions {
+		if !jb.noAuth {
+			atomic.StoreInt32(&s.failedOver, 1)
+			atomic.StoreInt32(&s.tokenExpired, 1)
+		}
+		s.sendStatus(s.dispatched().OnStatus, StatusFailover, "failover", func() string {
+			return "Failing over to " + server
+		})
+	}
+}
+
+// failoverMiddleware reports the failures and successes of the REST requests, an attempt is sent to the server in use
+// when a previous attempt of the request failed over
+func (jb *Client) failoverMiddleware(next transports.RoundTripperFunc) transports.RoundTripperFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		index := jb.failover.current()
+		// the server url may end in a base path, the servers share it
+		if host := strings.SplitN(jb.service.GetServerURL(), "/", 2)[0]; req.URL.Host != host {
+			req.URL.Host = host
+			req.Host = ""
+			req.URL.Scheme = "https"
+			if !jb.service.IsSSL() {
+				req.URL.Scheme = "http"
+			}
+		}
+
+		resp, err := next(req)
+		switch {
+		case err != nil:
+			if req.Context().Err() == nil {
+				jb.serverFailed(index)
+			}
+		case resp.StatusCode >= http.StatusInternalServerError:
+			jb.serverFailed(index)
+		default:
+			jb.failover.succeeded(index)
+		}
+		return resp, err
+	}
+}
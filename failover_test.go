@@ -0,0 +1,147 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFailover will test counting the failures of the servers in a row
+func TestFailover(t *testing.T) {
+	f := &failover{servers: []string{"one", "two", "three"}, threshold: 2}
+
+	_, ok := f.failed(0)
+	assert.False(t, ok)
+	f.succeeded(0)
+	_, ok = f.failed(0)
+	assert.False(t, ok, "failures are counted in a row")
+
+	server, ok := f.failed(0)
+	require.True(t, ok)
+	assert.Equal(t, "two", server)
+	assert.Equal(t, 1, f.current())
+
+	_, ok = f.failed(0)
+	assert.False(t, ok, "failures of a previous server are ignored")
+	f.failed(1)
+	server, _ = f.failed(1)
+	assert.Equal(t, "three", server)
+	f.failed(2)
+	server, _ = f.failed(2)
+	assert.Equal(t, "one", server, "wraps around to the first server")
+
+	single := &failover{servers: []string{"one"}, threshold: 1}
+	_, ok = single.failed(0)
+	assert.False(t, ok)
+}
+
+// TestWithServers will test the options of failing over
+func TestWithServers(t *testing.T) {
+	t.Run("first server is used", func(t *testing.T) {
+		client, err := New(WithServers("http://one.example.com", "two.example.com"))
+		require.NoError(t, err)
+		assert.Equal(t, "one.example.com", client.transport.GetServerURL())
+		assert.False(t, client.transport.IsSSL())
+		assert.Equal(t, DefaultFailoverThreshold, client.failover.threshold)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := New(WithServers())
+		assert.ErrorIs(t, err, ErrNoServers)
+		_, err = New(WithServers("one", "two"), WithFailoverThreshold(0))
+		assert.ErrorIs(t, err, ErrInvalidFailoverThreshold)
+	})
+
+	t.Run("injected transport", func(t *testing.T) {
+		client, err := New(WithServers("one.example.com", "two.example.com"), WithFailoverThreshold(1),
+			WithTransport(&transports.Mock{}))
+		require.NoError(t, err)
+		assert.False(t, client.failover.enabled())
+		assert.NotPanics(t, func() {
+			client.serverFailed(0)
+		})
+	})
+
+	t.Run("REST requests fail over", func(t *testing.T) {
+		first := newFakeServer(t)
+		second := newFakeServer(t)
+		first.failRequests("/v1/transaction/get/tx", 100, http.StatusBadGateway)
+		second.handleJSON("/v1/transaction/get/tx", http.StatusOK, `{"id":"tx"}`)
+
+		client, err := New(WithServers(first.URL, second.URL), WithFailoverThreshold(2))
+		require.NoError(t, err)
+
+		// the last attempt of the retry policy is sent to the second server
+		transaction, err := client.GetTransaction(context.Background(), "tx")
+		require.NoError(t, err)
+		assert.Equal(t, "tx", transaction.ID)
+		assert.Equal(t, strings.TrimPrefix(second.URL, "http://"), client.transport.GetServerURL())
+	})
+}
+
+// TestWithServers_SubscriptionFailover will test continuing a subscription on the next server when the first one is gone
+func TestWithServers_SubscriptionFailover(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		first := newFakeServer(t)
+		second := newFakeServer(t)
+		client := first.newClient(
+			WithServers(first.URL, second.URL),
+			WithFailoverThreshold(2),
+			WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2),
+		)
+
+		transactions := make(chan *models.TransactionResponse, 2)
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx },
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		first.waitSubscribed(controlChannel)
+		first.publishTransaction("query:"+testSubscriptionID+":100", "first")
+		first.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 110, Page: 2})
+		require.Eventually(t, func() bool {
+			checkpoint := subscription.Checkpoint()
+			return checkpoint.Block == 110 && checkpoint.Page == 2
+		}, 5*time.Second, 10*time.Millisecond)
+
+		first.Close()
+		mainChannel := "query:" + testSubscriptionID + ":110"
+		second.waitSubscribed(mainChannel)
+		second.publishTransaction(mainChannel, "second")
+
+		for _, id := range []string{"first", "second"} {
+			select {
+			case tx := <-transactions:
+				assert.Equal(t, id, tx.Id)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("transaction %s not received", id)
+			}
+		}
+
+		assert.True(t, recorder.has(StatusFailover))
+		recorder.mu.Lock()
+		for _, status := range recorder.statuses {
+			if status.StatusCode == uint32(StatusFailover) {
+				assert.Contains(t, status.Message, strings.TrimPrefix(second.URL, "http://"))
+			}
+		}
+		recorder.mu.Unlock()
+		assert.NotNil(t, second.requestHeaders("/v1/user/subscription-token"), "a token is fetched from the second server")
+		assert.Nil(t, second.requestHeaders("/v1/user/refresh-token"), "the token of the first server is not refreshed")
+		assert.Same(t, subscription, client.GetSubscription(testSubscriptionID))
+	})
+}
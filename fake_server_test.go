@@ -0,0 +1,141 @@
+package junglebus
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/junglebustest"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const testToken = junglebustest.Token
+
+// testProtocols are the subtests of forEachProtocol, the fake servers of the json subtest use the JSON protocol
+var testProtocols = []string{"protobuf", "json"}
+
+// forEachProtocol runs the test as a subtest for every protocol of the websocket connection, see testProtocols
+func forEachProtocol(t *testing.T, test func(t *testing.T)) {
+	for _, protocol := range testProtocols {
+		t.Run(protocol, test)
+	}
+}
+
+// usesJSON returns whether the test runs in the json subtest of forEachProtocol
+func usesJSON(t testing.TB) bool {
+	for _, name := range strings.Split(t.Name(), "/") {
+		if name == "json" {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidPublication returns a publication that fails to decode, in the protocol of the fake server
+func (f *fakeServer) invalidPublication() []byte {
+	if f.json {
+		return []byte(`{"id":1,"statusCode":"invalid"}`) // wrong types for transactions and control messages
+	}
+	return []byte{0x0a, 0xff} // field 1 with a truncated length
+}
+
+// fakeServer is a junglebustest.Server failing the test on errors
+type fakeServer struct {
+	*junglebustest.Server
+	t    testing.TB
+	json bool // whether the clients use the JSON protocol, see forEachProtocol
+}
+
+// newFakeServer starts a fake server that is closed when the test ends
+func newFakeServer(t testing.TB) *fakeServer {
+	return startFakeServer(t, junglebustest.NewServer())
+}
+
+// newFakeTLSServer starts a fake server using TLS that is closed when the test ends, see Certificate
+func newFakeTLSServer(t testing.TB) *fakeServer {
+	return startFakeServer(t, junglebustest.NewTLSServer())
+}
+
+func startFakeServer(t testing.TB, server *junglebustest.Server) *fakeServer {
+	t.Cleanup(server.Close)
+	return &fakeServer{Server: server, t: t, json: usesJSON(t)}
+}
+
+// newClient returns a junglebus client pointed at the fake server, using the JSON protocol in the json subtest of
+// forEachProtocol
+func (f *fakeServer) newClient(opts ...ClientOps) *Client {
+	if f.json {
+		opts = append(opts, WithJSONProtocol())
+	}
+	client, err := New(append([]ClientOps{WithHTTP(f.URL)}, opts...)...)
+	require.NoError(f.t, err)
+	return client
+}
+
+// handleJSON serves the JSON response on the given path
+func (f *fakeServer) handleJSON(path string, status int, response string) {
+	f.HandleJSON(path, status, response)
+}
+
+// requestHeaders returns the headers of the last request to the path
+func (f *fakeServer) requestHeaders(path string) http.Header {
+	return f.RequestHeaders(path)
+}
+
+// failRequests makes the next n requests on the path fail with the status
+func (f *fakeServer) failRequests(path string, n int, status int) {
+	f.FailRequests(path, n, status)
+}
+
+// rejectConnections makes the next n websocket connection attempts fail
+func (f *fakeServer) rejectConnections(n int) {
+	f.RejectConnections(n)
+}
+
+// dialTimes returns the times of all websocket connection attempts
+func (f *fakeServer) dialTimes() []time.Time {
+	return f.DialTimes()
+}
+
+// subscribed returns whether any open connection is subscribed to the channel
+func (f *fakeServer) subscribed(channel string) bool {
+	return f.Subscribed(channel)
+}
+
+// waitSubscribed waits until a client subscribed to the given channel
+func (f *fakeServer) waitSubscribed(channel string) {
+	require.True(f.t, f.WaitSubscribed(channel, 5*time.Second), "channel %s was never subscribed", channel)
+}
+
+// publish sends the data to every connection subscribed to the channel
+func (f *fakeServer) publish(channel string, data []byte) {
+	require.NoError(f.t, f.Publish(channel, data))
+}
+
+// publishMessage sends the message to every connection subscribed to the channel, in the protocol of the connection
+func (f *fakeServer) publishMessage(channel string, message proto.Message) {
+	require.NoError(f.t, f.PublishMessage(channel, message))
+}
+
+// queue publishes the data to the next connection subscribing to the channel, right after the subscribe reply
+func (f *fakeServer) queue(channel string, data []byte) {
+	f.Enqueue(channel, data)
+}
+
+// publishTransaction publishes a transaction with the given id on the channel
+func (f *fakeServer) publishTransaction(channel, id string) {
+	require.NoError(f.t, f.PublishTransaction(channel, &models.TransactionResponse{Id: id}))
+}
+
+// disconnect drops the websocket connections subscribed to the given channel
+func (f *fakeServer) disconnect(channel string) {
+	f.Disconnect(channel)
+}
+
+// disconnectAll drops every open websocket connection
+func (f *fakeServer) disconnectAll() {
+	f.DisconnectAll()
+}
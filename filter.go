@@ -0,0 +1,88 @@
+package junglebus
+
+import (
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// Filter returns whether a transaction is passed on to OnTransaction or OnMempool, see WithFilter
+type Filter func(tx *models.TransactionResponse) bool
+
+// And returns a filter passing the transactions passed by all the filters
+func And(filters ...Filter) Filter {
+	return func(tx *models.TransactionResponse) bool {
+		for _, filter := range filters {
+			if !filter(tx) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a filter passing the transactions passed by any of the filters
+func Or(filters ...Filter) Filter {
+	return func(tx *models.TransactionResponse) bool {
+		for _, filter := range filters {
+			if filter(tx) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a filter passing the transactions the filter does not pass
+func Not(filter Filter) Filter {
+	return func(tx *models.TransactionResponse) bool {
+		return !filter(tx)
+	}
+}
+
+// FilterAddresses returns a filter passing the transactions with an output paying to one of the P2PKH or P2SH
+// addresses, addresses that are not valid are ignored (see ValidateAddress)
+func FilterAddresses(addresses ...string) Filter {
+	scripts := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		decoded, err := decodeAddress(address)
+		if err != nil {
+			continue
+		}
+		hash := decoded[1:21]
+		switch decoded[0] {
+		case 0x00, 0x6f: // OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG
+			scripts[string(append(append([]byte{0x76, 0xa9, 0x14}, hash...), 0x88, 0xac))] = struct{}{}
+		default: // OP_HASH160 <hash> OP_EQUAL
+			scripts[string(append(append([]byte{0xa9, 0x14}, hash...), 0x87))] = struct{}{}
+		}
+	}
+
+	return func(tx *models.TransactionResponse) bool {
+		found := false
+		_ = tx.EachOutput(func(_ uint64, script []byte) bool {
+			_, found = scripts[string(script)]
+			return !found
+		})
+		return found
+	}
+}
+
+// FilterOutputPrefix returns a filter passing the transactions with an output whose locking script starts with the
+// prefix, like OP_FALSE OP_RETURN followed by the push of a protocol prefix
+func FilterOutputPrefix(prefix []byte) Filter {
+	prefix = append([]byte(nil), prefix...)
+	return func(tx *models.TransactionResponse) bool {
+		return tx.HasPrefix(prefix)
+	}
+}
+
+// FilterMinValue returns a filter passing the transactions whose outputs add up to at least sats satoshis
+func FilterMinValue(sats uint64) Filter {
+	return func(tx *models.TransactionResponse) bool {
+		var total uint64
+		err := tx.EachOutput(func(value uint64, _ []byte) bool {
+			total += value
+			return total < sats
+		})
+		return err == nil && total >= sats
+	}
+}
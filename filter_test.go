@@ -0,0 +1,167 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOutput is an output of a raw transaction built by newRawTransaction
+type testOutput struct {
+	value  uint64
+	script []byte
+}
+
+// newRawTransaction returns a raw transaction with a single input and the outputs
+func newRawTransaction(outputs ...testOutput) []byte {
+	raw := []byte{1, 0, 0, 0, 1}
+	raw = append(raw, make([]byte, 36)...)
+	raw = append(raw, 2, 0x51, 0x51, 0xff, 0xff, 0xff, 0xff)
+	raw = append(raw, byte(len(outputs)))
+	for _, output := range outputs {
+		value := make([]byte, 8)
+		binary.LittleEndian.PutUint64(value, output.value)
+		raw = append(raw, value...)
+		raw = append(raw, byte(len(output.script)))
+		raw = append(raw, output.script...)
+	}
+	return append(raw, 0, 0, 0, 0)
+}
+
+// p2pkhScript returns the locking script paying to the P2PKH address
+func p2pkhScript(t testing.TB, address string) []byte {
+	decoded, err := decodeAddress(address)
+	require.NoError(t, err)
+	return append(append([]byte{0x76, 0xa9, 0x14}, decoded[1:21]...), 0x88, 0xac)
+}
+
+var testOpReturn = []byte{0x00, 0x6a, 0x04, 'j', 'b', 'u', 's', 0x02, 'h', 'i'}
+
+func TestFilters(t *testing.T) {
+	const otherAddress = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	tx := &models.TransactionResponse{
+		Transaction: newRawTransaction(testOutput{1000, p2pkhScript(t, testAddress)}, testOutput{500, testOpReturn}),
+	}
+	empty := &models.TransactionResponse{}
+
+	assert.True(t, FilterAddresses("invalid", testAddress)(tx))
+	assert.False(t, FilterAddresses(otherAddress)(tx))
+	assert.False(t, FilterAddresses(testAddress)(empty))
+	assert.True(t, FilterAddresses("3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy")(&models.TransactionResponse{
+		Transaction: newRawTransaction(testOutput{1, []byte{
+			0xa9, 0x14, 0xb4, 0x72, 0xa2, 0x66, 0xd0, 0xbd, 0x89, 0xc1, 0x37, 0x06,
+			0xa4, 0x13, 0x2c, 0xcf, 0xb1, 0x6f, 0x7c, 0x3b, 0x9f, 0xcb, 0x87,
+		}}),
+	}), "P2SH")
+
+	assert.True(t, FilterOutputPrefix([]byte{0x00, 0x6a, 0x04, 'j', 'b'})(tx))
+	assert.False(t, FilterOutputPrefix([]byte{0x6a})(tx))
+
+	assert.True(t, FilterMinValue(1500)(tx))
+	assert.False(t, FilterMinValue(1501)(tx))
+	assert.False(t, FilterMinValue(1)(empty))
+
+	yes := func(*models.TransactionResponse) bool { return true }
+	no := func(*models.TransactionResponse) bool { return false }
+	assert.True(t, And()(tx))
+	assert.True(t, And(yes, yes)(tx))
+	assert.False(t, And(yes, no)(tx))
+	assert.False(t, Or()(tx))
+	assert.True(t, Or(no, yes)(tx))
+	assert.False(t, Or(no, no)(tx))
+	assert.True(t, Not(no)(tx))
+	assert.True(t, And(FilterAddresses(testAddress), Not(FilterMinValue(2000)))(tx))
+}
+
+// TestSubscribe_WithFilter will test that filtered out transactions are counted and not passed on
+func TestSubscribe_WithFilter(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		transactions := make(chan string, 2)
+		blocks := make(chan uint32, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx.Id },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnBlockDone:   func(height uint32, _ uint64) { blocks <- height },
+		}, WithFilter(FilterOutputPrefix(testOpReturn)), WithFilter(Not(FilterMinValue(1000))))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		for _, tx := range []*models.TransactionResponse{
+			{Id: "no-output", BlockHeight: 100},
+			{Id: "high-value", BlockHeight: 100, Transaction: newRawTransaction(testOutput{1000, testOpReturn})},
+			{Id: "match", BlockHeight: 100, Transaction: newRawTransaction(testOutput{0, testOpReturn})},
+		} {
+			require.NoError(t, server.PublishTransaction(mainChannel, tx))
+		}
+		server.publishMessage("query:"+testSubscriptionID+":control",
+			&models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+
+		select {
+		case height := <-blocks:
+			assert.Equal(t, uint32(100), height)
+		case <-time.After(5 * time.Second):
+			t.Fatal("block done not received")
+		}
+		assert.Equal(t, "match", <-transactions)
+		assert.Empty(t, transactions)
+		assert.Equal(t, Checkpoint{
+			Block:    100,
+			Position: &StreamPosition{Channel: mainChannel, Offset: 3, Epoch: "junglebustest"},
+		}, subscription.Checkpoint())
+
+		stats := subscription.Stats()
+		assert.Equal(t, uint64(3), stats.TransactionsReceived)
+		assert.Equal(t, uint64(2), stats.Filtered)
+	})
+}
+
+func BenchmarkFilterAddresses(b *testing.B) {
+	tx := &models.TransactionResponse{
+		Transaction: newRawTransaction(testOutput{0, testOpReturn}, testOutput{1000, p2pkhScript(b, testAddress)}),
+	}
+	filter := FilterAddresses("1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", testAddress)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !filter(tx) {
+			b.Fatal("not matched")
+		}
+	}
+}
+
+func BenchmarkFilterOutputPrefix(b *testing.B) {
+	tx := &models.TransactionResponse{
+		Transaction: newRawTransaction(testOutput{1000, p2pkhScript(b, testAddress)}, testOutput{0, testOpReturn}),
+	}
+	filter := FilterOutputPrefix(testOpReturn[:7])
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !filter(tx) {
+			b.Fatal("not matched")
+		}
+	}
+}
+
+func BenchmarkFilterMinValue(b *testing.B) {
+	tx := &models.TransactionResponse{
+		Transaction: newRawTransaction(testOutput{1000, p2pkhScript(b, testAddress)}, testOutput{0, testOpReturn}),
+	}
+	filter := And(FilterMinValue(1000), Not(FilterOutputPrefix([]byte{0x6a})))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !filter(tx) {
+			b.Fatal("not matched")
+		}
+	}
+}
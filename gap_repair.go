@@ -0,0 +1,121 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// repairGap compares the block the subscription resumes from with the earliest block the server still streams
+// it from, see models.SubscriptionDetails. The blocks in between are fetched with GetBlockTransactions and passed on
+// like streamed ones before the channels are subscribed to, and the subscription resumes at the earliest block.
+// A GapError is returned with WithNoGapRepair, or when fetching the blocks failed.
+//
+// The subscription is not checked when the server does not report its earliest block, or when the details can not
+// be fetched: streaming is not held up by it.
+func (s *Subscription) repairGap() error {
+	s.mu.Lock()
+	_, streamsMain := s.subscriptions[channelMain]
+	s.mu.Unlock()
+	from := s.Checkpoint().Block
+	if !streamsMain || from == 0 {
+		return nil
+	}
+
+	details, err := s.client.GetSubscriptionDetails(s.ctx, s.SubscriptionID)
+	if err != nil {
+		s.log(levelWarn, "checking the earliest block failed", "block", from, "error", err)
+		return nil
+	}
+	if details.EarliestBlock <= from {
+		return nil
+	}
+	to := details.EarliestBlock - 1
+	if s.untilBlock > 0 && to > s.untilBlock {
+		to = s.untilBlock
+	}
+	if s.noGapRepair {
+		s.log(levelError, "gap in stream", "from", from, "to", to)
+		return &GapError{SubscriptionID: s.SubscriptionID, From: from, To: to}
+	}
+
+	eventHandler := s.dispatched()
+	s.log(levelWarn, "repairing gap in stream", "from", from, "to", to)
+	s.sendStatus(eventHandler.OnStatus, StatusRepairStarted, "repairing", func() string {
+		return fmt.Sprintf("Blocks %d to %d are no longer streamed, fetching them", from, to)
+	})
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for height := from; height <= to; height++ {
+		if err = s.repairBlock(ctx, eventHandler, uint32(height)); err != nil {
+			if s.isStopped() {
+				return nil
+			}
+			s.log(levelError, "repairing gap failed", "block", height, "error", err)
+			return &GapError{SubscriptionID: s.SubscriptionID, From: height, To: to, Err: err}
+		}
+	}
+
+	// the blocks are passed on before the stream resumes after them
+	if !s.flushQueue() {
+		return nil
+	}
+	if s.Checkpoint().Block < details.EarliestBlock {
+		s.checkpoint.Store(Checkpoint{Block: details.EarliestBlock})
+	}
+	s.log(levelInfo, "repaired gap in stream", "from", from, "to", to)
+	s.sendStatus(eventHandler.OnStatus, StatusRepaired, "repaired", func() string {
+		return fmt.Sprintf("Fetched blocks %d to %d, streaming from block %d", from, to, details.EarliestBlock)
+	})
+	return nil
+}
+
+// repairBlock passes the transactions of a block fetched with GetBlockTransactions on like streamed ones, followed
+// by the block done
+func (s *Subscription) repairBlock(ctx context.Context, eventHandler EventHandler, height uint32) error {
+	channel := `query:` + s.SubscriptionID + `:` + strconv.FormatUint(uint64(height), 10)
+	transactions, errs := s.client.GetBlockTransactions(ctx, s.SubscriptionID, height)
+	var count uint64
+	for transaction := range transactions {
+		count++
+		atomic.AddUint64(&s.counters.transactions, 1)
+		s.client.observeTransaction(transaction, false)
+		if s.filteredOut(transaction) || s.duplicate(channelMain, transaction.Id) {
+			continue
+		}
+		s.handleTransaction(eventHandler, TxContext{
+			Channel:      channel,
+			Block:        height,
+			ReceivedAt:   time.Now(),
+			IsHistorical: true,
+		}, transaction)
+	}
+	if err := <-errs; err != nil {
+		return err
+	}
+	s.dispatchControl(channel, 0, time.Now(), &models.ControlResponse{
+		StatusCode:   uint32(SubscriptionBlockDone),
+		Status:       SubscriptionBlockDone.String(),
+		Block:        height,
+		Transactions: count,
+	})
+	return nil
+}
+
+// isGapUnrepaired returns whether the error is the GapError of WithNoGapRepair, connecting again does not help
+func isGapUnrepaired(err error) bool {
+	var gapErr *GapError
+	return errors.As(err, &gapErr) && gapErr.Err == nil
+}
@@ -0,0 +1,166 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveEarliestBlock serves the subscription details with the earliest block, and a transaction in every block,
+// the block transactions of the failing block are rejected
+func serveEarliestBlock(server *fakeServer, earliest *uint64, failing uint32) {
+	server.HandleFunc("/v1/subscription/", func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/v1/subscription/"), "/")
+		var response interface{}
+		switch len(parts) {
+		case 1:
+			response = &models.SubscriptionDetails{ID: parts[0], Status: "active", EarliestBlock: atomic.LoadUint64(earliest)}
+		case 4:
+			height, _ := strconv.ParseUint(parts[2], 10, 32)
+			if uint32(height) == failing {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			response = &models.BlockTransactionsPage{Transactions: []*models.TransactionResponse{{
+				Id:          "tx-" + parts[2],
+				BlockHeight: uint32(height),
+				Transaction: []byte{byte(height)},
+			}}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+// TestSubscribe_RepairGap will test fetching the blocks the server no longer streams the subscription from
+func TestSubscribe_RepairGap(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		t.Run("repaired", func(t *testing.T) {
+			server := newFakeServer(t)
+			earliest := uint64(103)
+			serveEarliestBlock(server, &earliest, 0)
+			client := server.newClient()
+
+			var mu sync.Mutex
+			var events []string
+			record := func(event string) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+			}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(tx *models.TransactionResponse) {
+					record(tx.Id)
+				},
+				OnBlockDone: func(block uint32, _ uint64) {
+					record("done-" + strconv.Itoa(int(block)))
+				},
+				OnStatus: func(status *models.ControlResponse) {
+					switch code := StatusCode(status.StatusCode); code {
+					case StatusRepairStarted, StatusRepaired:
+						record(code.String())
+					}
+				},
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":103")
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, []string{
+				"repairing", "tx-100", "done-100", "tx-101", "done-101", "tx-102", "done-102", "repaired",
+			}, events)
+			assert.Equal(t, Checkpoint{Block: 103}, subscription.Checkpoint())
+		})
+
+		t.Run("not behind the earliest block", func(t *testing.T) {
+			server := newFakeServer(t)
+			earliest := uint64(90)
+			serveEarliestBlock(server, &earliest, 0)
+			client := server.newClient()
+			statuses := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      statuses.onStatus,
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+			assert.False(t, statuses.has(StatusRepairStarted))
+		})
+
+		t.Run("no gap repair", func(t *testing.T) {
+			server := newFakeServer(t)
+			earliest := uint64(103)
+			serveEarliestBlock(server, &earliest, 0)
+			client := server.newClient()
+			_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {
+					t.Error("no transactions are passed on")
+				},
+			}, WithNoGapRepair())
+			require.ErrorIs(t, err, ErrStreamGap)
+			var gapErr *GapError
+			require.ErrorAs(t, err, &gapErr)
+			assert.Equal(t, &GapError{SubscriptionID: testSubscriptionID, From: 100, To: 102}, gapErr)
+			assert.Equal(t, "blocks 100 to 102 missing from the stream of subscription "+testSubscriptionID, err.Error())
+		})
+
+		t.Run("fetching failed", func(t *testing.T) {
+			server := newFakeServer(t)
+			earliest := uint64(103)
+			serveEarliestBlock(server, &earliest, 101)
+			client := server.newClient()
+			_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+			})
+			require.ErrorIs(t, err, ErrStreamGap)
+			require.ErrorIs(t, err, ErrUnauthorized)
+			var gapErr *GapError
+			require.ErrorAs(t, err, &gapErr)
+			assert.Equal(t, uint64(101), gapErr.From)
+			assert.Equal(t, uint64(102), gapErr.To)
+		})
+
+		t.Run("after reconnecting", func(t *testing.T) {
+			server := newFakeServer(t)
+			var earliest uint64
+			serveEarliestBlock(server, &earliest, 0)
+			client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnError:       func(error) {},
+			}, WithNoGapRepair())
+			require.NoError(t, err)
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+
+			// the server dropped the blocks while disconnected
+			atomic.StoreUint64(&earliest, 105)
+			server.DisconnectAll()
+			select {
+			case <-subscription.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("subscription did not fail")
+			}
+			assert.ErrorIs(t, subscription.Err(), ErrStreamGap)
+		})
+	})
+}
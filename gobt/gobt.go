@@ -0,0 +1,34 @@
+// Package gobt decodes the raw transactions of JungleBus into go-bt transactions, keeping the dependency on go-bt out
+// of the junglebus package
+//
+// Handler is the middleware of junglebus.DecodedHandler with the go-bt transaction parser, and Tx returns the decoded
+// transaction within the other middlewares and handlers of the transaction, decoding it once:
+//
+//	junglebus.WithTxMiddleware(gobt.Handler(func(ctx junglebus.TxContext, tx *bt.Tx, meta *models.TransactionResponse) {
+//		// handle the decoded transaction
+//	}, junglebus.WithVerifyTxID()))
+package gobt
+
+import (
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/libsv/go-bt/v2"
+)
+
+// Decode returns the go-bt transaction of the raw transaction
+func Decode(raw []byte) (*bt.Tx, error) {
+	return bt.NewTxFromBytes(raw)
+}
+
+// Handler returns a middleware calling handler with the transaction decoded by go-bt, see junglebus.DecodedHandler
+func Handler(handler func(ctx junglebus.TxContext, tx *bt.Tx, meta *models.TransactionResponse),
+	opts ...junglebus.DecodeOption) junglebus.TxMiddleware {
+
+	return junglebus.DecodedHandler(Decode, handler, opts...)
+}
+
+// Tx returns the transaction decoded by go-bt, it is decoded once for all the middlewares and handlers of the
+// transaction, see junglebus.DecodeTx
+func Tx(ctx junglebus.TxContext, tx *models.TransactionResponse) (*bt.Tx, error) {
+	return junglebus.DecodeTx(ctx, tx, Decode)
+}
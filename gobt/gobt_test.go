@@ -0,0 +1,109 @@
+package gobt_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/gobt"
+	"github.com/GorillaPool/go-junglebus/junglebustest"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// genesisTxID is the txid of the coinbase transaction of the genesis block, see genesisTx
+const genesisTxID = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+
+// genesisTx returns the raw coinbase transaction of the genesis block
+func genesisTx(t *testing.T) []byte {
+	raw, err := hex.DecodeString("01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d" +
+		"0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c" +
+		"6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61" +
+		"deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000")
+	require.NoError(t, err)
+	return raw
+}
+
+func TestDecode(t *testing.T) {
+	tx, err := gobt.Decode(genesisTx(t))
+	require.NoError(t, err)
+	assert.Equal(t, genesisTxID, tx.TxID())
+	require.Len(t, tx.Outputs, 1)
+	assert.Equal(t, uint64(5000000000), tx.Outputs[0].Satoshis)
+
+	_, err = gobt.Decode([]byte{1, 2, 3})
+	assert.Error(t, err)
+}
+
+// TestHandler will test decoding the transactions of a subscription once for the handler and the next middlewares
+func TestHandler(t *testing.T) {
+	const subscriptionID = "test-subscription"
+	server := junglebustest.NewServer()
+	defer server.Close()
+
+	handled := make(chan *bt.Tx, 10)
+	fromNext := make(chan *bt.Tx, 10)
+	errs := make(chan error, 10)
+	client, err := junglebus.New(junglebus.WithHTTP(server.URL))
+	require.NoError(t, err)
+	subscription, err := client.Subscribe(context.Background(), subscriptionID, 100, junglebus.EventHandler{
+		OnTransaction: func(*models.TransactionResponse) {},
+		OnStatus:      func(*models.ControlResponse) {},
+		OnError:       func(err error) { errs <- err },
+	}, junglebus.WithTxMiddleware(
+		gobt.Handler(func(_ junglebus.TxContext, tx *bt.Tx, _ *models.TransactionResponse) {
+			handled <- tx
+		}, junglebus.WithVerifyTxID()),
+		func(next junglebus.TxHandler) junglebus.TxHandler {
+			return func(ctx junglebus.TxContext, tx *models.TransactionResponse) {
+				if decoded, err := gobt.Tx(ctx, tx); err == nil {
+					fromNext <- decoded
+				}
+				next(ctx, tx)
+			}
+		},
+	))
+	require.NoError(t, err)
+	defer func() {
+		_ = subscription.Unsubscribe()
+	}()
+	mainChannel := junglebustest.MainChannel(subscriptionID, 100)
+	require.True(t, server.WaitSubscribed(mainChannel, 5*time.Second))
+
+	raw := genesisTx(t)
+	require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: genesisTxID, Transaction: raw}))
+	require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: "other", Transaction: raw}))
+	require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: "invalid", Transaction: []byte{1}}))
+
+	var tx *bt.Tx
+	select {
+	case tx = <-handled:
+		assert.Equal(t, genesisTxID, tx.TxID())
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction not handled")
+	}
+	select {
+	case decoded := <-fromNext:
+		assert.Same(t, tx, decoded, "the transaction is decoded once")
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction not passed on")
+	}
+
+	// a txid that does not match and a transaction that does not decode are not handled
+	for _, txID := range []string{"other", "invalid"} {
+		select {
+		case err := <-errs:
+			var decodeErr *junglebus.TxDecodeError
+			require.True(t, errors.As(err, &decodeErr))
+			assert.Equal(t, txID, decodeErr.TxID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("decode error not received")
+		}
+	}
+	assert.Empty(t, handled)
+}
@@ -0,0 +1,114 @@
+package junglebus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// HealthStatus is the result of Client.Health, Healthy is false when any of its components is not healthy
+type HealthStatus struct {
+	Healthy       bool
+	CheckedAt     time.Time
+	API           APIHealth                     // the REST API
+	Subscriptions map[string]SubscriptionHealth // the active subscriptions by subscription ID, empty without any
+}
+
+// APIHealth is the result of checking the REST API, by fetching the chain tip
+type APIHealth struct {
+	Healthy bool
+	Latency time.Duration // round trip of the request, also set when it failed
+	Height  uint32        // height of the chain tip
+	Err     error
+}
+
+// SubscriptionHealth is the state of the websocket connection of a subscription, it is healthy while connected
+type SubscriptionHealth struct {
+	Healthy        bool
+	Connected      bool
+	LastBlock      uint64
+	LastControl    time.Time     // when the last control message was received, zero before the first one
+	LastControlAge time.Duration // time since the last control message, 0 before the first one
+	Reconnects     uint64
+	LastConnectErr error // the last error connecting the websocket, nil once connected
+}
+
+// Health checks the REST API and the websocket connections of the active subscriptions, without active
+// subscriptions only the REST API is checked. The status is returned with ErrUnhealthy when a component is not
+// healthy, the context error is returned when ctx is done before the REST API responded.
+func (jb *Client) Health(ctx context.Context) (*HealthStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	status := &HealthStatus{CheckedAt: time.Now(), Subscriptions: make(map[string]SubscriptionHealth)}
+	start := time.Now()
+	tip, err := jb.getChainTip(ctx)
+	status.API.Latency = time.Since(start)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		status.API.Err = err
+	} else {
+		status.API.Healthy = true
+		status.API.Height = tip.Height
+	}
+
+	jb.subscriptionsMu.Lock()
+	subscriptions := make([]*Subscription, 0, len(jb.subscriptions))
+	for _, subscription := range jb.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	jb.subscriptionsMu.Unlock()
+	for _, subscription := range subscriptions {
+		status.Subscriptions[subscription.SubscriptionID] = subscription.health(status.CheckedAt)
+	}
+
+	var unhealthy []string
+	if !status.API.Healthy {
+		unhealthy = append(unhealthy, fmt.Sprintf("api: %v", status.API.Err))
+	}
+	for subscriptionID, subscription := range status.Subscriptions {
+		if !subscription.Healthy {
+			unhealthy = append(unhealthy, "subscription "+subscriptionID+": not connected")
+		}
+	}
+	if len(unhealthy) > 0 {
+		sort.Strings(unhealthy)
+		return status, fmt.Errorf("%w: %s", ErrUnhealthy, strings.Join(unhealthy, ", "))
+	}
+	status.Healthy = true
+	return status, nil
+}
+
+// Ping measures the round trip of a request to the REST API, the chain tip is fetched bypassing WithChainTipCache
+func (jb *Client) Ping(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if _, err := jb.getChainTip(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// health returns the state of the websocket connection of the subscription at now
+func (s *Subscription) health(now time.Time) SubscriptionHealth {
+	health := SubscriptionHealth{
+		Connected:      s.IsConnected(),
+		LastBlock:      s.LastBlock(),
+		Reconnects:     atomic.LoadUint64(&s.counters.reconnects),
+		LastConnectErr: s.connectErr(),
+	}
+	if lastControl := atomic.LoadInt64(&s.counters.lastControl); lastControl > 0 {
+		health.LastControl = time.Unix(0, lastControl)
+		health.LastControlAge = now.Sub(health.LastControl)
+	}
+	health.Healthy = health.Connected
+	return health
+}
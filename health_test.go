@@ -0,0 +1,99 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient_Health will test checking the REST API and the connections of the subscriptions
+func TestClient_Health(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		t.Run("without subscriptions", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusOK, `{"hash":"tip","height":800000}`)
+			status, err := server.newClient().Health(context.Background())
+			require.NoError(t, err)
+			assert.True(t, status.Healthy)
+			assert.True(t, status.API.Healthy)
+			assert.Equal(t, uint32(800000), status.API.Height)
+			assert.Positive(t, status.API.Latency)
+			assert.Empty(t, status.Subscriptions)
+		})
+
+		t.Run("with a subscription", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusOK, `{"hash":"tip","height":800000}`)
+			client := server.newClient()
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			controlChannel := "query:" + testSubscriptionID + ":control"
+			server.waitSubscribed(controlChannel)
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+			require.Eventually(t, func() bool {
+				return !subscription.Stats().LastControlTime.IsZero()
+			}, 5*time.Second, 10*time.Millisecond)
+
+			status, err := client.Health(context.Background())
+			require.NoError(t, err)
+			assert.True(t, status.Healthy)
+			health := status.Subscriptions[testSubscriptionID]
+			assert.True(t, health.Healthy)
+			assert.True(t, health.Connected)
+			assert.Equal(t, uint64(100), health.LastBlock)
+			assert.Equal(t, subscription.Stats().LastControlTime, health.LastControl)
+			assert.GreaterOrEqual(t, health.LastControlAge, time.Duration(0))
+		})
+
+		t.Run("api failing", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusUnauthorized, `{}`)
+			status, err := server.newClient().Health(context.Background())
+			require.ErrorIs(t, err, ErrUnhealthy)
+			require.NotNil(t, status)
+			assert.False(t, status.Healthy)
+			assert.False(t, status.API.Healthy)
+			assert.ErrorIs(t, status.API.Err, ErrUnauthorized)
+		})
+
+		t.Run("deadline", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			status, err := server.newClient().Health(ctx)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Nil(t, status)
+		})
+	})
+}
+
+// TestClient_Ping will test measuring the round trip to the REST API
+func TestClient_Ping(t *testing.T) {
+	server := newFakeServer(t)
+	server.handleJSON("/v1/block_header/tip", http.StatusOK, `{"hash":"tip","height":800000}`)
+	client := server.newClient(WithChainTipCache(time.Minute))
+	_, err := client.GetChainTip(context.Background())
+	require.NoError(t, err)
+
+	latency, err := client.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Positive(t, latency)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Ping(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
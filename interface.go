@@ -2,15 +2,17 @@ package junglebus
 
 import (
 	"context"
+	"time"
 
 	"github.com/GorillaPool/go-junglebus/models"
 	"github.com/centrifugal/centrifuge-go"
 )
 
-// StatusCode defines the codes that can be returned from the control channel of a subscription
+// StatusCode defines the codes that can be returned from the control channel of a subscription, codes below 100
+// are sent by the client about its connection and codes from 100 are forwarded from the server, see status_code.go
 type StatusCode uint
 
-var (
+const (
 	// StatusConnecting is when connecting to a server
 	StatusConnecting StatusCode = 1
 	// StatusConnected is when connected to a server
@@ -29,10 +31,38 @@ var (
 	StatusSubscribed StatusCode = 21
 	// StatusUnsubscribed is when unsubscribed on a server
 	StatusUnsubscribed StatusCode = 29
+	// StatusCancelled is when the subscription was stopped by cancelling its context
+	StatusCancelled StatusCode = 30
+	// StatusTokenRefreshFailed is when the token provider failed to provide a new token for the connection
+	StatusTokenRefreshFailed StatusCode = 40
+	// StatusTokenRefreshed is when the connection was replaced by one with a new token before the token expired
+	StatusTokenRefreshed StatusCode = 41
+	// StatusStalled is when no messages arrived for the stall timeout and the connection is replaced, see WithStallTimeout
+	StatusStalled StatusCode = 50
+	// StatusFailover is when the client fails over to the next server of WithServers, the message names the server
+	StatusFailover StatusCode = 51
+	// StatusStreamReset is when the stream position of the checkpoint is no longer available on the server and the
+	// subscription resumes at the block of the checkpoint instead, see Checkpoint
+	StatusStreamReset StatusCode = 52
+	// StatusRepairStarted is when the server no longer streams blocks the subscription resumes from, and they are
+	// fetched from the REST API before streaming continues, the message names the blocks, see WithNoGapRepair
+	StatusRepairStarted StatusCode = 53
+	// StatusRepaired is when the blocks of StatusRepairStarted were passed on and streaming continues
+	StatusRepaired StatusCode = 54
+	// StatusLagging is when the subscription or a consumer is behind the stream by more than the threshold of
+	// WithLagWarning, the message names which one and by how much
+	StatusLagging StatusCode = 55
+	// StatusDropped is when messages were dropped because the queue of the subscription was full, see
+	// WithOverflowPolicy
+	StatusDropped StatusCode = 56
 	// SubscriptionWait is sent when the server is waiting for a new block to be ready to send transactions
 	SubscriptionWait StatusCode = 100
 	// SubscriptionError is sent when an error was encountered
 	SubscriptionError StatusCode = 101
+	// SubscriptionDropped is StatusDropped
+	//
+	// Deprecated: the status is sent by the client, use StatusDropped
+	SubscriptionDropped = StatusDropped
 	// SubscriptionBlockDone is sent when a block is done processing
 	SubscriptionBlockDone StatusCode = 200
 	// SubscriptionReorg is sent when a reorg is initialized
@@ -41,13 +71,56 @@ var (
 	StatusError StatusCode = 999
 )
 
+// EventHandler holds the callbacks of a subscription. At least one of OnTransaction, OnBlock, OnMempool or their
+// error returning or context variants is required, subscribing fails with ErrNoHandlers otherwise. The ones that are set decide
+// which channels are subscribed to, the other callbacks are optional.
+//
+// OnStatus and OnError may be left out, the statuses of the connection are then not built at all and the errors are
+// logged with the logger of the client.
+// OnBlockDone is optional, when set it is called instead of OnStatus once all transactions of a block have been sent.
+// OnReorg is optional, when set it is called instead of OnStatus with the height to roll back to when the chain reorganized.
+// OnBlock is optional, when set it is called instead of OnTransaction with the transactions of a block once the block
+// is done, large blocks are passed on in parts of at most the max batch size (see WithMaxBatchSize).
+// OnUnknownChannel is optional, it is called with the raw payload of publications on channels that do not belong to
+// the subscription, without it they are sent to OnError as an UnknownChannelError.
+// OnRawPublication is optional, it is called with the payload of every publication as received, before decoding it.
+// Publications that fail to decode are sent to OnError as a DecodeError.
+// OnConfirmed and OnEvicted are optional, they are only called with WithMempoolTracking. OnConfirmed is called after
+// OnTransaction for a mined transaction that was seen in the mempool before, OnEvicted for a mempool transaction
+// that is no longer tracked without being mined.
+// OnTransactionE and OnMempoolE are optional, when set they are called instead of OnTransaction and OnMempool. A
+// transaction they return an error or panic for is retried following WithHandlerRetry, once the attempts are used
+// up it is passed to OnDeadLetter with the last error (or to OnError as a DeadLetterError without OnDeadLetter) and
+// the stream moves on. A dead-lettered transaction counts as handled for the block it belongs to.
+// OnTransactionCtx and OnMempoolCtx are optional, when set they are called instead of OnTransaction and OnMempool
+// (and their error returning variants) with how the transaction was received, see MessageContext. They are called
+// after the middlewares of WithTxMiddleware, OnBlock still takes the mined transactions when it is set. The context of
+// the span of the transaction with WithTracer is MessageContext.Context.
+// OnProgress is optional, it is called every interval of WithProgressInterval with the progress of the subscription.
+// OnCaughtUp is optional, it is called with the block of the waiting message once the subscription caught up with
+// the chain tip, after the messages before it were handled. It is called again when the subscription fell behind
+// after reconnecting and caught up once more, see IsCaughtUp.
 type EventHandler struct {
-	OnTransaction func(tx *models.TransactionResponse)
-	OnMempool     func(tx *models.TransactionResponse)
-	OnStatus      func(response *models.ControlResponse)
-	OnError       func(err error)
-	ctx           context.Context
-	debug         bool
+	OnTransaction    func(tx *models.TransactionResponse)
+	OnMempool        func(tx *models.TransactionResponse)
+	OnStatus         func(response *models.ControlResponse)
+	OnBlockDone      func(height uint32, transactions uint64)
+	OnReorg          func(height uint32)
+	OnBlock          func(height uint32, transactions []*models.TransactionResponse)
+	OnError          func(err error)
+	OnUnknownChannel func(channel string, data []byte)
+	OnRawPublication func(channel string, offset uint64, data []byte)
+	OnConfirmed      func(tx *models.TransactionResponse, firstSeen time.Time)
+	OnEvicted        func(txID string, firstSeen time.Time)
+	OnTransactionE   func(tx *models.TransactionResponse) error
+	OnMempoolE       func(tx *models.TransactionResponse) error
+	OnDeadLetter     func(tx *models.TransactionResponse, err error)
+	OnTransactionCtx func(ctx MessageContext, tx *models.TransactionResponse)
+	OnMempoolCtx     func(ctx MessageContext, tx *models.TransactionResponse)
+	OnProgress       func(progress Progress)
+	OnCaughtUp       func(height uint32)
+	ctx              context.Context
+	debug            bool
 }
 
 func (e *EventHandler) OnPublish(event centrifuge.PublicationEvent) {
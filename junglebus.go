@@ -7,10 +7,24 @@
 package junglebus
 
 import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
 	"github.com/GorillaPool/go-junglebus/transports"
 )
 
-var DefaultServer = "junglebus.gorillapool.io"
+// DefaultServer is the server of a client without WithHTTP or WithServers
+const DefaultServer = "junglebus.gorillapool.io"
+
+// Logger is used for the internal logging of the client, see WithLogger
+type Logger = transports.Logger
+
+// Tracer starts the spans of the client, see WithTracer
+type Tracer = transports.Tracer
 
 // ClientOps are used for client options
 type ClientOps func(c *Client)
@@ -18,29 +32,118 @@ type ClientOps func(c *Client)
 // Client is the go-junglebus client
 type Client struct {
 	transports.TransportService
-	transport        transports.TransportService
-	transportOptions []transports.ClientOps
-	subscription     *Subscription
-	debug            bool
+	transport           transports.Transport
+	service             transports.TransportService // the transport when it was not injected with WithTransport
+	customTransport     transports.Transport
+	transportOptions    []transports.ClientOps
+	subscriptions       map[string]*Subscription
+	subscriptionsMu     sync.Mutex
+	reconnectPolicy     reconnectPolicy
+	logger              Logger
+	tracer              Tracer
+	tlsConfig           *tls.Config
+	proxy               func(*http.Request) (*url.URL, error)
+	websocket           WebsocketTimeouts
+	websocketURL        *url.URL // overrides the URL of the websocket connections, see WithWebsocketURL
+	failover            failover // the servers of WithServers
+	headers             http.Header
+	userAgent           string
+	tokenProvider       TokenProvider // nil for the transport
+	tokenRefreshLeeway  time.Duration
+	tokenStore          TokenStore
+	noAuth              bool // whether subscriptions connect without a token
+	jsonProtocol        bool // whether subscriptions use the JSON protocol of centrifuge instead of protobuf
+	compression         bool // whether subscriptions negotiate permessage-deflate
+	maxMessageSize      int64
+	watchersMu          sync.Mutex
+	watchers            map[string]map[*txWatcher]struct{} // by txid, see WatchTransaction
+	watchPolicy         reconnectPolicy                    // the delays between polling watched transactions
+	chainTipTTL         time.Duration
+	chainTipMu          sync.Mutex
+	chainTip            *models.BlockHeader // cached when chainTipTTL is set
+	chainTipAt          time.Time
+	chainTipRefresh     *chainTipRefresh // the request in flight shared by the callers of GetChainTip
+	headerPollInterval  time.Duration    // how often SubscribeBlockHeaders polls the chain tip
+	addressPollInterval time.Duration    // how often SubscribeAddresses looks up new transactions
+	headersMu           sync.Mutex
+	currentTip          *models.BlockHeader // the best header of SubscribeBlockHeaders
+	staleBlocks         []string            // hashes of the last blocks replaced by a reorg, see IsStaleBlock
+	optionErr           error               // the first invalid option, returned by New
+	debug               bool
 }
 
-// New create a new jungle bus client
+// NewDefault returns a client of DefaultServer with the default options, like New without options
+func NewDefault() *Client {
+	client, _ := New() // the default options are always valid
+	return client
+}
+
+// New create a new jungle bus client, New fails with ErrInvalidServerURL for a server url that can not be parsed
 func New(opts ...ClientOps) (*Client, error) {
-	client := &Client{}
+	client := &Client{
+		subscriptions: map[string]*Subscription{},
+	}
 
 	client.setDefaultOptions()
 
 	for _, opt := range opts {
 		opt(client)
 	}
+	if client.optionErr != nil {
+		return nil, client.optionErr
+	}
+
+	if client.customTransport != nil {
+		client.transport = client.customTransport
+		client.service, _ = client.customTransport.(transports.TransportService)
+	} else if len(client.transportOptions) > 0 {
+		var err error
+		if client.service, err = transports.NewTransport(
+			append([]transports.ClientOps{
+				transports.WithHTTP(DefaultServer), transports.WithMaxResponseSize(DefaultMaxMessageSize),
+			}, client.transportOptions...)...,
+		); err != nil {
+			return nil, err
+		}
+		client.transport = client.service
+	}
+	if client.failover.enabled() {
+		if client.service == nil {
+			// an injected transport does not tell which server it sends the requests to
+			client.failover.servers = nil
+		} else {
+			client.service.Use(client.failoverMiddleware)
+		}
+	}
 
 	return client, nil
 }
 
 func (jb *Client) setDefaultOptions() {
-	jb.transport, _ = transports.NewTransport(
+	jb.service, _ = transports.NewTransport(
 		transports.WithHTTP(DefaultServer),
+		transports.WithMaxResponseSize(DefaultMaxMessageSize),
 	)
+	jb.transport = jb.service
+	jb.logger = transports.NopLogger{}
+	jb.tracer = transports.NopTracer{}
+	jb.websocket = DefaultWebsocketTimeouts
+	jb.userAgent = transports.JungleBusUserAgent
+	jb.tokenRefreshLeeway = DefaultTokenRefreshLeeway
+	jb.maxMessageSize = DefaultMaxMessageSize
+	jb.failover.threshold = DefaultFailoverThreshold
+	jb.headerPollInterval = DefaultHeaderPollInterval
+	jb.addressPollInterval = DefaultAddressPollInterval
+	jb.watchPolicy = reconnectPolicy{
+		minDelay: DefaultWatchPollMinDelay,
+		maxDelay: DefaultWatchPollMaxDelay,
+		factor:   2,
+	}
+	jb.reconnectPolicy = reconnectPolicy{
+		minDelay: DefaultReconnectMinDelay,
+		maxDelay: DefaultReconnectMaxDelay,
+		factor:   DefaultReconnectFactor,
+	}
 }
 
 // SetDebug turn the debugging on or off
@@ -53,7 +156,8 @@ func (jb *Client) IsDebug() bool {
 	return jb.debug
 }
 
-// GetTransport returns the current transport service
+// GetTransport returns the current transport service, it points to nil when a transport
+// that is not a transports.TransportService was injected with WithTransport
 func (jb *Client) GetTransport() *transports.TransportService {
-	return &jb.transport
+	return &jb.service
 }
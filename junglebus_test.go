@@ -1,11 +1,22 @@
 package junglebus
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/GorillaPool/go-junglebus/models"
 	"github.com/GorillaPool/go-junglebus/transports"
@@ -58,7 +69,52 @@ func TestNewJungleBusClient(t *testing.T) {
 	t.Run("valid client", func(t *testing.T) {
 		client, err := New()
 		require.NoError(t, err)
-		assert.IsType(t, Client{}, *client)
+		assert.IsType(t, &Client{}, client)
+	})
+
+	t.Run("NewDefault", func(t *testing.T) {
+		client := NewDefault()
+		require.NotNil(t, client)
+		assert.Equal(t, DefaultServer, client.transport.GetServerURL())
+		assert.True(t, client.transport.IsSSL())
+	})
+
+	t.Run("server url", func(t *testing.T) {
+		tests := []struct {
+			serverURL string
+			expected  string
+		}{
+			{"junglebus.gorillapool.io", "wss://junglebus.gorillapool.io/connection/websocket"},
+			{"https://junglebus.gorillapool.io/", "wss://junglebus.gorillapool.io/connection/websocket"},
+			{"http://localhost:8080/", "ws://localhost:8080/connection/websocket"},
+			{"https://example.com/junglebus/", "wss://example.com/junglebus/connection/websocket"},
+		}
+		for _, test := range tests {
+			client, err := New(WithHTTP(test.serverURL), WithJSONProtocol())
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, client.websocketEndpoint(), test.serverURL)
+		}
+	})
+
+	t.Run("base path", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/junglebus/v1/transaction/get/"+testTxID, http.StatusOK, `{"id":"`+testTxID+`"}`)
+		client, err := New(WithHTTP(server.URL + "/junglebus/"))
+		require.NoError(t, err)
+		tx, err := client.GetTransaction(context.Background(), testTxID)
+		require.NoError(t, err)
+		assert.Equal(t, testTxID, tx.ID)
+	})
+
+	t.Run("invalid server url", func(t *testing.T) {
+		for _, opt := range []ClientOps{
+			WithHTTP("ftp://junglebus.gorillapool.io"),
+			WithHTTPClient("https://", http.DefaultClient),
+			WithServers("https://one.example.com", "https://two.example.com?key=value"),
+		} {
+			_, err := New(opt)
+			assert.ErrorIs(t, err, ErrInvalidServerURL)
+		}
 	})
 }
 
@@ -140,3 +196,788 @@ func getTestClient(transportHandler testTransportHandler) *Client {
 
 	return client
 }
+
+// testLogger records the formatted log lines
+type testLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) log(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) { l.log(format, args...) }
+func (l *testLogger) Infof(format string, args ...interface{})  { l.log(format, args...) }
+func (l *testLogger) Errorf(format string, args ...interface{}) { l.log(format, args...) }
+
+// TestWithLogger will test routing the debug output through the logger
+func TestWithLogger(t *testing.T) {
+	server := newFakeServer(t)
+	server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+
+	logger := &testLogger{}
+	client := server.newClient(WithDebugging(true), WithLogger(logger))
+
+	_, err := client.GetTransaction(context.Background(), txID)
+	require.NoError(t, err)
+	require.Len(t, logger.lines, 1)
+	assert.Contains(t, logger.lines[0], "Transaction: ")
+}
+
+type testParentKey struct{}
+
+// testSpan is a span recorded by testTracer
+type testSpan struct {
+	mu         *sync.Mutex
+	name       string
+	parent     interface{}
+	attributes map[string]interface{}
+	ended      bool
+}
+
+func (s *testSpan) SetAttributes(attributes ...transports.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attribute := range attributes {
+		s.attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.SetAttributes(transports.Attribute{Key: "error", Value: err})
+}
+
+func (s *testSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// testTracer records the started spans, the parent of a span started in the context of another one is its name
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attributes ...transports.Attribute) (context.Context, transports.Span) {
+	span := &testSpan{mu: &t.mu, name: name, parent: ctx.Value(testParentKey{}), attributes: map[string]interface{}{}}
+	span.SetAttributes(attributes...)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, testParentKey{}, name), span
+}
+
+// span returns a copy of the first span with the given name
+func (t *testTracer) span(name string) *testSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, span := range t.spans {
+		if span.name == name {
+			snapshot := *span
+			snapshot.attributes = map[string]interface{}{}
+			for key, value := range span.attributes {
+				snapshot.attributes[key] = value
+			}
+			return &snapshot
+		}
+	}
+	return nil
+}
+
+// TestWithTracer will test tracing requests and handler calls in the context of the caller
+func TestWithTracer(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+
+		tracer := &testTracer{}
+		client := server.newClient(WithTracer(tracer))
+		ctx := context.WithValue(context.Background(), testParentKey{}, "request")
+
+		_, err := client.GetTransaction(ctx, txID)
+		require.NoError(t, err)
+		span := tracer.span("junglebus GET")
+		require.NotNil(t, span)
+		assert.Equal(t, "request", span.parent)
+		assert.Equal(t, server.URL+"/v1/transaction/get/"+txID, span.attributes["http.url"])
+		assert.Equal(t, http.StatusOK, span.attributes["http.status_code"])
+		assert.True(t, span.ended)
+
+		received := make(chan struct{})
+		subscription, err := client.Subscribe(ctx, "test-subscription", 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) { close(received) },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		server.waitSubscribed("query:test-subscription:100")
+		server.publishTransaction("query:test-subscription:100", txID)
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction not received")
+		}
+
+		require.Eventually(t, func() bool {
+			span = tracer.span("junglebus OnTransaction")
+			return span != nil && span.ended
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "request", span.parent)
+		assert.Equal(t, "test-subscription", span.attributes["junglebus.subscription_id"])
+		assert.Equal(t, "main", span.attributes["junglebus.channel"])
+		assert.Equal(t, txID, span.attributes["junglebus.txid"])
+	})
+}
+
+// TestWithTracer_handlers will test passing the span of a transaction to OnTransactionCtx and tracing the calls of
+// OnReorg and OnError
+func TestWithTracer_handlers(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		tracer := &testTracer{}
+		client := server.newClient(WithTracer(tracer))
+		ctx := context.WithValue(context.Background(), testParentKey{}, "subscribe")
+
+		parents := make(chan interface{}, 1)
+		reorgs := make(chan uint32, 1)
+		errs := make(chan error, 1)
+		subscription, err := client.Subscribe(ctx, "test-subscription", 100, EventHandler{
+			OnTransactionCtx: func(ctx MessageContext, _ *models.TransactionResponse) {
+				parents <- ctx.Context().Value(testParentKey{})
+			},
+			OnReorg:  func(height uint32) { reorgs <- height },
+			OnStatus: func(*models.ControlResponse) {},
+			OnError:  func(err error) { errs <- err },
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:test-subscription:100"
+		controlChannel := "query:test-subscription:control"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(controlChannel)
+		server.publishTransaction(mainChannel, txID)
+		select {
+		case parent := <-parents:
+			assert.Equal(t, "junglebus OnTransaction", parent, "the handler is given the context of its span")
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction not received")
+		}
+		span := tracer.span("junglebus OnTransaction")
+		require.NotNil(t, span)
+		assert.Equal(t, "subscribe", span.parent)
+		assert.Equal(t, txID, span.attributes["junglebus.txid"])
+
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionReorg), Block: 99})
+		select {
+		case height := <-reorgs:
+			assert.Equal(t, uint32(99), height)
+		case <-time.After(5 * time.Second):
+			t.Fatal("reorg not received")
+		}
+		require.Eventually(t, func() bool {
+			span = tracer.span("junglebus OnReorg")
+			return span != nil && span.ended
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "subscribe", span.parent)
+		assert.Equal(t, uint32(99), span.attributes["junglebus.block_height"])
+
+		server.publish(mainChannel, server.invalidPublication())
+		var received error
+		select {
+		case received = <-errs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("error not received")
+		}
+		require.Eventually(t, func() bool {
+			span = tracer.span("junglebus OnError")
+			return span != nil && span.ended
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, received, span.attributes["error"])
+	})
+}
+
+// countingRoundTripper counts the requests going through it
+type countingRoundTripper struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (c *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests = append(c.requests, req.URL.Path)
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// TestWithCustomHTTPClient will test using the injected http client for all requests
+func TestWithCustomHTTPClient(t *testing.T) {
+	t.Run("used for all requests", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+
+		roundTripper := &countingRoundTripper{}
+		// the order of the options does not matter
+		client, err := New(WithCustomHTTPClient(&http.Client{Transport: roundTripper}), WithHTTP(server.URL))
+		require.NoError(t, err)
+
+		_, err = client.GetTransaction(context.Background(), txID)
+		require.NoError(t, err)
+		_, err = (*client.GetTransport()).GetSubscriptionToken(context.Background(), "test-subscription")
+		require.NoError(t, err)
+		_, err = (*client.GetTransport()).RefreshToken(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"/v1/transaction/get/" + txID,
+			"/v1/user/subscription-token",
+			"/v1/user/refresh-token",
+		}, roundTripper.requests)
+	})
+
+	t.Run("timeout is respected", func(t *testing.T) {
+		server := newFakeServer(t)
+		release := make(chan struct{})
+		defer close(release)
+		server.HandleFunc("/v1/transaction/get/"+txID, func(http.ResponseWriter, *http.Request) {
+			<-release
+		})
+
+		client := server.newClient(WithCustomHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+		_, err := client.GetTransaction(context.Background(), txID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Client.Timeout")
+	})
+}
+
+// TestWithTLSConfig will test connecting to a server with a certificate of a private CA
+func TestWithTLSConfig(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeTLSServer(t)
+		server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+
+		subscribe := func(t *testing.T, client *Client) {
+			connected := make(chan struct{})
+			var connectedOnce sync.Once
+			subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus: func(status *models.ControlResponse) {
+					if status.StatusCode == uint32(StatusConnected) {
+						connectedOnce.Do(func() { close(connected) })
+					}
+				},
+				OnError: func(error) {},
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			select {
+			case <-connected:
+			case <-time.After(5 * time.Second):
+				t.Fatal("websocket not connected")
+			}
+		}
+
+		t.Run("unknown authority", func(t *testing.T) {
+			client := server.newClient()
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "certificate")
+		})
+
+		t.Run("certificate pool", func(t *testing.T) {
+			pool := x509.NewCertPool()
+			pool.AddCert(server.Certificate())
+			client := server.newClient(WithTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+
+			transaction, err := client.GetTransaction(context.Background(), txID)
+			require.NoError(t, err)
+			assert.Equal(t, txID, transaction.ID)
+			subscribe(t, client)
+		})
+
+		t.Run("insecure skip verify", func(t *testing.T) {
+			client := server.newClient(WithInsecureSkipVerify())
+
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.NoError(t, err)
+			subscribe(t, client)
+		})
+	})
+}
+
+// testProxy is a forward proxy recording the requests going through it
+type testProxy struct {
+	*httptest.Server
+	mu            sync.Mutex
+	requests      []string
+	authorization []string
+}
+
+func newTestProxy(t *testing.T) *testProxy {
+	p := &testProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p.mu.Lock()
+		p.requests = append(p.requests, req.Method+" "+req.Host)
+		p.authorization = append(p.authorization, req.Header.Get("Proxy-Authorization"))
+		p.mu.Unlock()
+
+		if req.Method != http.MethodConnect {
+			req.RequestURI = ""
+			req.Header.Del("Proxy-Authorization")
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.Copy(w, resp.Body)
+			return
+		}
+
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			_ = target.Close()
+			return
+		}
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			_, _ = io.Copy(target, conn)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		_ = conn.Close()
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// TestWithProxy will test sending requests and websocket connections through a proxy
+func TestWithProxy(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+		proxy := newTestProxy(t)
+		proxyURL, err := url.Parse(proxy.URL)
+		require.NoError(t, err)
+		proxyURL.User = url.UserPassword("user", "secret")
+
+		client := server.newClient(WithProxy(http.ProxyURL(proxyURL)))
+		_, err = client.GetTransaction(context.Background(), txID)
+		require.NoError(t, err)
+
+		subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed("query:test-subscription:100")
+
+		host := strings.TrimPrefix(server.URL, "http://")
+		proxy.mu.Lock()
+		defer proxy.mu.Unlock()
+		// the transaction, the subscription token and the details with the earliest block of the subscription
+		assert.Equal(t, []string{"GET " + host, "POST " + host, "GET " + host, "CONNECT " + host}, proxy.requests)
+		for _, authorization := range proxy.authorization {
+			assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", authorization)
+		}
+	})
+}
+
+// TestWithProxy_environment will test replacing the proxy of the environment the websocket dialer connects through
+func TestWithProxy_environment(t *testing.T) {
+	server := newFakeServer(t)
+	server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+	proxy := newTestProxy(t)
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	endpoint, err := url.Parse("ws" + strings.TrimPrefix(server.URL, "http") + websocketPath)
+	require.NoError(t, err)
+
+	// never dialed, the address only tells the connection to the proxy of the environment apart
+	environmentURL := &url.URL{Scheme: "http", Host: "environment.invalid:3128"}
+	dial := dialThroughProxy(http.ProxyURL(proxyURL), http.ProxyURL(environmentURL), endpoint)
+
+	// like the websocket dialer, connect to the proxy of the environment and ask it for a tunnel
+	conn, err := dial(context.Background(), "tcp", environmentURL.Host)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	require.NoError(t, connectTunnel(context.Background(), conn, environmentURL, endpoint.Host))
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/v1/transaction/get/"+txID, nil)
+	require.NoError(t, err)
+	require.NoError(t, request.Write(conn))
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	require.NoError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	proxy.mu.Lock()
+	assert.Equal(t, []string{"CONNECT " + endpoint.Host}, proxy.requests)
+	proxy.mu.Unlock()
+
+	t.Run("other requests", func(t *testing.T) {
+		conn, err := dial(context.Background(), "tcp", environmentURL.Host)
+		require.NoError(t, err)
+		defer func() {
+			_ = conn.Close()
+		}()
+		assert.Error(t, connectTunnel(context.Background(), conn, environmentURL, "other.invalid:443"))
+	})
+}
+
+// TestWithWebsocketTimeouts will test setting and validating the websocket timeouts
+func TestWithWebsocketTimeouts(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		timeouts := DefaultWebsocketTimeouts
+		timeouts.Write = 0
+		_, err := New(WithWebsocketTimeouts(timeouts))
+		require.ErrorIs(t, err, ErrInvalidTimeout)
+		assert.Contains(t, err.Error(), "websocket write timeout is 0s")
+
+		timeouts = DefaultWebsocketTimeouts
+		timeouts.Handshake = -time.Second
+		_, err = New(WithWebsocketTimeouts(timeouts))
+		require.ErrorIs(t, err, ErrInvalidTimeout)
+		assert.Contains(t, err.Error(), "websocket handshake timeout is -1s")
+	})
+
+	t.Run("handshake timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/user/subscription-token", func(w http.ResponseWriter, _ *http.Request) {
+			mustWrite(w, `{"token":"test-token"}`)
+		})
+		mux.HandleFunc("/connection/websocket", func(http.ResponseWriter, *http.Request) {
+			<-release
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		defer close(release)
+
+		timeouts := DefaultWebsocketTimeouts
+		timeouts.Handshake = 100 * time.Millisecond
+		client, err := New(WithHTTP(server.URL), WithWebsocketTimeouts(timeouts), WithMaxReconnectAttempts(1))
+		require.NoError(t, err)
+
+		start := time.Now()
+		subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		select {
+		case <-subscription.Done():
+			assert.ErrorIs(t, subscription.Err(), ErrMaxReconnectAttempts)
+			assert.Less(t, time.Since(start), 5*time.Second)
+		case <-time.After(10 * time.Second):
+			t.Fatal("handshake did not time out")
+		}
+	})
+}
+
+// TestWithWebsocketURL will test connecting subscriptions to another host than the REST requests
+func TestWithWebsocketURL(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		t.Run("invalid", func(t *testing.T) {
+			for _, websocketURL := range []string{"ftp://ws.example.com", "ws://", "wss://ws.example.com:port", ""} {
+				_, err := New(WithWebsocketURL(websocketURL))
+				assert.ErrorIs(t, err, ErrInvalidWebsocketURL, websocketURL)
+			}
+		})
+
+		t.Run("endpoint", func(t *testing.T) {
+			tests := []struct {
+				serverURL    string
+				websocketURL string
+				json         bool
+				expected     string
+			}{
+				{"https://api.example.com", "", false, "wss://api.example.com/connection/websocket?format=protobuf"},
+				{"http://api.example.com", "", true, "ws://api.example.com/connection/websocket"},
+				{"https://api.example.com", "ws.example.com", false, "wss://ws.example.com/connection/websocket?format=protobuf"},
+				{"http://api.example.com", "ws.example.com:8080", true, "ws://ws.example.com:8080/connection/websocket"},
+				{"http://api.example.com", "wss://ws.example.com", false, "wss://ws.example.com/connection/websocket?format=protobuf"},
+				{"https://api.example.com", "http://ws.example.com/", true, "ws://ws.example.com/connection/websocket"},
+				{"https://api.example.com", "https://ws.example.com/socket?region=eu", false,
+					"wss://ws.example.com/socket?format=protobuf&region=eu"},
+			}
+			for _, test := range tests {
+				opts := []ClientOps{WithHTTP(test.serverURL)}
+				if test.websocketURL != "" {
+					opts = append(opts, WithWebsocketURL(test.websocketURL))
+				}
+				if test.json {
+					opts = append(opts, WithJSONProtocol())
+				}
+				client, err := New(opts...)
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, client.websocketEndpoint())
+			}
+		})
+
+		t.Run("subscribe", func(t *testing.T) {
+			api := newFakeServer(t)
+			wsServer := newFakeServer(t)
+			client := api.newClient(WithWebsocketURL(wsServer.URL))
+
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+
+			wsServer.waitSubscribed("query:" + testSubscriptionID + ":control")
+			assert.Empty(t, api.dialTimes())
+			assert.NotNil(t, api.requestHeaders("/v1/user/subscription-token"))
+		})
+	})
+}
+
+// TestWithHeaders will test sending custom headers and user agent with all requests
+func TestWithHeaders(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+
+		t.Run("default user agent", func(t *testing.T) {
+			client := server.newClient()
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.NoError(t, err)
+			assert.Equal(t, transports.JungleBusUserAgent, server.requestHeaders("/v1/transaction/get/"+txID).Get("User-Agent"))
+		})
+
+		t.Run("custom headers", func(t *testing.T) {
+			client := server.newClient(
+				WithToken("client-token"),
+				WithHeaders(map[string]string{"X-Org-Token": "org", "token": "header-token"}),
+				WithUserAgent("indexer/1.0"),
+			)
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.NoError(t, err)
+			headers := server.requestHeaders("/v1/transaction/get/" + txID)
+			assert.Equal(t, "org", headers.Get("X-Org-Token"))
+			assert.Equal(t, "client-token", headers.Get("token"))
+			assert.Equal(t, "indexer/1.0", headers.Get("User-Agent"))
+
+			subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:test-subscription:100")
+			headers = server.requestHeaders("/connection/websocket")
+			assert.Equal(t, "org", headers.Get("X-Org-Token"))
+			assert.Equal(t, "indexer/1.0", headers.Get("User-Agent"))
+		})
+
+		t.Run("token header without token", func(t *testing.T) {
+			client := server.newClient(WithHeaders(map[string]string{"token": "header-token"}))
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.NoError(t, err)
+			assert.Equal(t, "header-token", server.requestHeaders("/v1/transaction/get/"+txID).Get("token"))
+		})
+	})
+}
+
+// TestWithRetryPolicy will test retrying requests failing with a 5xx status
+func TestWithRetryPolicy(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		retryPolicy := transports.RetryPolicy{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+		t.Run("subscribe after a bad gateway", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.failRequests("/v1/user/subscription-token", 1, http.StatusBadGateway)
+
+			logger := &testLogger{}
+			client := server.newClient(WithRetryPolicy(retryPolicy), WithLogger(logger))
+			subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:test-subscription:100")
+			assert.Equal(t, uint64(1), (*client.GetTransport()).Retries())
+			logger.mu.Lock()
+			assert.Contains(t, strings.Join(logger.lines, "\n"), "retrying POST")
+			logger.mu.Unlock()
+		})
+
+		t.Run("get gives up after max attempts", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+			server.failRequests("/v1/transaction/get/"+txID, 3, http.StatusServiceUnavailable)
+
+			client := server.newClient(WithRetryPolicy(retryPolicy))
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "503")
+			assert.Equal(t, uint64(2), (*client.GetTransport()).Retries())
+
+			_, err = client.GetTransaction(context.Background(), txID)
+			require.NoError(t, err)
+		})
+
+		t.Run("disabled", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, transactionJSON)
+			server.failRequests("/v1/transaction/get/"+txID, 1, http.StatusBadGateway)
+
+			client := server.newClient(WithRetryPolicy(transports.NoRetryPolicy))
+			_, err := client.GetTransaction(context.Background(), txID)
+			require.Error(t, err)
+			assert.Equal(t, uint64(0), (*client.GetTransport()).Retries())
+		})
+	})
+}
+
+// TestRateLimited will test requests that are rate limited by the server
+func TestRateLimited(t *testing.T) {
+	var requests int32
+	server := newFakeServer(t)
+	server.HandleFunc("/v1/transaction/get/"+txID, func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&requests, 1)%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, transactionJSON)
+	})
+
+	t.Run("retried by default", func(t *testing.T) {
+		client := server.newClient()
+		transaction, err := client.GetTransaction(context.Background(), txID)
+		require.NoError(t, err)
+		assert.Equal(t, txID, transaction.ID)
+	})
+
+	t.Run("rate limit error", func(t *testing.T) {
+		client := server.newClient(WithRetryPolicy(transports.NoRetryPolicy))
+		_, err := client.GetTransaction(context.Background(), txID)
+		var rateLimitErr *RateLimitError
+		require.True(t, errors.As(err, &rateLimitErr))
+		assert.Equal(t, time.Duration(0), rateLimitErr.RetryAfter)
+	})
+}
+
+// TestNotFound will test matching the errors of REST requests
+func TestNotFound(t *testing.T) {
+	server := newFakeServer(t)
+	server.handleJSON("/v1/transaction/get/"+txID, http.StatusNotFound, `{"message":"not found"}`)
+
+	client := server.newClient()
+	_, err := client.GetTransaction(context.Background(), txID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "/v1/transaction/get/"+txID, apiErr.Endpoint)
+	assert.Equal(t, "not found", apiErr.Body["message"])
+}
+
+// TestWithTransportMiddleware will test passing the subscription token request through the middlewares
+func TestWithTransportMiddleware(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+
+		var endpoints []string
+		client := server.newClient(WithTransportMiddleware(func(next transports.RoundTripperFunc) transports.RoundTripperFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				endpoints = append(endpoints, transports.EndpointFromContext(req.Context()))
+				req.Header.Set("X-Request-Id", "request-1")
+				return next(req)
+			}
+		}))
+		subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		assert.Equal(t, []string{"GetSubscriptionToken", "GetSubscriptionDetails"}, endpoints)
+		assert.Equal(t, "request-1", server.requestHeaders("/v1/user/subscription-token").Get("X-Request-Id"))
+	})
+}
+
+// TestWithTransport will test using an injected transport without a server
+func TestWithTransport(t *testing.T) {
+	mock := &transports.Mock{
+		ServerURL: "127.0.0.1:1",
+		GetTransactionFunc: func(_ context.Context, id string) (*models.Transaction, error) {
+			return &models.Transaction{ID: id}, nil
+		},
+		GetSubscriptionTokenFunc: func(context.Context, string) (string, error) {
+			return "mock-token", nil
+		},
+	}
+	client, err := New(WithTransport(mock), WithRetryPolicy(transports.NoRetryPolicy))
+	require.NoError(t, err)
+	assert.Nil(t, *client.GetTransport())
+
+	transaction, err := client.GetTransaction(context.Background(), txID)
+	require.NoError(t, err)
+	assert.Equal(t, txID, transaction.ID)
+
+	subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, EventHandler{
+		OnTransaction: func(*models.TransactionResponse) {},
+		OnStatus:      func(*models.ControlResponse) {},
+		OnError:       func(error) {},
+	})
+	require.NoError(t, err)
+	require.NoError(t, subscription.Unsubscribe())
+
+	assert.Equal(t, []transports.MockCall{
+		{Method: "GetTransaction", Args: []interface{}{txID}},
+		{Method: "GetSubscriptionToken", Args: []interface{}{"test-subscription"}},
+		{Method: "SetToken", Args: []interface{}{"mock-token"}},
+		{Method: "GetSubscriptionDetails", Args: []interface{}{"test-subscription"}},
+	}, mock.Calls())
+	assert.Len(t, mock.CallsTo("GetSubscriptionToken"), 1)
+}
@@ -0,0 +1,602 @@
+// Package junglebustest provides an in-process JungleBus server for testing code using the junglebus client
+//
+// The server serves the token endpoints and a websocket speaking the centrifuge protobuf or JSON protocol,
+// publications are pushed on the channels the client subscribed to. Point the client at it with junglebus.WithHTTP(server.URL).
+package junglebustest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/sinks"
+	"github.com/centrifugal/protocol"
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Token is the token returned by the token endpoints of the server
+const Token = "test-token"
+
+// defaultEpoch is the epoch of the channel streams until SetEpoch is called
+const defaultEpoch = "junglebustest"
+
+// Error codes of centrifuge for rejected connections and subscriptions
+const (
+	unauthorizedCode     = 101
+	permissionDeniedCode = 103
+	tokenExpiredCode     = 109
+)
+
+// Server is an in-process JungleBus server
+type Server struct {
+	*httptest.Server
+	mux            *http.ServeMux
+	mu             sync.Mutex
+	conns          map[*conn]struct{}
+	pending        map[string][]publication
+	reject         int
+	dials          []time.Time
+	headers        map[string]http.Header // of the last request per path
+	fails          map[string]*failure
+	expired        map[string]bool          // tokens rejected when connecting
+	auth           bool                     // whether connecting without a token is rejected
+	tokens         []string                 // of all connect commands
+	serverChannels []string                 // subscribed server-side when connecting
+	rejected       map[string]bool          // channels refused when subscribing
+	epoch          string                   // of the channel streams
+	history        map[string][]publication // every publication per channel, its offset is its index + 1
+	replayHistory  bool                     // whether subscribing sends the history of the channel
+}
+
+// publication is queued raw data, or a message encoded in the protocol of the connection
+type publication struct {
+	data    []byte
+	message proto.Message
+}
+
+// failure makes the next requests on a path fail with the status
+type failure struct {
+	n      int
+	status int
+}
+
+// conn is a single websocket connection to the server
+type conn struct {
+	server   *Server
+	ws       *websocket.Conn
+	writeMu  sync.Mutex
+	mu       sync.Mutex
+	channels map[string]bool
+	json     bool // whether the connection speaks the JSON protocol
+}
+
+// MainChannel returns the channel of the transactions of a subscription
+func MainChannel(subscriptionID string, fromBlock uint64) string {
+	return "query:" + subscriptionID + ":" + strconv.FormatUint(fromBlock, 10)
+}
+
+// ControlChannel returns the channel of the control messages of a subscription
+func ControlChannel(subscriptionID string) string {
+	return "query:" + subscriptionID + ":control"
+}
+
+// MempoolChannel returns the channel of the mempool transactions of a subscription
+func MempoolChannel(subscriptionID string) string {
+	return "query:" + subscriptionID + ":mempool"
+}
+
+// NewServer starts a server, it should be closed with Close
+func NewServer() *Server {
+	return newServer((*httptest.Server).Start)
+}
+
+// NewTLSServer starts a server using TLS, the client must trust Certificate, see junglebus.WithTLSConfig
+func NewTLSServer() *Server {
+	return newServer((*httptest.Server).StartTLS)
+}
+
+// newServer starts a server with the given start method of httptest.Server
+func newServer(start func(*httptest.Server)) *Server {
+	s := &Server{
+		conns:    map[*conn]struct{}{},
+		pending:  map[string][]publication{},
+		headers:  map[string]http.Header{},
+		fails:    map[string]*failure{},
+		expired:  map[string]bool{},
+		rejected: map[string]bool{},
+		epoch:    defaultEpoch,
+		history:  map[string][]publication{},
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/v1/user/subscription-token", s.handleToken)
+	s.mux.HandleFunc("/v1/user/refresh-token", s.handleToken)
+	s.mux.HandleFunc("/connection/websocket", s.handleWebsocket)
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		s.headers[req.URL.Path] = req.Header.Clone()
+		fail := s.fails[req.URL.Path]
+		if fail != nil && fail.n > 0 {
+			fail.n--
+			s.mu.Unlock()
+			w.WriteHeader(fail.status)
+			return
+		}
+		s.mu.Unlock()
+		s.mux.ServeHTTP(w, req)
+	}))
+	start(s.Server)
+
+	return s
+}
+
+// Close drops all websocket connections and shuts down the server
+func (s *Server) Close() {
+	s.DisconnectAll()
+	s.Server.Close()
+}
+
+// HandleFunc registers the handler for the pattern, like the REST endpoints used by the test
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// HandleJSON serves the JSON response with the status on the path
+func (s *Server) HandleJSON(path string, status int, response string) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, response)
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"token":"`+Token+`"}`)
+}
+
+func (s *Server) handleWebsocket(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	s.dials = append(s.dials, time.Now())
+	reject := s.reject > 0
+	if reject {
+		s.reject--
+	}
+	s.mu.Unlock()
+	if reject {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	upgrader := websocket.Upgrader{Subprotocols: []string{"centrifuge-protobuf"}, EnableCompression: true}
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+
+	c := &conn{server: s, ws: ws, channels: map[string]bool{}, json: ws.Subprotocol() != "centrifuge-protobuf"}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = ws.Close()
+	}()
+
+	for {
+		var data []byte
+		if _, data, err = ws.ReadMessage(); err != nil {
+			return
+		}
+		var decoder protocol.CommandDecoder = protocol.NewProtobufCommandDecoder(data)
+		if c.json {
+			decoder = protocol.NewJSONCommandDecoder(data)
+		}
+		for {
+			cmd, decodeErr := decoder.Decode()
+			if cmd != nil {
+				c.handleCommand(cmd)
+			}
+			if decodeErr != nil {
+				break
+			}
+		}
+	}
+}
+
+func (c *conn) handleCommand(cmd *protocol.Command) {
+	if cmd.Id == 0 {
+		return // pong
+	}
+
+	reply := &protocol.Reply{Id: cmd.Id}
+	switch {
+	case cmd.Connect != nil:
+		c.server.mu.Lock()
+		c.server.tokens = append(c.server.tokens, cmd.Connect.Token)
+		expired := c.server.expired[cmd.Connect.Token]
+		unauthorized := c.server.auth && cmd.Connect.Token == ""
+		c.server.mu.Unlock()
+		if expired {
+			reply.Error = &protocol.Error{Code: tokenExpiredCode, Message: "token expired"}
+			break
+		}
+		if unauthorized {
+			reply.Error = &protocol.Error{Code: unauthorizedCode, Message: "unauthorized"}
+			break
+		}
+		reply.Connect = &protocol.ConnectResult{Client: "junglebustest", Version: "0.0.0"}
+		if channels := c.server.ServerChannels(); len(channels) > 0 {
+			reply.Connect.Subs = make(map[string]*protocol.SubscribeResult, len(channels))
+			c.mu.Lock()
+			for _, channel := range channels {
+				c.channels[channel] = true
+				reply.Connect.Subs[channel] = &protocol.SubscribeResult{}
+			}
+			c.mu.Unlock()
+		}
+	case cmd.Subscribe != nil:
+		c.server.mu.Lock()
+		rejected := c.server.rejected[cmd.Subscribe.Channel]
+		c.server.mu.Unlock()
+		if rejected {
+			reply.Error = &protocol.Error{Code: permissionDeniedCode, Message: "permission denied"}
+			break
+		}
+		c.mu.Lock()
+		c.channels[cmd.Subscribe.Channel] = true
+		c.mu.Unlock()
+		c.server.mu.Lock()
+		reply.Subscribe = &protocol.SubscribeResult{
+			Recoverable: true,
+			Epoch:       c.server.epoch,
+			Offset:      uint64(len(c.server.history[cmd.Subscribe.Channel])),
+		}
+		c.server.mu.Unlock()
+		defer c.flushPending(cmd.Subscribe.Channel)
+	case cmd.Unsubscribe != nil:
+		c.mu.Lock()
+		delete(c.channels, cmd.Unsubscribe.Channel)
+		c.mu.Unlock()
+		reply.Unsubscribe = &protocol.UnsubscribeResult{}
+	case cmd.Refresh != nil:
+		reply.Refresh = &protocol.RefreshResult{}
+	default:
+		reply.Connect = &protocol.ConnectResult{Client: "junglebustest", Version: "0.0.0"}
+	}
+	_ = c.write(reply)
+}
+
+// flushPending sends the history of the channel when it is replayed and the publications queued for the channel
+// right after subscribing
+func (c *conn) flushPending(channel string) {
+	c.server.mu.Lock()
+	var offset uint64 // of the publication before the first one sent
+	var pubs []publication
+	if c.server.replayHistory {
+		pubs = append(pubs, c.server.history[channel]...)
+	} else {
+		offset = uint64(len(c.server.history[channel]))
+	}
+	pending := c.server.pending[channel]
+	delete(c.server.pending, channel)
+	c.server.history[channel] = append(c.server.history[channel], pending...)
+	pubs = append(pubs, pending...)
+	c.server.mu.Unlock()
+
+	for _, pub := range pubs {
+		offset++
+		data := pub.data
+		if pub.message != nil {
+			var err error
+			if data, err = c.encode(pub.message); err != nil {
+				continue
+			}
+		}
+		_ = c.push(channel, data, offset)
+	}
+}
+
+// encode encodes the message in the protocol of the connection
+func (c *conn) encode(message proto.Message) ([]byte, error) {
+	if c.json {
+		return json.Marshal(message)
+	}
+	return proto.Marshal(message)
+}
+
+func (c *conn) push(channel string, data []byte, offset uint64) error {
+	return c.write(&protocol.Reply{Push: &protocol.Push{
+		Channel: channel,
+		Pub:     &protocol.Publication{Data: data, Offset: offset},
+	}})
+}
+
+func (c *conn) isSubscribed(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[channel]
+}
+
+func (c *conn) write(reply *protocol.Reply) error {
+	if c.json {
+		data, err := protocol.NewJSONReplyEncoder().Encode(reply)
+		if err != nil {
+			return err
+		}
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		return c.ws.WriteMessage(websocket.TextMessage, data)
+	}
+
+	data, err := protocol.NewProtobufReplyEncoder().Encode(reply)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, binary.MaxVarintLen64)
+	frame = frame[:binary.PutUvarint(frame, uint64(len(data)))]
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(websocket.BinaryMessage, append(frame, data...))
+}
+
+// RequestHeaders returns the headers of the last request to the path
+func (s *Server) RequestHeaders(path string) http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[path]
+}
+
+// FailRequests makes the next n requests on the path fail with the status
+func (s *Server) FailRequests(path string, n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fails[path] = &failure{n: n, status: status}
+}
+
+// RejectConnections makes the next n websocket connection attempts fail
+func (s *Server) RejectConnections(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = n
+}
+
+// ExpireToken makes websocket connections with the token fail with the token expired error of centrifuge, the
+// client then asks for a new token
+func (s *Server) ExpireToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired[token] = true
+}
+
+// RequireAuth makes websocket connections without a token fail with the unauthorized error of centrifuge
+func (s *Server) RequireAuth(required bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = required
+}
+
+// ReplayHistory makes subscribing to a channel send every publication published on it before, with its original
+// offset, like JungleBus streaming a channel from its block. Clients resuming from a stream position skip the
+// publications up to the offset of the position.
+func (s *Server) ReplayHistory(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replayHistory = enabled
+}
+
+// SetEpoch starts a new epoch of the channel streams with an empty history, like a server that lost the history of
+// its channels. Clients resuming from a stream position of the previous epoch resume at their checkpoint block.
+func (s *Server) SetEpoch(epoch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epoch = epoch
+	s.history = map[string][]publication{}
+}
+
+// RejectChannel makes subscribing to the channel fail with the permission denied error of centrifuge, which the
+// client does not retry
+func (s *Server) RejectChannel(channel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[channel] = true
+}
+
+// SubscribeServerSide subscribes the next connections to the channels on the server side, publications on them
+// reach the client without it subscribing
+func (s *Server) SubscribeServerSide(channels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serverChannels = append(s.serverChannels, channels...)
+}
+
+// ServerChannels returns the channels connections are subscribed to on the server side
+func (s *Server) ServerChannels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.serverChannels...)
+}
+
+// ConnectTokens returns the tokens of all websocket connections, in order
+func (s *Server) ConnectTokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.tokens...)
+}
+
+// DialTimes returns the times of all websocket connection attempts
+func (s *Server) DialTimes() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time{}, s.dials...)
+}
+
+// Connections returns the number of open websocket connections
+func (s *Server) Connections() int {
+	return len(s.connections())
+}
+
+// connections returns a snapshot of the open connections
+func (s *Server) connections() []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// Subscribed returns whether any open connection is subscribed to the channel
+func (s *Server) Subscribed(channel string) bool {
+	for _, c := range s.connections() {
+		if c.isSubscribed(channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSubscribed waits until a client subscribed to the channel, it returns false on timeout
+func (s *Server) WaitSubscribed(channel string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !s.Subscribed(channel) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// Publish sends the data to every connection subscribed to the channel, the data is sent as is in both protocols
+func (s *Server) Publish(channel string, data []byte) error {
+	return s.publish(channel, publication{data: data})
+}
+
+// PublishMessage sends the message to every connection subscribed to the channel, encoded in the protocol of the
+// connection
+func (s *Server) PublishMessage(channel string, message proto.Message) error {
+	return s.publish(channel, publication{message: message})
+}
+
+// PublishTransaction sends the transaction to every connection subscribed to the channel
+func (s *Server) PublishTransaction(channel string, transaction *models.TransactionResponse) error {
+	return s.PublishMessage(channel, transaction)
+}
+
+// PublishControl sends the control message to every connection subscribed to the channel
+func (s *Server) PublishControl(channel string, control *models.ControlResponse) error {
+	return s.PublishMessage(channel, control)
+}
+
+// Replay publishes the records of an archived stream, like a file written by sinks.NDJSONFile, on the channels of
+// the subscription, returning the number of records published. Statuses of the connection itself are skipped.
+func (s *Server) Replay(reader *sinks.Reader, subscriptionID string, fromBlock uint64) (int, error) {
+	published := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return published, nil
+		} else if err != nil {
+			return published, err
+		}
+
+		switch {
+		case record.Kind == sinks.KindTransaction && record.Transaction != nil:
+			err = s.PublishTransaction(MainChannel(subscriptionID, fromBlock), record.Transaction)
+		case record.Kind == sinks.KindMempool && record.Transaction != nil:
+			err = s.PublishTransaction(MempoolChannel(subscriptionID), record.Transaction)
+		case record.Kind == sinks.KindStatus && record.Status != nil && record.Status.StatusCode >= 100:
+			err = s.PublishControl(ControlChannel(subscriptionID), record.Status)
+		default:
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		published++
+	}
+}
+
+func (s *Server) publish(channel string, pub publication) error {
+	s.mu.Lock()
+	s.history[channel] = append(s.history[channel], pub)
+	offset := uint64(len(s.history[channel]))
+	s.mu.Unlock()
+
+	var err error
+	for _, c := range s.connections() {
+		if !c.isSubscribed(channel) {
+			continue
+		}
+		data := pub.data
+		if pub.message != nil {
+			var encodeErr error
+			if data, encodeErr = c.encode(pub.message); encodeErr != nil {
+				return encodeErr
+			}
+		}
+		if writeErr := c.push(channel, data, offset); writeErr != nil && !errors.Is(writeErr, io.EOF) {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+// Enqueue publishes the data to the next connection subscribing to the channel, right after the subscribe reply
+func (s *Server) Enqueue(channel string, data []byte) {
+	s.enqueue(channel, publication{data: data})
+}
+
+// EnqueueMessage publishes the message to the next connection subscribing to the channel, encoded in the protocol
+// of the connection
+func (s *Server) EnqueueMessage(channel string, message proto.Message) {
+	s.enqueue(channel, publication{message: message})
+}
+
+// EnqueueTransaction publishes the transaction to the next connection subscribing to the channel
+func (s *Server) EnqueueTransaction(channel string, transaction *models.TransactionResponse) error {
+	s.EnqueueMessage(channel, transaction)
+	return nil
+}
+
+// EnqueueControl publishes the control message to the next connection subscribing to the channel
+func (s *Server) EnqueueControl(channel string, control *models.ControlResponse) error {
+	s.EnqueueMessage(channel, control)
+	return nil
+}
+
+func (s *Server) enqueue(channel string, pub publication) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[channel] = append(s.pending[channel], pub)
+}
+
+// Disconnect drops the websocket connections subscribed to the channel
+func (s *Server) Disconnect(channel string) {
+	for _, c := range s.connections() {
+		if c.isSubscribed(channel) {
+			_ = c.ws.Close()
+		}
+	}
+}
+
+// DisconnectAll drops every open websocket connection
+func (s *Server) DisconnectAll() {
+	for _, c := range s.connections() {
+		_ = c.ws.Close()
+	}
+}
@@ -0,0 +1,142 @@
+package junglebustest_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/junglebustest"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestServer will test subscribing to the server with the junglebus client
+func TestServer(t *testing.T) {
+	server := junglebustest.NewServer()
+	defer server.Close()
+
+	mainChannel := junglebustest.MainChannel("test-subscription", 100)
+	require.NoError(t, server.EnqueueTransaction(mainChannel, &models.TransactionResponse{Id: "tx-1", BlockHeight: 100}))
+
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	recorded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+
+	client, err := junglebus.New(junglebus.WithHTTP(server.URL), junglebus.WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond, 2))
+	require.NoError(t, err)
+	subscription, err := client.Subscribe(context.Background(), "test-subscription", 100, junglebus.EventHandler{
+		OnTransaction: func(tx *models.TransactionResponse) {
+			record("tx " + tx.Id)
+		},
+		OnStatus: func(status *models.ControlResponse) {
+			if junglebus.StatusCode(status.StatusCode).IsBlockDone() {
+				record("block done")
+			}
+		},
+		OnError: func(error) {},
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = subscription.Unsubscribe()
+	}()
+
+	controlChannel := junglebustest.ControlChannel("test-subscription")
+	require.True(t, server.WaitSubscribed(controlChannel, 5*time.Second))
+	require.True(t, server.WaitSubscribed(mainChannel, 5*time.Second))
+	require.NoError(t, server.PublishControl(controlChannel, &models.ControlResponse{
+		StatusCode: uint32(junglebus.SubscriptionBlockDone),
+		Block:      100,
+	}))
+	require.Eventually(t, func() bool {
+		return len(recorded()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"tx tx-1", "block done"}, recorded())
+
+	// the client reconnects after a disconnect, resuming from the last block
+	server.Disconnect(controlChannel)
+	require.Eventually(t, func() bool {
+		return len(server.DialTimes()) > 1 && server.Subscribed(mainChannel)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestServer_Replay will test archiving a stream with a sink and replaying the archive to another subscription
+func TestServer_Replay(t *testing.T) {
+	const subscriptionID = "test-subscription"
+	path := filepath.Join(t.TempDir(), "archive.ndjson.gz")
+
+	subscribe := func(server *junglebustest.Server, events chan<- string, opts ...junglebus.SubscribeOption) *junglebus.Subscription {
+		client, err := junglebus.New(junglebus.WithHTTP(server.URL))
+		require.NoError(t, err)
+		subscription, err := client.Subscribe(context.Background(), subscriptionID, 100, junglebus.EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { events <- "tx " + tx.Id },
+			OnMempool:     func(tx *models.TransactionResponse) { events <- "mempool " + tx.Id },
+			OnBlockDone:   func(uint32, uint64) { events <- "block done" },
+			OnError:       func(error) {},
+		}, opts...)
+		require.NoError(t, err)
+		require.True(t, server.WaitSubscribed(junglebustest.ControlChannel(subscriptionID), 5*time.Second))
+		require.True(t, server.WaitSubscribed(junglebustest.MainChannel(subscriptionID, 100), 5*time.Second))
+		require.True(t, server.WaitSubscribed(junglebustest.MempoolChannel(subscriptionID), 5*time.Second))
+		return subscription
+	}
+	receive := func(events <-chan string, n int) []string {
+		var received []string
+		for i := 0; i < n; i++ {
+			select {
+			case event := <-events:
+				received = append(received, event)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("received %v, expected %d events", received, n)
+			}
+		}
+		return received
+	}
+
+	server := junglebustest.NewServer()
+	defer server.Close()
+	sink, err := sinks.NewNDJSONFile(path, sinks.WithGzip())
+	require.NoError(t, err)
+	events := make(chan string, 10)
+	subscription := subscribe(server, events, junglebus.WithSink(sink))
+	require.NoError(t, server.PublishTransaction(junglebustest.MempoolChannel(subscriptionID),
+		&models.TransactionResponse{Id: "tx-1"}))
+	require.NoError(t, server.PublishTransaction(junglebustest.MainChannel(subscriptionID, 100),
+		&models.TransactionResponse{Id: "tx-1", BlockHeight: 100}))
+	require.NoError(t, server.PublishControl(junglebustest.ControlChannel(subscriptionID), &models.ControlResponse{
+		StatusCode: uint32(junglebus.SubscriptionBlockDone),
+		Block:      100,
+	}))
+	expected := receive(events, 3)
+	require.NoError(t, subscription.Unsubscribe())
+
+	replayServer := junglebustest.NewServer()
+	defer replayServer.Close()
+	replayEvents := make(chan string, 10)
+	replayed := subscribe(replayServer, replayEvents)
+	defer func() {
+		_ = replayed.Unsubscribe()
+	}()
+	reader, err := sinks.OpenReader(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = reader.Close()
+	}()
+	published, err := replayServer.Replay(reader, subscriptionID, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+	assert.Equal(t, expected, receive(replayEvents, 3))
+}
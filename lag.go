@@ -0,0 +1,135 @@
+package junglebus
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// lagTracker tracks the position of the newest message received from the server and of the newest message handled,
+// only accessed atomically
+type lagTracker struct {
+	receivedBlock   uint64
+	receivedOffset  uint64
+	processedBlock  uint64
+	processedOffset uint64
+	behindSince     int64 // unix nanoseconds since the lag exceeds the threshold of WithLagWarning, 0 when it does not
+	warned          int32 // 1 once the lag was reported, until it no longer exceeds the threshold
+}
+
+// received records a message received from the server, offsets are those of the main channel and 0 for others.
+// Until a message was handled the lag is counted from the block of the first message.
+func (l *lagTracker) received(block uint32, offset uint64) {
+	if block > 0 {
+		atomic.StoreUint64(&l.receivedBlock, uint64(block))
+		atomic.CompareAndSwapUint64(&l.processedBlock, 0, uint64(block))
+	}
+	if offset > 0 {
+		atomic.StoreUint64(&l.receivedOffset, offset)
+	}
+}
+
+// processed records a message handled by the event handler
+func (l *lagTracker) processed(block uint32, offset uint64) {
+	if block > 0 {
+		atomic.StoreUint64(&l.processedBlock, uint64(block))
+	}
+	if offset > 0 {
+		atomic.StoreUint64(&l.processedOffset, offset)
+	}
+}
+
+// LagStats is how far the handling of a subscription or a consumer is behind the messages received from the server
+type LagStats struct {
+	HeadBlock       uint64 // block of the newest message received
+	HeadOffset      uint64 // offset of the newest publication received on the main channel
+	ProcessedBlock  uint64 // block of the newest message handled
+	ProcessedOffset uint64 // offset of the newest publication of the main channel handled
+	BlockLag        uint64 // blocks the handled messages are behind, see WithLagWarning
+	OffsetLag       uint64 // publications of the main channel received and not handled yet
+}
+
+// stats returns a snapshot of the lag
+func (l *lagTracker) stats() LagStats {
+	stats := LagStats{
+		HeadBlock:       atomic.LoadUint64(&l.receivedBlock),
+		HeadOffset:      atomic.LoadUint64(&l.receivedOffset),
+		ProcessedBlock:  atomic.LoadUint64(&l.processedBlock),
+		ProcessedOffset: atomic.LoadUint64(&l.processedOffset),
+	}
+	if stats.HeadBlock > stats.ProcessedBlock {
+		stats.BlockLag = stats.HeadBlock - stats.ProcessedBlock
+	}
+	// offsets start over on the channel subscribed to after reconnecting
+	if stats.HeadOffset > stats.ProcessedOffset {
+		stats.OffsetLag = stats.HeadOffset - stats.ProcessedOffset
+	}
+	return stats
+}
+
+// behind returns for how long the lag exceeds the threshold, the duration is only returned the first time it
+// exceeds the duration, 0 otherwise
+func (l *lagTracker) behind(threshold uint64, duration time.Duration, now time.Time) time.Duration {
+	if l.stats().BlockLag <= threshold {
+		atomic.StoreInt64(&l.behindSince, 0)
+		atomic.StoreInt32(&l.warned, 0)
+		return 0
+	}
+	since := atomic.LoadInt64(&l.behindSince)
+	if since == 0 {
+		atomic.StoreInt64(&l.behindSince, now.UnixNano())
+		return 0
+	}
+	behind := now.Sub(time.Unix(0, since))
+	if behind < duration || !atomic.CompareAndSwapInt32(&l.warned, 0, 1) {
+		return 0
+	}
+	return behind
+}
+
+// watchLag sends a StatusLagging status when the subscription or one of its consumers is more blocks behind than
+// the threshold of WithLagWarning for its duration, until the subscription is torn down
+func (s *Subscription) watchLag() {
+	interval := s.lagDuration / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	eventHandler := s.dispatched()
+	warn := func(name string, lag *lagTracker, now time.Time) {
+		behind := lag.behind(s.lagThreshold, s.lagDuration, now)
+		if behind == 0 {
+			return
+		}
+		stats := lag.stats()
+		s.log(levelWarn, "lagging behind the stream", "consumer", name, "block_lag", stats.BlockLag,
+			"block", stats.ProcessedBlock, "head", stats.HeadBlock)
+		s.sendStatus(eventHandler.OnStatus, StatusLagging, "lagging", func() string {
+			handler := "Subscription"
+			if name != "" {
+				handler = "Consumer " + name
+			}
+			return fmt.Sprintf("%s is %d blocks behind the stream for %s, at block %d of %d", handler, stats.BlockLag,
+				behind.Round(time.Millisecond), stats.ProcessedBlock, stats.HeadBlock)
+		})
+	}
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			warn("", &s.lag, now)
+			s.consumersMu.Lock()
+			consumers := make([]*Consumer, 0, len(s.consumers))
+			for _, c := range s.consumers {
+				consumers = append(consumers, c)
+			}
+			s.consumersMu.Unlock()
+			for _, c := range consumers {
+				warn(c.Name, &c.lag, now)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package junglebus
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscription_Lag will test tracking how far the handlers are behind the stream
+func TestSubscription_Lag(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server := newFakeServer(t)
+		statuses := &statusRecorder{}
+		release := make(chan struct{})
+		subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {
+				<-release
+			},
+			OnStatus: statuses.onStatus,
+		}, WithQueueSize(10), WithLagWarning(1, 20*time.Millisecond))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(mainChannel)
+
+		consumerRelease := make(chan struct{})
+		consumer, err := subscription.NewConsumer("slow", EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {
+				<-consumerRelease
+			},
+		})
+		require.NoError(t, err)
+
+		for block := uint32(100); block <= 103; block++ {
+			require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+				Id: "tx-" + strconv.Itoa(int(block)), BlockHeight: block,
+			}))
+		}
+		require.Eventually(t, func() bool {
+			return subscription.Stats().Lag.HeadBlock == 103
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, LagStats{
+			HeadBlock: 103, HeadOffset: 4, ProcessedBlock: 100, BlockLag: 3, OffsetLag: 4,
+		}, subscription.Stats().Lag)
+		assert.Equal(t, uint64(3), consumer.Stats().Lag.BlockLag)
+
+		lagging := func(handler string) bool {
+			statuses.mu.Lock()
+			defer statuses.mu.Unlock()
+			for _, status := range statuses.statuses {
+				if status.StatusCode == uint32(StatusLagging) && strings.HasPrefix(status.Message, handler+" is 3 blocks") {
+					return true
+				}
+			}
+			return false
+		}
+		// the statuses are queued behind the blocked handler
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		require.Eventually(t, func() bool {
+			return lagging("Subscription") && lagging("Consumer slow")
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			return subscription.Stats().Lag == LagStats{HeadBlock: 103, HeadOffset: 4, ProcessedBlock: 103,
+				ProcessedOffset: 4}
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, uint64(3), subscription.ConsumerStats()["slow"].Lag.BlockLag)
+
+		close(consumerRelease)
+		require.Eventually(t, func() bool {
+			return consumer.Stats().Lag.BlockLag == 0 && consumer.Stats().Lag.OffsetLag == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,22 @@
+package junglebus
+
+import (
+	"context"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// Hydrate sets the raw transaction of a transaction received in lite mode, see WithLiteMode, fetching it with
+// GetRawTransaction. The request passes through the rate limiter of the client. Transactions that already have
+// their raw transaction are left as is.
+func (s *Subscription) Hydrate(ctx context.Context, tx *models.TransactionResponse) error {
+	if len(tx.Transaction) > 0 {
+		return nil
+	}
+	raw, err := s.client.GetRawTransaction(ctx, tx.Id)
+	if err != nil {
+		return err
+	}
+	tx.Transaction = raw
+	return nil
+}
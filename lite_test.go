@@ -0,0 +1,128 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscription_channelNameLite will test routing the channels of lite mode
+func TestSubscription_channelNameLite(t *testing.T) {
+	tests := []struct {
+		channel string
+		name    string
+		ok      bool
+	}{
+		{"query:sub:control", channelControl, true},
+		{"query:sub:mempool:lite", channelMempool, true},
+		{"query:sub:100:lite", channelMain, true},
+		{"query:sub:100:2:lite", channelMain, true},
+		{"query:sub:mempool", "", false},
+		{"query:sub:100", "", false},
+		{"query:sub:control:lite", "", false},
+		{"query:sub:lite", "", false},
+		{"query:sub:100:lite:lite", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.channel, func(t *testing.T) {
+			s := &Subscription{SubscriptionID: "sub", lite: true}
+			name, ok := s.channelName(test.channel)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.name, name)
+		})
+	}
+}
+
+// TestSubscribe_LiteMode will test streaming transactions without their raw transaction and hydrating them
+func TestSubscribe_LiteMode(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		rawTx := mustDecodeHex("0100000001111111111111111111111111111111111111111111111111111111111111" +
+			"11110000000000ffffffff01000000000000000006006a03666f6f00000000")
+		server := newFakeServer(t)
+		var requests int32
+		server.HandleFunc("/v1/transaction/get/"+testTxID+"/bin", func(w http.ResponseWriter, _ *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			_, _ = w.Write(rawTx)
+		})
+		client := server.newClient(WithRateLimit(100, 1))
+
+		recorder := &statusRecorder{}
+		transactions := make(chan *models.TransactionResponse, 2)
+		mempool := make(chan *models.TransactionResponse, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx },
+			OnMempool:     func(tx *models.TransactionResponse) { mempool <- tx },
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		}, WithLiteMode(), WithTxMiddleware(DecodedHandler(func(raw []byte) ([]byte, error) {
+			t.Error("transaction without raw bytes decoded")
+			return raw, nil
+		}, func(TxContext, []byte, *models.TransactionResponse) {})))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100:lite"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool:lite"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+		assert.False(t, server.subscribed("query:"+testSubscriptionID+":100"))
+
+		fixture := "testdata/publication_transaction_lite.pb"
+		if server.json {
+			fixture = "testdata/publication_transaction_lite.json"
+		}
+		data, err := os.ReadFile(fixture)
+		require.NoError(t, err)
+		server.publish(mainChannel, data)
+		server.publishTransaction(mempoolChannel, testTxID)
+		server.publishTransaction(mainChannel, testTxID)
+
+		var received []*models.TransactionResponse
+		for i := 0; i < 2; i++ {
+			select {
+			case tx := <-transactions:
+				received = append(received, tx)
+			case <-time.After(5 * time.Second):
+				t.Fatal("lite transaction not received")
+			}
+		}
+		assert.Equal(t, "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", received[0].Id)
+		assert.Equal(t, uint32(1), received[0].BlockHeight)
+		assert.Empty(t, received[0].Transaction)
+
+		var tx *models.TransactionResponse
+		select {
+		case tx = <-mempool:
+		case <-time.After(5 * time.Second):
+			t.Fatal("lite mempool transaction not received")
+		}
+		assert.Empty(t, tx.Transaction)
+
+		// hydrating fetches the raw transaction once
+		require.NoError(t, subscription.Hydrate(context.Background(), tx))
+		assert.Equal(t, rawTx, tx.Transaction)
+		require.NoError(t, subscription.Hydrate(context.Background(), tx))
+		require.NoError(t, subscription.Hydrate(context.Background(), received[1]))
+		assert.Equal(t, rawTx, received[1].Transaction)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+		t.Run("not found", func(t *testing.T) {
+			unknown := &models.TransactionResponse{Id: "unknown"}
+			assert.ErrorIs(t, subscription.Hydrate(context.Background(), unknown), ErrNotFound)
+			assert.Empty(t, unknown.Transaction)
+		})
+
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		assert.Empty(t, recorder.errors)
+	})
+}
@@ -0,0 +1,75 @@
+package junglebus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GorillaPool/go-junglebus/transports"
+)
+
+// logLevel is the severity of a logged event
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// LevelLogger is optionally implemented by a Logger to tell whether it logs debug messages, the debug events of
+// every publication are only formatted when DebugEnabled returns true
+type LevelLogger interface {
+	DebugEnabled() bool
+}
+
+// fieldLogger is implemented by loggers taking the key value pairs of an event as structured attributes
+type fieldLogger interface {
+	logFields(level logLevel, msg string, keyvals ...interface{})
+}
+
+// logEvent logs the message with its key value pairs, a Logger without support for attributes gets them
+// appended to the message. Warnings are logged with Errorf.
+func logEvent(logger Logger, level logLevel, msg string, keyvals ...interface{}) {
+	switch l := logger.(type) {
+	case transports.NopLogger:
+		return
+	case fieldLogger:
+		l.logFields(level, msg, keyvals...)
+		return
+	}
+
+	var line strings.Builder
+	line.WriteString(msg)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		_, _ = fmt.Fprintf(&line, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	switch level {
+	case levelDebug:
+		logger.Debugf("%s", line.String())
+	case levelInfo:
+		logger.Infof("%s", line.String())
+	default:
+		logger.Errorf("%s", line.String())
+	}
+}
+
+// logEnabled returns whether events of the level are logged, so the hot paths only build the key value pairs of
+// events that are logged
+func logEnabled(logger Logger, level logLevel) bool {
+	switch l := logger.(type) {
+	case transports.NopLogger:
+		return false
+	case LevelLogger:
+		return level > levelDebug || l.DebugEnabled()
+	}
+	return true
+}
+
+// log logs an event of the subscription, adding its ID
+func (s *Subscription) log(level logLevel, msg string, keyvals ...interface{}) {
+	if !logEnabled(s.client.logger, level) {
+		return
+	}
+	logEvent(s.client.logger, level, msg, append([]interface{}{"subscription_id", s.SubscriptionID}, keyvals...)...)
+}
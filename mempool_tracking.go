@@ -0,0 +1,123 @@
+package junglebus
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// pendingTx is a mempool transaction waiting to be mined
+type pendingTx struct {
+	txID      string
+	firstSeen time.Time
+}
+
+// mempoolTracker remembers when mempool transactions were first seen, at most size of them and for at most ttl
+type mempoolTracker struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // last seen first, a transaction is not moved when seen again
+}
+
+func newMempoolTracker(size int, ttl time.Duration) *mempoolTracker {
+	return &mempoolTracker{size: size, ttl: ttl, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// seen remembers the txid when it is not pending yet, returning the pending transactions evicted to make room
+func (t *mempoolTracker) seen(txID string, now time.Time) []pendingTx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.entries[txID]; ok {
+		return nil
+	}
+	t.entries[txID] = t.order.PushFront(pendingTx{txID: txID, firstSeen: now})
+	var evicted []pendingTx
+	for t.order.Len() > t.size {
+		evicted = append(evicted, t.remove(t.order.Back()))
+	}
+	return evicted
+}
+
+// confirmed forgets the txid, returning when it was first seen when it was pending
+func (t *mempoolTracker) confirmed(txID string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	element, ok := t.entries[txID]
+	if !ok {
+		return time.Time{}, false
+	}
+	return t.remove(element).firstSeen, true
+}
+
+// expire returns the pending transactions first seen at least ttl ago, forgetting them
+func (t *mempoolTracker) expire(now time.Time) []pendingTx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var expired []pendingTx
+	for back := t.order.Back(); back != nil && now.Sub(back.Value.(pendingTx).firstSeen) >= t.ttl; back = t.order.Back() {
+		expired = append(expired, t.remove(back))
+	}
+	return expired
+}
+
+// pending returns the number of pending transactions
+func (t *mempoolTracker) pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.order.Len()
+}
+
+func (t *mempoolTracker) remove(element *list.Element) pendingTx {
+	pending := t.order.Remove(element).(pendingTx)
+	delete(t.entries, pending.txID)
+	return pending
+}
+
+// trackMempool remembers a mempool transaction, or passes a mined transaction that was pending to OnConfirmed
+func (s *Subscription) trackMempool(eventHandler EventHandler, tx *models.TransactionResponse, mempool bool) {
+	if s.mempoolTracker == nil {
+		return
+	}
+	if mempool {
+		s.evict(eventHandler, s.mempoolTracker.seen(tx.Id, time.Now()))
+		return
+	}
+	if firstSeen, ok := s.mempoolTracker.confirmed(tx.Id); ok && eventHandler.OnConfirmed != nil {
+		eventHandler.OnConfirmed(tx, firstSeen)
+	}
+}
+
+// evict passes the pending transactions that were never mined to OnEvicted
+func (s *Subscription) evict(eventHandler EventHandler, evicted []pendingTx) {
+	for _, pending := range evicted {
+		s.log(levelDebug, "mempool transaction evicted", "txid", pending.txID, "first_seen", pending.firstSeen)
+		if eventHandler.OnEvicted != nil {
+			eventHandler.OnEvicted(pending.txID, pending.firstSeen)
+		}
+	}
+}
+
+// watchMempool evicts the pending transactions that were not mined within the ttl, until the subscription is torn
+// down
+func (s *Subscription) watchMempool() {
+	interval := s.mempoolTracker.ttl / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	eventHandler := s.dispatched()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.evict(eventHandler, s.mempoolTracker.expire(now))
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package junglebus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolTracker(t *testing.T) {
+	t.Run("bounded", func(t *testing.T) {
+		const size, seen = 1000, 5000
+		tracker := newMempoolTracker(size, 0)
+		start := time.Now()
+		var evicted []pendingTx
+		for i := 0; i < seen; i++ {
+			evicted = append(evicted, tracker.seen(strconv.Itoa(i), start.Add(time.Duration(i)))...)
+		}
+		assert.Equal(t, size, tracker.pending())
+		assert.Len(t, tracker.entries, size)
+		require.Len(t, evicted, seen-size)
+		for i, pending := range evicted {
+			assert.Equal(t, strconv.Itoa(i), pending.txID, "the oldest transactions are evicted first")
+		}
+
+		assert.Empty(t, tracker.seen(strconv.Itoa(seen-1), time.Now()), "seeing a pending transaction again")
+		_, ok := tracker.confirmed("0")
+		assert.False(t, ok)
+		firstSeen, ok := tracker.confirmed(strconv.Itoa(seen - 1))
+		require.True(t, ok)
+		assert.Equal(t, start.Add(seen-1), firstSeen)
+		assert.Equal(t, size-1, tracker.pending())
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		tracker := newMempoolTracker(10000, time.Minute)
+		start := time.Now()
+		for i := 0; i < 3000; i++ {
+			tracker.seen(strconv.Itoa(i), start.Add(time.Duration(i)*time.Millisecond))
+		}
+		assert.Empty(t, tracker.expire(start.Add(time.Minute-time.Millisecond)))
+		expired := tracker.expire(start.Add(time.Minute + 999*time.Millisecond))
+		require.Len(t, expired, 1000)
+		assert.Equal(t, "999", expired[999].txID)
+		assert.Equal(t, 2000, tracker.pending())
+		assert.Len(t, tracker.entries, 2000)
+	})
+}
+
+// TestSubscribe_WithMempoolTracking will test linking mined transactions to when they were seen in the mempool
+func TestSubscribe_WithMempoolTracking(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		var mu sync.Mutex
+		var events []string
+		record := func(event string) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		}
+		confirmed := make(chan time.Time, 1)
+		before := time.Now()
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnStatus: func(*models.ControlResponse) {},
+			OnConfirmed: func(tx *models.TransactionResponse, firstSeen time.Time) {
+				record("confirmed " + tx.Id)
+				confirmed <- firstSeen
+			},
+			OnEvicted: func(txID string, firstSeen time.Time) {
+				assert.False(t, firstSeen.Before(before))
+				record("evicted " + txID)
+			},
+		}, WithMempoolTracking(2, 200*time.Millisecond))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+		for _, id := range []string{"a", "b", "c"} {
+			server.publishTransaction(mempoolChannel, id)
+		}
+		server.publishTransaction(mainChannel, "b")
+		server.publishTransaction(mainChannel, "unseen")
+
+		select {
+		case firstSeen := <-confirmed:
+			assert.False(t, firstSeen.Before(before))
+		case <-time.After(5 * time.Second):
+			t.Fatal("confirmation not received")
+		}
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(events) == 3
+		}, 5*time.Second, 10*time.Millisecond, "c expires")
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"evicted a", "confirmed b", "evicted c"}, events)
+	})
+}
@@ -0,0 +1,120 @@
+package junglebus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// GetMerkleProof get the merkle proof of a mined transaction by its txid from JungleBus, in BUMP format.
+// ErrNotFound is returned for unknown or unmined transactions.
+func (jb *Client) GetMerkleProof(ctx context.Context, txID string) (*models.MerkleProof, error) {
+	return jb.transport.GetMerkleProof(ctx, txID)
+}
+
+// VerifyMerkleProof computes the merkle root of every transaction in the proof and compares it to the merkle root
+// of the block header, without any requests. False is returned when a root or the block height does not match,
+// ErrInvalidMerkleProof when the proof is malformed.
+func VerifyMerkleProof(proof *models.MerkleProof, header *models.BlockHeader) (bool, error) {
+	if proof == nil || header == nil || len(proof.Path) == 0 {
+		return false, fmt.Errorf("%w: missing proof or block header", ErrInvalidMerkleProof)
+	}
+	if proof.BlockHeight != header.Height {
+		return false, nil
+	}
+
+	// hashes are kept in the internal byte order, the reverse of the hex encoding
+	levels := make([]map[uint64]*models.MerkleLeaf, len(proof.Path))
+	for height, leaves := range proof.Path {
+		levels[height] = make(map[uint64]*models.MerkleLeaf, len(leaves))
+		for i := range leaves {
+			levels[height][leaves[i].Offset] = &leaves[i]
+		}
+	}
+
+	verified := false
+	for _, leaf := range proof.Path[0] {
+		if !leaf.TxID {
+			continue
+		}
+		root, err := merkleNode(levels, 0, leaf.Offset)
+		if err != nil {
+			return false, err
+		}
+		for height := range levels {
+			sibling, err := merkleNode(levels, height, leaf.Offset>>height^1)
+			if err != nil {
+				return false, err
+			}
+			if sibling == nil {
+				sibling = root
+			}
+			if leaf.Offset>>height&1 == 0 {
+				root = merkleParent(root, sibling)
+			} else {
+				root = merkleParent(sibling, root)
+			}
+		}
+		if hex.EncodeToString(reverseBytes(root)) != header.MerkleRoot {
+			return false, nil
+		}
+		verified = true
+	}
+	if !verified {
+		return false, fmt.Errorf("%w: no transaction in the proof", ErrInvalidMerkleProof)
+	}
+	return true, nil
+}
+
+// merkleNode returns the hash of the node at the offset in the level of the tree, computing it from the level
+// below when the proof does not contain it. Nil is returned for duplicates.
+func merkleNode(levels []map[uint64]*models.MerkleLeaf, height int, offset uint64) ([]byte, error) {
+	if leaf, ok := levels[height][offset]; ok {
+		if leaf.Duplicate {
+			return nil, nil
+		}
+		hash, err := hex.DecodeString(leaf.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("%w: invalid hash at level %d offset %d", ErrInvalidMerkleProof, height, offset)
+		}
+		return reverseBytes(hash), nil
+	}
+	if height == 0 {
+		return nil, fmt.Errorf("%w: missing node at level 0 offset %d", ErrInvalidMerkleProof, offset)
+	}
+
+	left, err := merkleNode(levels, height-1, offset*2)
+	if err != nil {
+		return nil, err
+	}
+	right, err := merkleNode(levels, height-1, offset*2+1)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil {
+		return nil, fmt.Errorf("%w: duplicate left node at level %d offset %d", ErrInvalidMerkleProof, height-1, offset*2)
+	}
+	if right == nil {
+		right = left
+	}
+	return merkleParent(left, right), nil
+}
+
+// merkleParent returns the double SHA-256 of the concatenated child hashes
+func merkleParent(left, right []byte) []byte {
+	first := sha256.Sum256(append(append(make([]byte, 0, 2*sha256.Size), left...), right...))
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// reverseBytes returns a reversed copy of the bytes
+func reverseBytes(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
@@ -0,0 +1,103 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transactions of block 100000
+var block100000 = []string{
+	"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+	"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+	"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+	"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+}
+
+// TestVerifyMerkleProof will test fetching a transaction, its proof and block header and verifying the proof
+func TestVerifyMerkleProof(t *testing.T) {
+	proofJSON, err := os.ReadFile("testdata/merkle_proof.json")
+	require.NoError(t, err)
+	txID := block100000[2]
+
+	server := newFakeServer(t)
+	server.handleJSON("/v1/transaction/get/"+txID, http.StatusOK, `{"id":"`+txID+`","block_height":100000}`)
+	server.handleJSON("/v1/transaction/proof/"+txID, http.StatusOK, string(proofJSON))
+	server.handleJSON("/v1/block_header/get/100000", http.StatusOK, `{
+		"hash": "000000000003ba27aa200b1cecaad478d2b00432346c3f1f3986da1afd33e506",
+		"height": 100000,
+		"merkleroot": "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766"
+	}`)
+	client := server.newClient()
+
+	ctx := context.Background()
+	transaction, err := client.GetTransaction(ctx, txID)
+	require.NoError(t, err)
+	proof, err := client.GetMerkleProof(ctx, transaction.ID)
+	require.NoError(t, err)
+	header, err := client.GetBlockHeaderByHeight(ctx, transaction.BlockHeight)
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		ok, err := VerifyMerkleProof(proof, header)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := &models.MerkleProof{BlockHeight: proof.BlockHeight, Path: [][]models.MerkleLeaf{
+			append([]models.MerkleLeaf(nil), proof.Path[0]...), proof.Path[1],
+		}}
+		tampered.Path[0][1].Hash = block100000[1]
+		ok, err := VerifyMerkleProof(tampered, header)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("other block", func(t *testing.T) {
+		ok, err := VerifyMerkleProof(proof, &models.BlockHeader{Height: 100001, MerkleRoot: header.MerkleRoot})
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("all transactions of the block", func(t *testing.T) {
+		compound := &models.MerkleProof{BlockHeight: 100000, Path: [][]models.MerkleLeaf{{}, {}}}
+		for i, txID := range block100000 {
+			compound.Path[0] = append(compound.Path[0], models.MerkleLeaf{Offset: uint64(i), Hash: txID, TxID: true})
+		}
+		ok, err := VerifyMerkleProof(compound, header)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		// the last transaction of a level with an odd number of nodes is paired with itself
+		odd := &models.MerkleProof{BlockHeight: 1, Path: [][]models.MerkleLeaf{{
+			{Offset: 2, Hash: block100000[2], TxID: true}, {Offset: 3, Duplicate: true},
+		}, {
+			{Offset: 0, Hash: proof.Path[1][0].Hash},
+		}}}
+		ok, err := VerifyMerkleProof(odd, &models.BlockHeader{
+			Height: 1, MerkleRoot: "fa435470825de273081dcc706b25514c936fa6dc80ab965ce6970d68ddd0b553",
+		})
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := VerifyMerkleProof(&models.MerkleProof{BlockHeight: 100000, Path: [][]models.MerkleLeaf{{
+			{Offset: 0, Hash: "zz", TxID: true},
+		}}}, header)
+		assert.ErrorIs(t, err, ErrInvalidMerkleProof)
+
+		_, err = VerifyMerkleProof(&models.MerkleProof{BlockHeight: 100000, Path: [][]models.MerkleLeaf{{
+			{Offset: 0, Hash: block100000[0]},
+		}}}, header)
+		assert.ErrorIs(t, err, ErrInvalidMerkleProof)
+	})
+}
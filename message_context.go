@@ -0,0 +1,101 @@
+package junglebus
+
+import (
+	"sync/atomic"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// MessageContext describes how a transaction was received, it is passed to OnTransactionCtx and OnMempoolCtx. It is
+// the TxContext the middlewares of WithTxMiddleware are given.
+//
+// IsHistorical is true for the transactions of the blocks sent while catching up, it turns false for good once the
+// server waited for the next block (SubscriptionWait): the subscription reached the chain tip and the blocks after
+// are live. Mempool transactions are never historical.
+type MessageContext = TxContext
+
+// pageMark is the block and page reported by the last control message received
+type pageMark struct {
+	block uint32
+	page  uint64
+}
+
+// markControl records the progress of a control message as it was received, for the MessageContext of the
+// transactions after it. The subscription is no longer historical once the server waited for the next block.
+func (s *Subscription) markControl(controlResponse *models.ControlResponse) {
+	code := StatusCode(controlResponse.StatusCode)
+	s.setWaiting(code.IsWaiting())
+	if code.IsWaiting() {
+		atomic.StoreInt32(&s.live, 1)
+	}
+	if controlResponse.Block > 0 {
+		s.lastPage.Store(pageMark{block: controlResponse.Block, page: controlResponse.Page})
+	}
+}
+
+// pageOf returns the page of the block a transaction received now belongs to, 0 before a control message reported
+// a page of the block
+func (s *Subscription) pageOf(block uint32) uint64 {
+	if mark, _ := s.lastPage.Load().(pageMark); mark.block == block {
+		return mark.page
+	}
+	return 0
+}
+
+// isHistorical returns whether a transaction received now is from before the subscription reached the chain tip,
+// mempool transactions never are
+func (s *Subscription) isHistorical(mempool bool) bool {
+	return !mempool && atomic.LoadInt32(&s.live) == 0
+}
+
+// withMessageContext returns the event handler with OnTransactionCtx and OnMempoolCtx taking the place of
+// OnTransaction and OnMempool. The innermost middleware of WithTxMiddleware, which is given the context of every
+// transaction, keeps it until OnTransaction or OnMempool is called: the options wrapping them, like
+// WithHandlerConcurrency and WithStrictOrdering, apply to OnTransactionCtx and OnMempoolCtx as well.
+func (s *Subscription) withMessageContext(eventHandler EventHandler) EventHandler {
+	onTransaction, onMempool := eventHandler.OnTransactionCtx, eventHandler.OnMempoolCtx
+	batched := eventHandler.OnBlock != nil
+	if onTransaction != nil && !batched {
+		eventHandler.OnTransaction = func(tx *models.TransactionResponse) {
+			onTransaction(s.takeTxContext(tx, false), tx)
+		}
+	} else if onTransaction != nil && eventHandler.OnTransaction == nil {
+		// only decides the channels, the transactions are passed on to OnBlock
+		eventHandler.OnTransaction = func(*models.TransactionResponse) {}
+	}
+	if onMempool != nil {
+		eventHandler.OnMempool = func(tx *models.TransactionResponse) {
+			onMempool(s.takeTxContext(tx, true), tx)
+		}
+	}
+	s.messageContext = true
+	s.txMiddleware = append(s.txMiddleware, func(next TxHandler) TxHandler {
+		return func(ctx TxContext, tx *models.TransactionResponse) {
+			if ctx.Mempool && onMempool != nil || !ctx.Mempool && onTransaction != nil && !batched {
+				s.txContexts.Store(tx, ctx)
+			}
+			next(ctx, tx)
+		}
+	})
+	return eventHandler
+}
+
+// takeTxContext returns the context the transaction was passed on to the event handler with, a transaction that did
+// not go through the middlewares gets the context of its block
+func (s *Subscription) takeTxContext(tx *models.TransactionResponse, mempool bool) TxContext {
+	if ctx, ok := s.txContexts.LoadAndDelete(tx); ok {
+		return ctx.(TxContext)
+	}
+	ctx := TxContext{Mempool: mempool, ctx: s.ctx, onError: s.EventHandler.OnError, cache: &txCache{}}
+	if !mempool {
+		ctx.Block = tx.BlockHeight
+	}
+	return ctx
+}
+
+// forgetTxContext drops the context of a transaction that is not passed on to the event handler
+func (s *Subscription) forgetTxContext(tx *models.TransactionResponse) {
+	if s.messageContext {
+		s.txContexts.Delete(tx)
+	}
+}
@@ -0,0 +1,88 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribe_MessageContext will test passing how transactions were received to OnTransactionCtx and OnMempoolCtx
+func TestSubscribe_MessageContext(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		server := newFakeServer(t)
+
+		var mu sync.Mutex
+		contexts := make(map[string]MessageContext)
+		onMessage := func(ctx MessageContext, tx *models.TransactionResponse) {
+			mu.Lock()
+			defer mu.Unlock()
+			contexts[tx.Id] = ctx
+		}
+		received := func(txID string) (MessageContext, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			ctx, ok := contexts[txID]
+			return ctx, ok
+		}
+
+		subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransactionCtx: onMessage,
+			OnMempoolCtx:     onMessage,
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+
+		publishMined := func(txID string, block uint32) {
+			require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: txID, BlockHeight: block}))
+		}
+		start := time.Now()
+		publishMined("tx-1", 100)
+		server.publishMessage(controlChannel, &models.ControlResponse{Block: 100, Page: 2})
+		publishMined("tx-2", 100)
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 101})
+		publishMined("tx-3", 101)
+		server.publishTransaction(mempoolChannel, "tx-4")
+		require.Eventually(t, func() bool {
+			_, ok := received("tx-3")
+			_, mempool := received("tx-4")
+			return ok && mempool
+		}, 5*time.Second, 10*time.Millisecond)
+
+		first, _ := received("tx-1")
+		assert.Equal(t, mainChannel, first.Channel)
+		assert.Equal(t, uint32(100), first.Block)
+		assert.Equal(t, uint64(0), first.Page)
+		assert.Equal(t, uint64(1), first.Offset)
+		assert.True(t, first.IsHistorical)
+		assert.False(t, first.Mempool)
+		assert.False(t, first.ReceivedAt.Before(start))
+
+		second, _ := received("tx-2")
+		assert.Equal(t, uint64(2), second.Page)
+		assert.Equal(t, uint64(2), second.Offset)
+		assert.True(t, second.IsHistorical)
+
+		live, _ := received("tx-3")
+		assert.Equal(t, uint32(101), live.Block)
+		assert.Equal(t, uint64(0), live.Page)
+		assert.False(t, live.IsHistorical)
+
+		mempool, _ := received("tx-4")
+		assert.Equal(t, mempoolChannel, mempool.Channel)
+		assert.True(t, mempool.Mempool)
+		assert.False(t, mempool.IsHistorical)
+	})
+}
@@ -0,0 +1,301 @@
+// Package metrics exposes the statistics of junglebus subscriptions as Prometheus metrics
+//
+// The Collector is a prometheus.Collector to register with a prometheus.Registerer, or to scrape directly as an
+// http.Handler.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the handler duration histogram buckets
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// subscriptionMetric is a metric taken from the stats of every subscription
+type subscriptionMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(stats junglebus.SubscriptionStats) uint64
+}
+
+// consumerMetric is a metric taken from the stats of every consumer of a subscription
+type consumerMetric struct {
+	desc  *prometheus.Desc
+	value func(stats junglebus.ConsumerStats) uint64
+}
+
+var (
+	subscriptionLabels = []string{"subscription_id"}
+	consumerLabels     = []string{"subscription_id", "consumer"}
+
+	subscriptionMetrics = []subscriptionMetric{
+		newSubscriptionMetric("junglebus_last_block", prometheus.GaugeValue,
+			"Last block reported on the control channel.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.LastBlock }),
+		newSubscriptionMetric("junglebus_reconnects_total", prometheus.CounterValue,
+			"Reconnect attempts.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Reconnects }),
+		newSubscriptionMetric("junglebus_errors_total", prometheus.CounterValue,
+			"Errors sent to the error handler.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Errors }),
+		newSubscriptionMetric("junglebus_dropped_messages_total", prometheus.CounterValue,
+			"Messages dropped by the overflow policy.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.DroppedMessages }),
+		newSubscriptionMetric("junglebus_filtered_total", prometheus.CounterValue,
+			"Transactions dropped by the filter of the subscription.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Filtered }),
+		newSubscriptionMetric("junglebus_duplicates_suppressed_total", prometheus.CounterValue,
+			"Transactions suppressed as duplicates.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.DuplicatesSuppressed }),
+		newSubscriptionMetric("junglebus_dead_lettered_total", prometheus.CounterValue,
+			"Transactions given up on after failing every handler attempt.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.DeadLettered }),
+		newSubscriptionMetric("junglebus_reordered_total", prometheus.CounterValue,
+			"Transactions passed on out of the order they were received in.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Reordered }),
+		newSubscriptionMetric("junglebus_reorder_dropped_total", prometheus.CounterValue,
+			"Transactions dropped for arriving after later ones were passed on.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.ReorderDropped }),
+		newSubscriptionMetric("junglebus_head_block", prometheus.GaugeValue,
+			"Block of the newest message received from the server.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Lag.HeadBlock }),
+		newSubscriptionMetric("junglebus_processed_block", prometheus.GaugeValue,
+			"Block of the newest message handled.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Lag.ProcessedBlock }),
+		newSubscriptionMetric("junglebus_block_lag", prometheus.GaugeValue,
+			"Blocks the handled messages are behind the received ones.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Lag.BlockLag }),
+		newSubscriptionMetric("junglebus_offset_lag", prometheus.GaugeValue,
+			"Publications of the main channel received and not handled yet.",
+			func(s junglebus.SubscriptionStats) uint64 { return s.Lag.OffsetLag }),
+	}
+
+	consumerMetrics = []consumerMetric{
+		newConsumerMetric("junglebus_consumer_block_lag",
+			"Blocks the messages handled by a consumer are behind the received ones.",
+			func(s junglebus.ConsumerStats) uint64 { return s.Lag.BlockLag }),
+		newConsumerMetric("junglebus_consumer_offset_lag",
+			"Publications of the main channel queued for a consumer and not handled yet.",
+			func(s junglebus.ConsumerStats) uint64 { return s.Lag.OffsetLag }),
+		newConsumerMetric("junglebus_consumer_queue_depth",
+			"Messages waiting in the queue of a consumer.",
+			func(s junglebus.ConsumerStats) uint64 { return uint64(s.QueueDepth) }),
+	}
+
+	transactionsDesc = prometheus.NewDesc("junglebus_transactions_total", "Transactions received.",
+		[]string{"subscription_id", "channel"}, nil)
+	retriesDesc = prometheus.NewDesc("junglebus_http_retries_total", "Retried REST requests.", nil, nil)
+	circuitDesc = prometheus.NewDesc("junglebus_http_circuit_state",
+		"State of the circuit breaker, 0 closed, 1 open, 2 half-open.", nil, nil)
+)
+
+func newSubscriptionMetric(name string, valueType prometheus.ValueType, help string,
+	value func(stats junglebus.SubscriptionStats) uint64) subscriptionMetric {
+
+	return subscriptionMetric{
+		desc:      prometheus.NewDesc(name, help, subscriptionLabels, nil),
+		valueType: valueType,
+		value:     value,
+	}
+}
+
+func newConsumerMetric(name, help string, value func(stats junglebus.ConsumerStats) uint64) consumerMetric {
+	return consumerMetric{desc: prometheus.NewDesc(name, help, consumerLabels, nil), value: value}
+}
+
+// Collector collects the metrics of the subscriptions added to it, it is a prometheus.Collector
+type Collector struct {
+	mu            sync.Mutex
+	subscriptions map[string]*junglebus.Subscription
+	transport     transports.TransportService
+	durations     *prometheus.HistogramVec // per subscription and handler
+	registry      *prometheus.Registry     // only the collector, for ServeHTTP and WriteTo
+}
+
+// NewCollector creates a collector for the given subscriptions, more can be added later with Add
+func NewCollector(subscriptions ...*junglebus.Subscription) *Collector {
+	c := &Collector{
+		subscriptions: map[string]*junglebus.Subscription{},
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "junglebus_handler_duration_seconds",
+			Help:    "Duration of the event handler callbacks.",
+			Buckets: DefaultBuckets,
+		}, []string{"subscription_id", "handler"}),
+		registry: prometheus.NewPedanticRegistry(),
+	}
+	c.registry.MustRegister(c)
+	c.Add(subscriptions...)
+	return c
+}
+
+// Add starts collecting the metrics of the subscriptions, replacing subscriptions with the same ID
+func (c *Collector) Add(subscriptions ...*junglebus.Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, subscription := range subscriptions {
+		c.subscriptions[subscription.SubscriptionID] = subscription
+	}
+}
+
+// Remove stops collecting the metrics of the subscription with the given ID, its handler durations included
+func (c *Collector) Remove(subscriptionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subscriptions, subscriptionID)
+	c.durations.DeletePartialMatch(prometheus.Labels{"subscription_id": subscriptionID})
+}
+
+// SetTransport starts collecting the metrics of the REST transport, see junglebus.Client.GetTransport
+func (c *Collector) SetTransport(transport transports.TransportService) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transport = transport
+}
+
+// InstrumentHandler returns the event handler recording the duration of its callbacks in the
+// junglebus_handler_duration_seconds histogram of the subscription
+func (c *Collector) InstrumentHandler(subscriptionID string, eventHandler junglebus.EventHandler) junglebus.EventHandler {
+	instrumented := eventHandler
+	observer := func(handler string) prometheus.Observer {
+		return c.durations.WithLabelValues(subscriptionID, handler)
+	}
+	if eventHandler.OnTransaction != nil {
+		duration := observer("OnTransaction")
+		instrumented.OnTransaction = func(tx *models.TransactionResponse) {
+			defer observeSince(duration, time.Now())
+			eventHandler.OnTransaction(tx)
+		}
+	}
+	if eventHandler.OnMempool != nil {
+		duration := observer("OnMempool")
+		instrumented.OnMempool = func(tx *models.TransactionResponse) {
+			defer observeSince(duration, time.Now())
+			eventHandler.OnMempool(tx)
+		}
+	}
+	if eventHandler.OnTransactionE != nil {
+		duration := observer("OnTransactionE")
+		instrumented.OnTransactionE = func(tx *models.TransactionResponse) error {
+			defer observeSince(duration, time.Now())
+			return eventHandler.OnTransactionE(tx)
+		}
+	}
+	if eventHandler.OnMempoolE != nil {
+		duration := observer("OnMempoolE")
+		instrumented.OnMempoolE = func(tx *models.TransactionResponse) error {
+			defer observeSince(duration, time.Now())
+			return eventHandler.OnMempoolE(tx)
+		}
+	}
+	if eventHandler.OnBlock != nil {
+		duration := observer("OnBlock")
+		instrumented.OnBlock = func(height uint32, transactions []*models.TransactionResponse) {
+			defer observeSince(duration, time.Now())
+			eventHandler.OnBlock(height, transactions)
+		}
+	}
+	if eventHandler.OnBlockDone != nil {
+		duration := observer("OnBlockDone")
+		instrumented.OnBlockDone = func(height uint32, transactions uint64) {
+			defer observeSince(duration, time.Now())
+			eventHandler.OnBlockDone(height, transactions)
+		}
+	}
+	return instrumented
+}
+
+// observeSince records the duration of a handler call that started at start
+func observeSince(duration prometheus.Observer, start time.Time) {
+	duration.Observe(time.Since(start).Seconds())
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range subscriptionMetrics {
+		ch <- metric.desc
+	}
+	for _, metric := range consumerMetrics {
+		ch <- metric.desc
+	}
+	ch <- transactionsDesc
+	ch <- retriesDesc
+	ch <- circuitDesc
+	c.durations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector, the stats are taken from the subscriptions on every collection
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	subscriptions := make([]*junglebus.Subscription, 0, len(c.subscriptions))
+	for _, subscription := range c.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	transport := c.transport
+	c.mu.Unlock()
+
+	for _, subscription := range subscriptions {
+		id := subscription.SubscriptionID
+		stats := subscription.Stats()
+		for _, metric := range subscriptionMetrics {
+			ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, float64(metric.value(stats)), id)
+		}
+		ch <- prometheus.MustNewConstMetric(transactionsDesc, prometheus.CounterValue,
+			float64(stats.TransactionsReceived), id, "main")
+		ch <- prometheus.MustNewConstMetric(transactionsDesc, prometheus.CounterValue,
+			float64(stats.MempoolReceived), id, "mempool")
+		for consumer, consumerStats := range subscription.ConsumerStats() {
+			for _, metric := range consumerMetrics {
+				ch <- prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue,
+					float64(metric.value(consumerStats)), id, consumer)
+			}
+		}
+	}
+	if transport != nil {
+		ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(transport.Retries()))
+		ch <- prometheus.MustNewConstMetric(circuitDesc, prometheus.GaugeValue, float64(transport.CircuitState()))
+	}
+	c.durations.Collect(ch)
+}
+
+// ServeHTTP writes the metrics of the collector alone, in the format negotiated with the scraper
+func (c *Collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+}
+
+// WriteTo writes the metrics of the collector alone in the Prometheus text format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	families, err := c.registry.Gather()
+	if err != nil {
+		return 0, err
+	}
+	out := &countingWriter{w: w}
+	encoder := expfmt.NewEncoder(out, expfmt.FmtText)
+	for _, family := range families {
+		if err = encoder.Encode(family); err != nil {
+			return out.n, err
+		}
+	}
+	return out.n, nil
+}
+
+// countingWriter counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCollector will test the metrics collected by the collector
+func TestCollector(t *testing.T) {
+	collector := NewCollector(&junglebus.Subscription{SubscriptionID: "test-subscription"})
+	problems, err := testutil.CollectAndLint(collector)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	var handled int
+	eventHandler := collector.InstrumentHandler("test-subscription", junglebus.EventHandler{
+		OnTransaction: func(*models.TransactionResponse) { handled++ },
+	})
+	for i := 0; i < 3; i++ {
+		eventHandler.OnTransaction(&models.TransactionResponse{})
+	}
+	assert.Equal(t, 3, handled)
+
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP junglebus_last_block Last block reported on the control channel.
+# TYPE junglebus_last_block gauge
+junglebus_last_block{subscription_id="test-subscription"} 0
+# HELP junglebus_transactions_total Transactions received.
+# TYPE junglebus_transactions_total counter
+junglebus_transactions_total{channel="main",subscription_id="test-subscription"} 0
+junglebus_transactions_total{channel="mempool",subscription_id="test-subscription"} 0
+# HELP junglebus_block_lag Blocks the handled messages are behind the received ones.
+# TYPE junglebus_block_lag gauge
+junglebus_block_lag{subscription_id="test-subscription"} 0
+`), "junglebus_last_block", "junglebus_transactions_total", "junglebus_block_lag"))
+
+	// the durations are not known up front, only their count is compared
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var histogram bool
+	for _, family := range families {
+		if family.GetName() != "junglebus_handler_duration_seconds" {
+			continue
+		}
+		require.Len(t, family.GetMetric(), 1)
+		histogram = true
+		metric := family.GetMetric()[0]
+		assert.Equal(t, uint64(3), metric.GetHistogram().GetSampleCount())
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{"subscription_id": "test-subscription", "handler": "OnTransaction"}, labels)
+	}
+	assert.True(t, histogram)
+
+	transport, err := transports.NewTransport(transports.WithHTTP("http://localhost"))
+	require.NoError(t, err)
+	collector.SetTransport(transport)
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP junglebus_http_retries_total Retried REST requests.
+# TYPE junglebus_http_retries_total counter
+junglebus_http_retries_total 0
+# HELP junglebus_http_circuit_state State of the circuit breaker, 0 closed, 1 open, 2 half-open.
+# TYPE junglebus_http_circuit_state gauge
+junglebus_http_circuit_state 0
+`), "junglebus_http_retries_total", "junglebus_http_circuit_state"))
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `junglebus_reconnects_total{subscription_id="test-subscription"} 0`+"\n")
+
+	collector.Remove("test-subscription")
+	var out strings.Builder
+	_, err = collector.WriteTo(&out)
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "test-subscription")
+}
+
+// TestCollector_labelEscaping will test escaping subscription IDs in the text format, which only escapes quotes,
+// backslashes and newlines: Go quoting would escape the tab and the non-ASCII character too
+func TestCollector_labelEscaping(t *testing.T) {
+	collector := NewCollector(&junglebus.Subscription{SubscriptionID: "quote\"back\\slash\nnewline\ttab é"})
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP junglebus_errors_total Errors sent to the error handler.
+# TYPE junglebus_errors_total counter
+junglebus_errors_total{subscription_id="quote\"back\\slash\nnewline	tab é"} 0
+`), "junglebus_errors_total"))
+
+	var out strings.Builder
+	_, err := collector.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `junglebus_errors_total{subscription_id="quote\"back\\slash\nnewline	tab é"} 0`+"\n")
+}
@@ -8,3 +8,23 @@ type Address struct {
 	BlockHash     string `json:"block_hash"`
 	BlockIndex    uint64 `json:"block_index"`
 }
+
+// AddressTx is a transaction of an address
+type AddressTx struct {
+	TransactionID string `json:"transaction_id"`
+	BlockHash     string `json:"block_hash"`
+	BlockHeight   uint32 `json:"block_height"`
+	BlockIndex    uint64 `json:"block_index"` // position of the transaction in the block
+
+	Transaction *Transaction `json:"-"` // full transaction, only set when hydrated
+}
+
+// AddressDetails is the state of an address on JungleBus
+type AddressDetails struct {
+	Address          string `json:"address"`
+	FirstSeenHeight  uint32 `json:"first_seen_height"` // block height of the first transaction of the address
+	FirstSeenTime    uint32 `json:"first_seen_time"`   // unix timestamp of the block of the first transaction
+	TransactionCount uint64 `json:"transaction_count"`
+	Crawled          bool   `json:"crawled"`        // whether the history of the address is complete
+	CrawledHeight    uint32 `json:"crawled_height"` // block height the history of the address is complete up to
+}
@@ -4,12 +4,13 @@ package models
 // This comes from Bux models_block_headers.go
 type BlockHeader struct {
 	Hash       string `json:"hash"`
+	PrevHash   string `json:"prevhash,omitempty"` // hash of the previous block, empty when not returned by the server
 	Coin       uint32 `json:"coin"`
 	Height     uint32 `json:"height"`
-	Time       uint32 `json:"time"`
+	Time       uint32 `json:"time"` // unix timestamp of the block
 	Nonce      uint32 `json:"nonce"`
 	Version    uint32 `json:"version"`
 	MerkleRoot string `json:"merkleroot"`
-	Bits       string `json:"bits"`
-	Synced     uint64 `json:"synced"`
+	Bits       string `json:"bits"`   // difficulty target in compact form, hex encoded
+	Synced     uint64 `json:"synced"` // whether JungleBus processed the block, 1 when synced
 }
@@ -0,0 +1,16 @@
+package models
+
+// MerkleProof is a merkle proof of one or more transactions in a block, in the JSON form of the BSV Unified Merkle
+// Path (BUMP) format
+type MerkleProof struct {
+	BlockHeight uint32         `json:"blockHeight"`
+	Path        [][]MerkleLeaf `json:"path"` // the leaves of every level of the tree, from the transactions up
+}
+
+// MerkleLeaf is a node of a level of a merkle proof
+type MerkleLeaf struct {
+	Offset    uint64 `json:"offset"`              // position of the node in the level
+	Hash      string `json:"hash,omitempty"`      // hex encoded, empty when duplicate
+	TxID      bool   `json:"txid,omitempty"`      // whether the node is a transaction the proof is for
+	Duplicate bool   `json:"duplicate,omitempty"` // whether the node is a copy of its sibling, at the end of a level
+}
@@ -0,0 +1,25 @@
+package models
+
+import "sync"
+
+// transactionPool keeps released transactions for reuse, see AcquireTransactionResponse
+var transactionPool = sync.Pool{
+	New: func() interface{} {
+		return &TransactionResponse{}
+	},
+}
+
+// AcquireTransactionResponse returns an empty transaction from the pool, to be given back with Release
+func AcquireTransactionResponse() *TransactionResponse {
+	return transactionPool.Get().(*TransactionResponse)
+}
+
+// Release resets the transaction and gives it back to the pool of AcquireTransactionResponse, the transaction and
+// its fields must not be used afterwards. Transactions that are never released are garbage collected as usual.
+func (x *TransactionResponse) Release() {
+	if x == nil {
+		return
+	}
+	x.Reset()
+	transactionPool.Put(x)
+}
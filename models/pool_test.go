@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTransactionResponse_Release will test released transactions are reset before they are reused
+func TestTransactionResponse_Release(t *testing.T) {
+	tx := AcquireTransactionResponse()
+	tx.Id = "txid"
+	tx.BlockHeight = 100
+	tx.Transaction = []byte{1, 2, 3}
+	tx.Release()
+	assert.Empty(t, tx.Id)
+	assert.Zero(t, tx.BlockHeight)
+	assert.Nil(t, tx.Transaction)
+
+	reused := AcquireTransactionResponse()
+	assert.Empty(t, reused.Id)
+	assert.Nil(t, reused.Transaction)
+	reused.Release()
+
+	assert.NotPanics(t, func() {
+		var released *TransactionResponse
+		released.Release()
+	})
+}
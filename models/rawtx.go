@@ -0,0 +1,112 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// txReader reads the fields of a raw transaction
+type txReader struct {
+	raw []byte
+	pos int
+	err error
+}
+
+// next returns the next n bytes, an error is kept when the transaction is too short
+func (r *txReader) next(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.raw)-r.pos) {
+		r.err = fmt.Errorf("%w: unexpected end at byte %d", ErrMalformedTransaction, r.pos)
+		return nil
+	}
+	b := r.raw[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// varInt reads a variable length integer
+func (r *txReader) varInt() uint64 {
+	prefix := r.next(1)
+	if prefix == nil {
+		return 0
+	}
+	switch prefix[0] {
+	case 0xfd:
+		if b := r.next(2); b != nil {
+			return uint64(binary.LittleEndian.Uint16(b))
+		}
+	case 0xfe:
+		if b := r.next(4); b != nil {
+			return uint64(binary.LittleEndian.Uint32(b))
+		}
+	case 0xff:
+		if b := r.next(8); b != nil {
+			return binary.LittleEndian.Uint64(b)
+		}
+	default:
+		return uint64(prefix[0])
+	}
+	return 0
+}
+
+// EachOutput calls fn with the value in satoshis and the locking script of the outputs of the raw transaction, until
+// fn returns false. The script points into the raw transaction. ErrMalformedTransaction is returned for a
+// transaction that can not be read up to its last output.
+func EachOutput(raw []byte, fn func(value uint64, script []byte) bool) error {
+	r := &txReader{raw: raw}
+	r.next(4) // version
+	for inputs := r.varInt(); inputs > 0 && r.err == nil; inputs-- {
+		r.next(36) // previous txid and output index
+		r.next(r.varInt())
+		r.next(4) // sequence
+	}
+	for outputs := r.varInt(); outputs > 0 && r.err == nil; outputs-- {
+		value := r.next(8)
+		script := r.next(r.varInt())
+		if r.err == nil && !fn(binary.LittleEndian.Uint64(value), script) {
+			return nil
+		}
+	}
+	return r.err
+}
+
+// DataOutput is a data output of a transaction, see DataPushes
+type DataOutput struct {
+	Index  uint32   // index of the output in the transaction
+	Value  uint64   // in satoshis
+	Pushes [][]byte // the pushes after OP_RETURN, pointing into the raw transaction
+}
+
+// EachOutput calls fn with the outputs of the raw transaction, see EachOutput
+func (x *TransactionResponse) EachOutput(fn func(value uint64, script []byte) bool) error {
+	return EachOutput(x.GetTransaction(), fn)
+}
+
+// DataOutputs returns the data outputs of the raw transaction with their pushes, like the B, MAP or BAP protocols
+// use. The outputs read before a malformed part of the transaction are returned with the error.
+func (x *TransactionResponse) DataOutputs() ([]DataOutput, error) {
+	var outputs []DataOutput
+	var index uint32
+	err := x.EachOutput(func(value uint64, script []byte) bool {
+		if pushes, ok := DataPushes(script); ok {
+			outputs = append(outputs, DataOutput{Index: index, Value: value, Pushes: pushes})
+		}
+		index++
+		return true
+	})
+	return outputs, err
+}
+
+// HasPrefix returns whether the locking script of an output of the raw transaction starts with the prefix, like
+// OP_FALSE OP_RETURN followed by the push of a protocol prefix
+func (x *TransactionResponse) HasPrefix(prefix []byte) bool {
+	found := false
+	_ = x.EachOutput(func(_ uint64, script []byte) bool {
+		found = bytes.HasPrefix(script, prefix)
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOutput is an output of a raw transaction built by newRawTransaction
+type testOutput struct {
+	value  uint64
+	script []byte
+}
+
+// newRawTransaction returns a raw transaction with a single input and the outputs
+func newRawTransaction(outputs ...testOutput) []byte {
+	raw := []byte{1, 0, 0, 0, 1}
+	raw = append(raw, make([]byte, 36)...)
+	raw = append(raw, 2, 0x51, 0x51, 0xff, 0xff, 0xff, 0xff)
+	raw = append(raw, byte(len(outputs)))
+	for _, output := range outputs {
+		value := make([]byte, 8)
+		binary.LittleEndian.PutUint64(value, output.value)
+		raw = append(raw, value...)
+		raw = append(raw, byte(len(output.script)))
+		raw = append(raw, output.script...)
+	}
+	return append(raw, 0, 0, 0, 0)
+}
+
+var (
+	testP2PKH = []byte{
+		0x76, 0xa9, 0x14, 0x62, 0xe9, 0x07, 0xb1, 0x5c, 0xbf, 0x27, 0xd5, 0x42, 0x53,
+		0x99, 0xeb, 0xf6, 0xf0, 0xfb, 0x50, 0xeb, 0xb8, 0x8f, 0x18, 0x88, 0xac,
+	}
+	testOpReturn = []byte{0x00, 0x6a, 0x04, 'j', 'b', 'u', 's', 0x02, 'h', 'i'}
+)
+
+func TestEachOutput(t *testing.T) {
+	raw := newRawTransaction(testOutput{1000, testP2PKH}, testOutput{0, testOpReturn})
+
+	var values []uint64
+	var scripts [][]byte
+	require.NoError(t, EachOutput(raw, func(value uint64, script []byte) bool {
+		values = append(values, value)
+		scripts = append(scripts, script)
+		return true
+	}))
+	assert.Equal(t, []uint64{1000, 0}, values)
+	assert.Equal(t, testOpReturn, scripts[1])
+
+	t.Run("stops early", func(t *testing.T) {
+		calls := 0
+		require.NoError(t, EachOutput(raw, func(uint64, []byte) bool {
+			calls++
+			return false
+		}))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for _, n := range []int{0, 3, 10, len(raw) - 10} {
+			assert.ErrorIs(t, EachOutput(raw[:n], func(uint64, []byte) bool { return true }), ErrMalformedTransaction, n)
+		}
+	})
+
+	t.Run("large varint", func(t *testing.T) {
+		// an output count of 2^32 with only two outputs
+		huge := append(append([]byte(nil), raw[:48]...), 0xff, 0, 0, 0, 0, 1, 0, 0, 0)
+		huge = append(huge, raw[49:len(raw)-4]...)
+		assert.ErrorIs(t, EachOutput(huge, func(uint64, []byte) bool { return true }), ErrMalformedTransaction)
+	})
+}
+
+func TestEachChunk(t *testing.T) {
+	long := make([]byte, 300)
+	script := append([]byte{OpFalse, OpReturn, 0x02, 'h', 'i', OpPushData1, 3, 'a', 'b', 'c', OpPushData2, 0x2c, 0x01}, long...)
+	script = append(script, OpPushData4, 1, 0, 0, 0, '|', 0x51)
+
+	var chunks []ScriptChunk
+	require.NoError(t, EachChunk(script, func(chunk ScriptChunk) bool {
+		chunks = append(chunks, chunk)
+		return true
+	}))
+	assert.Equal(t, []ScriptChunk{
+		{Op: OpFalse, Data: []byte{}},
+		{Op: OpReturn},
+		{Op: 0x02, Data: []byte("hi")},
+		{Op: OpPushData1, Data: []byte("abc")},
+		{Op: OpPushData2, Data: long},
+		{Op: OpPushData4, Data: []byte("|")},
+		{Op: 0x51},
+	}, chunks)
+	assert.True(t, chunks[0].IsPush())
+	assert.False(t, chunks[1].IsPush())
+
+	t.Run("malformed", func(t *testing.T) {
+		for name, script := range map[string][]byte{
+			"direct push":    {0x02, 'h'},
+			"pushdata1 size": {OpPushData1},
+			"pushdata2 size": {OpPushData2, 1},
+			"pushdata4 size": {OpPushData4, 1, 0, 0},
+			"pushdata4 data": {OpPushData4, 0xff, 0xff, 0xff, 0xff, 'a'},
+		} {
+			assert.ErrorIs(t, EachChunk(script, func(ScriptChunk) bool { return true }), ErrMalformedScript, name)
+		}
+	})
+}
+
+func TestTransactionResponse_DataOutputs(t *testing.T) {
+	tx := &TransactionResponse{Transaction: newRawTransaction(
+		testOutput{1000, testP2PKH},
+		testOutput{0, testOpReturn},
+		testOutput{1, []byte{OpReturn, 0x01, 'a', 0x51, 0x01, 'b'}},
+		testOutput{0, []byte{OpFalse, OpReturn, 0x05, 'x'}},
+	)}
+
+	outputs, err := tx.DataOutputs()
+	require.NoError(t, err)
+	assert.Equal(t, []DataOutput{
+		{Index: 1, Value: 0, Pushes: [][]byte{[]byte("jbus"), []byte("hi")}},
+		{Index: 2, Value: 1, Pushes: [][]byte{[]byte("a")}},
+		{Index: 3, Value: 0, Pushes: [][]byte{}},
+	}, outputs)
+
+	truncated := &TransactionResponse{Transaction: tx.Transaction[:len(tx.Transaction)-20]}
+	outputs, err = truncated.DataOutputs()
+	assert.ErrorIs(t, err, ErrMalformedTransaction)
+	assert.Len(t, outputs, 1)
+
+	outputs, err = (&TransactionResponse{}).DataOutputs()
+	assert.ErrorIs(t, err, ErrMalformedTransaction)
+	assert.Empty(t, outputs)
+}
+
+func TestTransactionResponse_HasPrefix(t *testing.T) {
+	tx := &TransactionResponse{Transaction: newRawTransaction(testOutput{1000, testP2PKH}, testOutput{0, testOpReturn})}
+	assert.True(t, tx.HasPrefix([]byte{OpFalse, OpReturn, 0x04, 'j', 'b'}))
+	assert.True(t, tx.HasPrefix(testP2PKH[:3]))
+	assert.False(t, tx.HasPrefix([]byte{OpReturn}))
+	assert.False(t, (&TransactionResponse{}).HasPrefix(nil))
+}
+
+// FuzzEachChunk checks that hostile scripts are rejected without panicking
+func FuzzEachChunk(f *testing.F) {
+	f.Add(testOpReturn)
+	f.Add(testP2PKH)
+	f.Add([]byte{OpPushData4, 0xff, 0xff, 0xff, 0x7f})
+	f.Add([]byte{OpPushData2, 0xff})
+	f.Fuzz(func(t *testing.T, script []byte) {
+		total := 0
+		err := EachChunk(script, func(chunk ScriptChunk) bool {
+			total += 1 + len(chunk.Data)
+			assert.Equal(t, chunk.IsPush(), chunk.Data != nil)
+			return true
+		})
+		if err == nil {
+			assert.LessOrEqual(t, total, len(script))
+		}
+		_, _ = DataPushes(script)
+	})
+}
+
+// FuzzDataOutputs checks that hostile transactions are rejected without panicking
+func FuzzDataOutputs(f *testing.F) {
+	f.Add(newRawTransaction(testOutput{1000, testP2PKH}, testOutput{0, testOpReturn}))
+	f.Add(newRawTransaction(testOutput{0, []byte{OpFalse, OpReturn, OpPushData4, 0xff, 0xff, 0xff, 0xff}}))
+	f.Add([]byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		tx := &TransactionResponse{Transaction: raw}
+		outputs, err := tx.DataOutputs()
+		if err == nil {
+			for _, output := range outputs {
+				for _, push := range output.Pushes {
+					assert.LessOrEqual(t, len(push), len(raw))
+				}
+			}
+		}
+		_ = tx.HasPrefix([]byte{OpFalse, OpReturn})
+	})
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedTransaction is when a raw transaction can not be read
+var ErrMalformedTransaction = errors.New("malformed transaction")
+
+// ErrMalformedScript is when a script ends in the middle of a push
+var ErrMalformedScript = errors.New("malformed script")
+
+// Opcodes of the script parser
+const (
+	OpFalse     byte = 0x00
+	OpPushData1 byte = 0x4c
+	OpPushData2 byte = 0x4d
+	OpPushData4 byte = 0x4e
+	OpReturn    byte = 0x6a
+)
+
+// ScriptChunk is an opcode of a script with the data it pushes
+type ScriptChunk struct {
+	Op   byte   // the opcode, the length of the data for direct pushes
+	Data []byte // the pushed data, nil for opcodes that are not pushes, points into the script
+}
+
+// IsPush returns whether the chunk pushes data, OP_FALSE pushes empty data
+func (c ScriptChunk) IsPush() bool {
+	return c.Op <= OpPushData4
+}
+
+// EachChunk calls fn with the chunks of the script, until fn returns false. ErrMalformedScript is returned when a
+// push runs past the end of the script, after fn was called with the chunks before it.
+func EachChunk(script []byte, fn func(chunk ScriptChunk) bool) error {
+	for pos := 0; pos < len(script); {
+		op := script[pos]
+		pos++
+
+		var size uint64
+		switch {
+		case op < OpPushData1:
+			size = uint64(op)
+		case op == OpPushData1 && pos+1 <= len(script):
+			size = uint64(script[pos])
+			pos++
+		case op == OpPushData2 && pos+2 <= len(script):
+			size = uint64(binary.LittleEndian.Uint16(script[pos:]))
+			pos += 2
+		case op == OpPushData4 && pos+4 <= len(script):
+			size = uint64(binary.LittleEndian.Uint32(script[pos:]))
+			pos += 4
+		case op <= OpPushData4:
+			return fmt.Errorf("%w: push length past the end at byte %d", ErrMalformedScript, pos-1)
+		default:
+			if !fn(ScriptChunk{Op: op}) {
+				return nil
+			}
+			continue
+		}
+
+		if size > uint64(len(script)-pos) {
+			return fmt.Errorf("%w: push of %d bytes past the end at byte %d", ErrMalformedScript, size, pos)
+		}
+		if !fn(ScriptChunk{Op: op, Data: script[pos : pos+int(size) : pos+int(size)]}) {
+			return nil
+		}
+		pos += int(size)
+	}
+	return nil
+}
+
+// DataPushes returns the pushes after the OP_RETURN of a data output script, OP_FALSE OP_RETURN or OP_RETURN, and
+// whether the script is a data output. Parsing stops at the first opcode that is not a push or at a malformed push,
+// the data after OP_RETURN is not executed so it does not have to be a valid script.
+func DataPushes(script []byte) ([][]byte, bool) {
+	switch {
+	case len(script) >= 2 && script[0] == OpFalse && script[1] == OpReturn:
+		script = script[2:]
+	case len(script) >= 1 && script[0] == OpReturn:
+		script = script[1:]
+	default:
+		return nil, false
+	}
+
+	pushes := [][]byte{}
+	_ = EachChunk(script, func(chunk ScriptChunk) bool {
+		if !chunk.IsPush() {
+			return false
+		}
+		pushes = append(pushes, chunk.Data)
+		return true
+	})
+	return pushes, true
+}
@@ -0,0 +1,16 @@
+package models
+
+// SubscriptionDetails is a subscription registered on JungleBus and the queries it matches transactions with
+type SubscriptionDetails struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"` // like "active"
+	Addresses   []string `json:"addresses"`
+	InputTypes  []string `json:"input_types"`
+	OutputTypes []string `json:"output_types"`
+	Contexts    []string `json:"contexts"`
+	SubContexts []string `json:"sub_contexts"`
+	Mempool     bool     `json:"mempool"` // whether mempool transactions are streamed
+	// EarliestBlock is the earliest block the server streams the subscription from, 0 when it streams every block
+	EarliestBlock uint64 `json:"earliest_block,omitempty"`
+}
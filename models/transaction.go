@@ -3,11 +3,11 @@ package models
 // Transaction struct
 type Transaction struct {
 	ID          string `json:"id"`
-	Transaction []byte `json:"transaction"`
-	BlockHash   string `json:"block_hash"`
+	Transaction []byte `json:"transaction"` // the raw transaction
+	BlockHash   string `json:"block_hash"`  // empty when the transaction is not mined yet
 	BlockHeight uint32 `json:"block_height"`
-	BlockTime   uint32 `json:"block_time"`
-	BlockIndex  uint64 `json:"block_index"`
+	BlockTime   uint32 `json:"block_time"`  // unix timestamp of the block
+	BlockIndex  uint64 `json:"block_index"` // position of the transaction in the block
 
 	// index data
 	// input/output types are
@@ -24,3 +24,9 @@ type Transaction struct {
 	// the merkle proof in binary
 	MerkleProof []byte `json:"merkle_proof"`
 }
+
+// BlockTransactionsPage is a page of the transactions of a subscription in a block
+type BlockTransactionsPage struct {
+	Transactions []*TransactionResponse `json:"transactions"`
+	NextPage     uint64                 `json:"next_page"` // 0 on the last page of the block
+}
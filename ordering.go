@@ -0,0 +1,112 @@
+package junglebus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// DefaultOrderingBufferSize is the default maximum number of transactions WithStrictOrdering holds back
+const DefaultOrderingBufferSize = 100000
+
+// orderingBuffer holds mined transactions back until their block is done, to pass them on ordered by block and
+// index, see WithStrictOrdering
+type orderingBuffer struct {
+	mu        sync.Mutex
+	size      int
+	next      func(tx *models.TransactionResponse) // OnTransaction the transactions are passed on to
+	pending   []*models.TransactionResponse
+	latest    *models.TransactionResponse // the transaction of pending ordered last, to count reordering
+	delivered bool                        // whether a transaction was passed on at lastBlock and lastIndex
+	lastBlock uint32
+	lastIndex uint64
+}
+
+// before returns whether the transaction at the block and index is ordered before the other one
+func before(block uint32, index uint64, other *models.TransactionResponse) bool {
+	return block < other.BlockHeight || block == other.BlockHeight && index < other.BlockIndex
+}
+
+// orderTransaction is OnTransaction with WithStrictOrdering, it holds the transaction back until its block is done,
+// see flushOrdered
+func (s *Subscription) orderTransaction(tx *models.TransactionResponse) {
+	b := s.ordering
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.delivered && !before(b.lastBlock, b.lastIndex, tx) {
+		// a later transaction was passed on already, like when a page is sent again after reconnecting
+		atomic.AddUint64(&s.counters.reorderDropped, 1)
+		s.release(tx)
+		return
+	}
+	if b.latest != nil && before(tx.BlockHeight, tx.BlockIndex, b.latest) {
+		atomic.AddUint64(&s.counters.reordered, 1)
+	} else {
+		b.latest = tx
+	}
+	b.pending = append(b.pending, tx)
+	if len(b.pending) >= b.size {
+		s.log(levelWarn, "ordering buffer full", "block", tx.BlockHeight, "transactions", len(b.pending))
+		s.EventHandler.OnError(fmt.Errorf("%w: passing on %d transactions before block %d is done",
+			ErrOrderingBufferFull, len(b.pending), tx.BlockHeight))
+		s.deliverOrderedLocked(math.MaxUint32)
+	}
+}
+
+// flushOrdered passes the transactions held back up to the block that is done on in order
+func (s *Subscription) flushOrdered(block uint32) {
+	b := s.ordering
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.deliverOrderedLocked(block)
+}
+
+func (s *Subscription) deliverOrderedLocked(block uint32) {
+	b := s.ordering
+	sort.SliceStable(b.pending, func(i, j int) bool {
+		return before(b.pending[i].BlockHeight, b.pending[i].BlockIndex, b.pending[j])
+	})
+	n := sort.Search(len(b.pending), func(i int) bool {
+		return b.pending[i].BlockHeight > block
+	})
+	for _, tx := range b.pending[:n] {
+		b.delivered, b.lastBlock, b.lastIndex = true, tx.BlockHeight, tx.BlockIndex
+		b.next(tx)
+	}
+	b.pending = append(b.pending[:0], b.pending[n:]...)
+	b.latest = nil
+	if len(b.pending) > 0 {
+		b.latest = b.pending[len(b.pending)-1]
+	}
+}
+
+// discardOrdered drops the transactions held back from the block that is rolled back, the transactions streamed
+// again from that block are passed on again
+func (s *Subscription) discardOrdered(block uint32) {
+	b := s.ordering
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := b.pending[:0]
+	for _, tx := range b.pending {
+		if tx.BlockHeight < block {
+			kept = append(kept, tx)
+		} else {
+			s.forgetTxContext(tx)
+		}
+	}
+	b.pending = kept
+	b.latest = nil
+	for _, tx := range b.pending {
+		if b.latest == nil || before(b.latest.BlockHeight, b.latest.BlockIndex, tx) {
+			b.latest = tx
+		}
+	}
+	if b.delivered && b.lastBlock >= block {
+		b.delivered = block > 0
+		b.lastBlock, b.lastIndex = block-1, math.MaxUint64
+	}
+}
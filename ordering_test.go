@@ -0,0 +1,159 @@
+package junglebus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribe_WithStrictOrdering will test passing transactions on ordered by block and index
+func TestSubscribe_WithStrictOrdering(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+
+		subscribe := func(t *testing.T, opts ...SubscribeOption) (*fakeServer, *Subscription, func() []string,
+			*statusRecorder) {
+
+			server := newFakeServer(t)
+			var mu sync.Mutex
+			var received []string
+			errs := &statusRecorder{}
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(tx *models.TransactionResponse) {
+					mu.Lock()
+					defer mu.Unlock()
+					received = append(received, tx.Id)
+				},
+				OnError: errs.onError,
+			}, append(opts, WithStrictOrdering())...)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = subscription.Unsubscribe()
+			})
+			server.waitSubscribed(mainChannel)
+			server.waitSubscribed(controlChannel)
+			return server, subscription, func() []string {
+				mu.Lock()
+				defer mu.Unlock()
+				return append([]string(nil), received...)
+			}, errs
+		}
+		publish := func(t *testing.T, server *fakeServer, subscription *Subscription, block uint32, indexes ...uint64) {
+			received := subscription.Stats().TransactionsReceived
+			for _, index := range indexes {
+				require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+					Id:          strconv.Itoa(int(block)) + "/" + strconv.Itoa(int(index)),
+					BlockHeight: block,
+					BlockIndex:  index,
+				}))
+			}
+			require.Eventually(t, func() bool {
+				return subscription.Stats().TransactionsReceived == received+uint64(len(indexes))
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+		blockDone := func(server *fakeServer, block uint32) {
+			server.publishMessage(controlChannel, &models.ControlResponse{
+				StatusCode: uint32(SubscriptionBlockDone), Block: block,
+			})
+		}
+
+		t.Run("ordered at block done", func(t *testing.T) {
+			server, subscription, received, _ := subscribe(t)
+			publish(t, server, subscription, 100, 2, 3, 0, 1)
+			assert.Empty(t, received(), "transactions are held back until the block is done")
+
+			blockDone(server, 100)
+			require.Eventually(t, func() bool {
+				return len(received()) == 4
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"100/0", "100/1", "100/2", "100/3"}, received())
+
+			// the page sent again is dropped
+			publish(t, server, subscription, 100, 1)
+			publish(t, server, subscription, 101, 0)
+			blockDone(server, 101)
+			require.Eventually(t, func() bool {
+				return len(received()) == 5
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, "101/0", received()[4])
+
+			stats := subscription.Stats()
+			assert.Equal(t, uint64(2), stats.Reordered)
+			assert.Equal(t, uint64(1), stats.ReorderDropped)
+		})
+
+		t.Run("buffer full", func(t *testing.T) {
+			server, subscription, received, errs := subscribe(t, WithOrderingBufferSize(2))
+			publish(t, server, subscription, 100, 1, 0)
+			require.Eventually(t, func() bool {
+				return len(received()) == 2
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"100/0", "100/1"}, received())
+
+			errs.mu.Lock()
+			defer errs.mu.Unlock()
+			require.Len(t, errs.errors, 1)
+			assert.ErrorIs(t, errs.errors[0], ErrOrderingBufferFull)
+		})
+
+		t.Run("with OnTransactionCtx", func(t *testing.T) {
+			server := newFakeServer(t)
+			type message struct {
+				ctx MessageContext
+				tx  *models.TransactionResponse
+			}
+			received := make(chan message, 4)
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransactionCtx: func(ctx MessageContext, tx *models.TransactionResponse) {
+					received <- message{ctx, tx}
+				},
+			}, WithStrictOrdering())
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed(mainChannel)
+			server.waitSubscribed(controlChannel)
+
+			publish(t, server, subscription, 100, 2, 3, 0, 1)
+			select {
+			case m := <-received:
+				t.Fatalf("%s passed on before the block was done", m.tx.Id)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			blockDone(server, 100)
+			var ids []string
+			var offsets []uint64
+			for i := 0; i < 4; i++ {
+				select {
+				case m := <-received:
+					assert.Equal(t, mainChannel, m.ctx.Channel)
+					assert.Equal(t, uint32(100), m.ctx.Block)
+					ids = append(ids, m.tx.Id)
+					offsets = append(offsets, m.ctx.Offset)
+				case <-time.After(5 * time.Second):
+					t.Fatal("transaction not received")
+				}
+			}
+			assert.Equal(t, []string{"100/0", "100/1", "100/2", "100/3"}, ids)
+			// the context stays with its transaction, they were published as indexes 2, 3, 0 and 1
+			assert.Equal(t, []uint64{3, 4, 1, 2}, offsets)
+		})
+
+		t.Run("with handler concurrency", func(t *testing.T) {
+			server := newFakeServer(t)
+			_, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+			}, WithStrictOrdering(), WithHandlerConcurrency(4))
+			assert.ErrorIs(t, err, ErrOrderingConcurrency)
+		})
+	})
+}
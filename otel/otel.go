@@ -0,0 +1,105 @@
+// Package otel traces junglebus clients with OpenTelemetry, keeping the dependency on OpenTelemetry out of the
+// junglebus package
+//
+// NewTracer adapts a trace.TracerProvider to the Tracer of junglebus.WithTracer, WithTracerProvider is the shortcut:
+//
+//	client, err := junglebus.New(junglebus.WithHTTP(url), otel.WithTracerProvider(provider))
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer the spans are started with
+const InstrumentationName = "github.com/GorillaPool/go-junglebus"
+
+// Tracer is a junglebus tracer starting OpenTelemetry spans
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns the tracer starting the spans with the tracer of provider, they are children of the span in the
+// context of the request or subscription
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(InstrumentationName)}
+}
+
+// WithTracerProvider will set the tracer of the client to the spans of provider, see junglebus.WithTracer
+func WithTracerProvider(provider trace.TracerProvider) junglebus.ClientOps {
+	return junglebus.WithTracer(NewTracer(provider))
+}
+
+// Start starts a span in ctx, the returned context carries it
+func (t *Tracer) Start(ctx context.Context, name string, attributes ...transports.Attribute) (context.Context,
+	transports.Span) {
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(keyValues(attributes)...))
+	return ctx, &otelSpan{span: span}
+}
+
+// otelSpan is a junglebus span of an OpenTelemetry span
+type otelSpan struct {
+	span trace.Span
+}
+
+// SetAttributes sets the attributes on the span
+func (s *otelSpan) SetAttributes(attributes ...transports.Attribute) {
+	s.span.SetAttributes(keyValues(attributes)...)
+}
+
+// RecordError records err as an event of the span and sets its status to error
+func (s *otelSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends the span
+func (s *otelSpan) End() {
+	s.span.End()
+}
+
+// keyValues returns the OpenTelemetry attributes of the junglebus attributes, values of other types than strings,
+// numbers and booleans are formatted as strings
+func keyValues(attributes []transports.Attribute) []attribute.KeyValue {
+	keyValues := make([]attribute.KeyValue, 0, len(attributes))
+	for _, a := range attributes {
+		keyValues = append(keyValues, keyValue(a))
+	}
+	return keyValues
+}
+
+func keyValue(a transports.Attribute) attribute.KeyValue {
+	key := attribute.Key(a.Key)
+	switch v := a.Value.(type) {
+	case string:
+		return key.String(v)
+	case bool:
+		return key.Bool(v)
+	case int:
+		return key.Int(v)
+	case int32:
+		return key.Int64(int64(v))
+	case int64:
+		return key.Int64(v)
+	case uint32:
+		return key.Int64(int64(v))
+	case uint64:
+		return key.Int64(int64(v))
+	case float64:
+		return key.Float64(v)
+	case error:
+		return key.String(v.Error())
+	default:
+		return key.String(fmt.Sprint(v))
+	}
+}
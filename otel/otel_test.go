@@ -0,0 +1,121 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus"
+	"github.com/GorillaPool/go-junglebus/junglebustest"
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// endedSpan returns the first span ended with the name, nil if none is
+func endedSpan(recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+// TestWithTracerProvider will test tracing the handler calls of a subscription as children of the span it was
+// started in, OnTransactionCtx is given the context of the span of the transaction
+func TestWithTracerProvider(t *testing.T) {
+	const subscriptionID = "test-subscription"
+	server := junglebustest.NewServer()
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := junglebus.New(junglebus.WithHTTP(server.URL), WithTracerProvider(provider))
+	require.NoError(t, err)
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "subscribe")
+	defer root.End()
+	contexts := make(chan context.Context, 1)
+	subscription, err := client.Subscribe(ctx, subscriptionID, 100, junglebus.EventHandler{
+		OnTransactionCtx: func(ctx junglebus.MessageContext, _ *models.TransactionResponse) {
+			contexts <- ctx.Context()
+		},
+		OnStatus: func(*models.ControlResponse) {},
+		OnError:  func(error) {},
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = subscription.Unsubscribe()
+	}()
+
+	mainChannel := junglebustest.MainChannel(subscriptionID, 100)
+	require.True(t, server.WaitSubscribed(mainChannel, 5*time.Second))
+	require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: "tx", BlockHeight: 101}))
+	var handlerCtx context.Context
+	select {
+	case handlerCtx = <-contexts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction not received")
+	}
+
+	var span sdktrace.ReadOnlySpan
+	require.Eventually(t, func() bool {
+		span = endedSpan(recorder, "junglebus OnTransaction")
+		return span != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, root.SpanContext(), span.Parent())
+	assert.Equal(t, span.SpanContext(), trace.SpanContextFromContext(handlerCtx),
+		"the handler is given the context of its span")
+	assert.Equal(t, InstrumentationName, span.InstrumentationLibrary().Name)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("junglebus.subscription_id", subscriptionID),
+		attribute.String("junglebus.channel", "main"),
+		attribute.String("junglebus.txid", "tx"),
+		attribute.Int64("junglebus.block_height", 101),
+	}, span.Attributes())
+}
+
+// TestTracer will test the attributes and errors of the spans
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, span := tracer.Start(context.Background(), "junglebus GET",
+		transports.Attribute{Key: "http.url", Value: "http://localhost/v1/transaction/get/tx"},
+		transports.Attribute{Key: "junglebus.transactions", Value: 3},
+	)
+	assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+	span.SetAttributes(
+		transports.Attribute{Key: "http.status_code", Value: 500},
+		transports.Attribute{Key: "error", Value: errors.New("failed")},
+		transports.Attribute{Key: "junglebus.fallback", Value: true},
+		transports.Attribute{Key: "junglebus.status", Value: junglebus.StatusDropped},
+	)
+	span.RecordError(nil)
+	span.RecordError(errors.New("failed"))
+	span.End()
+
+	ended := endedSpan(recorder, "junglebus GET")
+	require.NotNil(t, ended)
+	assert.Equal(t, trace.SpanContextFromContext(ctx), ended.SpanContext())
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.url", "http://localhost/v1/transaction/get/tx"),
+		attribute.Int("junglebus.transactions", 3),
+		attribute.Int("http.status_code", 500),
+		attribute.String("error", "failed"),
+		attribute.Bool("junglebus.fallback", true),
+		attribute.String("junglebus.status", "dropped"),
+	}, ended.Attributes())
+	assert.Equal(t, codes.Error, ended.Status().Code)
+	assert.Equal(t, "failed", ended.Status().Description)
+	require.Len(t, ended.Events(), 1, "a nil error is not recorded")
+	assert.Equal(t, "exception", ended.Events()[0].Name)
+}
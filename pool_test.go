@@ -0,0 +1,102 @@
+package junglebus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestSubscription_pooledMessages will test handling pooled transactions and releasing the dropped ones
+func TestSubscription_pooledMessages(t *testing.T) {
+	client, err := New(WithHTTP("localhost"))
+	require.NoError(t, err)
+	var received []string
+	recorder := &statusRecorder{}
+	handler := EventHandler{
+		OnTransaction: func(tx *models.TransactionResponse) {
+			received = append(received, tx.Id)
+			tx.Release()
+		},
+		OnMempool: func(tx *models.TransactionResponse) {
+			received = append(received, "mempool "+tx.Id)
+			tx.Release()
+		},
+		OnStatus: recorder.onStatus,
+		OnError:  recorder.onError,
+	}
+	s := &Subscription{SubscriptionID: testSubscriptionID, EventHandler: handler, client: client, pooled: true,
+		filter: func(tx *models.TransactionResponse) bool { return tx.Id != "filtered" }}
+
+	for _, id := range []string{"first", "filtered", "second"} {
+		s.onServerPublication(handler, "query:"+testSubscriptionID+":100", 0, []byte(`{"id":"`+id+`"}`))
+	}
+	s.onServerPublication(handler, "query:"+testSubscriptionID+":mempool", 0, []byte(`{"id":"pending"}`))
+	s.onServerPublication(handler, "query:"+testSubscriptionID+":100", 0, []byte(`{"id":`))
+
+	assert.Equal(t, []string{"first", "second", "mempool pending"}, received)
+	require.Len(t, recorder.errors, 1)
+	var decodeErr *DecodeError
+	assert.ErrorAs(t, recorder.errors[0], &decodeErr)
+}
+
+// TestWithPooledMessages will test pooling is not used together with mempool tracking
+func TestWithPooledMessages(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+		recorder := &statusRecorder{}
+		handler := EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		}
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, handler, WithPooledMessages())
+		require.NoError(t, err)
+		assert.True(t, subscription.pooled)
+		require.NoError(t, subscription.Unsubscribe())
+
+		subscription, err = client.Subscribe(context.Background(), testSubscriptionID, 100, handler,
+			WithPooledMessages(), WithMempoolTracking(10, 0))
+		require.NoError(t, err)
+		assert.False(t, subscription.pooled)
+		require.NoError(t, subscription.Unsubscribe())
+	})
+}
+
+// BenchmarkSubscription_pooledMessages handles a transaction publication with and without pooled messages
+func BenchmarkSubscription_pooledMessages(b *testing.B) {
+	client, err := New(WithHTTP("localhost"))
+	require.NoError(b, err)
+	data, err := proto.Marshal(&models.TransactionResponse{
+		Id:          testTxID,
+		BlockHeight: 100,
+		Transaction: newRawTransaction(testOutput{1000, p2pkhScript(b, testAddress)}, testOutput{0, testOpReturn}),
+	})
+	require.NoError(b, err)
+	channel := "query:" + testSubscriptionID + ":100"
+
+	for _, pooled := range []bool{false, true} {
+		name := "default"
+		if pooled {
+			name = "pooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			handler := EventHandler{
+				OnTransaction: func(tx *models.TransactionResponse) { tx.Release() },
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			}
+			s := &Subscription{SubscriptionID: testSubscriptionID, EventHandler: handler, client: client, pooled: pooled}
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.onServerPublication(handler, channel, uint64(i), data)
+			}
+		})
+	}
+}
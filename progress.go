@@ -0,0 +1,138 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultProgressTipInterval is the minimum time between fetching the chain tip for Progress
+const DefaultProgressTipInterval = time.Minute
+
+// progressSamples is the number of recent blocks the rate of Progress is measured over
+const progressSamples = 30
+
+// Progress is how far a subscription is on its way to the chain tip, see Subscription.Progress
+type Progress struct {
+	Block           uint64        // the last block reported on the control channel
+	Tip             uint64        // the height of the chain tip, 0 until it was fetched
+	TipUpdatedAt    time.Time     // when the chain tip was last fetched or passed
+	Remaining       uint64        // blocks between Block and Tip
+	BlocksPerMinute float64       // the rate of the recent blocks done, 0 before two blocks were done
+	ETA             time.Duration // the time to reach the tip at the recent rate, 0 when unknown or caught up
+}
+
+// progressSample is the time a block was done
+type progressSample struct {
+	block uint64
+	at    time.Time
+}
+
+// progressTracker measures the rate of blocks done and keeps the chain tip, fetching it at most every tip interval
+type progressTracker struct {
+	mu       sync.Mutex
+	samples  []progressSample // ring of the recent blocks done
+	next     int
+	tip      uint64
+	tipAt    time.Time
+	fetchAt  time.Time // when the chain tip was last requested
+	fetching bool
+}
+
+// blockDone records a block done at the time, a block beyond the known chain tip moves the tip along
+func (p *progressTracker) blockDone(block uint64, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sample := progressSample{block: block, at: at}
+	if len(p.samples) < progressSamples {
+		p.samples = append(p.samples, sample)
+	} else {
+		p.samples[p.next] = sample
+		p.next = (p.next + 1) % progressSamples
+	}
+	if block > p.tip {
+		p.tip, p.tipAt = block, at
+	}
+}
+
+// rate returns the blocks done per minute over the recent samples, 0 before two blocks were done
+func (p *progressTracker) rate() float64 {
+	if len(p.samples) < 2 {
+		return 0
+	}
+	oldest, newest := p.samples[p.next%len(p.samples)], p.samples[(p.next+len(p.samples)-1)%len(p.samples)]
+	elapsed := newest.at.Sub(oldest.at)
+	if elapsed <= 0 || newest.block <= oldest.block {
+		return 0
+	}
+	return float64(newest.block-oldest.block) / elapsed.Minutes()
+}
+
+// Progress returns how far the subscription is on its way to the chain tip. The chain tip is fetched in the
+// background when the last fetch is older than DefaultProgressTipInterval, a failing fetch keeps the last known tip
+// and does not affect the stream. Remaining is 0 and the ETA is 0 once the subscription reached the tip.
+func (s *Subscription) Progress() Progress {
+	s.refreshTip()
+
+	progress := Progress{Block: s.LastBlock()}
+	s.progress.mu.Lock()
+	progress.Tip, progress.TipUpdatedAt = s.progress.tip, s.progress.tipAt
+	progress.BlocksPerMinute = s.progress.rate()
+	s.progress.mu.Unlock()
+
+	if progress.Tip > progress.Block {
+		progress.Remaining = progress.Tip - progress.Block
+	}
+	if progress.Remaining > 0 && progress.BlocksPerMinute > 0 {
+		progress.ETA = time.Duration(float64(progress.Remaining) / progress.BlocksPerMinute * float64(time.Minute))
+	}
+	return progress
+}
+
+// refreshTip fetches the chain tip in the background, unless it was requested within the tip interval or a request
+// is still running
+func (s *Subscription) refreshTip() {
+	s.progress.mu.Lock()
+	if s.progress.fetching || (!s.progress.fetchAt.IsZero() && time.Since(s.progress.fetchAt) < s.tipInterval) {
+		s.progress.mu.Unlock()
+		return
+	}
+	s.progress.fetching, s.progress.fetchAt = true, time.Now()
+	s.progress.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(s.ctx, s.tipInterval)
+		defer cancel()
+		tip, err := s.client.GetChainTip(ctx)
+
+		s.progress.mu.Lock()
+		defer s.progress.mu.Unlock()
+		s.progress.fetching = false
+		if err != nil {
+			s.log(levelDebug, "fetching chain tip failed", "error", err)
+			return
+		}
+		if height := uint64(tip.Height); height >= s.progress.tip {
+			s.progress.tip, s.progress.tipAt = height, time.Now()
+		}
+	}()
+}
+
+// watchProgress passes the progress of the subscription to OnProgress every progress interval, until the
+// subscription is torn down
+func (s *Subscription) watchProgress() {
+	s.refreshTip()
+	ticker := time.NewTicker(s.progressInterval)
+	defer ticker.Stop()
+
+	onProgress := s.EventHandler.OnProgress
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		progress := s.Progress()
+		s.dispatch(func() { onProgress(progress) })
+	}
+}
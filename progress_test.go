@@ -0,0 +1,120 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProgressTracker will test measuring the rate of the recent blocks done
+func TestProgressTracker(t *testing.T) {
+	tracker := &progressTracker{}
+	assert.Zero(t, tracker.rate())
+
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < progressSamples+10; i++ {
+		// the first blocks are slower and no longer part of the recent samples
+		delay := 30 * time.Second
+		if i < 10 {
+			delay = time.Hour
+		}
+		start = start.Add(delay)
+		tracker.blockDone(uint64(700000+i), start)
+	}
+	assert.InDelta(t, 2, tracker.rate(), 0.001)
+	assert.Equal(t, uint64(700000+progressSamples+9), tracker.tip)
+}
+
+// TestSubscription_Progress will test estimating how far a subscription is from the chain tip
+func TestSubscription_Progress(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		controlChannel := "query:" + testSubscriptionID + ":control"
+
+		t.Run("on progress", func(t *testing.T) {
+			var tipRequests int32
+			server := newFakeServer(t)
+			server.HandleFunc("/v1/block_header/tip", func(w http.ResponseWriter, _ *http.Request) {
+				atomic.AddInt32(&tipRequests, 1)
+				w.Header().Set("Content-Type", "application/json")
+				mustWrite(w, `{"hash":"tip","height":110}`)
+			})
+
+			progresses := make(chan Progress, 100)
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnProgress: func(progress Progress) {
+					select {
+					case progresses <- progress:
+					default:
+					}
+				},
+			}, WithProgressInterval(10*time.Millisecond))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+
+			server.waitSubscribed(controlChannel)
+			for block := uint32(100); block <= 102; block++ {
+				time.Sleep(5 * time.Millisecond)
+				server.publishMessage(controlChannel, &models.ControlResponse{
+					StatusCode: uint32(SubscriptionBlockDone), Block: block,
+				})
+			}
+			var progress Progress
+			require.Eventually(t, func() bool {
+				progress = <-progresses
+				return progress.Block == 102 && progress.Tip == 110
+			}, 5*time.Second, time.Millisecond)
+			assert.Equal(t, uint64(8), progress.Remaining)
+			assert.Positive(t, progress.BlocksPerMinute)
+			assert.Positive(t, progress.ETA)
+			assert.False(t, progress.TipUpdatedAt.IsZero())
+
+			// the chain tip is only fetched once per tip interval
+			for i := 0; i < 10; i++ {
+				subscription.Progress()
+			}
+			assert.Equal(t, int32(1), atomic.LoadInt32(&tipRequests))
+		})
+
+		t.Run("chain tip failing", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusUnauthorized, `{}`)
+			statuses := &statusRecorder{}
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnError:       statuses.onError,
+			}, func(s *Subscription) {
+				s.tipInterval = time.Millisecond
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+
+			server.waitSubscribed(controlChannel)
+			subscription.Progress()
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+			require.Eventually(t, func() bool {
+				return subscription.LastBlock() == 100
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// a block beyond the known chain tip moves it along
+			progress := subscription.Progress()
+			assert.Equal(t, uint64(100), progress.Tip)
+			assert.Zero(t, progress.Remaining)
+			assert.Zero(t, progress.ETA)
+			assert.True(t, subscription.IsConnected())
+			statuses.mu.Lock()
+			defer statuses.mu.Unlock()
+			assert.Empty(t, statuses.errors)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+package junglebus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// dialThroughProxy returns a dial function for the websocket connection to endpoint going through the proxy returned
+// by proxy, tunneling the connection with an HTTP CONNECT request. The user info of the proxy url is sent as
+// basic proxy authorization. Only http proxies are supported.
+//
+// The websocket dialer always connects through the http proxy returned by environment first, which is
+// http.ProxyFromEnvironment: the connection it dials to that proxy is made to the websocket server through proxy
+// instead, and the CONNECT request it then sends is answered here, so proxy replaces the proxy of the environment.
+func dialThroughProxy(proxy, environment func(*http.Request) (*url.URL, error),
+	endpoint *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+
+	scheme, port := "https", "443"
+	if endpoint.Scheme == "ws" || endpoint.Scheme == "http" {
+		scheme, port = "http", "80"
+	}
+	server := endpoint.Host
+	if endpoint.Port() == "" {
+		server = net.JoinHostPort(endpoint.Hostname(), port)
+	}
+	dial := dialProxy(proxy, scheme)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == server {
+			return dial(ctx, network, addr)
+		}
+		environmentURL, err := environment(&http.Request{
+			Method: http.MethodGet,
+			URL:    &url.URL{Scheme: scheme, Host: endpoint.Host, Path: endpoint.Path},
+			Header: http.Header{},
+		})
+		if err != nil || environmentURL == nil || environmentURL.Scheme != "http" || proxyAddress(environmentURL) != addr {
+			return dial(ctx, network, addr)
+		}
+		conn, err := dial(ctx, network, server)
+		if err != nil {
+			return nil, err
+		}
+		return &answeredTunnel{Conn: conn, server: server}, nil
+	}
+}
+
+// dialProxy returns a dial function connecting through the proxy returned by proxy for requests of the scheme
+func dialProxy(proxy func(*http.Request) (*url.URL, error), scheme string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		proxyURL, err := proxy(&http.Request{
+			Method: http.MethodGet,
+			URL:    &url.URL{Scheme: scheme, Host: addr},
+			Header: http.Header{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		if proxyURL.Scheme != "http" {
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+
+		conn, err := dialer.DialContext(ctx, network, proxyAddress(proxyURL))
+		if err != nil {
+			return nil, err
+		}
+		if err = connectTunnel(ctx, conn, proxyURL, addr); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// proxyAddress returns the host and port of the http proxy
+func proxyAddress(proxyURL *url.URL) string {
+	if proxyURL.Port() == "" {
+		return net.JoinHostPort(proxyURL.Hostname(), "80")
+	}
+	return proxyURL.Host
+}
+
+// answeredTunnel is a connection to the websocket server that answers the CONNECT request the websocket dialer sends
+// to the proxy of the environment, as if that proxy opened the tunnel
+type answeredTunnel struct {
+	net.Conn
+	server   string
+	request  []byte // the CONNECT request written so far
+	response []byte // the answer to the CONNECT request not read yet
+	answered bool
+}
+
+// Write reads the CONNECT request until it is complete, anything written after it is sent to the server
+func (t *answeredTunnel) Write(p []byte) (int, error) {
+	if t.answered {
+		return t.Conn.Write(p)
+	}
+	t.request = append(t.request, p...)
+	end := bytes.Index(t.request, []byte("\r\n\r\n"))
+	if end < 0 {
+		return len(p), nil
+	}
+	connect, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(t.request[:end+4])))
+	if err != nil {
+		return 0, err
+	}
+	if connect.Method != http.MethodConnect || connect.Host != t.server {
+		return 0, fmt.Errorf("unexpected %s %s request to the proxy of the environment", connect.Method, connect.Host)
+	}
+	rest := t.request[end+4:]
+	t.request = nil
+	t.response = []byte("HTTP/1.1 200 Connection established\r\n\r\n")
+	t.answered = true
+	if len(rest) > 0 {
+		if _, err = t.Conn.Write(rest); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Read returns the answer to the CONNECT request before reading from the server
+func (t *answeredTunnel) Read(p []byte) (int, error) {
+	if len(t.response) > 0 {
+		n := copy(p, t.response)
+		t.response = t.response[n:]
+		return n, nil
+	}
+	return t.Conn.Read(p)
+}
+
+// connectTunnel asks the proxy on conn to open a tunnel to addr
+func connectTunnel(ctx context.Context, conn net.Conn, proxyURL *url.URL, addr string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() {
+			_ = conn.SetDeadline(time.Time{})
+		}()
+	}
+
+	connect := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		connect.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := connect.Write(conn); err != nil {
+		return err
+	}
+
+	// the proxy does not send anything after its response before the tunnel is used, the reader can be dropped
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connect)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy refused the connection to %s: %s", addr, resp.Status)
+	}
+	return nil
+}
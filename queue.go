@@ -0,0 +1,301 @@
+package junglebus
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// OverflowPolicy defines what happens when a message arrives while the queue of a subscription is full. Only
+// transactions are dropped, control messages and statuses are always handled.
+type OverflowPolicy uint8
+
+const (
+	// OverflowPolicyBlock waits until the handler made room in the queue, this applies backpressure on the connection
+	OverflowPolicyBlock OverflowPolicy = iota
+	// OverflowPolicyDropOldest drops the oldest queued transaction to make room for the new message
+	OverflowPolicyDropOldest
+	// OverflowPolicyDropNewest drops the new transaction, other messages take the place of the oldest queued
+	// transaction
+	OverflowPolicyDropNewest
+)
+
+// messageQueue is the queue of messages between the connection and the event handler, see WithQueueSize. The
+// overflow policy only drops transactions: control messages, like a block being done, are needed to move the
+// checkpoint on and to roll back reorgs. While the queue is full of transactions they make room for the other
+// messages, the queue only grows past its size when it holds no transaction.
+type messageQueue struct {
+	mu       sync.Mutex
+	size     int
+	policy   OverflowPolicy
+	drop     func() // called for every message dropped
+	messages []queuedMessage
+	ready    chan struct{} // signalled when a message was queued
+	space    chan struct{} // signalled when a message was taken from the queue
+}
+
+type queuedMessage struct {
+	fn          func()
+	transaction bool // whether the overflow policy may drop it
+}
+
+func newMessageQueue(size int, policy OverflowPolicy, drop func()) *messageQueue {
+	return &messageQueue{
+		size:   size,
+		policy: policy,
+		drop:   drop,
+		ready:  make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+	}
+}
+
+// len returns the number of messages waiting in the queue, 0 without a queue
+func (q *messageQueue) len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// push queues fn following the overflow policy, transaction is whether it may be dropped. Blocking stops once done
+// or cancel is closed, cancel may be nil. It returns whether fn was queued.
+func (q *messageQueue) push(fn func(), transaction bool, done, cancel <-chan struct{}) bool {
+	for {
+		q.mu.Lock()
+		queued, dropped := q.pushLocked(queuedMessage{fn: fn, transaction: transaction})
+		q.mu.Unlock()
+		if dropped {
+			q.drop()
+		}
+		if queued {
+			signal(q.ready)
+			return true
+		}
+		if dropped {
+			return false
+		}
+		select {
+		case <-q.space:
+		case <-done:
+			return false
+		case <-cancel:
+			return false
+		}
+	}
+}
+
+// pushLocked queues the message when there is room or the policy makes room, it returns whether the message was
+// queued and whether a message was dropped
+func (q *messageQueue) pushLocked(message queuedMessage) (bool, bool) {
+	if len(q.messages) < q.size {
+		q.messages = append(q.messages, message)
+		return true, false
+	}
+	if q.policy == OverflowPolicyBlock {
+		return false, false
+	}
+	if message.transaction && q.policy == OverflowPolicyDropNewest {
+		return false, true
+	}
+	for i, queued := range q.messages {
+		if queued.transaction {
+			copy(q.messages[i:], q.messages[i+1:])
+			q.messages[len(q.messages)-1] = message
+			return true, true
+		}
+	}
+	if message.transaction {
+		return false, true
+	}
+	q.messages = append(q.messages, message)
+	return true, false
+}
+
+// tryPush queues fn when there is room, like a control message
+func (q *messageQueue) tryPush(fn func()) bool {
+	q.mu.Lock()
+	queued := len(q.messages) < q.size
+	if queued {
+		q.messages = append(q.messages, queuedMessage{fn: fn})
+	}
+	q.mu.Unlock()
+	if queued {
+		signal(q.ready)
+	}
+	return queued
+}
+
+// pop takes the oldest message from the queue, it returns false when the queue is empty
+func (q *messageQueue) pop() (func(), bool) {
+	q.mu.Lock()
+	if len(q.messages) == 0 {
+		q.mu.Unlock()
+		return nil, false
+	}
+	fn := q.messages[0].fn
+	q.messages[0] = queuedMessage{}
+	q.messages = q.messages[1:]
+	q.mu.Unlock()
+	signal(q.space)
+	return fn, true
+}
+
+// signal wakes up the goroutine waiting on the channel, without blocking when it is already signalled
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch runs fn on the queue of the subscription, or right away when the subscription has no queue
+// Only the queue worker calls the event handler, messages are handled in the order they were dispatched.
+// The overflow policy does not drop fn, see dispatchTransaction.
+func (s *Subscription) dispatch(fn func()) {
+	if s.queue == nil {
+		fn()
+		return
+	}
+	s.queue.push(fn, false, s.done, nil)
+}
+
+// dispatchTransaction runs the handling of a transaction like dispatch, the overflow policy may drop it
+func (s *Subscription) dispatchTransaction(fn func()) {
+	if s.queue == nil {
+		fn()
+		return
+	}
+	s.queue.push(fn, true, s.done, nil)
+}
+
+// flushQueue waits until the messages queued so far are handled, it returns false when the subscription was torn
+// down first
+func (s *Subscription) flushQueue() bool {
+	if s.queue == nil {
+		return !s.isStopped()
+	}
+	flushed := make(chan struct{})
+	if !s.queue.push(func() { close(flushed) }, false, s.done, nil) {
+		return false
+	}
+	select {
+	case <-flushed:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// drop counts a message dropped by the overflow policy, it is reported by the queue worker
+func (s *Subscription) drop() {
+	atomic.AddUint64(&s.counters.dropped, 1)
+	atomic.AddUint64(&s.counters.unreportedDrops, 1)
+}
+
+// dispatched returns the event handler with the callbacks used for connection events going through the queue
+func (s *Subscription) dispatched() EventHandler {
+	if s.queue == nil {
+		return s.EventHandler
+	}
+
+	eventHandler := s.EventHandler
+	dispatched := eventHandler
+	dispatched.OnTransaction = func(tx *models.TransactionResponse) {
+		s.dispatchTransaction(func() { eventHandler.OnTransaction(tx) })
+	}
+	dispatched.OnMempool = func(tx *models.TransactionResponse) {
+		s.dispatchTransaction(func() { eventHandler.OnMempool(tx) })
+	}
+	dispatched.OnStatus = func(response *models.ControlResponse) {
+		s.dispatch(func() { eventHandler.OnStatus(response) })
+	}
+	dispatched.OnError = func(err error) {
+		s.dispatch(func() { eventHandler.OnError(err) })
+	}
+	if eventHandler.OnRawPublication != nil {
+		dispatched.OnRawPublication = func(channel string, offset uint64, data []byte) {
+			s.dispatch(func() { eventHandler.OnRawPublication(channel, offset, data) })
+		}
+	}
+	if eventHandler.OnConfirmed != nil {
+		dispatched.OnConfirmed = func(tx *models.TransactionResponse, firstSeen time.Time) {
+			s.dispatch(func() { eventHandler.OnConfirmed(tx, firstSeen) })
+		}
+	}
+	if eventHandler.OnEvicted != nil {
+		dispatched.OnEvicted = func(txID string, firstSeen time.Time) {
+			s.dispatch(func() { eventHandler.OnEvicted(txID, firstSeen) })
+		}
+	}
+	if eventHandler.OnUnknownChannel != nil {
+		dispatched.OnUnknownChannel = func(channel string, data []byte) {
+			s.dispatch(func() { eventHandler.OnUnknownChannel(channel, data) })
+		}
+	}
+	return dispatched
+}
+
+// handleQueue calls the event handler for the queued messages until the subscription is torn down,
+// messages still in the queue at that point are discarded. With WithDiskSpool the spooled messages are handled
+// once the queue is empty, they were spooled after everything in the queue.
+func (s *Subscription) handleQueue() {
+	defer close(s.queueDone)
+	if s.spool != nil {
+		defer s.closeSpool()
+	}
+	var spoolReady chan struct{}
+	if s.spool != nil {
+		spoolReady = s.spool.ready
+	}
+	for {
+		if fn, ok := s.queue.pop(); ok {
+			if !s.handleQueued(fn) {
+				return
+			}
+			continue
+		}
+		if s.isStopped() {
+			return
+		}
+		if s.spool != nil && s.handleSpooled() {
+			continue
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-s.queue.ready:
+		case <-spoolReady:
+		}
+	}
+}
+
+// handleQueued calls fn for the queue worker, it returns false when the subscription has been torn down meanwhile
+func (s *Subscription) handleQueued(fn func()) bool {
+	if s.isStopped() {
+		return false
+	}
+	if dropped := atomic.SwapUint64(&s.counters.unreportedDrops, 0); dropped > 0 {
+		s.log(levelWarn, "dropped messages", "dropped", dropped, "block", s.LastBlock())
+		s.sendStatus(s.EventHandler.OnStatus, StatusDropped, "dropped", func() string {
+			return fmt.Sprintf("Dropped %d messages, the queue was full", dropped)
+		})
+	}
+	fn()
+	if atomic.LoadInt32(&s.draining) == 1 {
+		atomic.AddUint64(&s.counters.drained, 1)
+	}
+	return true
+}
+
+// waitQueue waits until the queue worker stopped calling the event handler, after the subscription was torn down
+func (s *Subscription) waitQueue() {
+	if s.queue != nil {
+		<-s.queueDone
+	}
+}
@@ -0,0 +1,16 @@
+package junglebus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// rawTxID returns the txid of the raw transaction, its double SHA-256 in reverse byte order
+func rawTxID(raw []byte) string {
+	first := sha256.Sum256(raw)
+	hash := sha256.Sum256(first[:])
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
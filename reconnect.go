@@ -0,0 +1,209 @@
+package junglebus
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/centrifugal/centrifuge-go"
+	"github.com/jpillora/backoff"
+)
+
+// Defaults of the reconnect policy
+const (
+	DefaultReconnectMinDelay = 200 * time.Millisecond
+	DefaultReconnectMaxDelay = 20 * time.Second
+	DefaultReconnectFactor   = 2
+)
+
+// reconnectPolicy defines how often and how fast a subscription tries to get its connection back
+type reconnectPolicy struct {
+	minDelay    time.Duration
+	maxDelay    time.Duration
+	factor      float64
+	maxAttempts int // 0 is unlimited
+}
+
+// delay returns how long to wait before the given attempt, counting from 0
+func (p reconnectPolicy) delay(attempt int) time.Duration {
+	b := &backoff.Backoff{
+		Min:    p.minDelay,
+		Max:    p.maxDelay,
+		Factor: p.factor,
+	}
+	return b.ForAttempt(float64(attempt))
+}
+
+// reconnect replaces the lost connection of the subscription with a new one, following the reconnect policy
+// The centrifuge client is closed to stop its own reconnect loop, only the current connection is replaced
+func (s *Subscription) reconnect(centrifugeClient *centrifuge.Client) {
+	s.mu.Lock()
+	if s.centrifugeClient != centrifugeClient || s.isStopped() {
+		s.mu.Unlock()
+		return
+	}
+	s.centrifugeClient = nil
+	s.mu.Unlock()
+
+	centrifugeClient.Close()
+	go s.resubscribe()
+}
+
+// resubscribe keeps connecting until it succeeds, waiting the backoff delay before every attempt
+// The subscription fails with a SubscribeError of ErrConnect wrapping ErrMaxReconnectAttempts when the maximum number
+// of attempts is reached
+func (s *Subscription) resubscribe() {
+	policy := s.client.reconnectPolicy
+	eventHandler := s.dispatched()
+	for {
+		s.mu.Lock()
+		attempt := s.reconnects
+		s.reconnects++
+		s.mu.Unlock()
+
+		if policy.maxAttempts > 0 && attempt >= policy.maxAttempts {
+			s.stop()
+			s.waitQueue()
+			s.log(levelError, "giving up reconnecting", "attempt", attempt, "block", s.LastBlock())
+			err := &SubscribeError{
+				Kind:           ErrConnect,
+				SubscriptionID: s.SubscriptionID,
+				Endpoint:       s.client.websocketEndpoint(),
+				Err:            ErrMaxReconnectAttempts,
+			}
+			if connectErr := s.connectErr(); connectErr != nil {
+				err.Err = fmt.Errorf("%w, last error: %v", ErrMaxReconnectAttempts, connectErr)
+			}
+			s.EventHandler.OnError(err)
+			s.finish(err)
+			return
+		}
+
+		delay := policy.delay(attempt)
+		atomic.AddUint64(&s.counters.reconnects, 1)
+		s.log(levelInfo, "reconnecting", "attempt", attempt+1, "block", s.LastBlock(), "delay", delay)
+		s.sendStatus(eventHandler.OnStatus, StatusConnecting, "reconnecting", func() string {
+			if !s.streamsBlocks() {
+				return fmt.Sprintf("Reconnecting to server in %s", delay)
+			}
+			return fmt.Sprintf("Reconnecting to server at block %d in %s", s.LastBlock(), delay)
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := s.connect()
+		if err == nil {
+			return
+		}
+		if isGapUnrepaired(err) {
+			s.stop()
+			s.log(levelError, "giving up reconnecting", "attempt", attempt+1, "error", err)
+			s.waitQueue()
+			s.EventHandler.OnError(err)
+			s.finish(err)
+			return
+		}
+		s.setConnectErr(err)
+		s.log(levelError, "reconnect failed", "attempt", attempt+1, "error", err)
+		eventHandler.OnError(err)
+	}
+}
+
+// setConnectErr records the error of a failed connection attempt
+func (s *Subscription) setConnectErr(err error) {
+	s.mu.Lock()
+	s.lastConnectErr = err
+	s.mu.Unlock()
+}
+
+// connectErr returns the error of the last failed connection attempt
+func (s *Subscription) connectErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastConnectErr
+}
+
+// IsConnected returns whether the subscription has an established connection to the server
+func (s *Subscription) IsConnected() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	centrifugeClient := s.centrifugeClient
+	s.mu.Unlock()
+	return centrifugeClient != nil && centrifugeClient.State() == centrifuge.StateConnected
+}
+
+// Reconnect replaces the connection of the subscription with a new one and waits until it is connected, or ctx is
+// done. The subscription and its stats are kept, the new connection resumes from the checkpoint: the mined
+// transactions and control messages of the old connection that were not handled yet are dropped, and the page of the
+// current block is sent again, without the publications up to the stream position of the checkpoint. Queued mempool
+// transactions are still handled, and so are the messages spooled to disk with WithDiskSpool.
+// ErrNotSubscribed is returned when the subscription was torn down. When ctx is done first, its error is returned
+// and the subscription keeps connecting following the reconnect policy of the client.
+func (s *Subscription) Reconnect(ctx context.Context) error {
+	if s == nil || s.isStopped() {
+		return ErrNotSubscribed
+	}
+
+	wait := make(chan struct{}, 1)
+	s.mu.Lock()
+	centrifugeClient := s.centrifugeClient
+	s.centrifugeClient = nil
+	if centrifugeClient != nil {
+		atomic.AddUint32(&s.connection, 1)
+	}
+	s.connectWaiters = append(s.connectWaiters, wait)
+	s.mu.Unlock()
+	defer s.removeConnectWaiter(wait)
+
+	// without a connection the subscription is already reconnecting, it is only waited for
+	if centrifugeClient != nil {
+		centrifugeClient.Close()
+		s.log(levelInfo, "reconnecting on request", "block", s.LastBlock())
+		if err := s.connect(); err != nil {
+			s.dispatched().OnError(err)
+			go s.resubscribe()
+		}
+	}
+
+	for !s.IsConnected() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return ErrNotSubscribed
+		case <-wait:
+		}
+	}
+	return nil
+}
+
+// removeConnectWaiter stops signalling the channel of Reconnect
+func (s *Subscription) removeConnectWaiter(wait chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, waiter := range s.connectWaiters {
+		if waiter == wait {
+			s.connectWaiters = append(s.connectWaiters[:i], s.connectWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// currentConnection returns the generation of the connection, see replacedConnection
+func (s *Subscription) currentConnection() uint32 {
+	return atomic.LoadUint32(&s.connection)
+}
+
+// replacedConnection returns whether the connection of the generation was replaced by Reconnect since
+func (s *Subscription) replacedConnection(connection uint32) bool {
+	return atomic.LoadUint32(&s.connection) != connection
+}
@@ -0,0 +1,111 @@
+package junglebus
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// withRecovery returns the event handler with every callback recovering from panics, a panic is
+// sent to OnError as a PanicError and the subscription keeps running. Panics of OnError itself are only logged.
+func (s *Subscription) withRecovery(eventHandler EventHandler) EventHandler {
+	recovered := eventHandler
+	onError := eventHandler.OnError
+
+	if eventHandler.OnTransaction != nil {
+		recovered.OnTransaction = func(tx *models.TransactionResponse) {
+			defer s.recoverPanic("OnTransaction", onError)
+			eventHandler.OnTransaction(tx)
+		}
+	}
+	if eventHandler.OnMempool != nil {
+		recovered.OnMempool = func(tx *models.TransactionResponse) {
+			defer s.recoverPanic("OnMempool", onError)
+			eventHandler.OnMempool(tx)
+		}
+	}
+	if eventHandler.OnStatus != nil {
+		recovered.OnStatus = func(response *models.ControlResponse) {
+			defer s.recoverPanic("OnStatus", onError)
+			eventHandler.OnStatus(response)
+		}
+	}
+	if eventHandler.OnBlockDone != nil {
+		recovered.OnBlockDone = func(height uint32, transactions uint64) {
+			defer s.recoverPanic("OnBlockDone", onError)
+			eventHandler.OnBlockDone(height, transactions)
+		}
+	}
+	if eventHandler.OnReorg != nil {
+		recovered.OnReorg = func(height uint32) {
+			defer s.recoverPanic("OnReorg", onError)
+			eventHandler.OnReorg(height)
+		}
+	}
+	if eventHandler.OnBlock != nil {
+		recovered.OnBlock = func(height uint32, transactions []*models.TransactionResponse) {
+			defer s.recoverPanic("OnBlock", onError)
+			eventHandler.OnBlock(height, transactions)
+		}
+	}
+	if eventHandler.OnConfirmed != nil {
+		recovered.OnConfirmed = func(tx *models.TransactionResponse, firstSeen time.Time) {
+			defer s.recoverPanic("OnConfirmed", onError)
+			eventHandler.OnConfirmed(tx, firstSeen)
+		}
+	}
+	if eventHandler.OnEvicted != nil {
+		recovered.OnEvicted = func(txID string, firstSeen time.Time) {
+			defer s.recoverPanic("OnEvicted", onError)
+			eventHandler.OnEvicted(txID, firstSeen)
+		}
+	}
+	if eventHandler.OnCaughtUp != nil {
+		recovered.OnCaughtUp = func(height uint32) {
+			defer s.recoverPanic("OnCaughtUp", onError)
+			eventHandler.OnCaughtUp(height)
+		}
+	}
+	if eventHandler.OnProgress != nil {
+		recovered.OnProgress = func(progress Progress) {
+			defer s.recoverPanic("OnProgress", onError)
+			eventHandler.OnProgress(progress)
+		}
+	}
+	if eventHandler.OnUnknownChannel != nil {
+		recovered.OnUnknownChannel = func(channel string, data []byte) {
+			defer s.recoverPanic("OnUnknownChannel", onError)
+			eventHandler.OnUnknownChannel(channel, data)
+		}
+	}
+	if eventHandler.OnRawPublication != nil {
+		recovered.OnRawPublication = func(channel string, offset uint64, data []byte) {
+			defer s.recoverPanic("OnRawPublication", onError)
+			eventHandler.OnRawPublication(channel, offset, data)
+		}
+	}
+	if eventHandler.OnError != nil {
+		recovered.OnError = func(err error) {
+			defer s.recoverPanic("OnError", onError)
+			eventHandler.OnError(err)
+		}
+	}
+
+	return recovered
+}
+
+// recoverPanic recovers from a panic of the handler and sends it to onError as a PanicError, it must be deferred
+func (s *Subscription) recoverPanic(handler string, onError func(err error)) {
+	if value := recover(); value != nil {
+		err := &PanicError{Handler: handler, Value: value, Stack: debug.Stack()}
+		s.log(levelWarn, "handler panicked", "handler", handler, "panic", value, "stack", string(err.Stack))
+		if handler == "OnError" || onError == nil {
+			return
+		}
+		defer func() {
+			_ = recover()
+		}()
+		onError(err)
+	}
+}
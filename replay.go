@@ -0,0 +1,216 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/sinks"
+)
+
+// ReplayOption configures a Replay
+type ReplayOption func(c *replayConfig)
+
+// replayConfig is the configuration of a Replay
+type replayConfig struct {
+	subscriptionID string
+	speed          float64
+	fromBlock      uint64
+	toBlock        uint64
+	blockRange     bool
+	subscribeOpts  []SubscribeOption
+}
+
+// WithReplaySpeed replays the records with the time between them as they were recorded, divided by speed: 1 is the
+// original timing, 2 twice as fast. Records are replayed as fast as possible when the speed is 0 (default).
+func WithReplaySpeed(speed float64) ReplayOption {
+	return func(c *replayConfig) {
+		c.speed = speed
+	}
+}
+
+// WithReplayBlocks only replays the transactions and control messages of the blocks from fromBlock up to and
+// including toBlock, toBlock 0 replays up to the end of the recording. Mempool transactions are skipped.
+func WithReplayBlocks(fromBlock, toBlock uint64) ReplayOption {
+	return func(c *replayConfig) {
+		c.fromBlock = fromBlock
+		c.toBlock = toBlock
+		c.blockRange = true
+	}
+}
+
+// WithReplaySubscriptionID sets the subscription ID used for the checkpoints of WithCheckpointStore and the channel
+// names passed to the middlewares of WithTxMiddleware
+func WithReplaySubscriptionID(subscriptionID string) ReplayOption {
+	return func(c *replayConfig) {
+		c.subscriptionID = subscriptionID
+	}
+}
+
+// WithReplaySubscribeOptions applies the subscribe options to the replay, like WithCheckpointStore, WithFilter or
+// WithTxMiddleware. Options of the connection and the queue have no effect, the records are handled in order as
+// they are read.
+func WithReplaySubscribeOptions(opts ...SubscribeOption) ReplayOption {
+	return func(c *replayConfig) {
+		c.subscribeOpts = append(c.subscribeOpts, opts...)
+	}
+}
+
+// Replay reads a stream recorded by a sink, see sinks.NDJSONFile, and drives the event handler with it as if the
+// stream was received from the server. Control messages are handled like live ones, a block done saves the
+// checkpoint and flushes OnBlock.
+//
+// Replay returns once all records have been handled, cancelling ctx stops it and returns the context error.
+func Replay(ctx context.Context, r io.Reader, eventHandler EventHandler, opts ...ReplayOption) error {
+	client, err := New()
+	if err != nil {
+		return err
+	}
+	return client.Replay(ctx, r, eventHandler, opts...)
+}
+
+// Replay reads a stream recorded by a sink and drives the event handler with it, see the package level Replay
+func (jb *Client) Replay(ctx context.Context, r io.Reader, eventHandler EventHandler, opts ...ReplayOption) error {
+	config := &replayConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	reader, err := sinks.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	subscribeOpts := append([]SubscribeOption{WithFromBlock(config.fromBlock)}, config.subscribeOpts...)
+	subscribeOpts = append(subscribeOpts, func(s *Subscription) {
+		// records are handled as they are read, the transactions are not owned by the library
+		s.queueSize = 0
+		s.spoolDir = ""
+		s.pooled = false
+	})
+	s, _, err := jb.newSubscription(ctx, config.subscriptionID, eventHandler, subscribeOpts)
+	if err != nil {
+		return err
+	}
+	s.checkpoint.Store(Checkpoint{Block: s.FromBlock})
+
+	err = s.replay(ctx, reader, config)
+	if _, drainErr := s.drain(ctx); err == nil {
+		err = drainErr
+	}
+	s.stop()
+	s.finish(err)
+	return err
+}
+
+// replay passes the records of the reader on to the event handler until all have been read or the subscription
+// stopped
+func (s *Subscription) replay(ctx context.Context, reader *sinks.Reader, config *replayConfig) error {
+	var last time.Time
+	for !s.isStopped() {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !config.includes(record) {
+			continue
+		}
+
+		if config.speed > 0 && !last.IsZero() && record.Time.After(last) {
+			timer := time.NewTimer(time.Duration(float64(record.Time.Sub(last)) / config.speed))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+		last = record.Time
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		s.replayRecord(record)
+	}
+	return nil
+}
+
+// replayRecord handles a record like the publication it was recorded from
+func (s *Subscription) replayRecord(record *sinks.Record) {
+	if record.Kind == sinks.KindStatus {
+		code := StatusCode(record.Status.StatusCode)
+		if !code.IsServer() {
+			if s.sink != nil {
+				s.sinkStatus(record.Status, s.EventHandler.OnError)
+			}
+			if s.reportStatus {
+				s.EventHandler.OnStatus(record.Status)
+			}
+			return
+		}
+		atomic.AddUint64(&s.counters.control, 1)
+		atomic.StoreInt64(&s.counters.lastControl, record.Time.UnixNano())
+		s.markControl(record.Status)
+		s.dispatchControl(`query:`+s.SubscriptionID+`:`+channelControl, 0, record.Time, record.Status)
+		return
+	}
+
+	mempool := record.Kind == sinks.KindMempool
+	channel := `query:` + s.SubscriptionID + `:` + channelMempool
+	name := channelMempool
+	if mempool {
+		atomic.AddUint64(&s.counters.mempool, 1)
+	} else {
+		atomic.AddUint64(&s.counters.transactions, 1)
+		channel = `query:` + s.SubscriptionID + `:` + strconv.FormatUint(uint64(record.Transaction.BlockHeight), 10)
+		name = channelMain
+	}
+	transaction := record.Transaction
+	s.client.observeTransaction(transaction, mempool)
+	if s.filteredOut(transaction) || s.duplicate(name, transaction.Id) {
+		return
+	}
+	if !mempool && s.untilBlock > 0 && uint64(transaction.BlockHeight) > s.untilBlock {
+		return
+	}
+	s.handleTransaction(s.EventHandler, TxContext{
+		Channel:      channel,
+		Mempool:      mempool,
+		Block:        transaction.BlockHeight,
+		Page:         s.pageOf(transaction.BlockHeight),
+		ReceivedAt:   record.Time,
+		IsHistorical: s.isHistorical(mempool),
+	}, transaction)
+	s.trackMempool(s.EventHandler, transaction, mempool)
+}
+
+// includes returns whether the record is replayed, it is skipped when it is incomplete or outside the block range
+func (c *replayConfig) includes(record *sinks.Record) bool {
+	var block uint64
+	switch record.Kind {
+	case sinks.KindTransaction:
+		if record.Transaction == nil {
+			return false
+		}
+		block = uint64(record.Transaction.BlockHeight)
+	case sinks.KindMempool:
+		return record.Transaction != nil && !c.blockRange
+	case sinks.KindStatus:
+		if record.Status == nil {
+			return false
+		}
+		if !StatusCode(record.Status.StatusCode).IsServer() || record.Status.Block == 0 {
+			return true
+		}
+		block = uint64(record.Status.Block)
+	default:
+		return false
+	}
+	return !c.blockRange || (block >= c.fromBlock && (c.toBlock == 0 || block <= c.toBlock))
+}
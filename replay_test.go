@@ -0,0 +1,144 @@
+package junglebus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recording returns a recorded stream of two blocks with a mempool transaction in between, one second apart
+func recording(t *testing.T) *bytes.Buffer {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	records := []*sinks.Record{
+		{Kind: sinks.KindStatus, Status: &models.ControlResponse{StatusCode: uint32(StatusConnected)}},
+		{Kind: sinks.KindTransaction, Transaction: &models.TransactionResponse{Id: "tx-1", BlockHeight: 100}},
+		{Kind: sinks.KindTransaction, Transaction: &models.TransactionResponse{Id: "tx-2", BlockHeight: 100}},
+		{Kind: sinks.KindStatus, Status: &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100,
+			Transactions: 2}},
+		{Kind: sinks.KindMempool, Transaction: &models.TransactionResponse{Id: "mempool-1"}},
+		{Kind: sinks.KindTransaction, Transaction: &models.TransactionResponse{Id: "tx-3", BlockHeight: 101}},
+		{Kind: sinks.KindStatus, Status: &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 101,
+			Transactions: 1}},
+	}
+	buf := &bytes.Buffer{}
+	for i, record := range records {
+		record.Time = start.Add(time.Duration(i) * time.Second)
+		line, err := json.Marshal(record)
+		require.NoError(t, err)
+		buf.Write(append(line, '\n'))
+	}
+	return buf
+}
+
+// replayEvents returns an event handler recording the callbacks in order
+func replayEvents(events *[]string) EventHandler {
+	return EventHandler{
+		OnTransaction: func(tx *models.TransactionResponse) {
+			*events = append(*events, "transaction "+tx.Id)
+		},
+		OnMempool: func(tx *models.TransactionResponse) {
+			*events = append(*events, "mempool "+tx.Id)
+		},
+		OnBlockDone: func(block uint32, _ uint64) {
+			*events = append(*events, fmt.Sprintf("block done %d", block))
+		},
+		OnStatus: func(status *models.ControlResponse) {
+			*events = append(*events, "status "+StatusCode(status.StatusCode).String())
+		},
+	}
+}
+
+// TestReplay will test driving the event handler with a recorded stream and saving its checkpoints
+func TestReplay(t *testing.T) {
+	var events []string
+	store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+	err := Replay(context.Background(), recording(t), replayEvents(&events),
+		WithReplaySubscriptionID(testSubscriptionID),
+		WithReplaySubscribeOptions(WithCheckpointStore(store)),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"status " + StatusConnected.String(),
+		"transaction tx-1",
+		"transaction tx-2",
+		"block done 100",
+		"mempool mempool-1",
+		"transaction tx-3",
+		"block done 101",
+	}, events)
+	assert.Equal(t, Checkpoint{Block: 102}, store.get(testSubscriptionID), "block 101 is done")
+}
+
+// TestReplay_WithReplayBlocks will test restricting the replay to a block range
+func TestReplay_WithReplayBlocks(t *testing.T) {
+	var events []string
+	var batches [][]string
+	eventHandler := replayEvents(&events)
+	eventHandler.OnTransaction = nil
+	eventHandler.OnBlock = func(block uint32, txs []*models.TransactionResponse) {
+		var ids []string
+		for _, tx := range txs {
+			ids = append(ids, tx.Id)
+		}
+		batches = append(batches, ids)
+	}
+	err := Replay(context.Background(), recording(t), eventHandler, WithReplayBlocks(101, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"status " + StatusConnected.String(), "block done 101"}, events)
+	assert.Equal(t, [][]string{{"tx-3"}}, batches)
+}
+
+// TestReplay_clientStatus will test replaying statuses sent by the client as statuses rather than control messages,
+// they are replayed whatever their block
+func TestReplay_clientStatus(t *testing.T) {
+	records := recording(t)
+	for _, code := range []StatusCode{StatusDropped, legacyDroppedCode} {
+		line, err := json.Marshal(&sinks.Record{Kind: sinks.KindStatus, Time: time.Now(),
+			Status: &models.ControlResponse{StatusCode: uint32(code), Block: 50}})
+		require.NoError(t, err)
+		records.Write(append(line, '\n'))
+	}
+
+	var events []string
+	eventHandler := replayEvents(&events)
+	eventHandler.OnBlockDone = nil
+	err := Replay(context.Background(), records, eventHandler, WithReplayBlocks(101, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"status " + StatusConnected.String(),
+		"transaction tx-3",
+		"status " + SubscriptionBlockDone.String(),
+		"status " + StatusDropped.String(),
+		"status " + legacyDroppedCode.String(),
+	}, events)
+}
+
+// TestReplay_WithReplaySpeed will test replaying with the original timing and cancelling the replay
+func TestReplay_WithReplaySpeed(t *testing.T) {
+	t.Run("faster", func(t *testing.T) {
+		var events []string
+		start := time.Now()
+		err := Replay(context.Background(), recording(t), replayEvents(&events), WithReplaySpeed(30))
+		require.NoError(t, err)
+		// the records are six seconds apart in total
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		assert.Len(t, events, 7)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		var events []string
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := Replay(ctx, recording(t), replayEvents(&events), WithReplaySpeed(1))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, []string{"status " + StatusConnected.String()}, events)
+	})
+}
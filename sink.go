@@ -0,0 +1,37 @@
+package junglebus
+
+import (
+	"fmt"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// sinkTransaction writes a transaction to the sink of WithSink, a failing write is sent to onError
+func (s *Subscription) sinkTransaction(tx *models.TransactionResponse, mempool bool, onError func(err error)) {
+	if err := s.sink.WriteTransaction(tx, mempool); err != nil {
+		s.sinkFailed(err, onError)
+	}
+}
+
+// sinkStatus writes a control message or status to the sink of WithSink, a failing write is sent to onError
+func (s *Subscription) sinkStatus(status *models.ControlResponse, onError func(err error)) {
+	if err := s.sink.WriteStatus(status); err != nil {
+		s.sinkFailed(err, onError)
+	}
+}
+
+// sinkFailed reports a failing write to the sink, writes racing the teardown of the subscription are ignored
+func (s *Subscription) sinkFailed(err error, onError func(err error)) {
+	if s.isStopped() {
+		return
+	}
+	s.log(levelWarn, "writing to sink failed", "error", err)
+	onError(fmt.Errorf("failed to write to sink: %w", err))
+}
+
+// closeSink closes the sink of WithSink once the subscription has been torn down
+func (s *Subscription) closeSink() {
+	if err := s.sink.Close(); err != nil {
+		s.log(levelWarn, "closing sink failed", "error", err)
+	}
+}
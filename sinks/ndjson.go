@@ -0,0 +1,178 @@
+package sinks
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// NDJSONOption configures an NDJSONFile
+type NDJSONOption func(f *NDJSONFile)
+
+// WithMaxFileSize will rotate the file once size bytes of records were written to it, counted before compression.
+// The file is never rotated when the size is 0 (default).
+func WithMaxFileSize(size int64) NDJSONOption {
+	return func(f *NDJSONFile) {
+		f.maxSize = size
+	}
+}
+
+// WithGzip will gzip the file, Reader detects gzipped files by themselves
+func WithGzip() NDJSONOption {
+	return func(f *NDJSONFile) {
+		f.gzip = true
+	}
+}
+
+// NDJSONFile is a Sink writing every message as a line of JSON to a file, see Record
+//
+// Records are appended to the file at the path. A rotated file is renamed to the name of the path with the time of
+// the rotation added before its extensions, like archive-20240102T150405.000000000.ndjson for archive.ndjson.
+type NDJSONFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	gzip    bool
+	file    *os.File
+	zw      *gzip.Writer // nil without WithGzip
+	w       *bufio.Writer
+	written int64 // bytes of records written to the current file
+	closed  bool
+}
+
+// NewNDJSONFile opens the file at the path for appending records, creating it if needed
+func NewNDJSONFile(path string, opts ...NDJSONOption) (*NDJSONFile, error) {
+	f := &NDJSONFile{path: path}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at the path, appending to it when it exists
+func (f *NDJSONFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	var w io.Writer = file
+	if f.gzip {
+		// a gzip stream appended to an existing one is read as a continuation of it
+		f.zw = gzip.NewWriter(file)
+		w = f.zw
+	}
+	f.w = bufio.NewWriter(w)
+	f.written = 0
+	return nil
+}
+
+// WriteTransaction writes a transaction record
+func (f *NDJSONFile) WriteTransaction(tx *models.TransactionResponse, mempool bool) error {
+	kind := KindTransaction
+	if mempool {
+		kind = KindMempool
+	}
+	return f.write(&Record{Kind: kind, Time: time.Now().UTC(), Transaction: tx})
+}
+
+// WriteStatus writes a status record
+func (f *NDJSONFile) WriteStatus(status *models.ControlResponse) error {
+	return f.write(&Record{Kind: KindStatus, Time: time.Now().UTC(), Status: status})
+}
+
+// write appends the record as a line, rotating the file first when it is full
+func (f *NDJSONFile) write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	if f.maxSize > 0 && f.written > 0 && f.written+int64(len(line)) > f.maxSize {
+		if err = f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.w.Write(line)
+	f.written += int64(n)
+	return err
+}
+
+// Flush writes the buffered records to the file
+func (f *NDJSONFile) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+	if f.zw != nil {
+		return f.zw.Flush()
+	}
+	return nil
+}
+
+// Close flushes the buffered records and closes the file
+func (f *NDJSONFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return f.closeFile()
+}
+
+// closeFile flushes and closes the current file, the mutex must be held
+func (f *NDJSONFile) closeFile() error {
+	err := f.w.Flush()
+	if f.zw != nil {
+		if closeErr := f.zw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// rotate renames the current file and opens a new one at the path, the mutex must be held
+func (f *NDJSONFile) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.rotatedPath(time.Now().UTC())); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// rotatedPath returns the name of the file rotated at the given time
+func (f *NDJSONFile) rotatedPath(at time.Time) string {
+	dir, name := filepath.Split(f.path)
+	ext := ""
+	if i := strings.Index(name, "."); i > 0 {
+		name, ext = name[:i], name[i:]
+	}
+	return filepath.Join(dir, name+"-"+at.Format("20060102T150405.000000000")+ext)
+}
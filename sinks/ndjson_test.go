@@ -0,0 +1,117 @@
+package sinks
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// readAll reads all records of the file at the path
+func readAll(t *testing.T, path string) []*Record {
+	reader, err := OpenReader(path)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, reader.Close())
+	}()
+	var records []*Record
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+// TestNDJSONFile will test writing records and reading them back, plain and gzipped
+func TestNDJSONFile(t *testing.T) {
+	tx := &models.TransactionResponse{Id: "tx-1", BlockHeight: 100, Transaction: []byte{1, 2, 3}}
+	status := &models.ControlResponse{StatusCode: 200, Status: "block-done", Block: 100, Transactions: 1}
+
+	for _, compressed := range []bool{false, true} {
+		name := "plain"
+		var opts []NDJSONOption
+		if compressed {
+			name = "gzip"
+			opts = append(opts, WithGzip())
+		}
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "archive.ndjson")
+			sink, err := NewNDJSONFile(path, opts...)
+			require.NoError(t, err)
+			require.NoError(t, sink.WriteTransaction(tx, false))
+			require.NoError(t, sink.WriteTransaction(&models.TransactionResponse{Id: "tx-2"}, true))
+			require.NoError(t, sink.Close())
+			assert.ErrorIs(t, sink.WriteStatus(status), ErrClosed)
+
+			// appending to the existing file
+			sink, err = NewNDJSONFile(path, opts...)
+			require.NoError(t, err)
+			require.NoError(t, sink.WriteStatus(status))
+			require.NoError(t, sink.Close())
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, compressed, bytes.HasPrefix(data, gzipMagic))
+
+			records := readAll(t, path)
+			require.Len(t, records, 3)
+			assert.Equal(t, KindTransaction, records[0].Kind)
+			assert.True(t, proto.Equal(tx, records[0].Transaction))
+			assert.False(t, records[0].Time.IsZero())
+			assert.Equal(t, KindMempool, records[1].Kind)
+			assert.Equal(t, "tx-2", records[1].Transaction.Id)
+			assert.Equal(t, KindStatus, records[2].Kind)
+			assert.True(t, proto.Equal(status, records[2].Status))
+		})
+	}
+}
+
+// TestNDJSONFile_rotation will test rotating the file once it reached its maximum size
+func TestNDJSONFile_rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "archive.ndjson.gz")
+	sink, err := NewNDJSONFile(path, WithGzip(), WithMaxFileSize(200))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sink.WriteTransaction(&models.TransactionResponse{Id: strings.Repeat("a", 64)}, false))
+	}
+	require.NoError(t, sink.Close())
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "archive-*.ndjson.gz"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, rotated)
+	total := len(readAll(t, path))
+	for _, file := range rotated {
+		records := readAll(t, file)
+		assert.NotEmpty(t, records)
+		total += len(records)
+	}
+	assert.Equal(t, 10, total)
+}
+
+// TestReader will test reading invalid and empty input
+func TestReader(t *testing.T) {
+	reader, err := NewReader(strings.NewReader(""))
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	reader, err = NewReader(strings.NewReader("{\"kind\":\"status\",\"status\":{\"block\":1}}\n\n{\"kind\":"))
+	require.NoError(t, err)
+	record, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), record.Status.Block)
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "invalid record on line 2")
+}
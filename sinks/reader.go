@@ -0,0 +1,100 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzipMagic are the first bytes of a gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Reader reads the records of an NDJSON file written by NDJSONFile, gzipped or not
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer // nil when the reader does not own its input
+	line   int
+}
+
+// NewReader returns a reader of the records in r, detecting gzipped input
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return &Reader{r: buffered}, nil
+	}
+	zr, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: bufio.NewReader(zr), closer: zr}, nil
+}
+
+// OpenReader opens the file at the path for reading its records, the reader has to be closed
+func OpenReader(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	reader.closer = closers{reader.closer, file}
+	return reader, nil
+}
+
+// Next returns the next record, io.EOF once all records have been read
+func (r *Reader) Next() (*Record, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		r.line++
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		record := &Record{}
+		if err = json.Unmarshal(line, record); err != nil {
+			return nil, fmt.Errorf("invalid record on line %d: %w", r.line, err)
+		}
+		return record, nil
+	}
+}
+
+// Close closes the file opened by OpenReader
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// closers closes all of its closers in order, skipping nil ones
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var err error
+	for _, closer := range c {
+		if closer == nil {
+			continue
+		}
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
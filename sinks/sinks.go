@@ -0,0 +1,40 @@
+// Package sinks archives the messages a subscription delivers, see junglebus.WithSink
+//
+// NDJSONFile writes them as newline delimited JSON, optionally gzipped and rotated by size, and Reader reads such a
+// file back, for example to replay an archived stream in tests.
+package sinks
+
+import (
+	"errors"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// ErrClosed is when writing to a sink that has been closed
+var ErrClosed = errors.New("sink closed")
+
+// Sink receives the messages of a subscription before they are passed on to the event handler
+type Sink interface {
+	// WriteTransaction writes a mined transaction, or a mempool transaction when mempool is set
+	WriteTransaction(tx *models.TransactionResponse, mempool bool) error
+	// WriteStatus writes a control message of the server or a status of the connection
+	WriteStatus(status *models.ControlResponse) error
+	// Close flushes and closes the sink
+	Close() error
+}
+
+// Kinds of records
+const (
+	KindTransaction = "transaction"
+	KindMempool     = "mempool"
+	KindStatus      = "status"
+)
+
+// Record is a message written by a sink, a line of an NDJSON file
+type Record struct {
+	Kind        string                      `json:"kind"`
+	Time        time.Time                   `json:"time"` // when the record was written
+	Transaction *models.TransactionResponse `json:"transaction,omitempty"`
+	Status      *models.ControlResponse     `json:"status,omitempty"`
+}
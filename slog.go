@@ -0,0 +1,63 @@
+//go:build go1.21
+
+package junglebus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// slogLogger is a Logger writing to a slog.Logger, events are logged with structured attributes
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// WithSlog will log to the slog logger, with the subscription ID, channel, block, status code and reconnect
+// attempt as attributes of the connection events. Publications are logged at debug level, handler errors and
+// dropped messages at warn level and protocol errors at error level.
+func WithSlog(logger *slog.Logger) ClientOps {
+	if logger == nil {
+		return func(*Client) {}
+	}
+	return WithLogger(slogLogger{logger: logger})
+}
+
+// Debugf logs the formatted message at debug level
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.logf(slog.LevelDebug, format, args...)
+}
+
+// Infof logs the formatted message at info level
+func (l slogLogger) Infof(format string, args ...interface{}) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+// Errorf logs the formatted message at error level
+func (l slogLogger) Errorf(format string, args ...interface{}) {
+	l.logf(slog.LevelError, format, args...)
+}
+
+// DebugEnabled returns whether the slog logger logs debug messages
+func (l slogLogger) DebugEnabled() bool {
+	return l.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+func (l slogLogger) logf(level slog.Level, format string, args ...interface{}) {
+	if l.logger.Enabled(context.Background(), level) {
+		l.logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l slogLogger) logFields(level logLevel, msg string, keyvals ...interface{}) {
+	slogLevel := slog.LevelDebug
+	switch level {
+	case levelInfo:
+		slogLevel = slog.LevelInfo
+	case levelWarn:
+		slogLevel = slog.LevelWarn
+	case levelError:
+		slogLevel = slog.LevelError
+	}
+	l.logger.Log(context.Background(), slogLevel, msg, keyvals...)
+}
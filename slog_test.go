@@ -0,0 +1,109 @@
+//go:build go1.21
+
+package junglebus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the logged JSON records
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	for decoder.More() {
+		record := map[string]interface{}{}
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// TestWithSlog will test logging the connection events with structured attributes
+func TestWithSlog(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		output := &syncBuffer{}
+		client := server.newClient(WithSlog(slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+		received := make(chan struct{})
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) { close(received) },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, "tx-1")
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction not received")
+		}
+
+		records := map[string]map[string]interface{}{}
+		for _, record := range output.records(t) {
+			records[record["msg"].(string)] = record
+		}
+
+		require.Contains(t, records, "connected")
+		assert.Equal(t, "INFO", records["connected"]["level"])
+		assert.Equal(t, testSubscriptionID, records["connected"]["subscription_id"])
+		assert.Equal(t, float64(StatusConnected), records["connected"]["status_code"])
+
+		require.Contains(t, records, "connecting")
+		assert.Equal(t, float64(100), records["connecting"]["block"])
+
+		require.Contains(t, records, "publication")
+		assert.Equal(t, "DEBUG", records["publication"]["level"])
+		assert.Equal(t, mainChannel, records["publication"]["channel"])
+	})
+}
+
+// TestWithSlog_level will test skipping the debug events for a slog logger above debug level
+func TestWithSlog_level(t *testing.T) {
+	output := &syncBuffer{}
+	client, err := New(WithHTTP("localhost"),
+		WithSlog(slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelInfo}))))
+	require.NoError(t, err)
+	assert.False(t, logEnabled(client.logger, levelDebug))
+	assert.True(t, logEnabled(client.logger, levelInfo))
+	assert.False(t, logEnabled(transports.NopLogger{}, levelError))
+	assert.True(t, logEnabled(&testLogger{}, levelDebug))
+
+	s := &Subscription{SubscriptionID: testSubscriptionID, client: client}
+	s.log(levelDebug, "publication")
+	s.log(levelInfo, "connected")
+	records := output.records(t)
+	require.Len(t, records, 1)
+	assert.Equal(t, "connected", records[0]["msg"])
+}
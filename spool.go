@@ -0,0 +1,404 @@
+package junglebus
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultSpoolQueueSize is the queue size of a subscription with WithDiskSpool that did not set one, see WithQueueSize
+const DefaultSpoolQueueSize = 1000
+
+// spoolHeaderSize is the size of the header of a spool record, the length and the CRC-32 of the record
+const spoolHeaderSize = 8
+
+// kinds of the messages in the spool
+const (
+	spoolKindTransaction = 1
+	spoolKindMempool     = 2
+	spoolKindControl     = 3
+)
+
+// fields of a spool record, encoded as protobuf
+const (
+	spoolFieldKind       protowire.Number = 1
+	spoolFieldChannel    protowire.Number = 2
+	spoolFieldOffset     protowire.Number = 3
+	spoolFieldReceivedAt protowire.Number = 4
+	spoolFieldMessage    protowire.Number = 5
+	spoolFieldPage       protowire.Number = 6
+	spoolFieldHistorical protowire.Number = 7
+)
+
+// errSpoolClosed is when a message is spooled after the subscription was torn down
+var errSpoolClosed = errors.New("spool closed")
+
+// diskSpool is a FIFO of length-prefixed records in a file, the queue of a subscription overflows into it
+//
+// Every record starts with its length and its CRC-32, both big-endian uint32, followed by the record itself. The
+// file is truncated once all records have been read, unread records are kept when the spool is closed.
+type diskSpool struct {
+	mu       sync.Mutex
+	cond     *sync.Cond // signalled when the spool was emptied or closed
+	file     *os.File
+	maxBytes int64
+	readAt   int64 // offset of the next record to read
+	size     int64 // offset of the end of the last record
+	records  int   // records not read yet
+	closed   bool
+	ready    chan struct{} // signalled when a record was written
+}
+
+// openDiskSpool opens the spool of the subscription in dir, keeping the records left behind by a previous run.
+// A corrupted tail, like a record that was only partially written before a crash, is cut off and returned as a
+// SpoolCorruptionError together with the spool.
+func openDiskSpool(dir, subscriptionID string, maxBytes int64) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, url.PathEscape(subscriptionID)+".spool"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	spool := &diskSpool{file: file, maxBytes: maxBytes, ready: make(chan struct{}, 1)}
+	spool.cond = sync.NewCond(&spool.mu)
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	var corruptionErr error
+	for spool.size < info.Size() {
+		length, err := spool.recordLength(spool.size, info.Size())
+		if err != nil {
+			corruptionErr = &SpoolCorruptionError{Path: file.Name(), Offset: spool.size,
+				Truncated: info.Size() - spool.size, Err: err}
+			break
+		}
+		spool.size += spoolHeaderSize + length
+		spool.records++
+	}
+	if corruptionErr != nil {
+		if err = file.Truncate(spool.size); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return spool, corruptionErr
+}
+
+// recordLength returns the length of the record at offset, checking it is complete and matches its checksum
+func (d *diskSpool) recordLength(offset, end int64) (int64, error) {
+	var header [spoolHeaderSize]byte
+	if end-offset < spoolHeaderSize {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if _, err := d.file.ReadAt(header[:], offset); err != nil {
+		return 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if end-offset-spoolHeaderSize < length {
+		return 0, io.ErrUnexpectedEOF
+	}
+	record := make([]byte, length)
+	if _, err := d.file.ReadAt(record, offset+spoolHeaderSize); err != nil {
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:]) {
+		return 0, ErrSpoolCorrupted
+	}
+	return length, nil
+}
+
+// pushLocked appends a record, waiting until the spool was emptied when it would grow beyond its maximum size.
+// The mutex must be held.
+func (d *diskSpool) pushLocked(record []byte) error {
+	for !d.closed && d.records > 0 && d.size+spoolHeaderSize+int64(len(record)) > d.maxBytes {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return errSpoolClosed
+	}
+
+	data := make([]byte, spoolHeaderSize+len(record))
+	binary.BigEndian.PutUint32(data[:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(data[4:spoolHeaderSize], crc32.ChecksumIEEE(record))
+	copy(data[spoolHeaderSize:], record)
+	if _, err := d.file.WriteAt(data, d.size); err != nil {
+		// a partially written record is overwritten by the next one
+		return err
+	}
+	d.size += int64(len(data))
+	d.records++
+	select {
+	case d.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pop reads the oldest record, ok is false when the spool is empty. The records that can not be read anymore are
+// dropped, the file is truncated once all records have been read.
+func (d *diskSpool) pop() (record []byte, ok bool, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.records == 0 || d.closed {
+		return nil, false, nil
+	}
+
+	var length int64
+	if length, err = d.recordLength(d.readAt, d.size); err != nil {
+		err = &SpoolCorruptionError{Path: d.file.Name(), Offset: d.readAt, Truncated: d.size - d.readAt, Err: err}
+		d.records = 0
+	} else {
+		record = make([]byte, length)
+		if _, err = d.file.ReadAt(record, d.readAt+spoolHeaderSize); err == nil {
+			ok = true
+		}
+		d.readAt += spoolHeaderSize + length
+		d.records--
+	}
+	if d.records == 0 {
+		d.readAt, d.size = 0, 0
+		if truncateErr := d.file.Truncate(0); err == nil {
+			err = truncateErr
+		}
+		d.cond.Broadcast()
+	}
+	return record, ok, err
+}
+
+// len returns the number of records not read yet
+func (d *diskSpool) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.records
+}
+
+// close closes the file, moving the unread records to the start of the file so they are read first next time
+func (d *diskSpool) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	d.cond.Broadcast()
+
+	var err error
+	if d.readAt > 0 {
+		err = d.compact()
+	}
+	if closeErr := d.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// compact moves the unread records to the start of the file, the mutex must be held
+func (d *diskSpool) compact() error {
+	unread := make([]byte, d.size-d.readAt)
+	if _, err := d.file.ReadAt(unread, d.readAt); err != nil {
+		return err
+	}
+	if _, err := d.file.WriteAt(unread, 0); err != nil {
+		return err
+	}
+	d.readAt, d.size = 0, int64(len(unread))
+	return d.file.Truncate(d.size)
+}
+
+// spoolRecord is a message waiting in the spool, with how it was received
+type spoolRecord struct {
+	kind       uint64
+	channel    string
+	offset     uint64
+	receivedAt time.Time
+	page       uint64
+	historical bool
+	message    []byte
+}
+
+// encode encodes the record as protobuf
+func (r *spoolRecord) encode() []byte {
+	var data []byte
+	data = protowire.AppendTag(data, spoolFieldKind, protowire.VarintType)
+	data = protowire.AppendVarint(data, r.kind)
+	data = protowire.AppendTag(data, spoolFieldChannel, protowire.BytesType)
+	data = protowire.AppendString(data, r.channel)
+	data = protowire.AppendTag(data, spoolFieldOffset, protowire.VarintType)
+	data = protowire.AppendVarint(data, r.offset)
+	data = protowire.AppendTag(data, spoolFieldReceivedAt, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(r.receivedAt.UnixNano()))
+	data = protowire.AppendTag(data, spoolFieldPage, protowire.VarintType)
+	data = protowire.AppendVarint(data, r.page)
+	data = protowire.AppendTag(data, spoolFieldHistorical, protowire.VarintType)
+	data = protowire.AppendVarint(data, protowire.EncodeBool(r.historical))
+	data = protowire.AppendTag(data, spoolFieldMessage, protowire.BytesType)
+	return protowire.AppendBytes(data, r.message)
+}
+
+// decode decodes a record encoded by encode, skipping unknown fields
+func (r *spoolRecord) decode(data []byte) error {
+	for len(data) > 0 {
+		number, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case number == spoolFieldChannel && typ == protowire.BytesType:
+			r.channel, n = protowire.ConsumeString(data)
+		case number == spoolFieldMessage && typ == protowire.BytesType:
+			r.message, n = protowire.ConsumeBytes(data)
+		case typ == protowire.VarintType:
+			var value uint64
+			value, n = protowire.ConsumeVarint(data)
+			switch number {
+			case spoolFieldKind:
+				r.kind = value
+			case spoolFieldOffset:
+				r.offset = value
+			case spoolFieldReceivedAt:
+				r.receivedAt = time.Unix(0, int64(value))
+			case spoolFieldPage:
+				r.page = value
+			case spoolFieldHistorical:
+				r.historical = protowire.DecodeBool(value)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// spoolTransaction queues the handling of a transaction like dispatch, spooling it to disk once the queue is full
+func (s *Subscription) spoolTransaction(ctx TxContext, tx *models.TransactionResponse, fn func()) {
+	kind := uint64(spoolKindTransaction)
+	if ctx.Mempool {
+		kind = spoolKindMempool
+	}
+	record := &spoolRecord{kind: kind, channel: ctx.Channel, offset: ctx.Offset, receivedAt: ctx.ReceivedAt,
+		page: ctx.Page, historical: ctx.IsHistorical}
+	if s.spoolMessage(record, tx, fn) {
+		// the spool keeps its own copy
+		s.release(tx)
+	}
+}
+
+// dispatchControl queues the handling of a control message like dispatch, spooling it to disk with WithDiskSpool
+func (s *Subscription) dispatchControl(channel string, offset uint64, receivedAt time.Time,
+	controlResponse *models.ControlResponse) {
+
+	if s.sink != nil {
+		s.sinkStatus(controlResponse, s.EventHandler.OnError)
+	}
+	s.fanOut(func(c *Consumer) { c.control(controlResponse) })
+	s.lag.received(controlResponse.Block, 0)
+	// the checkpoint of the control message covers the publications of the main channel that arrived before it
+	position := s.streamPosition()
+	fn := func() { s.onControl(controlResponse, position) }
+	if s.spool == nil {
+		connection := s.currentConnection()
+		s.dispatch(func() {
+			if !s.replacedConnection(connection) {
+				fn()
+			}
+		})
+		return
+	}
+	s.spoolMessage(&spoolRecord{kind: spoolKindControl, channel: channel, offset: offset, receivedAt: receivedAt},
+		controlResponse, fn)
+}
+
+// spoolMessage runs fn on the queue, or writes the message to the spool when the queue is full or the spool is not
+// empty yet, keeping the messages in order. It returns whether the message went to the spool.
+func (s *Subscription) spoolMessage(record *spoolRecord, message proto.Message, fn func()) bool {
+	s.spool.mu.Lock()
+	if s.spool.records == 0 && s.queue.tryPush(fn) {
+		s.spool.mu.Unlock()
+		return false
+	}
+	data, err := proto.Marshal(message)
+	if err == nil {
+		record.message = data
+		err = s.spool.pushLocked(record.encode())
+	}
+	s.spool.mu.Unlock()
+
+	if err != nil && !errors.Is(err, errSpoolClosed) {
+		s.log(levelError, "spooling message failed", "channel", record.channel, "error", err)
+		s.dispatch(func() { s.EventHandler.OnError(err) })
+	}
+	return true
+}
+
+// replaySpooled handles a message read from the spool
+func (s *Subscription) replaySpooled(data []byte) {
+	var record spoolRecord
+	err := record.decode(data)
+	if err == nil && record.kind == spoolKindControl {
+		control := &models.ControlResponse{}
+		if err = proto.Unmarshal(record.message, control); err == nil {
+			s.onControl(control, nil)
+			return
+		}
+	} else if err == nil {
+		tx := s.newTransaction()
+		if err = proto.Unmarshal(record.message, tx); err == nil {
+			s.callTxHandler(TxContext{
+				Channel:      record.channel,
+				Mempool:      record.kind == spoolKindMempool,
+				Block:        tx.BlockHeight,
+				Page:         record.page,
+				Offset:       record.offset,
+				ReceivedAt:   record.receivedAt,
+				IsHistorical: record.historical,
+			}, tx)
+			return
+		}
+		s.release(tx)
+	}
+	s.log(levelError, "invalid spooled message", "error", err)
+	s.EventHandler.OnError(&DecodeError{Channel: record.channel, Offset: record.offset, Data: data, Err: err})
+}
+
+// handleSpooled handles the oldest message of the spool for the queue worker, it returns false when the spool is
+// empty. Records that can not be read are sent to OnError.
+func (s *Subscription) handleSpooled() bool {
+	if s.isStopped() {
+		return false
+	}
+	data, ok, err := s.spool.pop()
+	if err != nil {
+		s.log(levelError, "reading spool failed", "error", err)
+		s.EventHandler.OnError(err)
+	}
+	if ok {
+		s.replaySpooled(data)
+	}
+	return ok || err != nil
+}
+
+// closeSpool closes the spool once the queue worker stopped, keeping the messages that were not handled
+func (s *Subscription) closeSpool() {
+	if err := s.spool.close(); err != nil {
+		s.log(levelWarn, "closing spool failed", "error", err)
+	}
+}
@@ -0,0 +1,284 @@
+package junglebus
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// push appends a record to the spool for the tests
+func (d *diskSpool) push(t *testing.T, record []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	require.NoError(t, d.pushLocked(record))
+}
+
+// spoolTx returns the spool record of a mined transaction
+func spoolTx(t *testing.T, id string) []byte {
+	data, err := proto.Marshal(&models.TransactionResponse{Id: id, BlockHeight: 100})
+	require.NoError(t, err)
+	record := &spoolRecord{kind: spoolKindTransaction, channel: "query:" + testSubscriptionID + ":100", message: data}
+	return record.encode()
+}
+
+// TestDiskSpool will test spooling records in order and recovering them after a restart
+func TestDiskSpool(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := openDiskSpool(dir, testSubscriptionID, 1<<20)
+	require.NoError(t, err)
+
+	for _, record := range []string{"first", "second", "third"} {
+		spool.push(t, []byte(record))
+	}
+	record, ok, err := spool.pop()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "first", string(record))
+	assert.Equal(t, 2, spool.len())
+
+	// the unread records are kept, and read first after opening the spool again
+	require.NoError(t, spool.close())
+	spool, err = openDiskSpool(dir, testSubscriptionID, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, 2, spool.len())
+	spool.push(t, []byte("fourth"))
+	var records []string
+	for {
+		record, ok, err = spool.pop()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		records = append(records, string(record))
+	}
+	assert.Equal(t, []string{"second", "third", "fourth"}, records)
+
+	// the file is emptied once everything was read
+	info, err := os.Stat(filepath.Join(dir, testSubscriptionID+".spool"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+	require.NoError(t, spool.close())
+}
+
+// TestDiskSpool_corrupted will test cutting off a partially written or corrupted tail
+func TestDiskSpool_corrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		err     error
+		kept    []string
+	}{
+		{
+			name:    "partial record",
+			corrupt: func(data []byte) []byte { return data[:len(data)-2] },
+			err:     io.ErrUnexpectedEOF,
+			kept:    []string{"kept"},
+		},
+		{
+			name:    "partial header",
+			corrupt: func(data []byte) []byte { return append(data, 0, 0, 0) },
+			err:     io.ErrUnexpectedEOF,
+			kept:    []string{"kept", "last"},
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(data []byte) []byte {
+				data[len(data)-1] ^= 0xff
+				return data
+			},
+			err:  ErrSpoolCorrupted,
+			kept: []string{"kept"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			spool, err := openDiskSpool(dir, testSubscriptionID, 1<<20)
+			require.NoError(t, err)
+			spool.push(t, []byte("kept"))
+			spool.push(t, []byte("last"))
+			require.NoError(t, spool.close())
+
+			path := filepath.Join(dir, testSubscriptionID+".spool")
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, test.corrupt(data), 0o600))
+
+			spool, err = openDiskSpool(dir, testSubscriptionID, 1<<20)
+			var corruptionErr *SpoolCorruptionError
+			require.ErrorAs(t, err, &corruptionErr)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, int64(len(test.kept)*(spoolHeaderSize+4)), corruptionErr.Offset)
+			require.NotNil(t, spool)
+			defer func() {
+				_ = spool.close()
+			}()
+
+			require.Equal(t, len(test.kept), spool.len())
+			for _, kept := range test.kept {
+				record, ok, err := spool.pop()
+				require.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, kept, string(record))
+			}
+		})
+	}
+}
+
+// TestSpoolRecord will test encoding and decoding spool records
+func TestSpoolRecord(t *testing.T) {
+	record := &spoolRecord{
+		kind:       spoolKindMempool,
+		channel:    "query:sub:mempool",
+		offset:     42,
+		receivedAt: time.Unix(1700000000, 123),
+		page:       3,
+		historical: true,
+		message:    []byte{1, 2, 3},
+	}
+	var decoded spoolRecord
+	require.NoError(t, decoded.decode(record.encode()))
+	assert.Equal(t, record.kind, decoded.kind)
+	assert.Equal(t, record.channel, decoded.channel)
+	assert.Equal(t, record.offset, decoded.offset)
+	assert.True(t, record.receivedAt.Equal(decoded.receivedAt))
+	assert.Equal(t, record.page, decoded.page)
+	assert.True(t, decoded.historical)
+	assert.Equal(t, record.message, decoded.message)
+
+	assert.Error(t, decoded.decode([]byte{0x0a, 0x05}))
+}
+
+// TestSubscribe_WithDiskSpool will test overflowing the queue into the spool and handling it in order
+func TestSubscribe_WithDiskSpool(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var handled []string
+		blockDone := make(chan struct{})
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				if tx.Id == "tx-1" {
+					close(started)
+					<-release
+				}
+				mu.Lock()
+				handled = append(handled, tx.Id)
+				mu.Unlock()
+			},
+			OnBlockDone: func(uint32, uint64) {
+				mu.Lock()
+				handled = append(handled, "block done")
+				mu.Unlock()
+				close(blockDone)
+			},
+			OnStatus: recorder.onStatus,
+			OnError:  recorder.onError,
+		}, WithQueueSize(1), WithDiskSpool(t.TempDir(), 1<<20))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, "tx-1")
+		<-started
+		for _, id := range []string{"tx-2", "tx-3", "tx-4", "tx-5"} {
+			server.publishTransaction(mainChannel, id)
+		}
+		server.publishMessage("query:"+testSubscriptionID+":control", &models.ControlResponse{
+			StatusCode: uint32(SubscriptionBlockDone),
+			Block:      100,
+		})
+		require.Eventually(t, func() bool {
+			return subscription.Stats().SpoolDepth == 4
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Zero(t, subscription.Stats().DroppedMessages)
+		close(release)
+
+		select {
+		case <-blockDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("block not done")
+		}
+		mu.Lock()
+		assert.Equal(t, []string{"tx-1", "tx-2", "tx-3", "tx-4", "tx-5", "block done"}, handled)
+		mu.Unlock()
+		assert.Zero(t, subscription.Stats().SpoolDepth)
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		assert.Empty(t, recorder.errors)
+	})
+}
+
+// TestSubscribe_WithDiskSpoolReplay will test handling the spool of a previous run before the live messages
+func TestSubscribe_WithDiskSpoolReplay(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		dir := t.TempDir()
+		spool, err := openDiskSpool(dir, testSubscriptionID, 1<<20)
+		require.NoError(t, err)
+		spool.push(t, spoolTx(t, "spooled-1"))
+		spool.push(t, spoolTx(t, "spooled-2"))
+		require.NoError(t, spool.close())
+		// a record that was only partially written before a crash
+		path := filepath.Join(dir, testSubscriptionID+".spool")
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = file.Write([]byte{0, 0, 1, 0, 1})
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		server := newFakeServer(t)
+		client := server.newClient()
+		received := make(chan string, 3)
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				received <- tx.Id
+			},
+			OnStatus: recorder.onStatus,
+			OnError:  recorder.onError,
+		}, WithDiskSpool(dir, 1<<20))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, "live")
+		var ids []string
+		for i := 0; i < 3; i++ {
+			select {
+			case id := <-received:
+				ids = append(ids, id)
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+			}
+		}
+		assert.Equal(t, []string{"spooled-1", "spooled-2", "live"}, ids)
+
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		require.Len(t, recorder.errors, 1)
+		var corruptionErr *SpoolCorruptionError
+		require.ErrorAs(t, recorder.errors[0], &corruptionErr)
+		assert.Equal(t, int64(5), corruptionErr.Truncated)
+	})
+}
@@ -0,0 +1,63 @@
+package junglebus
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// touch records activity on the connection, restarting the stall timeout
+func (s *Subscription) touch() {
+	atomic.StoreInt64(&s.counters.lastActivity, time.Now().UnixNano())
+}
+
+// setWaiting records whether the server is waiting for the next block, the stall timeout is suspended meanwhile
+func (s *Subscription) setWaiting(waiting bool) {
+	var value int32
+	if waiting {
+		value = 1
+	}
+	atomic.StoreInt32(&s.waiting, value)
+}
+
+// watchStalls replaces the connection when no publication arrived for the stall timeout, until the subscription
+// is torn down. The connection is not replaced while reconnecting or while the server is waiting for the next block.
+func (s *Subscription) watchStalls() {
+	s.touch()
+	interval := s.stallTimeout / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	eventHandler := s.dispatched()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&s.counters.lastActivity)))
+		if idle < s.stallTimeout || atomic.LoadInt32(&s.waiting) == 1 {
+			continue
+		}
+		s.mu.Lock()
+		centrifugeClient := s.centrifugeClient
+		s.mu.Unlock()
+		if centrifugeClient == nil {
+			continue
+		}
+
+		s.touch()
+		s.log(levelWarn, "subscription stalled", "idle", idle, "block", s.LastBlock())
+		s.sendStatus(eventHandler.OnStatus, StatusStalled, "stalled", func() string {
+			if !s.streamsBlocks() {
+				return fmt.Sprintf("No messages for %s, reconnecting", idle.Round(time.Millisecond))
+			}
+			return fmt.Sprintf("No messages for %s, reconnecting at block %d", idle.Round(time.Millisecond), s.LastBlock())
+		})
+		s.reconnect(centrifugeClient)
+	}
+}
@@ -0,0 +1,79 @@
+package junglebus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithStallTimeout will test reconnecting a subscription that stopped receiving messages
+func TestWithStallTimeout(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const stallTimeout = 200 * time.Millisecond
+		controlChannel := "query:" + testSubscriptionID + ":control"
+
+		subscribe := func(t *testing.T, server *fakeServer) (*Subscription, *statusRecorder) {
+			client := server.newClient(WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond, 2))
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      recorder.onStatus,
+				OnError:       recorder.onError,
+			}, WithStallTimeout(stallTimeout))
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = subscription.Unsubscribe()
+			})
+			server.waitSubscribed(controlChannel)
+			return subscription, recorder
+		}
+
+		t.Run("stalled", func(t *testing.T) {
+			server := newFakeServer(t)
+			subscription, recorder := subscribe(t, server)
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 105})
+
+			require.Eventually(t, func() bool {
+				return recorder.has(StatusStalled)
+			}, 5*time.Second, 10*time.Millisecond)
+			require.Eventually(t, func() bool {
+				return len(server.dialTimes()) == 2 && server.subscribed("query:"+testSubscriptionID+":105")
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, uint64(105), subscription.LastBlock())
+			assert.Equal(t, uint64(1), subscription.Stats().Reconnects)
+		})
+
+		t.Run("activity", func(t *testing.T) {
+			server := newFakeServer(t)
+			_, recorder := subscribe(t, server)
+
+			deadline := time.Now().Add(3 * stallTimeout)
+			for time.Now().Before(deadline) {
+				server.publishTransaction("query:"+testSubscriptionID+":100", "tx")
+				time.Sleep(stallTimeout / 4)
+			}
+			assert.False(t, recorder.has(StatusStalled))
+			assert.Len(t, server.dialTimes(), 1)
+		})
+
+		t.Run("waiting", func(t *testing.T) {
+			server := newFakeServer(t)
+			_, recorder := subscribe(t, server)
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 100})
+
+			time.Sleep(3 * stallTimeout)
+			assert.False(t, recorder.has(StatusStalled))
+			assert.Len(t, server.dialTimes(), 1)
+
+			// the next block ends waiting, the connection stalls again without messages
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+			require.Eventually(t, func() bool {
+				return recorder.has(StatusStalled) && len(server.dialTimes()) == 2
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package junglebus
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SubscriptionStats is a snapshot of the statistics of a subscription, the counters are kept across reconnects
+type SubscriptionStats struct {
+	TransactionsReceived uint64    // mined transactions received
+	MempoolReceived      uint64    // mempool transactions received
+	ControlReceived      uint64    // control messages received
+	LastBlock            uint64    // last block reported on the control channel, see Subscription.LastBlock
+	LastBlockTime        time.Time // when the last block was done, zero before any block was done
+	LastControlTime      time.Time // when the last control message was received, zero before the first one
+	Reconnects           uint64    // reconnect attempts
+	Errors               uint64    // errors sent to OnError
+	QueueDepth           int       // messages waiting in the queue to be handled
+	SpoolDepth           int       // messages waiting in the disk spool of WithDiskSpool to be handled
+	DroppedMessages      uint64    // messages dropped by the overflow policy
+	Filtered             uint64    // transactions dropped by the filter of WithFilter, see TransactionsReceived
+	DuplicatesSuppressed uint64    // transactions suppressed by WithDedup
+	DeadLettered         uint64    // transactions given up on by OnTransactionE or OnMempoolE, see OnDeadLetter
+	Reordered            uint64    // transactions WithStrictOrdering passed on before ones received earlier
+	ReorderDropped       uint64    // transactions WithStrictOrdering dropped for being ordered before passed on ones
+	Lag                  LagStats  // how far the event handler is behind the stream
+}
+
+// subscriptionCounters are the counters behind SubscriptionStats, only accessed atomically
+type subscriptionCounters struct {
+	transactions    uint64
+	mempool         uint64
+	control         uint64
+	reconnects      uint64
+	errors          uint64
+	dropped         uint64
+	filtered        uint64
+	duplicates      uint64
+	deadLettered    uint64
+	reordered       uint64
+	reorderDropped  uint64
+	unreportedDrops uint64 // drops not yet reported with a status
+	lastBlockTime   int64  // unix nanoseconds
+	lastControl     int64  // unix nanoseconds of the last control message, see Client.Health
+	lastActivity    int64  // unix nanoseconds of the last publication or connect, see WithStallTimeout
+	inFlight        int64  // publication callbacks running, see Shutdown
+	drained         uint64 // queued messages handled since Shutdown was called
+}
+
+// Stats returns a snapshot of the statistics of the subscription
+func (s *Subscription) Stats() SubscriptionStats {
+	stats := SubscriptionStats{
+		TransactionsReceived: atomic.LoadUint64(&s.counters.transactions),
+		MempoolReceived:      atomic.LoadUint64(&s.counters.mempool),
+		ControlReceived:      atomic.LoadUint64(&s.counters.control),
+		LastBlock:            s.LastBlock(),
+		Reconnects:           atomic.LoadUint64(&s.counters.reconnects),
+		Errors:               atomic.LoadUint64(&s.counters.errors),
+		QueueDepth:           s.queue.len(),
+		DroppedMessages:      atomic.LoadUint64(&s.counters.dropped),
+		Filtered:             atomic.LoadUint64(&s.counters.filtered),
+		DuplicatesSuppressed: atomic.LoadUint64(&s.counters.duplicates),
+		DeadLettered:         atomic.LoadUint64(&s.counters.deadLettered),
+		Reordered:            atomic.LoadUint64(&s.counters.reordered),
+		ReorderDropped:       atomic.LoadUint64(&s.counters.reorderDropped),
+		Lag:                  s.lag.stats(),
+	}
+	if s.spool != nil {
+		stats.SpoolDepth = s.spool.len()
+	}
+	if lastBlockTime := atomic.LoadInt64(&s.counters.lastBlockTime); lastBlockTime > 0 {
+		stats.LastBlockTime = time.Unix(0, lastBlockTime)
+	}
+	if lastControl := atomic.LoadInt64(&s.counters.lastControl); lastControl > 0 {
+		stats.LastControlTime = time.Unix(0, lastControl)
+	}
+	return stats
+}
+
+// countErrors returns onError counting the errors of the subscription, a nil onError only counts them
+func (s *Subscription) countErrors(onError func(err error)) func(err error) {
+	return func(err error) {
+		atomic.AddUint64(&s.counters.errors, 1)
+		if onError != nil {
+			onError(err)
+		}
+	}
+}
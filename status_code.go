@@ -0,0 +1,94 @@
+package junglebus
+
+import "strconv"
+
+// String returns the name of the status code, like "block done"
+func (c StatusCode) String() string {
+	switch c {
+	case StatusConnecting:
+		return "connecting"
+	case StatusConnected:
+		return "connected"
+	case StatusJoin:
+		return "join"
+	case StatusLeave:
+		return "leave"
+	case StatusDisconnecting:
+		return "disconnecting"
+	case StatusDisconnected:
+		return "disconnected"
+	case StatusSubscribing:
+		return "subscribing"
+	case StatusSubscribed:
+		return "subscribed"
+	case StatusUnsubscribed:
+		return "unsubscribed"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusTokenRefreshFailed:
+		return "token refresh failed"
+	case StatusTokenRefreshed:
+		return "token refreshed"
+	case StatusStalled:
+		return "stalled"
+	case StatusFailover:
+		return "failover"
+	case StatusStreamReset:
+		return "stream reset"
+	case StatusRepairStarted:
+		return "repairing"
+	case StatusRepaired:
+		return "repaired"
+	case StatusLagging:
+		return "lagging"
+	case StatusDropped:
+		return "dropped"
+	case SubscriptionWait:
+		return "waiting"
+	case SubscriptionError:
+		return "subscription error"
+	case SubscriptionBlockDone:
+		return "block done"
+	case SubscriptionReorg:
+		return "reorg"
+	case StatusError:
+		return "error"
+	default:
+		return "StatusCode(" + strconv.FormatUint(uint64(c), 10) + ")"
+	}
+}
+
+// legacyDroppedCode is the code StatusDropped had before it moved to the client codes, recordings of WithSink made
+// before still carry it
+const legacyDroppedCode StatusCode = 102
+
+// IsServer returns whether the code is forwarded from the server, instead of sent by the client about its connection
+func (c StatusCode) IsServer() bool {
+	return c >= SubscriptionWait && c != StatusError && c != legacyDroppedCode
+}
+
+// IsBlockDone returns whether all transactions of the block of the message have been sent
+func (c StatusCode) IsBlockDone() bool {
+	return c == SubscriptionBlockDone
+}
+
+// IsReorg returns whether the chain reorganized, the block of the message is the height to roll back to
+func (c StatusCode) IsReorg() bool {
+	return c == SubscriptionReorg
+}
+
+// IsWaiting returns whether the server caught up with the chain and is waiting for the next block
+func (c StatusCode) IsWaiting() bool {
+	return c == SubscriptionWait
+}
+
+// IsError returns whether the code reports an error, the error itself is also sent to OnError when the client
+// encountered it
+func (c StatusCode) IsError() bool {
+	return c == StatusError || c == SubscriptionError || c == StatusTokenRefreshFailed
+}
+
+// IsTerminal returns whether the subscription stopped, no messages follow a terminal status
+func (c StatusCode) IsTerminal() bool {
+	return c == StatusCancelled
+}
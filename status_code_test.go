@@ -0,0 +1,57 @@
+package junglebus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatusCode will test the names and predicates of the status codes
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		code     StatusCode
+		value    uint32
+		name     string
+		server   bool
+		err      bool
+		terminal bool
+	}{
+		{StatusConnecting, 1, "connecting", false, false, false},
+		{StatusConnected, 2, "connected", false, false, false},
+		{StatusJoin, 3, "join", false, false, false},
+		{StatusLeave, 4, "leave", false, false, false},
+		{StatusDisconnecting, 10, "disconnecting", false, false, false},
+		{StatusDisconnected, 11, "disconnected", false, false, false},
+		{StatusSubscribing, 20, "subscribing", false, false, false},
+		{StatusSubscribed, 21, "subscribed", false, false, false},
+		{StatusUnsubscribed, 29, "unsubscribed", false, false, false},
+		{StatusCancelled, 30, "cancelled", false, false, true},
+		{StatusTokenRefreshFailed, 40, "token refresh failed", false, true, false},
+		{StatusTokenRefreshed, 41, "token refreshed", false, false, false},
+		{StatusStalled, 50, "stalled", false, false, false},
+		{StatusFailover, 51, "failover", false, false, false},
+		{StatusRepairStarted, 53, "repairing", false, false, false},
+		{StatusRepaired, 54, "repaired", false, false, false},
+		{StatusLagging, 55, "lagging", false, false, false},
+		{StatusDropped, 56, "dropped", false, false, false},
+		{SubscriptionWait, 100, "waiting", true, false, false},
+		{SubscriptionError, 101, "subscription error", true, true, false},
+		{SubscriptionBlockDone, 200, "block done", true, false, false},
+		{SubscriptionReorg, 300, "reorg", true, false, false},
+		{StatusError, 999, "error", false, true, false},
+		{legacyDroppedCode, 102, "StatusCode(102)", false, false, false},
+		{StatusCode(42), 42, "StatusCode(42)", false, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.value, uint32(test.code))
+			assert.Equal(t, test.name, test.code.String())
+			assert.Equal(t, test.server, test.code.IsServer())
+			assert.Equal(t, test.err, test.code.IsError())
+			assert.Equal(t, test.terminal, test.code.IsTerminal())
+			assert.Equal(t, test.code == SubscriptionBlockDone, test.code.IsBlockDone())
+			assert.Equal(t, test.code == SubscriptionReorg, test.code.IsReorg())
+			assert.Equal(t, test.code == SubscriptionWait, test.code.IsWaiting())
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package junglebus
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/centrifugal/centrifuge-go"
+)
+
+// StreamPosition is the position of a publication in the stream of a channel. Offsets are only comparable within
+// the same channel and epoch, the server starts a new epoch when it lost the history of the channel.
+type StreamPosition struct {
+	Channel string `json:"channel"`
+	Offset  uint64 `json:"offset"`
+	Epoch   string `json:"epoch"`
+}
+
+// StreamCheckpointStore is a CheckpointStore that also persists the stream position of a checkpoint, a subscription
+// resuming from it skips the publications it already passed on. FileCheckpointStore implements it.
+type StreamCheckpointStore interface {
+	CheckpointStore
+	// SaveCheckpoint stores the checkpoint the subscription has reached, including its stream position
+	SaveCheckpoint(ctx context.Context, subscriptionID string, checkpoint Checkpoint) error
+	// LoadCheckpoint returns the stored checkpoint, or ErrCheckpointNotFound when nothing was stored yet
+	LoadCheckpoint(ctx context.Context, subscriptionID string) (Checkpoint, error)
+}
+
+// saveCheckpoint saves the checkpoint to the checkpoint store, with its stream position when the store keeps it.
+// Without a position to resume after, the checkpoint of a block that is done is saved as the start of the next block,
+// resuming from the block itself would pass all of its transactions on again.
+func (s *Subscription) saveCheckpoint(ctx context.Context, checkpoint Checkpoint) error {
+	store, keepsPosition := s.checkpointStore.(StreamCheckpointStore)
+	if atomic.LoadInt32(&s.checkpointDone) == 1 && (!keepsPosition || checkpoint.Position == nil) {
+		checkpoint = Checkpoint{Block: checkpoint.Block + 1}
+	}
+	if keepsPosition {
+		return store.SaveCheckpoint(ctx, s.SubscriptionID, checkpoint)
+	}
+	return s.checkpointStore.Save(ctx, s.SubscriptionID, checkpoint.Block, checkpoint.Page)
+}
+
+// loadCheckpoint loads the checkpoint from the checkpoint store, with its stream position when the store keeps it
+func (s *Subscription) loadCheckpoint(ctx context.Context) (Checkpoint, error) {
+	if store, ok := s.checkpointStore.(StreamCheckpointStore); ok {
+		return store.LoadCheckpoint(ctx, s.SubscriptionID)
+	}
+	block, page, err := s.checkpointStore.Load(ctx, s.SubscriptionID)
+	return Checkpoint{Block: block, Page: page}, err
+}
+
+// streamPosition returns the position of the last publication received on the main channel, nil before the first one
+func (s *Subscription) streamPosition() *StreamPosition {
+	position, _ := s.position.Load().(StreamPosition)
+	if position.Channel == "" {
+		return nil
+	}
+	return &position
+}
+
+// onMainChannel returns whether the position is on a main channel of the subscription
+func (s *Subscription) onMainChannel(position StreamPosition) bool {
+	name, ok := s.channelName(position.Channel)
+	return ok && name == channelMain
+}
+
+// resumed returns whether the stream of the channel still holds the position it resumes after. When it does not,
+// the position is dropped and nothing is skipped.
+func (s *Subscription) resumed(position *StreamPosition, stream *centrifuge.StreamPosition) bool {
+	if stream != nil && stream.Epoch == position.Epoch && stream.Offset >= position.Offset {
+		return true
+	}
+
+	checkpoint := s.Checkpoint()
+	checkpoint.Position = nil
+	s.checkpoint.Store(checkpoint)
+	s.log(levelWarn, "stream position not available", "channel", position.Channel, "offset", position.Offset,
+		"epoch", position.Epoch, "block", checkpoint.Block)
+	s.sendStatus(s.dispatched().OnStatus, StatusStreamReset, "stream reset", func() string {
+		return fmt.Sprintf("Offset %d of %s is no longer available, resuming at block %d, transactions may be "+
+			"delivered again", position.Offset, position.Channel, checkpoint.Block)
+	})
+	return false
+}
@@ -0,0 +1,145 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// txRecorder collects the transactions passed on to OnTransaction and the epochs of their TxContext
+type txRecorder struct {
+	mu     sync.Mutex
+	ids    []string
+	epochs []string
+}
+
+func (r *txRecorder) middleware(next TxHandler) TxHandler {
+	return func(ctx TxContext, tx *models.TransactionResponse) {
+		r.mu.Lock()
+		r.epochs = append(r.epochs, ctx.Epoch)
+		r.mu.Unlock()
+		next(ctx, tx)
+	}
+}
+
+func (r *txRecorder) onTransaction(tx *models.TransactionResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, tx.Id)
+}
+
+func (r *txRecorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+// TestSubscribe_StreamPosition will test skipping the publications of the main channel that were already handled
+func TestSubscribe_StreamPosition(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+
+		t.Run("resumes after the checkpoint", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.ReplayHistory(true)
+			store, err := NewFileCheckpointStore(t.TempDir())
+			require.NoError(t, err)
+
+			first := &txRecorder{}
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: first.onTransaction,
+			}, WithCheckpointStore(store))
+			require.NoError(t, err)
+			server.waitSubscribed(mainChannel)
+			server.publishTransaction(mainChannel, "tx-1")
+			server.publishTransaction(mainChannel, "tx-2")
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+			require.Eventually(t, func() bool {
+				checkpoint, loadErr := store.LoadCheckpoint(context.Background(), testSubscriptionID)
+				return loadErr == nil && checkpoint.Position != nil
+			}, 5*time.Second, 10*time.Millisecond)
+			require.NoError(t, subscription.Unsubscribe())
+			assert.Equal(t, []string{"tx-1", "tx-2"}, first.received())
+
+			checkpoint, err := store.LoadCheckpoint(context.Background(), testSubscriptionID)
+			require.NoError(t, err)
+			assert.Equal(t, Checkpoint{Block: 100, Position: &StreamPosition{
+				Channel: mainChannel, Offset: 2, Epoch: "junglebustest",
+			}}, checkpoint)
+
+			// published while the subscription was down, the server sends the whole block again
+			server.publishTransaction(mainChannel, "tx-3")
+			second := &txRecorder{}
+			subscription, err = server.newClient().Subscribe(context.Background(), testSubscriptionID, 0, EventHandler{
+				OnTransaction: second.onTransaction,
+			}, WithCheckpointStore(store), WithTxMiddleware(second.middleware))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			require.Eventually(t, func() bool {
+				return len(second.received()) > 0
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"tx-3"}, second.received())
+			assert.Equal(t, []string{"junglebustest"}, second.epochs)
+		})
+
+		t.Run("seeded position", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.ReplayHistory(true)
+			server.publishTransaction(mainChannel, "tx-1")
+			server.publishTransaction(mainChannel, "tx-2")
+
+			recorder := &txRecorder{}
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: recorder.onTransaction,
+			}, WithStreamPosition(StreamPosition{Channel: mainChannel, Offset: 1, Epoch: "junglebustest"}))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			require.Eventually(t, func() bool {
+				return len(recorder.received()) > 0
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"tx-2"}, recorder.received())
+		})
+
+		t.Run("position no longer available", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.ReplayHistory(true)
+			server.SetEpoch("restarted")
+			server.publishTransaction(mainChannel, "tx-1")
+
+			recorder := &txRecorder{}
+			statuses := &statusRecorder{}
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: recorder.onTransaction,
+				OnStatus:      statuses.onStatus,
+			}, WithStreamPosition(StreamPosition{Channel: mainChannel, Offset: 1, Epoch: "junglebustest"}))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			require.Eventually(t, func() bool {
+				return len(recorder.received()) > 0
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"tx-1"}, recorder.received())
+			assert.True(t, statuses.has(StatusStreamReset))
+		})
+
+		t.Run("invalid position", func(t *testing.T) {
+			server := newFakeServer(t)
+			_, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+			}, WithStreamPosition(StreamPosition{Channel: "query:other-subscription:100", Offset: 1}))
+			assert.ErrorIs(t, err, ErrInvalidStreamPosition)
+			assert.Empty(t, server.dialTimes())
+		})
+	})
+}
@@ -3,267 +3,1319 @@ package junglebus
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/sinks"
 	"github.com/centrifugal/centrifuge-go"
 	"google.golang.org/protobuf/proto"
 )
 
+const (
+	channelControl = "control"
+	channelMain    = "main"
+	channelMempool = "mempool"
+	liteSuffix     = ":lite" // suffix of the main and mempool channels in lite mode
+
+	// error codes of centrifuge for connections rejected for a missing or expired token
+	unauthorizedCode = 101
+	tokenExpiredCode = 109
+)
+
+// Checkpoint is a position in the stream of a subscription, a page is a part of a block
+//
+// Position is the stream position of the last publication received on the main channel before the checkpoint was
+// reached, nil when unknown. When the main channel resumes on the channel of the position, the publications up to
+// and including its offset are skipped, so no transaction is passed on twice. When the server no longer holds the
+// position in the history of the channel, a StatusStreamReset status is sent and the block or page is passed on
+// again as a whole.
+type Checkpoint struct {
+	Block    uint64          `json:"block"`
+	Page     uint64          `json:"page"`
+	Position *StreamPosition `json:"position,omitempty"`
+}
+
 type Subscription struct {
-	SubscriptionID   string
-	FromBlock        uint64
-	EventHandler     EventHandler
-	client           *Client
-	centrifugeClient *centrifuge.Client
-	subscriptions    map[string]*centrifuge.Subscription
+	counters           subscriptionCounters // first for 64-bit alignment
+	checkpoint         atomic.Value         // Checkpoint
+	SubscriptionID     string
+	FromBlock          uint64
+	EventHandler       EventHandler
+	client             *Client
+	subscribing        bool                                // Subscribe has not returned yet, guarded by the subscriptions mutex of the client
+	centrifugeClient   *centrifuge.Client                  // the current connection, nil while reconnecting
+	subscriptions      map[string]*centrifuge.Subscription // the channels of the current connection
+	extraChannels      map[string]func(data []byte)        // the channels of WithExtraChannel by their full name
+	mu                 sync.Mutex
+	ctx                context.Context
+	done               chan struct{}
+	doneOnce           sync.Once
+	finished           chan struct{}
+	finishOnce         sync.Once
+	err                error
+	reconnects         int             // failed connection attempts since the last time it was connected
+	lastConnectErr     error           // the error of the last failed connection attempt, reported when giving up
+	connection         uint32          // replaced by Reconnect, queued publications of older ones are discarded
+	connectWaiters     []chan struct{} // signalled when connected, see Reconnect
+	checkpointStore    CheckpointStore
+	pageResume         bool
+	checkpointDone     int32           // 1 when the block of the checkpoint is done, see saveCheckpoint
+	position           atomic.Value    // StreamPosition of the last publication received on the main channel
+	seedPosition       *StreamPosition // the position of WithStreamPosition
+	untilBlock         uint64
+	mempoolOnly        bool   // subscribed with SubscribeMempool
+	noGapRepair        bool   // fail instead of fetching the blocks the server no longer streams, see repairGap
+	tokenExpired       int32  // 1 when the last connection was rejected for an expired token
+	failedOver         int32  // 1 when the client failed over since the last token, see WithServers
+	refreshedToken     string // the last token of the token provider, empty for the token of the transport
+	tokenTimer         *time.Timer
+	validate           bool
+	panicRecovery      bool
+	queue              *messageQueue // nil when the event handler is called synchronously
+	queueDone          chan struct{}
+	queueSize          int
+	handlerConcurrency int
+	overflowPolicy     OverflowPolicy
+	handlerWorkers     chan struct{} // nil when transactions are handled one at a time
+	blockHandlers      sync.WaitGroup
+	batch              []*models.TransactionResponse // mined transactions collected for OnBlock
+	batchMu            sync.Mutex
+	maxBatchSize       int
+	strictOrdering     bool
+	orderingSize       int
+	ordering           *orderingBuffer // nil without WithStrictOrdering
+	consumersMu        sync.Mutex
+	consumers          map[string]*Consumer // by name, see NewConsumer
+	lag                lagTracker
+	lagThreshold       uint64 // blocks, see WithLagWarning
+	lagDuration        time.Duration
+	stallTimeout       time.Duration // 0 when stalls are not detected
+	progress           progressTracker
+	progressInterval   time.Duration // 0 without OnProgress calls
+	tipInterval        time.Duration // the minimum time between fetching the chain tip for Progress
+	filter             Filter        // nil when all transactions are passed on
+	txMiddleware       []TxMiddleware
+	dedup              *dedupCache // nil without WithDedup
+	dedupSize          int
+	dedupTTL           time.Duration
+	mempoolTracker     *mempoolTracker // nil without WithMempoolTracking
+	txHandler          TxHandler       // calls the event handler through txMiddleware, nil without middlewares
+	waiting            int32           // 1 while the server is waiting for the next block
+	live               int32           // 1 once the server waited for the next block, see TxContext.IsHistorical
+	caughtUp           int32           // 1 while caught up with the chain tip, see IsCaughtUp
+	rearmed            int32           // 1 when the subscription reconnected since it caught up, see rearmCaughtUp
+	rearmBlock         uint32          // the block the subscription reconnected at
+	lastPage           atomic.Value    // the pageMark of the last control message received
+	messageContext     bool            // whether OnTransactionCtx or OnMempoolCtx are set, see withMessageContext
+	txContexts         sync.Map        // the TxContext of the transactions on their way to the event handler
+	lite               bool            // whether the main and mempool channels stream transactions without raw bytes
+	pooled             bool            // whether transactions come from the pool of models.AcquireTransactionResponse
+	reportStatus       bool            // whether the event handler has an OnStatus callback, see sendStatus
+	draining           int32           // 1 once Shutdown stopped accepting publications
+	handlerRetry       handlerRetryPolicy
+	spoolDir           string // empty without WithDiskSpool
+	spoolMaxBytes      int64
+	spool              *diskSpool // nil without WithDiskSpool
+	sink               sinks.Sink // nil without WithSink
+}
+
+// LastBlock returns the last block reported on the control channel, or the starting block before any was reported
+func (s *Subscription) LastBlock() uint64 {
+	return s.Checkpoint().Block
+}
+
+// LastPage returns the page of the last block reported on the control channel
+func (s *Subscription) LastPage() uint64 {
+	return s.Checkpoint().Page
 }
 
-func (s *Subscription) Unsubscribe() (err error) {
-	for _, sub := range s.subscriptions {
-		err = sub.Unsubscribe()
+// Checkpoint returns the block and page the subscription resumes from when reconnecting, the page is only resumed
+// from with WithPageResume
+func (s *Subscription) Checkpoint() Checkpoint {
+	checkpoint, _ := s.checkpoint.Load().(Checkpoint)
+	return checkpoint
+}
+
+// Unsubscribe unsubscribes from all channels and closes the connection, returning the failures of all channels
+// that could not be unsubscribed. The connection is closed and the subscription torn down regardless.
+func (s *Subscription) Unsubscribe() error {
+	if s == nil {
+		return ErrNotSubscribed
 	}
-	s.centrifugeClient.Close()
+	s.stop()
 
-	return err
+	var errs []error
+	s.mu.Lock()
+	// take over the connection, a concurrent Unsubscribe then has nothing left to tear down
+	centrifugeClient := s.centrifugeClient
+	s.centrifugeClient = nil
+	if centrifugeClient != nil {
+		for name, sub := range s.subscriptions {
+			if sub == nil {
+				continue
+			}
+			if err := sub.Unsubscribe(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to unsubscribe from %s channel: %w", name, err))
+			}
+		}
+	}
+	s.mu.Unlock()
+	if centrifugeClient != nil {
+		centrifugeClient.Close()
+	}
+	s.finish(nil)
+
+	return joinErrors(errs)
 }
 
-func (jb *Client) Unsubscribe() (err error) {
-	for _, sub := range jb.subscription.subscriptions {
-		if err = sub.Unsubscribe(); err != nil {
-			return err
+// streamsBlocks returns whether the subscription receives mined transactions, a subscription of only the mempool
+// has no block to resume at
+func (s *Subscription) streamsBlocks() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.subscriptions[channelMain]
+	return ok
+}
+
+// UnsubscribeMain stops receiving mined transactions, leaving the other channels and the connection up
+func (s *Subscription) UnsubscribeMain() error {
+	return s.unsubscribeChannel(channelMain)
+}
+
+// UnsubscribeMempool stops receiving mempool transactions, leaving the other channels and the connection up
+func (s *Subscription) UnsubscribeMempool() error {
+	return s.unsubscribeChannel(channelMempool)
+}
+
+// unsubscribeChannel unsubscribes from a single channel, unsubscribing a channel twice is a no-op
+func (s *Subscription) unsubscribeChannel(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[name]
+	if !ok {
+		return nil
+	}
+	if s.centrifugeClient == nil {
+		// reconnecting, the next connection leaves out the channel
+		delete(s.subscriptions, name)
+		return nil
+	}
+	if err := sub.Unsubscribe(); err != nil {
+		return fmt.Errorf("failed to unsubscribe %s from %s channel: %w", s.SubscriptionID, name, err)
+	}
+	delete(s.subscriptions, name)
+
+	if err := s.centrifugeClient.RemoveSubscription(sub); err != nil {
+		return fmt.Errorf("failed to remove %s channel of %s: %w", name, s.SubscriptionID, err)
+	}
+	return nil
+}
+
+// isCurrent returns whether the centrifuge client is the current connection of the subscription
+func (s *Subscription) isCurrent(centrifugeClient *centrifuge.Client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.centrifugeClient == centrifugeClient
+}
+
+// Unsubscribe stops the subscriptions with the given IDs, or all active subscriptions when no ID is given,
+// returning the failures of all of them. ErrNotSubscribed is returned for IDs that are not active, or when no
+// subscription is active at all.
+func (jb *Client) Unsubscribe(subscriptionIDs ...string) error {
+	if jb == nil {
+		return ErrNotSubscribed
+	}
+
+	var errs []error
+	jb.subscriptionsMu.Lock()
+	if len(subscriptionIDs) == 0 {
+		if len(jb.subscriptions) == 0 {
+			jb.subscriptionsMu.Unlock()
+			return ErrNotSubscribed
+		}
+		for subscriptionID := range jb.subscriptions {
+			subscriptionIDs = append(subscriptionIDs, subscriptionID)
 		}
 	}
+	subscriptions := make([]*Subscription, 0, len(subscriptionIDs))
+	for _, subscriptionID := range subscriptionIDs {
+		if subscription, ok := jb.subscriptions[subscriptionID]; ok {
+			subscriptions = append(subscriptions, subscription)
+		} else {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrNotSubscribed, subscriptionID))
+		}
+	}
+	jb.subscriptionsMu.Unlock()
 
-	jb.subscription.centrifugeClient.Close()
-	jb.subscription = nil
+	for _, subscription := range subscriptions {
+		if err := subscription.Unsubscribe(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unsubscribe %s: %w", subscription.SubscriptionID, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
 
+// GetSubscription returns the active subscription with the given ID, or nil when not subscribed
+func (jb *Client) GetSubscription(subscriptionID string) *Subscription {
+	jb.subscriptionsMu.Lock()
+	defer jb.subscriptionsMu.Unlock()
+	return jb.subscriptions[subscriptionID]
+}
+
+// addSubscription registers the subscription as active, failing when its ID is already in use
+func (jb *Client) addSubscription(s *Subscription) error {
+	jb.subscriptionsMu.Lock()
+	defer jb.subscriptionsMu.Unlock()
+	if active, ok := jb.subscriptions[s.SubscriptionID]; ok {
+		if active.subscribing {
+			return fmt.Errorf("%w: %s", ErrSubscriptionInProgress, s.SubscriptionID)
+		}
+		return fmt.Errorf("%w: %s", ErrAlreadySubscribed, s.SubscriptionID)
+	}
+	s.subscribing = true
+	jb.subscriptions[s.SubscriptionID] = s
 	return nil
 }
 
-func (jb *Client) Subscribe(ctx context.Context, subscriptionID string, fromBlock uint64, eventHandler EventHandler) (*Subscription, error) {
+// subscribed marks the subscription as done subscribing, another Subscribe of its ID then returns
+// ErrAlreadySubscribed
+func (jb *Client) subscribed(s *Subscription) {
+	jb.subscriptionsMu.Lock()
+	defer jb.subscriptionsMu.Unlock()
+	s.subscribing = false
+}
 
-	var subs *Subscription
-	lastBlock := fromBlock
+// removeSubscription removes the subscription from the active subscriptions, if it is still registered
+func (jb *Client) removeSubscription(s *Subscription) {
+	jb.subscriptionsMu.Lock()
+	defer jb.subscriptionsMu.Unlock()
+	if jb.subscriptions[s.SubscriptionID] == s {
+		delete(jb.subscriptions, s.SubscriptionID)
+	}
+}
 
-	var err error
-	token := jb.transport.GetToken()
-	if token == "" {
-		// get a new subscription token to use for all requests
-		if token, err = jb.transport.GetSubscriptionToken(ctx, subscriptionID); err != nil {
+// onControl tracks the block and page progress of a control message and passes it on to the event handler
+// A reorg rolls the progress back to the start of the block of the message, a reconnect resumes from there
+func (s *Subscription) onControl(controlResponse *models.ControlResponse, position *StreamPosition) {
+	code := StatusCode(controlResponse.StatusCode)
+	if s.ordering != nil {
+		switch {
+		case code.IsBlockDone():
+			s.flushOrdered(controlResponse.Block)
+		case code.IsReorg():
+			s.discardOrdered(controlResponse.Block)
+		}
+	}
+	// a block is only done once all of its transactions have been handled
+	s.waitBlock()
+	if s.EventHandler.OnBlock != nil {
+		switch {
+		case code.IsBlockDone():
+			s.flushBatch()
+		case code.IsReorg():
+			s.discardBatch()
+		}
+	}
+
+	switch {
+	case code.IsReorg():
+		atomic.StoreInt32(&s.checkpointDone, 0)
+		s.checkpoint.Store(Checkpoint{Block: uint64(controlResponse.Block)})
+		s.client.observeReorg(controlResponse.Block)
+	case controlResponse.Block > 0:
+		done := int32(0)
+		if code.IsBlockDone() {
+			done = 1
+		}
+		atomic.StoreInt32(&s.checkpointDone, done)
+		s.checkpoint.Store(Checkpoint{Block: uint64(controlResponse.Block), Page: controlResponse.Page, Position: position})
+	}
+
+	caughtUp := s.trackCaughtUp(controlResponse)
+	if code.IsBlockDone() {
+		now := time.Now()
+		atomic.StoreInt64(&s.counters.lastBlockTime, now.UnixNano())
+		s.progress.blockDone(uint64(controlResponse.Block), now)
+	}
+	if code.IsBlockDone() && s.checkpointStore != nil {
+		checkpoint := s.Checkpoint()
+		if err := s.saveCheckpoint(s.ctx, checkpoint); err != nil {
+			s.log(levelWarn, "saving checkpoint failed", "block", checkpoint.Block, "error", err)
+			s.EventHandler.OnError(err)
+		}
+	}
+
+	switch {
+	case code.IsBlockDone() && s.EventHandler.OnBlockDone != nil:
+		s.EventHandler.OnBlockDone(controlResponse.Block, controlResponse.Transactions)
+	case code.IsReorg() && s.EventHandler.OnReorg != nil:
+		s.EventHandler.OnReorg(controlResponse.Block)
+	default:
+		s.EventHandler.OnStatus(controlResponse)
+	}
+	if caughtUp {
+		s.log(levelInfo, "caught up with the chain tip", "block", controlResponse.Block)
+		if s.EventHandler.OnCaughtUp != nil {
+			s.EventHandler.OnCaughtUp(controlResponse.Block)
+		}
+	}
+
+	s.lag.processed(controlResponse.Block, 0)
+	s.completeAt(controlResponse)
+}
+
+// completeAt stops a subscription with an until block once that block is done
+func (s *Subscription) completeAt(controlResponse *models.ControlResponse) {
+	if s.untilBlock > 0 && StatusCode(controlResponse.StatusCode).IsBlockDone() &&
+		uint64(controlResponse.Block) >= s.untilBlock {
+		_ = s.Unsubscribe()
+	}
+}
+
+// pastUntilBlock returns whether the transaction of the channel is mined after the block of WithUntilBlock
+func (s *Subscription) pastUntilBlock(name string, transaction *models.TransactionResponse) bool {
+	return name != channelMempool && s.untilBlock > 0 && uint64(transaction.BlockHeight) > s.untilBlock
+}
+
+// Done returns a channel that is closed when the subscription has been torn down, by Unsubscribe,
+// cancelling its context, reaching its until block or failing to reconnect
+func (s *Subscription) Done() <-chan struct{} {
+	return s.finished
+}
+
+// Err returns why the subscription was torn down, nil when it is still running, was unsubscribed or completed
+func (s *Subscription) Err() error {
+	select {
+	case <-s.finished:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the subscription has been torn down and returns Err
+func (s *Subscription) Wait() error {
+	<-s.finished
+	return s.err
+}
+
+// finish removes the torn down subscription from the client and closes the Done channel, err is the cause
+func (s *Subscription) finish(err error) {
+	s.client.removeSubscription(s)
+	s.closeConsumers()
+	s.finishOnce.Do(func() {
+		if s.sink != nil {
+			s.closeSink()
+		}
+		s.err = err
+		close(s.finished)
+	})
+}
+
+// stop signals the context watcher and the reconnects that the subscription has been torn down
+func (s *Subscription) stop() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// fail tears down the subscription because of an error its connection cannot recover from
+func (s *Subscription) fail(centrifugeClient *centrifuge.Client, err error) {
+	s.mu.Lock()
+	if s.centrifugeClient != centrifugeClient || s.isStopped() {
+		s.mu.Unlock()
+		return
+	}
+	s.centrifugeClient = nil
+	s.mu.Unlock()
+	s.stop()
+	centrifugeClient.Close()
+
+	s.waitQueue()
+	s.log(levelError, "subscription failed", "error", err)
+	s.EventHandler.OnError(err)
+	s.finish(err)
+}
+
+// isStopped returns whether the subscription has been torn down
+func (s *Subscription) isStopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// watchContext tears down the subscription when the context it was started with is cancelled
+func (s *Subscription) watchContext() {
+	select {
+	case <-s.done:
+		return
+	case <-s.ctx.Done():
+	}
+
+	// closing the client moves all channel subscriptions to unsubscribed without
+	// waiting on replies from a server we are no longer interested in
+	s.stop()
+	s.mu.Lock()
+	centrifugeClient := s.centrifugeClient
+	s.mu.Unlock()
+	if centrifugeClient != nil {
+		centrifugeClient.Close()
+	}
+	// the final status is the last call of the event handler
+	s.waitQueue()
+
+	s.sendStatus(s.EventHandler.OnStatus, StatusCancelled, "cancelled", func() string {
+		return "Subscription cancelled: " + s.ctx.Err().Error()
+	})
+	s.finish(s.ctx.Err())
+}
+
+// sendStatus passes a status of the subscription to onStatus and the sink of WithSink, the message is only built
+// when the event handler has an OnStatus callback or there is a sink
+func (s *Subscription) sendStatus(onStatus func(*models.ControlResponse), code StatusCode, status string,
+	message func() string) {
+
+	if !s.reportStatus && s.sink == nil {
+		return
+	}
+	response := &models.ControlResponse{
+		StatusCode: uint32(code),
+		Status:     status,
+		Message:    message(),
+	}
+	if s.sink != nil {
+		s.sinkStatus(response, s.EventHandler.OnError)
+	}
+	if s.reportStatus {
+		onStatus(response)
+	}
+}
+
+// Subscribe starts streaming the transactions of the given subscription from fromBlock to the event handler.
+// Cancelling ctx unsubscribes, closes the connection and sends a final StatusCancelled status.
+// A lost connection is re-established following the reconnect policy of the client, the returned
+// subscription stays valid across reconnects. An event handler without transaction handlers returns ErrNoHandlers,
+// see EventHandler.
+//
+// Subscribe and Unsubscribe are safe for concurrent use. Subscribing to an ID that is already active returns
+// ErrAlreadySubscribed, or ErrSubscriptionInProgress while another Subscribe of the ID has not returned yet.
+// Unsubscribing while Subscribe of the ID has not returned tears the subscription down, Subscribe then returns
+// the subscription with its Done channel closed.
+func (jb *Client) Subscribe(ctx context.Context, subscriptionID string, fromBlock uint64, eventHandler EventHandler,
+	opts ...SubscribeOption) (*Subscription, error) {
+	return jb.SubscribeWithOptions(ctx, subscriptionID, eventHandler,
+		append([]SubscribeOption{WithFromBlock(fromBlock)}, opts...)...)
+}
+
+// SubscribeWithOptions starts streaming the transactions of the given subscription to the event handler, like
+// Subscribe, from the block of WithFromBlock. The options are validated before connecting, an invalid combination
+// returns an error like ErrInvalidUntilBlock without any request being made.
+func (jb *Client) SubscribeWithOptions(ctx context.Context, subscriptionID string, eventHandler EventHandler,
+	opts ...SubscribeOption) (*Subscription, error) {
+
+	subs, eventHandler, err := jb.newSubscription(ctx, subscriptionID, eventHandler, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if subs.validate {
+		if _, err := jb.GetSubscriptionDetails(ctx, subscriptionID); err != nil {
+			return nil, err
+		}
+	}
+
+	if subs.checkpointStore != nil {
+		checkpoint, err := subs.loadCheckpoint(ctx)
+		switch {
+		case err == nil:
+			subs.FromBlock = checkpoint.Block
+			subs.checkpoint.Store(checkpoint)
+		case !errors.Is(err, ErrCheckpointNotFound):
 			return nil, err
 		}
+	}
+	if subs.checkpoint.Load() == nil {
+		subs.checkpoint.Store(Checkpoint{Block: subs.FromBlock})
+	}
+	if subs.seedPosition != nil {
+		checkpoint := subs.Checkpoint()
+		checkpoint.Position = subs.seedPosition
+		subs.checkpoint.Store(checkpoint)
+	}
+	if subs.spoolDir != "" {
+		spool, err := openDiskSpool(subs.spoolDir, subscriptionID, subs.spoolMaxBytes)
+		var corruptionErr *SpoolCorruptionError
+		switch {
+		case errors.As(err, &corruptionErr):
+			subs.log(levelWarn, "spool corrupted", "offset", corruptionErr.Offset, "truncated", corruptionErr.Truncated)
+			subs.EventHandler.OnError(err)
+		case err != nil:
+			return nil, err
+		}
+		subs.spool = spool
+	}
+	if eventHandler.OnTransaction != nil || eventHandler.OnBlock != nil {
+		subs.subscriptions[channelMain] = nil
+	}
+	if eventHandler.OnMempool != nil {
+		subs.subscriptions[channelMempool] = nil
+	}
+	for channel := range subs.extraChannels {
+		subs.subscriptions[channel] = nil
+	}
+
+	if err := jb.addSubscription(subs); err != nil {
+		if subs.spool != nil {
+			_ = subs.spool.close()
+		}
+		return nil, err
+	}
+	if subs.queue != nil {
+		go subs.handleQueue()
+	}
+
+	if !jb.noAuth && (jb.tokenProvider != nil || jb.transport.GetToken() == "") {
+		// get a new subscription token to use for all requests
+		token, err := subs.subscriptionToken()
+		if err != nil {
+			subs.stop()
+			jb.removeSubscription(subs)
+			return nil, &SubscribeError{Kind: ErrTokenFetch, SubscriptionID: subscriptionID, Err: err}
+		}
 		if token != "" {
 			jb.transport.SetToken(token)
 		}
 	}
 
-	protocol := "wss"
-	if !jb.transport.IsSSL() {
-		protocol = "ws"
+	if err := subs.connect(); err != nil {
+		subs.stop()
+		jb.removeSubscription(subs)
+		return nil, err
+	}
+
+	go subs.watchContext()
+	if subs.stallTimeout > 0 {
+		go subs.watchStalls()
+	}
+	if subs.mempoolTracker != nil && subs.mempoolTracker.ttl > 0 {
+		go subs.watchMempool()
+	}
+	if subs.progressInterval > 0 && subs.EventHandler.OnProgress != nil {
+		go subs.watchProgress()
 	}
-	url := fmt.Sprintf("%s://%s/connection/websocket?format=protobuf", protocol, jb.transport.GetServerURL())
-	centrifugeClient := centrifuge.NewProtobufClient(url, centrifuge.Config{
-		Token: token,
-		GetToken: func(event centrifuge.ConnectionTokenEvent) (string, error) {
-			return jb.transport.RefreshToken(ctx)
+	if subs.lagDuration > 0 {
+		go subs.watchLag()
+	}
+	jb.subscribed(subs)
+
+	return subs, nil
+}
+
+// newSubscription returns the subscription with its options applied and validated and its event handler wrapped,
+// along with the event handler deciding which channels are subscribed to. Nothing is started yet.
+func (jb *Client) newSubscription(ctx context.Context, subscriptionID string, eventHandler EventHandler,
+	opts []SubscribeOption) (*Subscription, EventHandler, error) {
+
+	subs := &Subscription{
+		SubscriptionID: subscriptionID,
+		EventHandler:   eventHandler,
+		client:         jb,
+		subscriptions:  map[string]*centrifuge.Subscription{channelControl: nil},
+		ctx:            ctx,
+		done:           make(chan struct{}),
+		finished:       make(chan struct{}),
+		panicRecovery:  true,
+		maxBatchSize:   DefaultMaxBatchSize,
+		orderingSize:   DefaultOrderingBufferSize,
+		tipInterval:    DefaultProgressTipInterval,
+		handlerRetry: handlerRetryPolicy{
+			attempts: DefaultHandlerRetryAttempts,
+			minDelay: DefaultHandlerRetryMinDelay,
+			maxDelay: DefaultHandlerRetryMaxDelay,
 		},
-		Name:               "go-junglebus",
-		ReadTimeout:        30 * time.Second,
-		WriteTimeout:       2 * time.Second,
-		HandshakeTimeout:   30 * time.Second,
-		MaxServerPingDelay: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(subs)
+	}
+	if err := subs.validateOptions(); err != nil {
+		return nil, eventHandler, err
+	}
+	if subs.dedupSize > 0 || subs.dedupTTL > 0 {
+		if subs.dedupSize <= 0 {
+			subs.dedupSize = DefaultDedupSize
+		}
+		subs.dedup = newDedupCache(subs.dedupSize, subs.dedupTTL)
+		subs.EventHandler = subs.withDedup(subs.EventHandler)
+		eventHandler = subs.EventHandler
+	}
+	if subs.EventHandler.OnTransactionE != nil || subs.EventHandler.OnMempoolE != nil {
+		subs.EventHandler = subs.withRetry(subs.EventHandler)
+		eventHandler = subs.EventHandler
+	}
+	if subs.EventHandler.OnTransactionCtx != nil || subs.EventHandler.OnMempoolCtx != nil {
+		subs.EventHandler = subs.withMessageContext(subs.EventHandler)
+		eventHandler = subs.EventHandler
+	}
+	if subs.mempoolTracker != nil {
+		// the tracker passes transactions to OnConfirmed after they were handled
+		subs.pooled = false
+		// both channels are needed to see transactions being mined
+		if subs.EventHandler.OnTransaction == nil && subs.EventHandler.OnBlock == nil {
+			subs.EventHandler.OnTransaction = func(*models.TransactionResponse) {}
+		}
+		if subs.EventHandler.OnMempool == nil {
+			subs.EventHandler.OnMempool = func(*models.TransactionResponse) {}
+		}
+		eventHandler = subs.EventHandler
+	}
+	if eventHandler.OnTransaction == nil && eventHandler.OnBlock == nil && eventHandler.OnMempool == nil {
+		return nil, eventHandler, ErrNoHandlers
+	}
+	// statuses of the connection are only built for an OnStatus callback, control messages are still logged
+	subs.reportStatus = subs.EventHandler.OnStatus != nil
+	if !subs.reportStatus {
+		subs.EventHandler.OnStatus = func(*models.ControlResponse) {}
+	}
+	onError := eventHandler.OnError
+	if onError == nil {
+		// without an OnError callback the errors are logged rather than dropped
+		onError = func(err error) {
+			subs.log(levelError, "subscription error", "error", err)
+		}
+	}
+	subs.EventHandler.OnError = subs.countErrors(onError)
+	subs.EventHandler = subs.withTracing(subs.EventHandler)
+	if subs.panicRecovery {
+		subs.EventHandler = subs.withRecovery(subs.EventHandler)
+	}
+	if subs.handlerConcurrency > 1 {
+		subs.handlerWorkers = make(chan struct{}, subs.handlerConcurrency)
+		subs.EventHandler = subs.withConcurrency(subs.EventHandler)
+	}
+	if eventHandler.OnBlock != nil {
+		subs.EventHandler.OnTransaction = subs.addToBatch
+	}
+	if subs.strictOrdering && subs.EventHandler.OnTransaction != nil {
+		subs.ordering = &orderingBuffer{size: subs.orderingSize, next: subs.EventHandler.OnTransaction}
+		subs.EventHandler.OnTransaction = subs.orderTransaction
+	}
+	if len(subs.txMiddleware) > 0 {
+		subs.txHandler = subs.withTxMiddleware(subs.txMiddleware)
+	}
+	if subs.spoolDir != "" && subs.queueSize <= 0 {
+		subs.queueSize = DefaultSpoolQueueSize
+	}
+	if subs.queueSize > 0 {
+		subs.queue = newMessageQueue(subs.queueSize, subs.overflowPolicy, subs.drop)
+		subs.queueDone = make(chan struct{})
+	}
+	return subs, eventHandler, nil
+}
+
+// Run subscribes and blocks until the subscription has been torn down, returning why it stopped
+//
+// Cancelling ctx stops the subscription, Run then returns the context error.
+func (jb *Client) Run(ctx context.Context, subscriptionID string, fromBlock uint64, eventHandler EventHandler,
+	opts ...SubscribeOption) error {
+
+	subscription, err := jb.Subscribe(ctx, subscriptionID, fromBlock, eventHandler, opts...)
+	if err != nil {
+		return err
+	}
+	return subscription.Wait()
+}
+
+// SubscribeFromTip starts streaming the transactions of the given subscription from the current best block
+func (jb *Client) SubscribeFromTip(ctx context.Context, subscriptionID string, eventHandler EventHandler,
+	opts ...SubscribeOption) (*Subscription, error) {
+	return jb.SubscribeFromTipWithLookback(ctx, subscriptionID, 0, eventHandler, opts...)
+}
+
+// SubscribeFromTipWithLookback starts streaming the transactions of the given subscription from lookback
+// blocks before the current best block
+func (jb *Client) SubscribeFromTipWithLookback(ctx context.Context, subscriptionID string, lookback uint64,
+	eventHandler EventHandler, opts ...SubscribeOption) (*Subscription, error) {
+
+	tip, err := jb.GetChainTip(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromBlock uint64
+	if height := uint64(tip.Height); height > lookback {
+		fromBlock = height - lookback
+	}
+
+	return jb.Subscribe(ctx, subscriptionID, fromBlock, eventHandler, opts...)
+}
+
+// SubscribeMempool starts streaming only the mempool transactions of the given subscription to the handler, without
+// a block to start from or a historical stream: the connection subscribes to the mempool and control channels only.
+// No mined transactions, block done or reorg events arrive, and there is no checkpoint to resume from, a reconnect
+// picks up the mempool from where the server is. Options like WithQueueSize or WithFilter apply as with
+// SubscribeWithOptions, WithUntilBlock fails with ErrInvalidUntilBlock. WithMempoolTracking subscribes to the main
+// channel as well, to see the transactions being mined.
+func (jb *Client) SubscribeMempool(ctx context.Context, subscriptionID string,
+	handler func(tx *models.TransactionResponse), opts ...SubscribeOption) (*Subscription, error) {
+
+	if handler == nil {
+		return nil, ErrNoHandlers
+	}
+	opts = append(opts, func(s *Subscription) {
+		s.mempoolOnly = true
 	})
+	return jb.SubscribeWithOptions(ctx, subscriptionID, EventHandler{OnMempool: handler}, opts...)
+}
+
+// connect opens a new connection for the channels of the subscription, replacing the current connection
+func (s *Subscription) connect() error {
+	if err := s.repairGap(); err != nil {
+		return err
+	}
+
+	jb := s.client
+	ctx := s.ctx
+	eventHandler := s.dispatched()
+
+	// status logs the connection event and passes it on to the event handler
+	status := func(level logLevel, code StatusCode, message func() string, keyvals ...interface{}) {
+		s.log(level, code.String(), append(keyvals, "status_code", uint32(code))...)
+		s.sendStatus(eventHandler.OnStatus, code, code.String(), message)
+	}
+
+	endpoint := jb.websocketEndpoint()
+	// failures of the connection count towards failing over, unless it is made to the url of WithWebsocketURL
+	serverIndex := jb.failover.current()
+	failover := jb.failover.enabled() && jb.websocketURL == nil
+	// a connection rejected for an expired token is replaced by one with a new token
+	var token string
+	if !jb.noAuth {
+		token = s.token()
+	}
+	if atomic.CompareAndSwapInt32(&s.tokenExpired, 1, 0) {
+		var err error
+		if token, err = s.refreshToken(); err != nil {
+			atomic.StoreInt32(&s.tokenExpired, 1)
+			return err
+		}
+	}
+	config := centrifuge.Config{
+		Token:              token,
+		Name:               "go-junglebus",
+		ReadTimeout:        jb.websocket.Read,
+		WriteTimeout:       jb.websocket.Write,
+		HandshakeTimeout:   jb.websocket.Handshake,
+		MaxServerPingDelay: jb.websocket.MaxServerPingDelay,
+		TLSConfig:          jb.tlsConfig,
+		EnableCompression:  jb.compression,
+		Header:             jb.headers.Clone(),
+	}
+	if !jb.noAuth {
+		config.GetToken = func(event centrifuge.ConnectionTokenEvent) (string, error) {
+			token, err := s.refreshToken()
+			if err != nil {
+				eventHandler.OnError(err)
+			}
+			return token, err
+		}
+	}
+	if config.Header == nil {
+		config.Header = http.Header{}
+	}
+	config.Header.Set("User-Agent", jb.userAgent)
+	if jb.proxy != nil {
+		websocketURL, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		config.NetDialContext = dialThroughProxy(jb.proxy, http.ProxyFromEnvironment, websocketURL)
+	}
+	var centrifugeClient *centrifuge.Client
+	if jb.jsonProtocol {
+		centrifugeClient = centrifuge.NewJsonClient(endpoint, config)
+	} else {
+		centrifugeClient = centrifuge.NewProtobufClient(endpoint, config)
+	}
+
+	// callbacks of a replaced connection are ignored, connected is only used in the callbacks of this client
+	current := func() bool {
+		return ctx.Err() == nil && !s.isStopped() && s.isCurrent(centrifugeClient)
+	}
+	connected := false
 
 	centrifugeClient.OnConnecting(func(e centrifuge.ConnectingEvent) {
-		// are we reconnecting?
-		if jb.subscription != nil {
-			eventHandler.OnStatus(&models.ControlResponse{
-				StatusCode: uint32(StatusConnecting),
-				Status:     "reconnecting",
-				Message:    "Reconnecting to server at block " + strconv.FormatUint(lastBlock, 10),
-			})
-			_ = jb.Unsubscribe()
-			_, _ = jb.Subscribe(ctx, subscriptionID, lastBlock, eventHandler)
+		if !current() {
 			return
 		}
 
-		jb.subscription = subs
+		// the connection was lost, replace it following the reconnect policy of the client
+		if connected {
+			s.reconnect(centrifugeClient)
+			return
+		}
 
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusConnecting),
-			Status:     "connecting",
-			Message:    "Connecting to server",
-		})
+		status(levelInfo, StatusConnecting, func() string { return "Connecting to server" }, "block", s.LastBlock())
 	})
 
 	centrifugeClient.OnConnected(func(e centrifuge.ConnectedEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusConnected),
-			Status:     "connected",
-			Message:    "Connected to server",
-		})
+		if !current() {
+			return
+		}
+		connected = true
+		s.mu.Lock()
+		s.reconnects = 0
+		s.lastConnectErr = nil
+		for _, wait := range s.connectWaiters {
+			signal(wait)
+		}
+		s.mu.Unlock()
+		if failover {
+			jb.failover.succeeded(serverIndex)
+		}
+		s.touch()
+		s.scheduleTokenRefresh(centrifugeClient)
+		s.dispatch(s.rearmCaughtUp)
+		status(levelInfo, StatusConnected, func() string { return "Connected to server" })
 	})
 
 	centrifugeClient.OnDisconnected(func(e centrifuge.DisconnectedEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusDisconnected),
-			Status:     "disconnected",
-			Message:    "Disconnected from server",
-		})
+		if !current() {
+			return
+		}
+		status(levelInfo, StatusDisconnected, func() string { return "Disconnected from server" })
 	})
 
 	centrifugeClient.OnError(func(e centrifuge.ErrorEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusError),
-			Status:     "error",
-			Message:    e.Error.Error(),
-		})
+		if !current() {
+			return
+		}
+		status(levelError, StatusError, func() string { return e.Error.Error() }, "error", e.Error)
+
+		// a failed connection attempt is retried with the reconnect policy of the client
+		var transportErr centrifuge.TransportError
+		var connectErr centrifuge.ConnectError
+		var refreshErr centrifuge.RefreshError
+		isTransportErr := errors.As(e.Error, &transportErr)
+		if isTransportErr && failover {
+			jb.serverFailed(serverIndex)
+		}
+		if isTransportErr || errors.As(e.Error, &connectErr) || errors.As(e.Error, &refreshErr) {
+			s.setConnectErr(e.Error)
+			var serverErr *centrifuge.Error
+			if errors.As(connectErr.Err, &serverErr) {
+				switch {
+				case serverErr.Code == unauthorizedCode && jb.noAuth:
+					// connecting again without a token does not help
+					go s.fail(centrifugeClient, &SubscribeError{
+						Kind:           ErrConnect,
+						SubscriptionID: s.SubscriptionID,
+						Endpoint:       endpoint,
+						Err:            fmt.Errorf("%w: %s", ErrAuthRequired, serverErr.Message),
+					})
+					return
+				case serverErr.Code == tokenExpiredCode && !jb.noAuth:
+					atomic.StoreInt32(&s.tokenExpired, 1)
+				}
+			}
+			s.reconnect(centrifugeClient)
+		}
 	})
 
 	centrifugeClient.OnMessage(func(e centrifuge.MessageEvent) {
-		log.Printf("Message from server: %s", string(e.Data))
+		if logEnabled(jb.logger, levelDebug) {
+			s.log(levelDebug, "message", "bytes", len(e.Data))
+		}
 	})
 
 	centrifugeClient.OnSubscribed(func(e centrifuge.ServerSubscribedEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusSubscribed),
-			Status:     "subscribed",
-			Message:    "Subscribed to " + e.Channel,
-		})
+		if !current() {
+			return
+		}
+		status(levelInfo, StatusSubscribed, func() string { return "Subscribed to " + e.Channel }, "channel", e.Channel)
 	})
 
 	centrifugeClient.OnSubscribing(func(e centrifuge.ServerSubscribingEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusSubscribing),
-			Status:     "subscribing",
-			Message:    "Subscribing to " + e.Channel,
-		})
+		if !current() {
+			return
+		}
+		status(levelInfo, StatusSubscribing, func() string { return "Subscribing to " + e.Channel }, "channel", e.Channel)
 	})
 
 	centrifugeClient.OnUnsubscribed(func(e centrifuge.ServerUnsubscribedEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusUnsubscribed),
-			Status:     "unsubscribed",
-			Message:    "Unsubscribed from " + e.Channel,
-		})
+		if !current() {
+			return
+		}
+		status(levelInfo, StatusUnsubscribed, func() string { return "Unsubscribed from " + e.Channel }, "channel", e.Channel)
 	})
 
 	centrifugeClient.OnPublication(func(e centrifuge.ServerPublicationEvent) {
-		log.Printf("Publication from server-side channel %s: %s (offset %d)", e.Channel, e.Data, e.Offset)
-		var transaction *models.TransactionResponse
-		if strings.Contains(e.Channel, ":control") {
-			var control *models.ControlResponse
-			if err = json.Unmarshal(e.Data, &control); err != nil {
-				eventHandler.OnError(err)
-			} else {
-				eventHandler.OnStatus(control)
-			}
-		} else if strings.Contains(e.Channel, ":mempool") {
-			if err = json.Unmarshal(e.Data, &transaction); err != nil {
-				eventHandler.OnError(err)
-			} else {
-				eventHandler.OnMempool(transaction)
-			}
-		} else {
-			if err = json.Unmarshal(e.Data, &transaction); err != nil {
-				eventHandler.OnError(err)
-			} else {
-				eventHandler.OnTransaction(transaction)
-			}
+		if !current() || !s.acceptPublication() {
+			return
 		}
+		defer s.donePublication()
+		s.touch()
+		s.onServerPublication(eventHandler, e.Channel, e.Offset, e.Data)
 	})
 
 	centrifugeClient.OnJoin(func(e centrifuge.ServerJoinEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusJoin),
-			Status:     "join",
-			Message:    "Joined " + e.Channel,
-		})
+		if !current() {
+			return
+		}
+		status(levelDebug, StatusJoin, func() string { return "Joined " + e.Channel }, "channel", e.Channel)
 	})
 
 	centrifugeClient.OnLeave(func(e centrifuge.ServerLeaveEvent) {
-		eventHandler.OnStatus(&models.ControlResponse{
-			StatusCode: uint32(StatusLeave),
-			Status:     "leave",
-			Message:    "Left " + e.Channel,
-		})
+		if !current() {
+			return
+		}
+		status(levelDebug, StatusLeave, func() string { return "Left " + e.Channel }, "channel", e.Channel)
 	})
 
-	subs = &Subscription{
-		SubscriptionID:   subscriptionID,
-		FromBlock:        fromBlock,
-		EventHandler:     eventHandler,
-		client:           jb,
-		centrifugeClient: centrifugeClient,
-		subscriptions:    map[string]*centrifuge.Subscription{},
+	s.mu.Lock()
+	if s.isStopped() {
+		s.mu.Unlock()
+		centrifugeClient.Close()
+		return nil
+	}
+	subscriptions := make(map[string]*centrifuge.Subscription, len(s.subscriptions))
+	for name := range s.subscriptions {
+		sub, err := s.newChannel(centrifugeClient, name, current)
+		if err != nil {
+			s.mu.Unlock()
+			centrifugeClient.Close()
+			return err
+		}
+		subscriptions[name] = sub
 	}
+	s.centrifugeClient = centrifugeClient
+	s.subscriptions = subscriptions
+	s.mu.Unlock()
 
-	if subs.subscriptions["control"], err = subs.startSubscription(`query:` + subscriptionID + `:control`); err != nil {
-		return nil, err
+	// a failed connection attempt is reported through OnError and retried with the reconnect policy
+	_ = centrifugeClient.Connect()
+
+	return nil
+}
+
+// token returns the token to connect with, the last token of the token provider or the token of the transport
+func (s *Subscription) token() string {
+	s.mu.Lock()
+	token := s.refreshedToken
+	s.mu.Unlock()
+	if token == "" {
+		token = s.client.transport.GetToken()
 	}
-	subs.subscriptions["control"].OnPublication(func(e centrifuge.PublicationEvent) {
-		controlResponse := &models.ControlResponse{}
-		if err = proto.Unmarshal(e.Data, controlResponse); err != nil {
-			eventHandler.OnError(err)
-		} else {
-			lastBlock = uint64(controlResponse.Block)
-			eventHandler.OnStatus(controlResponse)
-		}
-	})
+	return token
+}
 
-	if eventHandler.OnTransaction != nil {
-		if subs.subscriptions["main"], err = subs.startSubscription(`query:` + subscriptionID + `:` + strconv.FormatUint(fromBlock, 10)); err != nil {
-			return nil, err
+// refreshToken gets a new token for the connection from the token provider, a failure is reported with a
+// StatusTokenRefreshFailed status
+func (s *Subscription) refreshToken() (string, error) {
+	provider := s.client.getTokenProvider()
+	failedOver := atomic.SwapInt32(&s.failedOver, 0) == 1
+	if failedOver && s.client.tokenProvider == nil {
+		// the token of the previous server is not refreshed, the new server issues a token of its own
+		provider = TokenProviderFunc(s.client.transport.GetSubscriptionToken)
+	}
+	token, err := provider.Token(s.ctx, s.SubscriptionID)
+	if err != nil {
+		if failedOver {
+			atomic.StoreInt32(&s.failedOver, 1)
 		}
-		transaction := &models.TransactionResponse{}
-		subs.subscriptions["main"].OnPublication(func(e centrifuge.PublicationEvent) {
-			if err = proto.Unmarshal(e.Data, transaction); err != nil {
-				eventHandler.OnError(err)
-			} else {
-				eventHandler.OnTransaction(transaction)
-			}
-		})
+		err = fmt.Errorf("%w: %v", ErrTokenRefresh, err)
+		s.log(levelError, "token refresh failed", "error", err)
+		s.sendStatus(s.dispatched().OnStatus, StatusTokenRefreshFailed, "token refresh failed", err.Error)
+		return "", err
 	}
 
-	if eventHandler.OnMempool != nil {
-		if subs.subscriptions["mempool"], err = subs.startSubscription(`query:` + subscriptionID + `:mempool`); err != nil {
-			return nil, err
+	s.mu.Lock()
+	s.refreshedToken = token
+	s.mu.Unlock()
+	s.saveToken(token)
+	return token, nil
+}
+
+// onServerPublication passes a publication of a server-side channel to the event handler, the channel is routed by
+// its exact name
+func (s *Subscription) onServerPublication(eventHandler EventHandler, channel string, offset uint64, data []byte) {
+	receivedAt := time.Now()
+	if logEnabled(s.client.logger, levelDebug) {
+		s.log(levelDebug, "publication", "channel", channel, "offset", offset, "bytes", len(data))
+	}
+	if eventHandler.OnRawPublication != nil {
+		eventHandler.OnRawPublication(channel, offset, data)
+	}
+	switch name, ok := s.channelName(channel); {
+	case !ok:
+		s.log(levelWarn, "publication on unknown channel", "channel", channel)
+		if eventHandler.OnUnknownChannel != nil {
+			eventHandler.OnUnknownChannel(channel, data)
+		} else {
+			eventHandler.OnError(&UnknownChannelError{Channel: channel, Data: data})
 		}
-		transaction := &models.TransactionResponse{}
-		subs.subscriptions["mempool"].OnPublication(func(e centrifuge.PublicationEvent) {
-			if err = proto.Unmarshal(e.Data, transaction); err != nil {
-				eventHandler.OnError(err)
-			} else {
-				eventHandler.OnMempool(transaction)
-			}
-		})
+	case name == channelControl:
+		control := &models.ControlResponse{}
+		if err := s.decodeServerPublication(data, control); err != nil {
+			s.log(levelError, "invalid publication", "channel", channel, "error", err)
+			eventHandler.OnError(&DecodeError{Channel: channel, Offset: offset, Data: data, Err: err})
+		} else {
+			s.receiveControl(channel, offset, receivedAt, control)
+		}
+	default:
+		transaction := s.newTransaction()
+		if err := s.decodeServerPublication(data, transaction); err != nil {
+			s.release(transaction)
+			s.log(levelError, "invalid publication", "channel", channel, "error", err)
+			eventHandler.OnError(&DecodeError{Channel: channel, Offset: offset, Data: data, Err: err})
+			return
+		}
+		s.receiveTransaction(eventHandler, name, TxContext{
+			Channel:    channel,
+			Offset:     offset,
+			ReceivedAt: receivedAt,
+		}, transaction)
 	}
+}
 
-	if err = centrifugeClient.Connect(); err != nil {
-		return nil, err
+// receiveControl counts a control message of the subscription and passes it on
+func (s *Subscription) receiveControl(channel string, offset uint64, receivedAt time.Time,
+	control *models.ControlResponse) {
+
+	s.markControl(control)
+	atomic.AddUint64(&s.counters.control, 1)
+	atomic.StoreInt64(&s.counters.lastControl, receivedAt.UnixNano())
+	s.log(levelDebug, "publication", "channel", channel, "block", control.Block, "status_code", control.StatusCode)
+	s.dispatchControl(channel, offset, receivedAt, control)
+}
+
+// receiveTransaction counts a transaction of the channel and passes it on, unless it is filtered out, mined after the
+// block of WithUntilBlock or a duplicate. The mempool, block, page and historical flag of the context are set here.
+func (s *Subscription) receiveTransaction(eventHandler EventHandler, name string, txCtx TxContext,
+	transaction *models.TransactionResponse) {
+
+	mempool := name == channelMempool
+	if mempool {
+		atomic.AddUint64(&s.counters.mempool, 1)
+	} else {
+		atomic.AddUint64(&s.counters.transactions, 1)
+	}
+	s.client.observeTransaction(transaction, mempool)
+	if s.filteredOut(transaction) || s.pastUntilBlock(name, transaction) || s.duplicate(name, transaction.Id) {
+		s.release(transaction)
+		return
 	}
+	txCtx.Mempool = mempool
+	txCtx.Block = transaction.BlockHeight
+	txCtx.Page = s.pageOf(transaction.BlockHeight)
+	txCtx.IsHistorical = s.isHistorical(mempool)
+	s.handleTransaction(eventHandler, txCtx, transaction)
+	s.trackMempool(eventHandler, transaction, mempool)
+}
 
-	for _, sub := range subs.subscriptions {
-		if err = sub.Subscribe(); err != nil {
-			return nil, err
+// newTransaction returns the transaction to decode a publication into, taken from the pool with WithPooledMessages
+func (s *Subscription) newTransaction() *models.TransactionResponse {
+	if s.pooled {
+		return models.AcquireTransactionResponse()
+	}
+	return &models.TransactionResponse{}
+}
+
+// release gives a transaction the handlers never received back to the pool with WithPooledMessages
+func (s *Subscription) release(transaction *models.TransactionResponse) {
+	s.forgetTxContext(transaction)
+	if s.pooled {
+		transaction.Release()
+	}
+}
+
+// filteredOut returns whether the filter of WithFilter drops the transaction, counting it
+func (s *Subscription) filteredOut(transaction *models.TransactionResponse) bool {
+	if s.filter == nil || s.filter(transaction) {
+		return false
+	}
+	atomic.AddUint64(&s.counters.filtered, 1)
+	if logEnabled(s.client.logger, levelDebug) {
+		s.log(levelDebug, "filtered out", "txid", transaction.Id)
+	}
+	return true
+}
+
+// decode decodes the payload of a publication into the message, using the protocol of the connection
+func (s *Subscription) decode(data []byte, message proto.Message) error {
+	if err := s.client.checkMessageSize(data); err != nil {
+		return err
+	}
+	var err error
+	if s.client.jsonProtocol {
+		err = json.Unmarshal(data, message)
+	} else {
+		err = proto.Unmarshal(data, message)
+	}
+	if err != nil {
+		return err
+	}
+	return s.client.inflate(message)
+}
+
+// decodeServerPublication decodes the payload of a server-side publication into the message, the payload is
+// protobuf on connections opened with format=protobuf but older servers publish JSON
+func (s *Subscription) decodeServerPublication(data []byte, message proto.Message) error {
+	if s.client.jsonProtocol {
+		return s.decode(data, message)
+	}
+	if err := s.client.checkMessageSize(data); err != nil {
+		return err
+	}
+	if protoErr := proto.Unmarshal(data, message); protoErr != nil {
+		proto.Reset(message)
+		if err := json.Unmarshal(data, message); err != nil {
+			return fmt.Errorf("failed to decode publication as protobuf (%v) or JSON: %w", protoErr, err)
 		}
 	}
+	return s.client.inflate(message)
+}
 
-	return subs, nil
+// channelName returns the name of the channel of the subscription the centrifuge channel belongs to, channels are
+// named query:<subscription id>:control, query:<subscription id>:mempool or query:<subscription id>:<block>[:<page>]
+// with a :lite suffix for the mempool and block channels in lite mode
+func (s *Subscription) channelName(channel string) (string, bool) {
+	prefix := `query:` + s.SubscriptionID + `:`
+	if !strings.HasPrefix(channel, prefix) {
+		return "", false
+	}
+	channel = channel[len(prefix):]
+	if s.lite && channel != channelControl {
+		trimmed := strings.TrimSuffix(channel, liteSuffix)
+		if trimmed == channel || trimmed == channelControl {
+			return "", false
+		}
+		channel = trimmed
+	}
+	segments := strings.Split(channel, ":")
+	if len(segments) == 1 && (segments[0] == channelControl || segments[0] == channelMempool) {
+		return segments[0], true
+	}
+	if len(segments) > 2 {
+		return "", false
+	}
+	for _, segment := range segments {
+		if _, err := strconv.ParseUint(segment, 10, 64); err != nil {
+			return "", false
+		}
+	}
+	return channelMain, true
 }
 
-func (s *Subscription) startSubscription(subscription string) (*centrifuge.Subscription, error) {
-	sub, err := s.centrifugeClient.NewSubscription(subscription, centrifuge.SubscriptionConfig{
+// newChannel creates the centrifuge subscription of the given channel and subscribes to it
+func (s *Subscription) newChannel(centrifugeClient *centrifuge.Client, name string, current func() bool) (*centrifuge.Subscription, error) {
+	if onPublication, ok := s.extraChannels[name]; ok {
+		return s.newExtraChannel(centrifugeClient, name, onPublication, current)
+	}
+	channel := `query:` + s.SubscriptionID + `:` + name
+	var resume *StreamPosition
+	if name == channelMain {
+		// the page is only part of the channel name when resuming in the middle of a block
+		checkpoint := s.Checkpoint()
+		channel = `query:` + s.SubscriptionID + `:` + strconv.FormatUint(checkpoint.Block, 10)
+		if s.pageResume && checkpoint.Page > 0 {
+			channel += `:` + strconv.FormatUint(checkpoint.Page, 10)
+		}
+		if s.lite {
+			channel += liteSuffix
+		}
+		// offsets are only known for the stream the position was taken on
+		if position := checkpoint.Position; position != nil && position.Channel == channel {
+			resume = position
+		}
+	} else if s.lite && name != channelControl {
+		channel += liteSuffix
+	}
+
+	sub, err := centrifugeClient.NewSubscription(channel, centrifuge.SubscriptionConfig{
 		Recoverable: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, &SubscribeError{Kind: ErrSubscribeChannel, SubscriptionID: s.SubscriptionID, Channel: channel, Err: err}
 	}
 
+	eventHandler := s.dispatched()
+	var epoch atomic.Value // string, the epoch of the stream of the channel
+	sub.OnSubscribed(func(e centrifuge.SubscribedEvent) {
+		if !current() {
+			return
+		}
+		if e.StreamPosition != nil {
+			epoch.Store(e.StreamPosition.Epoch)
+		}
+		if resume != nil && !s.resumed(resume, e.StreamPosition) {
+			resume = nil
+		}
+	})
+	sub.OnError(s.onChannelError(channel, eventHandler, current))
+	sub.OnPublication(func(e centrifuge.PublicationEvent) {
+		if !current() || !s.acceptPublication() {
+			return
+		}
+		defer s.donePublication()
+		receivedAt := time.Now()
+		s.touch()
+		if name == channelMain && e.Offset > 0 {
+			if resume != nil && e.Offset <= resume.Offset {
+				// handled before the subscription resumed
+				return
+			}
+			epoch, _ := epoch.Load().(string)
+			s.position.Store(StreamPosition{Channel: channel, Offset: e.Offset, Epoch: epoch})
+		}
+		if eventHandler.OnRawPublication != nil {
+			eventHandler.OnRawPublication(channel, e.Offset, e.Data)
+		}
+		if name == channelControl {
+			controlResponse := &models.ControlResponse{}
+			if err := s.decode(e.Data, controlResponse); err != nil {
+				s.log(levelError, "invalid publication", "channel", channel, "error", err)
+				eventHandler.OnError(&DecodeError{Channel: channel, Offset: e.Offset, Data: e.Data, Err: err})
+			} else {
+				s.receiveControl(channel, e.Offset, receivedAt, controlResponse)
+			}
+			return
+		}
+
+		// every publication gets its own transaction, handlers are allowed to keep it unless WithPooledMessages is used
+		transaction := s.newTransaction()
+		if err := s.decode(e.Data, transaction); err != nil {
+			s.release(transaction)
+			s.log(levelError, "invalid publication", "channel", channel, "error", err)
+			eventHandler.OnError(&DecodeError{Channel: channel, Offset: e.Offset, Data: e.Data, Err: err})
+			return
+		}
+		if logEnabled(s.client.logger, levelDebug) {
+			s.log(levelDebug, "publication", "channel", channel, "block", transaction.BlockHeight)
+		}
+		epoch, _ := epoch.Load().(string)
+		s.receiveTransaction(eventHandler, name, TxContext{
+			Channel:    channel,
+			Offset:     e.Offset,
+			Epoch:      epoch,
+			ReceivedAt: receivedAt,
+		}, transaction)
+	})
+
+	if err = sub.Subscribe(); err != nil {
+		return sub, &SubscribeError{Kind: ErrSubscribeChannel, SubscriptionID: s.SubscriptionID, Channel: channel, Err: err}
+	}
 	return sub, nil
 }
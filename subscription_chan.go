@@ -0,0 +1,187 @@
+package junglebus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// ChanPolicy defines what happens when a consumer does not read from a subscription channel fast enough
+type ChanPolicy uint8
+
+const (
+	// ChanPolicyBlock waits until the consumer reads from the full channel, this applies backpressure
+	// on the connection and all other channels of the subscription
+	ChanPolicyBlock ChanPolicy = iota
+	// ChanPolicyDrop drops messages that do not fit in the channel buffer
+	ChanPolicyDrop
+)
+
+// DefaultChanBufferSize is the default buffer size of the subscription channels
+const DefaultChanBufferSize = 100
+
+// ChanOption is used for channel subscription options
+type ChanOption func(c *chanConfig)
+
+type chanConfig struct {
+	bufferSize int
+	policy     ChanPolicy
+}
+
+// WithChanBufferSize sets the buffer size of every subscription channel
+//
+// The channels are always buffered, the first status is sent before SubscribeChan returns
+func WithChanBufferSize(size int) ChanOption {
+	return func(c *chanConfig) {
+		if size > 0 {
+			c.bufferSize = size
+		}
+	}
+}
+
+// WithChanPolicy sets the policy for messages arriving while a channel is full (ChanPolicyBlock is default)
+func WithChanPolicy(policy ChanPolicy) ChanOption {
+	return func(c *chanConfig) {
+		c.policy = policy
+	}
+}
+
+// ChanSubscription is a subscription that delivers its messages on read-only channels
+//
+// All channels are closed when the context is cancelled or Unsubscribe is called. With ChanPolicyBlock
+// every channel has to be drained, a full channel blocks delivery on all the others.
+type ChanSubscription struct {
+	*Subscription
+	Transactions <-chan *models.TransactionResponse
+	Mempool      <-chan *models.TransactionResponse
+	Status       <-chan *models.ControlResponse
+	Errors       <-chan error
+
+	policy       ChanPolicy
+	transactions chan *models.TransactionResponse
+	mempool      chan *models.TransactionResponse
+	status       chan *models.ControlResponse
+	errors       chan error
+	mu           sync.RWMutex
+	closed       bool
+	quit         chan struct{}
+	quitOnce     sync.Once
+	closeOnce    sync.Once
+}
+
+// SubscribeChan starts a subscription like Subscribe, delivering all messages on the channels of the returned ChanSubscription
+func (jb *Client) SubscribeChan(ctx context.Context, subscriptionID string, fromBlock uint64, opts ...ChanOption) (*ChanSubscription, error) {
+	config := &chanConfig{
+		bufferSize: DefaultChanBufferSize,
+		policy:     ChanPolicyBlock,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	c := &ChanSubscription{
+		policy:       config.policy,
+		transactions: make(chan *models.TransactionResponse, config.bufferSize),
+		mempool:      make(chan *models.TransactionResponse, config.bufferSize),
+		status:       make(chan *models.ControlResponse, config.bufferSize),
+		errors:       make(chan error, config.bufferSize),
+		quit:         make(chan struct{}),
+	}
+	c.Transactions = c.transactions
+	c.Mempool = c.mempool
+	c.Status = c.status
+	c.Errors = c.errors
+
+	subscription, err := jb.Subscribe(ctx, subscriptionID, fromBlock, EventHandler{
+		OnTransaction: func(tx *models.TransactionResponse) {
+			forward(c, c.transactions, tx)
+		},
+		OnMempool: func(tx *models.TransactionResponse) {
+			forward(c, c.mempool, tx)
+		},
+		OnStatus: func(status *models.ControlResponse) {
+			if StatusCode(status.StatusCode).IsTerminal() {
+				c.close(status)
+				return
+			}
+			forward(c, c.status, status)
+		},
+		OnError: func(err error) {
+			forward(c, c.errors, err)
+		},
+	})
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	c.Subscription = subscription
+
+	// also close the channels when the subscription stops without being cancelled
+	go func() {
+		<-subscription.Done()
+		c.close()
+	}()
+
+	return c, nil
+}
+
+// Unsubscribe stops the subscription and closes all channels
+func (c *ChanSubscription) Unsubscribe() error {
+	// release handlers blocked on a full channel, the teardown waits for them
+	c.stopForwarding()
+	err := c.Subscription.Unsubscribe()
+	c.close()
+	return err
+}
+
+// close closes all channels, waiting for messages that are being forwarded to be given up
+// The final status is only delivered when the status channel has room, it never blocks the teardown
+func (c *ChanSubscription) close(final ...*models.ControlResponse) {
+	c.closeOnce.Do(func() {
+		c.stopForwarding()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.closed = true
+		for _, status := range final {
+			select {
+			case c.status <- status:
+			default:
+			}
+		}
+		close(c.transactions)
+		close(c.mempool)
+		close(c.status)
+		close(c.errors)
+	})
+}
+
+// stopForwarding makes every pending and future forward give up
+func (c *ChanSubscription) stopForwarding() {
+	c.quitOnce.Do(func() {
+		close(c.quit)
+	})
+}
+
+// forward sends the value on the channel according to the subscription policy
+func forward[T any](c *ChanSubscription, ch chan T, value T) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return
+	}
+
+	if c.policy == ChanPolicyDrop {
+		select {
+		case ch <- value:
+		default:
+		}
+		return
+	}
+
+	select {
+	case ch <- value:
+	case <-c.quit:
+	}
+}
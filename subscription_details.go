@@ -0,0 +1,19 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// GetSubscriptionDetails get the subscription and the queries it matches transactions with, like the addresses
+// and output types. ErrSubscriptionNotFound is returned for unknown subscription IDs.
+func (jb *Client) GetSubscriptionDetails(ctx context.Context, subscriptionID string) (*models.SubscriptionDetails, error) {
+	details, err := jb.transport.GetSubscriptionDetails(ctx, subscriptionID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrSubscriptionNotFound, subscriptionID)
+	}
+	return details, err
+}
@@ -0,0 +1,68 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient_GetSubscriptionDetails will test looking up a subscription
+func TestClient_GetSubscriptionDetails(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/subscription/"+testSubscriptionID, http.StatusOK, `{
+		"id": "`+testSubscriptionID+`",
+		"name": "ordinals",
+		"status": "active",
+		"addresses": ["1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"],
+		"output_types": ["ord", "bsv20"],
+		"contexts": ["image/png"],
+		"mempool": true
+	}`)
+		client := server.newClient()
+
+		t.Run("found", func(t *testing.T) {
+			details, err := client.GetSubscriptionDetails(context.Background(), testSubscriptionID)
+			require.NoError(t, err)
+			assert.Equal(t, &models.SubscriptionDetails{
+				ID:          testSubscriptionID,
+				Name:        "ordinals",
+				Status:      "active",
+				Addresses:   []string{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+				OutputTypes: []string{"ord", "bsv20"},
+				Contexts:    []string{"image/png"},
+				Mempool:     true,
+			}, details)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			_, err := client.GetSubscriptionDetails(context.Background(), "deleted")
+			assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+		})
+
+		t.Run("validate on subscribe", func(t *testing.T) {
+			_, err := client.Subscribe(context.Background(), "deleted", 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			}, WithValidateSubscription())
+			require.ErrorIs(t, err, ErrSubscriptionNotFound)
+			assert.Nil(t, client.GetSubscription("deleted"))
+			assert.Equal(t, 0, server.Connections())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			subscription, err := client.Subscribe(ctx, testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			}, WithValidateSubscription())
+			require.NoError(t, err)
+			assert.NotNil(t, subscription)
+		})
+	})
+}
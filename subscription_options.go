@@ -0,0 +1,316 @@
+package junglebus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/sinks"
+)
+
+// SubscribeOption is used for subscription options
+type SubscribeOption func(s *Subscription)
+
+// WithFromBlock will stream the subscription from the given block, the genesis block is default. A stored checkpoint
+// of WithCheckpointStore takes precedence.
+func WithFromBlock(height uint64) SubscribeOption {
+	return func(s *Subscription) {
+		s.FromBlock = height
+	}
+}
+
+// WithCheckpointStore will resume the subscription from its stored checkpoint instead of fromBlock,
+// saving the checkpoint every time a block is done. Failing saves are sent to OnError, the stream continues. It
+// resumes from the start of the block after the last done one, or after the last publication of the done block with
+// a StreamCheckpointStore: the block is then only sent again when the server lost the stream of the channel.
+func WithCheckpointStore(store CheckpointStore) SubscribeOption {
+	return func(s *Subscription) {
+		s.checkpointStore = store
+	}
+}
+
+// WithPageResume will resume in the middle of a block from the page of the last control message, subscribing to the
+// channel query:<subscription id>:<block>:<page> instead of query:<subscription id>:<block>. The page channels are
+// not part of the documented JungleBus API, only use it with a server serving them. Without it the block is resumed
+// from its start and the transactions of the pages handled before are sent again, see WithDedup.
+func WithPageResume() SubscribeOption {
+	return func(s *Subscription) {
+		s.pageResume = true
+	}
+}
+
+// WithValidateSubscription will look up the subscription with GetSubscriptionDetails before connecting, Subscribe
+// then fails right away with ErrSubscriptionNotFound for unknown subscription IDs
+func WithValidateSubscription() SubscribeOption {
+	return func(s *Subscription) {
+		s.validate = true
+	}
+}
+
+// WithPanicRecovery will set whether panics in the event handler are recovered and sent to OnError as a
+// PanicError, keeping the subscription running (default true)
+func WithPanicRecovery(enabled bool) SubscribeOption {
+	return func(s *Subscription) {
+		s.panicRecovery = enabled
+	}
+}
+
+// WithQueueSize will queue up to size messages between the connection and the event handler, which is then
+// called from a separate goroutine. A slow handler no longer holds up the connection until the queue is full,
+// see WithOverflowPolicy. The event handler is called synchronously when the size is 0 (default).
+func WithQueueSize(size int) SubscribeOption {
+	return func(s *Subscription) {
+		s.queueSize = size
+	}
+}
+
+// WithOverflowPolicy will set what happens to messages arriving while the queue is full (OverflowPolicyBlock is
+// default). Only transactions are dropped: control messages, like a block being done or a reorg, are always handled.
+// Dropped messages are counted in Stats and reported with a StatusDropped status.
+func WithOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(s *Subscription) {
+		s.overflowPolicy = policy
+	}
+}
+
+// WithHandlerConcurrency will call OnTransaction and OnMempool (or OnTransactionCtx and OnMempoolCtx) from up to n
+// goroutines at the same time, in no particular order. Control messages, like a block being done, are only handled once all transactions received
+// before them have been handled. Transactions are handled one at a time when n is 1 or less (default).
+func WithHandlerConcurrency(n int) SubscribeOption {
+	return func(s *Subscription) {
+		s.handlerConcurrency = n
+	}
+}
+
+// WithStrictOrdering will hold mined transactions back until their block is done, and pass them on to OnTransaction
+// (or OnTransactionCtx or OnBlock) ordered by block and index. Transactions ordered at or before one that was passed on already, like a
+// page sent again after reconnecting, are dropped. At most WithOrderingBufferSize transactions are held back, when
+// more arrive before their block is done they are passed on right away and ErrOrderingBufferFull is sent to
+// OnError. See SubscriptionStats for how much reordering occurred. It can not be combined with
+// WithHandlerConcurrency.
+func WithStrictOrdering() SubscribeOption {
+	return func(s *Subscription) {
+		s.strictOrdering = true
+	}
+}
+
+// WithOrderingBufferSize will set the maximum number of transactions WithStrictOrdering holds back
+// (DefaultOrderingBufferSize is default)
+func WithOrderingBufferSize(size int) SubscribeOption {
+	return func(s *Subscription) {
+		if size > 0 {
+			s.orderingSize = size
+		}
+	}
+}
+
+// WithMaxBatchSize will set the maximum number of transactions passed to OnBlock at once, a block with more
+// transactions is passed on in parts (DefaultMaxBatchSize is default)
+func WithMaxBatchSize(size int) SubscribeOption {
+	return func(s *Subscription) {
+		if size > 0 {
+			s.maxBatchSize = size
+		}
+	}
+}
+
+// WithStallTimeout will reconnect the subscription from its last checkpoint when no publication arrived for the
+// timeout while connected, after sending a StatusStalled status. The timeout is suspended while the server is
+// waiting for the next block (SubscriptionWait). Stalls are not detected when the timeout is 0 (default).
+func WithStallTimeout(timeout time.Duration) SubscribeOption {
+	return func(s *Subscription) {
+		s.stallTimeout = timeout
+	}
+}
+
+// WithLagWarning will send a StatusLagging status once the subscription, or one of its consumers, handled messages
+// more than blocks behind the newest message received from the server for the duration, see LagStats. It is sent
+// again after the lag went back down. Like other statuses it is queued behind the messages waiting to be handled,
+// the warning is logged right away. The lag is not watched when the duration is 0 (default).
+func WithLagWarning(blocks uint64, duration time.Duration) SubscribeOption {
+	return func(s *Subscription) {
+		s.lagThreshold = blocks
+		s.lagDuration = duration
+	}
+}
+
+// WithProgressInterval will pass the progress of the subscription to OnProgress of the event handler every interval,
+// see Subscription.Progress. OnProgress is not called when the interval is 0 (default).
+func WithProgressInterval(interval time.Duration) SubscribeOption {
+	return func(s *Subscription) {
+		s.progressInterval = interval
+	}
+}
+
+// WithNoGapRepair will fail the subscription with a GapError when the server no longer streams blocks it resumes
+// from (see models.SubscriptionDetails), instead of fetching the missing blocks with GetBlockTransactions and passing
+// them on before streaming continues. Subscribe returns the GapError, after reconnecting it is sent to OnError.
+func WithNoGapRepair() SubscribeOption {
+	return func(s *Subscription) {
+		s.noGapRepair = true
+	}
+}
+
+// WithUntilBlock will stop the subscription once the given block is done, transactions of later blocks are
+// never delivered. Done is closed when the subscription stopped.
+func WithUntilBlock(height uint64) SubscribeOption {
+	return func(s *Subscription) {
+		s.untilBlock = height
+	}
+}
+
+// WithFilter will only pass the transactions the filter passes on to OnTransaction, OnBlock and OnMempool. Control
+// messages are not filtered, the checkpoint keeps moving while transactions are filtered out. Filtered out
+// transactions are counted in Stats. Filters given more than once must all pass a transaction, see And, Or and Not to
+// combine filters. The prebuilt filters read the raw transaction, transactions without it are filtered out by them.
+func WithFilter(filter Filter) SubscribeOption {
+	return func(s *Subscription) {
+		if s.filter != nil && filter != nil {
+			s.filter = And(s.filter, filter)
+		} else if filter != nil {
+			s.filter = filter
+		}
+	}
+}
+
+// WithTxMiddleware will call the middlewares around OnTransaction and OnMempool, in the order they were added, the
+// first one wraps all the others. Middlewares are given how the transaction was received, see TxContext, and are
+// called on the goroutine calling the event handler: on the queue of WithQueueSize, one transaction at a time. With
+// WithHandlerConcurrency the handler may still be running when next returns. Panics of middlewares are recovered
+// like panics of the event handler. See DedupTxMiddleware and LatencyTxMiddleware.
+func WithTxMiddleware(middleware ...TxMiddleware) SubscribeOption {
+	return func(s *Subscription) {
+		s.txMiddleware = append(s.txMiddleware, middleware...)
+	}
+}
+
+// WithDedup will suppress transactions whose txid was already handled on the same channel, like the transactions
+// of a block sent again when resuming after a reconnect. A txid is remembered once the event handler returned for
+// it, without an error for OnTransactionE and OnMempoolE: a transaction arriving again while the first one is still
+// queued is delivered twice, a transaction whose handler failed is delivered again. The last size txids of the main
+// and mempool channels are remembered across reconnects, see Subscription.ResetDedup. Suppressed transactions are
+// counted in Stats.
+func WithDedup(size int) SubscribeOption {
+	return func(s *Subscription) {
+		s.dedupSize = size
+	}
+}
+
+// WithDedupTTL will forget a txid of WithDedup once it was not seen for ttl, so a transaction can be delivered again
+// later, like a transaction mined again after a reorg. DefaultDedupSize txids are remembered when WithDedup is not given.
+func WithDedupTTL(ttl time.Duration) SubscribeOption {
+	return func(s *Subscription) {
+		s.dedupTTL = ttl
+	}
+}
+
+// WithMempoolTracking will remember when mempool transactions were first seen, calling OnConfirmed once they are
+// mined. At most size transactions are remembered, the oldest one is dropped to make room for a new one. A
+// transaction not mined within ttl is dropped as well, 0 keeps transactions until they are dropped to make room.
+// OnEvicted is called for the dropped transactions. The main and mempool channels are subscribed to, also without
+// OnTransaction or OnMempool.
+func WithMempoolTracking(size int, ttl time.Duration) SubscribeOption {
+	return func(s *Subscription) {
+		if size > 0 {
+			s.mempoolTracker = newMempoolTracker(size, ttl)
+		}
+	}
+}
+
+// WithLiteMode will subscribe to the lite variant of the main and mempool channels, streaming the txid, block and
+// index of transactions without the raw transaction, see Subscription.Hydrate to fetch it when needed. Filters and
+// DecodedHandler reading the raw transaction do not see it in lite mode.
+func WithLiteMode() SubscribeOption {
+	return func(s *Subscription) {
+		s.lite = true
+	}
+}
+
+// WithPooledMessages will decode transactions into models.TransactionResponse values taken from a pool, to save an
+// allocation per publication. Handlers must call Release once they are done with a transaction, OnBlock after the
+// whole batch, and must not keep it or its fields afterwards. Transactions that are not released are garbage
+// collected as usual. Pooling is not used together with WithMempoolTracking, which keeps transactions for OnConfirmed.
+func WithPooledMessages() SubscribeOption {
+	return func(s *Subscription) {
+		s.pooled = true
+	}
+}
+
+// WithHandlerRetry will set how often OnTransactionE and OnMempoolE are called for a failing transaction before it
+// is dead-lettered, waiting from minDelay up to maxDelay between the attempts (DefaultHandlerRetryAttempts,
+// DefaultHandlerRetryMinDelay and DefaultHandlerRetryMaxDelay are default). Retrying holds up the transactions after
+// it, 1 attempt dead-letters a failing transaction right away.
+func WithHandlerRetry(attempts int, minDelay, maxDelay time.Duration) SubscribeOption {
+	return func(s *Subscription) {
+		if attempts > 0 {
+			s.handlerRetry = handlerRetryPolicy{attempts: attempts, minDelay: minDelay, maxDelay: maxDelay}
+		}
+	}
+}
+
+// WithDiskSpool will write the messages arriving while the queue is full to a spool file in dir instead of applying
+// the overflow policy, they are handled in order once the event handler caught up. The spool grows up to maxBytes,
+// beyond that the connection waits until it was emptied. Messages left in the spool when the subscription stops are
+// handled first on the next Subscribe of the subscription ID, before the messages streamed again from the checkpoint.
+// A partially written record, like after a crash, is cut off and sent to OnError as a SpoolCorruptionError. The
+// queue holds DefaultSpoolQueueSize messages unless WithQueueSize sets its size.
+func WithDiskSpool(dir string, maxBytes int64) SubscribeOption {
+	return func(s *Subscription) {
+		s.spoolDir = dir
+		s.spoolMaxBytes = maxBytes
+	}
+}
+
+// WithSink will write every transaction, control message and status of the subscription to the sink before it is
+// passed on to the event handler, like a sinks.NDJSONFile to archive the stream. Failing writes are sent to OnError,
+// the stream continues. The sink is closed once the subscription has been torn down.
+func WithSink(sink sinks.Sink) SubscribeOption {
+	return func(s *Subscription) {
+		s.sink = sink
+	}
+}
+
+// WithStreamPosition will skip the publications of the main channel up to and including the position, instead of
+// passing on the block or page the subscription resumes at as a whole. It takes precedence over the position stored
+// by a StreamCheckpointStore, see Checkpoint for how resuming from a position works.
+func WithStreamPosition(position StreamPosition) SubscribeOption {
+	return func(s *Subscription) {
+		s.seedPosition = &position
+	}
+}
+
+// WithExtraChannel will subscribe to another channel of the server on the connection of the subscription, passing
+// the payload of every publication on it to onPublication through the queue of the subscription. The channel is
+// subscribed again after every reconnect and torn down by Unsubscribe. The channel must not be one of the channels
+// of the subscription itself.
+func WithExtraChannel(channel string, onPublication func(data []byte)) SubscribeOption {
+	return func(s *Subscription) {
+		if s.extraChannels == nil {
+			s.extraChannels = map[string]func(data []byte){}
+		}
+		s.extraChannels[channel] = onPublication
+	}
+}
+
+// validateOptions returns the error of an invalid option or combination of options
+func (s *Subscription) validateOptions() error {
+	switch {
+	case s.untilBlock > 0 && s.untilBlock < s.FromBlock:
+		return ErrInvalidUntilBlock
+	case s.untilBlock > 0 && s.mempoolOnly:
+		return fmt.Errorf("%w: the mempool has no blocks", ErrInvalidUntilBlock)
+	case s.queueSize < 0:
+		return ErrInvalidQueueSize
+	case s.strictOrdering && s.handlerConcurrency > 1:
+		return ErrOrderingConcurrency
+	case s.spoolDir != "" && s.spoolMaxBytes <= 0:
+		return ErrInvalidSpoolSize
+	case s.seedPosition != nil && !s.onMainChannel(*s.seedPosition):
+		return ErrInvalidStreamPosition
+	}
+	for channel, onPublication := range s.extraChannels {
+		if err := s.validateExtraChannel(channel, onPublication); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,2562 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/centrifugal/centrifuge-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const testSubscriptionID = "test-subscription"
+
+// statusRecorder collects the status messages sent to an event handler
+type statusRecorder struct {
+	mu       sync.Mutex
+	statuses []*models.ControlResponse
+	errors   []error
+}
+
+func (r *statusRecorder) onStatus(status *models.ControlResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, status)
+}
+
+func (r *statusRecorder) onError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, err)
+}
+
+func (r *statusRecorder) has(code StatusCode) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range r.statuses {
+		if status.StatusCode == uint32(code) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *statusRecorder) last() *models.ControlResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.statuses) == 0 {
+		return nil
+	}
+	return r.statuses[len(r.statuses)-1]
+}
+
+// assertNoGoroutineLeak waits for the number of goroutines to drop back to the given baseline
+func assertNoGoroutineLeak(t *testing.T, baseline int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "goroutines leaked")
+}
+
+// TestSubscribe_ContextCancel will test that cancelling the context tears down the subscription
+func TestSubscribe_ContextCancel(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+		goroutines := runtime.NumGoroutine()
+
+		recorder := &statusRecorder{}
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		subscription, err := client.Subscribe(ctx, testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, subscription)
+		server.waitSubscribed("query:" + testSubscriptionID + ":100")
+
+		require.Eventually(t, func() bool {
+			return recorder.has(StatusCancelled)
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, uint32(StatusCancelled), recorder.last().StatusCode)
+		assert.Nil(t, client.GetSubscription(testSubscriptionID))
+
+		require.Eventually(t, func() bool {
+			return server.Connections() == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+		assertNoGoroutineLeak(t, goroutines)
+	})
+}
+
+// TestSubscribe_UnsubscribeStopsWatcher will test that a manual unsubscribe does not emit a cancelled status
+func TestSubscribe_UnsubscribeStopsWatcher(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		recorder := &statusRecorder{}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		subscription, err := client.Subscribe(ctx, testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		})
+		require.NoError(t, err)
+		server.waitSubscribed("query:" + testSubscriptionID + ":control")
+
+		require.NoError(t, subscription.Unsubscribe())
+		cancel()
+
+		time.Sleep(50 * time.Millisecond)
+		assert.False(t, recorder.has(StatusCancelled))
+	})
+}
+
+// TestSubscription_UnsubscribeChannel will test unsubscribing from a single channel
+func TestSubscription_UnsubscribeChannel(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		transactions := make(chan *models.TransactionResponse, 1)
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx },
+			OnMempool:     func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		})
+		require.NoError(t, err)
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+
+		require.NoError(t, subscription.UnsubscribeMempool())
+		require.Eventually(t, func() bool {
+			return !server.subscribed(mempoolChannel)
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.True(t, server.subscribed(mainChannel))
+
+		t.Run("unsubscribing twice is a no-op", func(t *testing.T) {
+			require.NoError(t, subscription.UnsubscribeMempool())
+		})
+
+		t.Run("main channel keeps streaming", func(t *testing.T) {
+			server.publishMessage(mainChannel, &models.TransactionResponse{Id: txID, BlockHeight: 100})
+
+			select {
+			case tx := <-transactions:
+				assert.Equal(t, txID, tx.Id)
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+			}
+		})
+
+		t.Run("full unsubscribe afterwards", func(t *testing.T) {
+			require.NoError(t, subscription.UnsubscribeMain())
+			require.NoError(t, subscription.Unsubscribe())
+		})
+	})
+}
+
+// TestClient_MultipleSubscriptions will test running two independent subscriptions on one client
+func TestClient_MultipleSubscriptions(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		newHandler := func(transactions chan *models.TransactionResponse) EventHandler {
+			return EventHandler{
+				OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx },
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			}
+		}
+		receive := func(t *testing.T, transactions chan *models.TransactionResponse) *models.TransactionResponse {
+			select {
+			case tx := <-transactions:
+				return tx
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+				return nil
+			}
+		}
+
+		transactionsA := make(chan *models.TransactionResponse, 10)
+		transactionsB := make(chan *models.TransactionResponse, 10)
+		subscriptionA, err := client.Subscribe(context.Background(), "sub-a", 100, newHandler(transactionsA))
+		require.NoError(t, err)
+		subscriptionB, err := client.Subscribe(context.Background(), "sub-b", 200, newHandler(transactionsB))
+		require.NoError(t, err)
+		assert.NotSame(t, subscriptionA, subscriptionB)
+
+		server.waitSubscribed("query:sub-a:100")
+		server.waitSubscribed("query:sub-b:200")
+		require.Eventually(t, func() bool {
+			return client.GetSubscription("sub-a") == subscriptionA && client.GetSubscription("sub-b") == subscriptionB
+		}, 5*time.Second, 10*time.Millisecond)
+
+		t.Run("each subscription receives its own transactions", func(t *testing.T) {
+			server.publishTransaction("query:sub-a:100", "tx-a")
+			server.publishTransaction("query:sub-b:200", "tx-b")
+			assert.Equal(t, "tx-a", receive(t, transactionsA).Id)
+			assert.Equal(t, "tx-b", receive(t, transactionsB).Id)
+		})
+
+		t.Run("subscribing to an active id fails", func(t *testing.T) {
+			_, err = client.Subscribe(context.Background(), "sub-a", 100, newHandler(transactionsA))
+			assert.ErrorIs(t, err, ErrAlreadySubscribed)
+		})
+
+		t.Run("reconnect is scoped to the dropped connection", func(t *testing.T) {
+			dials := len(server.dialTimes())
+			server.disconnect("query:sub-a:control")
+			require.Eventually(t, func() bool {
+				return len(server.dialTimes()) > dials && server.subscribed("query:sub-a:100")
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Len(t, server.dialTimes(), dials+1)
+			assert.Same(t, subscriptionA, client.GetSubscription("sub-a"))
+			assert.Same(t, subscriptionB, client.GetSubscription("sub-b"))
+
+			server.publishTransaction("query:sub-a:100", "tx-a2")
+			server.publishTransaction("query:sub-b:200", "tx-b2")
+			assert.Equal(t, "tx-a2", receive(t, transactionsA).Id)
+			assert.Equal(t, "tx-b2", receive(t, transactionsB).Id)
+		})
+
+		t.Run("unsubscribe a single id", func(t *testing.T) {
+			require.NoError(t, client.Unsubscribe("sub-b"))
+			assert.Nil(t, client.GetSubscription("sub-b"))
+			assert.NotNil(t, client.GetSubscription("sub-a"))
+		})
+
+		t.Run("unsubscribe all", func(t *testing.T) {
+			require.NoError(t, client.Unsubscribe())
+			assert.Nil(t, client.GetSubscription("sub-a"))
+		})
+	})
+}
+
+// TestClient_SubscribeChan will test the channel based subscription API
+func TestClient_SubscribeChan(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		t.Run("delivers and closes on cancel", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			subscription, err := client.SubscribeChan(ctx, testSubscriptionID, 100)
+			require.NoError(t, err)
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+			server.waitSubscribed("query:" + testSubscriptionID + ":mempool")
+
+			server.publishTransaction("query:"+testSubscriptionID+":100", "tx-1")
+			server.publishTransaction("query:"+testSubscriptionID+":mempool", "tx-2")
+			select {
+			case tx := <-subscription.Transactions:
+				assert.Equal(t, "tx-1", tx.Id)
+			case <-time.After(5 * time.Second):
+				t.Fatal("transaction not received")
+			}
+			select {
+			case tx := <-subscription.Mempool:
+				assert.Equal(t, "tx-2", tx.Id)
+			case <-time.After(5 * time.Second):
+				t.Fatal("mempool transaction not received")
+			}
+
+			cancel()
+			var last *models.ControlResponse
+			for status := range subscription.Status {
+				last = status
+			}
+			require.NotNil(t, last)
+			assert.Equal(t, uint32(StatusCancelled), last.StatusCode)
+			_, ok := <-subscription.Transactions
+			assert.False(t, ok)
+			_, ok = <-subscription.Mempool
+			assert.False(t, ok)
+			_, ok = <-subscription.Errors
+			assert.False(t, ok)
+		})
+
+		t.Run("unsubscribe closes channels", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+
+			subscription, err := client.SubscribeChan(context.Background(), testSubscriptionID, 100, WithChanBufferSize(1))
+			require.NoError(t, err)
+			require.NoError(t, subscription.Unsubscribe())
+
+			_, ok := <-subscription.Transactions
+			assert.False(t, ok)
+		})
+
+		t.Run("drop policy does not block", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+
+			subscription, err := client.SubscribeChan(
+				context.Background(), testSubscriptionID, 100, WithChanBufferSize(10), WithChanPolicy(ChanPolicyDrop),
+			)
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+			server.waitSubscribed("query:" + testSubscriptionID + ":mempool")
+
+			for i := 0; i < 15; i++ {
+				server.publishTransaction("query:"+testSubscriptionID+":100", "tx")
+			}
+			server.publishTransaction("query:"+testSubscriptionID+":mempool", "tx-mempool")
+
+			require.Eventually(t, func() bool {
+				return len(subscription.Mempool) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Len(t, subscription.Transactions, 10)
+		})
+	})
+}
+
+// TestSubscribe_TransactionNotReused will test that handlers may keep the transactions they receive
+func TestSubscribe_TransactionNotReused(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		transactions := make(chan *models.TransactionResponse, 2)
+		mempool := make(chan *models.TransactionResponse, 2)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx },
+			OnMempool:     func(tx *models.TransactionResponse) { mempool <- tx },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		for _, channel := range []struct {
+			name         string
+			transactions chan *models.TransactionResponse
+		}{
+			{name: "query:" + testSubscriptionID + ":100", transactions: transactions},
+			{name: "query:" + testSubscriptionID + ":mempool", transactions: mempool},
+		} {
+			t.Run(channel.name, func(t *testing.T) {
+				server.waitSubscribed(channel.name)
+				server.publishTransaction(channel.name, "tx-1")
+				server.publishTransaction(channel.name, "tx-2")
+
+				var received []*models.TransactionResponse
+				for len(received) < 2 {
+					select {
+					case tx := <-channel.transactions:
+						received = append(received, tx)
+					case <-time.After(5 * time.Second):
+						t.Fatal("transaction not received")
+					}
+				}
+				assert.NotSame(t, received[0], received[1])
+				assert.Equal(t, "tx-1", received[0].Id)
+				assert.Equal(t, "tx-2", received[1].Id)
+			})
+		}
+	})
+}
+
+// TestSubscribe_ConcurrentPublications will test that errors on one channel do not leak into the others
+func TestSubscribe_ConcurrentPublications(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const publications = 20
+
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+
+		// an invalid message on every channel, while still subscribing
+		for _, channel := range []string{controlChannel, mainChannel, mempoolChannel} {
+			server.queue(channel, server.invalidPublication())
+		}
+
+		var mu sync.Mutex
+		var transactions, mempool, errs int
+		controls := map[uint32]bool{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				transactions++
+			},
+			OnMempool: func(*models.TransactionResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				mempool++
+			},
+			OnStatus: func(status *models.ControlResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				if status.StatusCode == uint32(SubscriptionBlockDone) {
+					controls[status.Block] = true
+				}
+			},
+			OnError: func(error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs++
+			},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		server.waitSubscribed(controlChannel)
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < publications; i++ {
+				assert.NoError(t, server.PublishControl(controlChannel,
+					&models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: uint32(100 + i)}))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < publications; i++ {
+				assert.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: "tx"}))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < publications; i++ {
+				assert.NoError(t, server.PublishTransaction(mempoolChannel, &models.TransactionResponse{Id: "tx"}))
+			}
+		}()
+		wg.Wait()
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return transactions == publications && mempool == publications && len(controls) == publications && errs == 3
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+// TestSubscription_LastBlock will test tracking the block progress reported on the control channel
+func TestSubscription_LastBlock(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = client.Unsubscribe()
+		}()
+		assert.Equal(t, uint64(100), subscription.LastBlock())
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed(controlChannel)
+
+		t.Run("updated by the control channel", func(t *testing.T) {
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 105})
+
+			require.Eventually(t, func() bool {
+				return subscription.LastBlock() == 105
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+
+		t.Run("statuses without a block are ignored", func(t *testing.T) {
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionWait)})
+
+			assert.Never(t, func() bool {
+				return subscription.LastBlock() != 105
+			}, 100*time.Millisecond, 10*time.Millisecond)
+		})
+
+		t.Run("reconnect resumes from the last block", func(t *testing.T) {
+			server.disconnect(controlChannel)
+			server.waitSubscribed("query:" + testSubscriptionID + ":105")
+		})
+	})
+}
+
+// TestSubscribe_OnBlockDone will test that block done messages are sent to their own callback
+func TestSubscribe_OnBlockDone(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		type blockDone struct {
+			height       uint32
+			transactions uint64
+		}
+		blocks := make(chan blockDone, 1)
+		recorder := &statusRecorder{}
+		_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnBlockDone: func(height uint32, transactions uint64) {
+				blocks <- blockDone{height: height, transactions: transactions}
+			},
+			OnError: recorder.onError,
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = client.Unsubscribe()
+		}()
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed(controlChannel)
+		for _, control := range []*models.ControlResponse{
+			{StatusCode: uint32(SubscriptionBlockDone), Block: 100, Transactions: 42},
+			{StatusCode: uint32(SubscriptionWait), Block: 101},
+		} {
+			server.publishMessage(controlChannel, control)
+		}
+
+		select {
+		case block := <-blocks:
+			assert.Equal(t, blockDone{height: 100, transactions: 42}, block)
+		case <-time.After(5 * time.Second):
+			t.Fatal("block done not received")
+		}
+		require.Eventually(t, func() bool {
+			return recorder.has(SubscriptionWait)
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.False(t, recorder.has(SubscriptionBlockDone))
+	})
+}
+
+// TestSubscribe_OnReorg will test that a reorg is reported and rolls back the resume height
+func TestSubscribe_OnReorg(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		reorgs := make(chan uint32, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnReorg:       func(height uint32) { reorgs <- height },
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = client.Unsubscribe()
+		}()
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed(controlChannel)
+		for _, control := range []*models.ControlResponse{
+			{StatusCode: uint32(SubscriptionBlockDone), Block: 110},
+			{StatusCode: uint32(SubscriptionReorg), Block: 107},
+		} {
+			server.publishMessage(controlChannel, control)
+		}
+
+		select {
+		case height := <-reorgs:
+			assert.Equal(t, uint32(107), height)
+		case <-time.After(5 * time.Second):
+			t.Fatal("reorg not received")
+		}
+		assert.Equal(t, uint64(107), subscription.LastBlock())
+
+		server.disconnect(controlChannel)
+		server.waitSubscribed("query:" + testSubscriptionID + ":107")
+	})
+}
+
+// TestSubscribe_ReconnectPolicy will test reconnecting with backoff and a maximum number of attempts
+func TestSubscribe_ReconnectPolicy(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const minDelay = 20 * time.Millisecond
+
+		newHandler := func(recorder *statusRecorder) EventHandler {
+			return EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      recorder.onStatus,
+				OnError:       recorder.onError,
+			}
+		}
+
+		t.Run("backs off until connected", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient(WithReconnectBackoff(minDelay, time.Second, 2), WithMaxReconnectAttempts(3))
+			server.rejectConnections(3)
+
+			recorder := &statusRecorder{}
+			_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(recorder))
+			require.NoError(t, err)
+			defer func() {
+				_ = client.Unsubscribe()
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+
+			dials := server.dialTimes()
+			require.Len(t, dials, 4)
+			for i := 1; i < len(dials); i++ {
+				expected := minDelay << (i - 1)
+				assert.GreaterOrEqual(t, dials[i].Sub(dials[i-1]), expected, "attempt %d", i)
+			}
+
+			t.Run("attempts are reset after connecting", func(t *testing.T) {
+				server.rejectConnections(2)
+				server.disconnectAll()
+				require.Eventually(t, func() bool {
+					return len(server.dialTimes()) == 7 && server.subscribed("query:"+testSubscriptionID+":100")
+				}, 5*time.Second, 10*time.Millisecond)
+				assert.NotNil(t, client.GetSubscription(testSubscriptionID))
+			})
+		})
+
+		t.Run("stops after the maximum attempts", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient(WithReconnectBackoff(minDelay, time.Second, 2), WithMaxReconnectAttempts(2))
+			server.rejectConnections(100)
+
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(recorder))
+			require.NoError(t, err)
+			assert.ErrorIs(t, subscription.Wait(), ErrMaxReconnectAttempts)
+
+			require.Eventually(t, func() bool {
+				recorder.mu.Lock()
+				defer recorder.mu.Unlock()
+				for _, err := range recorder.errors {
+					if errors.Is(err, ErrMaxReconnectAttempts) {
+						return true
+					}
+				}
+				return false
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Len(t, server.dialTimes(), 3)
+			assert.Nil(t, client.GetSubscription(testSubscriptionID))
+		})
+	})
+}
+
+// TestSubscribe_ReconnectDoesNotLeak will test that reconnecting keeps the subscription and releases old connections
+func TestSubscribe_ReconnectDoesNotLeak(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const disconnects = 50
+
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		transactions := make(chan *models.TransactionResponse, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { transactions <- tx },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+		baseline := runtime.NumGoroutine()
+
+		for i := 0; i < disconnects; i++ {
+			dials := len(server.dialTimes())
+			server.disconnectAll()
+			require.Eventually(t, func() bool {
+				return len(server.dialTimes()) > dials && server.Connections() == 1 && server.subscribed(mainChannel)
+			}, 5*time.Second, time.Millisecond, "reconnect %d", i)
+		}
+
+		assert.Same(t, subscription, client.GetSubscription(testSubscriptionID))
+		assertNoGoroutineLeak(t, baseline)
+
+		server.publishTransaction(mainChannel, "tx")
+		select {
+		case tx := <-transactions:
+			assert.Equal(t, "tx", tx.Id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction not received after reconnecting")
+		}
+	})
+}
+
+// TestSubscription_Reconnect will test replacing the connection on request, keeping the subscription and its stats
+func TestSubscription_Reconnect(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		mainChannel := "query:" + testSubscriptionID + ":100"
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(mainChannel)
+		require.Eventually(t, subscription.IsConnected, 5*time.Second, 10*time.Millisecond)
+
+		server.publishTransaction(mainChannel, "tx-1")
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 105})
+		require.Eventually(t, func() bool {
+			return subscription.LastBlock() == 105
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// reconnecting while publications are in flight
+		stop := make(chan struct{})
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_ = server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: "tx"})
+				}
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, subscription.Reconnect(ctx))
+		close(stop)
+		<-published
+
+		assert.True(t, subscription.IsConnected())
+		assert.Len(t, server.dialTimes(), 2)
+		server.waitSubscribed("query:" + testSubscriptionID + ":105")
+		assert.Equal(t, 1, server.Connections())
+		assert.Same(t, subscription, client.GetSubscription(testSubscriptionID))
+		assert.GreaterOrEqual(t, subscription.Stats().TransactionsReceived, uint64(1))
+		assert.Equal(t, uint64(105), subscription.LastBlock())
+
+		require.NoError(t, subscription.Unsubscribe())
+		assert.False(t, subscription.IsConnected())
+		assert.ErrorIs(t, subscription.Reconnect(ctx), ErrNotSubscribed)
+		var nilSubscription *Subscription
+		assert.False(t, nilSubscription.IsConnected())
+		assert.ErrorIs(t, nilSubscription.Reconnect(ctx), ErrNotSubscribed)
+	})
+}
+
+// TestSubscription_ReconnectQueued will test dropping the queued mined transactions of the replaced connection
+func TestSubscription_ReconnectQueued(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+
+		transactions := make(chan string, 10)
+		blocked := make(chan struct{})
+		unblock := make(chan struct{})
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				if tx.Id == "tx-1" {
+					close(blocked)
+					<-unblock
+				}
+				transactions <- tx.Id
+			},
+			OnMempool: func(tx *models.TransactionResponse) { transactions <- tx.Id },
+			OnStatus:  func(*models.ControlResponse) {},
+			OnError:   func(error) {},
+		}, WithQueueSize(10))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+
+		server.publishTransaction(mainChannel, "tx-1")
+		<-blocked
+		server.publishTransaction(mainChannel, "tx-2")
+		server.publishTransaction(mempoolChannel, "mempool-1")
+		require.Eventually(t, func() bool {
+			return subscription.Stats().TransactionsReceived == 2 && subscription.Stats().MempoolReceived == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, subscription.Reconnect(ctx))
+		close(unblock)
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, "tx-3")
+
+		var received []string
+		for len(received) < 3 {
+			select {
+			case txID := <-transactions:
+				received = append(received, txID)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("transactions not received, got %v", received)
+			}
+		}
+		assert.Equal(t, []string{"tx-1", "mempool-1", "tx-3"}, received, "tx-2 of the old connection is dropped")
+	})
+}
+
+// TestSubscription_Checkpoint will test resuming from the block and page reported on the control channel
+func TestSubscription_Checkpoint(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		}, WithPageResume())
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		assert.Equal(t, Checkpoint{Block: 100}, subscription.Checkpoint())
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed(controlChannel)
+		publishControl := func(control *models.ControlResponse) {
+			server.publishMessage(controlChannel, control)
+		}
+
+		t.Run("resumes in the middle of a block", func(t *testing.T) {
+			publishControl(&models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 110, Page: 3})
+			require.Eventually(t, func() bool {
+				return subscription.Checkpoint() == Checkpoint{Block: 110, Page: 3}
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, uint64(110), subscription.LastBlock())
+			assert.Equal(t, uint64(3), subscription.LastPage())
+
+			server.disconnect(controlChannel)
+			server.waitSubscribed("query:" + testSubscriptionID + ":110:3")
+		})
+
+		t.Run("reorg resumes at the start of the block", func(t *testing.T) {
+			server.waitSubscribed(controlChannel)
+			publishControl(&models.ControlResponse{StatusCode: uint32(SubscriptionReorg), Block: 108, Page: 2})
+			require.Eventually(t, func() bool {
+				return subscription.Checkpoint() == Checkpoint{Block: 108}
+			}, 5*time.Second, 10*time.Millisecond)
+
+			server.disconnect(controlChannel)
+			server.waitSubscribed("query:" + testSubscriptionID + ":108")
+		})
+	})
+}
+
+// TestSubscription_CheckpointWithoutPageResume will test resuming from the start of the block without WithPageResume
+func TestSubscription_CheckpointWithoutPageResume(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed(controlChannel)
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 110, Page: 3})
+		require.Eventually(t, func() bool {
+			return subscription.Checkpoint() == Checkpoint{Block: 110, Page: 3}
+		}, 5*time.Second, 10*time.Millisecond)
+
+		server.disconnect(controlChannel)
+		server.waitSubscribed("query:" + testSubscriptionID + ":110")
+	})
+}
+
+// memoryCheckpointStore is a CheckpointStore keeping the checkpoints in memory
+type memoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+	saveErr     error
+}
+
+func (m *memoryCheckpointStore) Save(_ context.Context, subscriptionID string, block uint64, page uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.checkpoints[subscriptionID] = Checkpoint{Block: block, Page: page}
+	return nil
+}
+
+func (m *memoryCheckpointStore) Load(_ context.Context, subscriptionID string) (uint64, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	checkpoint, ok := m.checkpoints[subscriptionID]
+	if !ok {
+		return 0, 0, ErrCheckpointNotFound
+	}
+	return checkpoint.Block, checkpoint.Page, nil
+}
+
+func (m *memoryCheckpointStore) get(subscriptionID string) Checkpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkpoints[subscriptionID]
+}
+
+// TestSubscribe_WithCheckpointStore will test resuming from and saving to a checkpoint store
+func TestSubscribe_WithCheckpointStore(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{
+			testSubscriptionID: {Block: 150, Page: 2},
+		}}
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		}, WithCheckpointStore(store))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed("query:" + testSubscriptionID + ":150")
+		server.waitSubscribed(controlChannel)
+		assert.Equal(t, uint64(150), subscription.FromBlock)
+
+		publishControl := func(control *models.ControlResponse) {
+			server.publishMessage(controlChannel, control)
+		}
+
+		t.Run("saved when a block is done", func(t *testing.T) {
+			publishControl(&models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 151})
+			publishControl(&models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 152})
+			// without a stream position the store resumes from the next block, the done block is not sent again
+			require.Eventually(t, func() bool {
+				return store.get(testSubscriptionID) == Checkpoint{Block: 153}
+			}, 5*time.Second, 10*time.Millisecond)
+
+			restarted := newFakeServer(t)
+			resumed, err := restarted.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+			}, WithCheckpointStore(store))
+			require.NoError(t, err)
+			defer func() {
+				_ = resumed.Unsubscribe()
+			}()
+			restarted.waitSubscribed("query:" + testSubscriptionID + ":153")
+		})
+
+		t.Run("failing saves do not stop the stream", func(t *testing.T) {
+			saveErr := errors.New("disk full")
+			store.mu.Lock()
+			store.saveErr = saveErr
+			store.mu.Unlock()
+
+			publishControl(&models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 153})
+			publishControl(&models.ControlResponse{StatusCode: uint32(SubscriptionWait), Block: 154})
+			require.Eventually(t, func() bool {
+				return subscription.LastBlock() == 154
+			}, 5*time.Second, 10*time.Millisecond)
+
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			assert.Equal(t, []error{saveErr}, recorder.errors)
+		})
+	})
+}
+
+// TestClient_SubscribeFromTip will test subscribing relative to the current best block
+func TestClient_SubscribeFromTip(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		eventHandler := EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		}
+
+		t.Run("from the tip", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusOK, `{"hash":"000000","height":800000}`)
+			client := server.newClient()
+
+			subscription, err := client.SubscribeFromTip(context.Background(), testSubscriptionID, eventHandler)
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			assert.Equal(t, uint64(800000), subscription.FromBlock)
+			server.waitSubscribed("query:" + testSubscriptionID + ":800000")
+		})
+
+		t.Run("with a lookback", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusOK, `{"hash":"000000","height":800000}`)
+			client := server.newClient()
+
+			subscription, err := client.SubscribeFromTipWithLookback(context.Background(), testSubscriptionID, 6, eventHandler)
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			assert.Equal(t, uint64(799994), subscription.FromBlock)
+		})
+
+		t.Run("lookback beyond genesis", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusOK, `{"hash":"000000","height":5}`)
+			client := server.newClient()
+
+			subscription, err := client.SubscribeFromTipWithLookback(context.Background(), testSubscriptionID, 10, eventHandler)
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			assert.Equal(t, uint64(0), subscription.FromBlock)
+		})
+
+		t.Run("tip cannot be fetched", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusInternalServerError, `{}`)
+			client := server.newClient()
+
+			_, err := client.SubscribeFromTip(context.Background(), testSubscriptionID, eventHandler)
+			require.Error(t, err)
+			assert.Nil(t, client.GetSubscription(testSubscriptionID))
+		})
+
+		t.Run("no tip", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.handleJSON("/v1/block_header/tip", http.StatusOK, `null`)
+			client := server.newClient()
+
+			_, err := client.SubscribeFromTip(context.Background(), testSubscriptionID, eventHandler)
+			assert.ErrorIs(t, err, ErrChainTipNotFound)
+		})
+	})
+}
+
+// TestSubscribe_WithUntilBlock will test stopping a subscription once a block is done
+func TestSubscribe_WithUntilBlock(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		var mu sync.Mutex
+		var heights []uint32
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				heights = append(heights, tx.BlockHeight)
+			},
+			OnStatus: func(*models.ControlResponse) {},
+			OnError:  func(error) {},
+		}, WithUntilBlock(101))
+		require.NoError(t, err)
+
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(controlChannel)
+		server.waitSubscribed(mainChannel)
+
+		publishTx := func(height uint32) {
+			server.publishMessage(mainChannel, &models.TransactionResponse{Id: "tx", BlockHeight: height})
+		}
+		publishBlockDone := func(height uint32) {
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: height})
+		}
+
+		publishTx(100)
+		publishBlockDone(100)
+		publishTx(101)
+		// a transaction above the bound arriving before the block done message
+		publishTx(102)
+		publishBlockDone(101)
+
+		select {
+		case <-subscription.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscription not done")
+		}
+		assert.Nil(t, client.GetSubscription(testSubscriptionID))
+		require.Eventually(t, func() bool {
+			return server.Connections() == 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []uint32{100, 101}, heights)
+	})
+}
+
+// TestSubscribeWithOptions will test subscribing from the block of WithFromBlock
+func TestSubscribeWithOptions(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		received := make(chan string, 1)
+		subscription, err := client.SubscribeWithOptions(context.Background(), testSubscriptionID, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				received <- tx.Id
+			},
+			OnStatus: func(*models.ControlResponse) {},
+			OnError:  func(error) {},
+		}, WithFromBlock(100), WithUntilBlock(101), WithQueueSize(10))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, testTxID)
+		select {
+		case id := <-received:
+			assert.Equal(t, testTxID, id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction not received")
+		}
+		assert.Equal(t, uint64(100), subscription.FromBlock)
+	})
+}
+
+// TestSubscribeWithOptions_invalid will test rejecting invalid options before connecting
+func TestSubscribeWithOptions_invalid(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []SubscribeOption
+			err  error
+		}{
+			{
+				name: "until before from",
+				opts: []SubscribeOption{WithFromBlock(100), WithUntilBlock(99)},
+				err:  ErrInvalidUntilBlock,
+			},
+			{name: "negative queue size", opts: []SubscribeOption{WithQueueSize(-1)}, err: ErrInvalidQueueSize},
+			{name: "empty spool", opts: []SubscribeOption{WithDiskSpool(t.TempDir(), 0)}, err: ErrInvalidSpoolSize},
+		}
+		server := newFakeServer(t)
+		client := server.newClient()
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := client.SubscribeWithOptions(context.Background(), testSubscriptionID, EventHandler{
+					OnTransaction: func(*models.TransactionResponse) {},
+				}, append(test.opts, WithValidateSubscription())...)
+				assert.ErrorIs(t, err, test.err)
+			})
+		}
+
+		// Subscribe validates the from block it was given
+		_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+		}, WithUntilBlock(50))
+		assert.ErrorIs(t, err, ErrInvalidUntilBlock)
+		assert.Empty(t, server.dialTimes())
+		assert.Nil(t, client.GetSubscription(testSubscriptionID))
+	})
+}
+
+// TestSubscribe_Errors will test that the failures of subscribing match their sentinel errors
+func TestSubscribe_Errors(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		eventHandler := func(recorder *statusRecorder) EventHandler {
+			return EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      recorder.onStatus,
+				OnError:       recorder.onError,
+			}
+		}
+		errFetch := errors.New("token service unavailable")
+
+		t.Run("token fetch", func(t *testing.T) {
+			client, err := New(WithTransport(&transports.Mock{
+				ServerURL: "127.0.0.1:1",
+				GetSubscriptionTokenFunc: func(context.Context, string) (string, error) {
+					return "", errFetch
+				},
+			}))
+			require.NoError(t, err)
+			_, err = client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler(&statusRecorder{}))
+			assert.ErrorIs(t, err, ErrTokenFetch)
+			assert.ErrorIs(t, err, errFetch)
+			assert.Contains(t, err.Error(), testSubscriptionID)
+			assert.Nil(t, client.GetSubscription(testSubscriptionID))
+		})
+
+		t.Run("connect", func(t *testing.T) {
+			client, err := New(WithTransport(&transports.Mock{ServerURL: "127.0.0.1:1"}),
+				WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2), WithMaxReconnectAttempts(1))
+			require.NoError(t, err)
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler(recorder))
+			require.NoError(t, err)
+
+			err = subscription.Wait()
+			assert.ErrorIs(t, err, ErrConnect)
+			assert.ErrorIs(t, err, ErrMaxReconnectAttempts)
+			var subscribeErr *SubscribeError
+			require.ErrorAs(t, err, &subscribeErr)
+			assert.Equal(t, testSubscriptionID, subscribeErr.SubscriptionID)
+			assert.Contains(t, subscribeErr.Endpoint, "127.0.0.1:1")
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			require.NotEmpty(t, recorder.errors)
+			assert.ErrorIs(t, recorder.errors[len(recorder.errors)-1], ErrConnect)
+		})
+
+		t.Run("subscribe channel", func(t *testing.T) {
+			server := newFakeServer(t)
+			channel := "query:" + testSubscriptionID + ":100"
+			server.RejectChannel(channel)
+			client := server.newClient()
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler(recorder))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+
+			var subscribeErr *SubscribeError
+			require.Eventually(t, func() bool {
+				recorder.mu.Lock()
+				defer recorder.mu.Unlock()
+				for _, err := range recorder.errors {
+					if errors.Is(err, ErrSubscribeChannel) {
+						return errors.As(err, &subscribeErr)
+					}
+				}
+				return false
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, channel, subscribeErr.Channel)
+			assert.Equal(t, testSubscriptionID, subscribeErr.SubscriptionID)
+		})
+
+		t.Run("already subscribed", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler(&statusRecorder{}))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+
+			_, err = client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler(&statusRecorder{}))
+			assert.ErrorIs(t, err, ErrAlreadySubscribed)
+			assert.Contains(t, err.Error(), testSubscriptionID)
+		})
+
+		t.Run("not subscribed", func(t *testing.T) {
+			client, err := New(WithTransport(&transports.Mock{}))
+			require.NoError(t, err)
+			assert.ErrorIs(t, client.Unsubscribe(testSubscriptionID), ErrNotSubscribed)
+			var subscription *Subscription
+			assert.ErrorIs(t, subscription.Unsubscribe(), ErrNotSubscribed)
+		})
+	})
+}
+
+// TestSubscription_Err will test reporting why a subscription was torn down
+func TestSubscription_Err(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		eventHandler := EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		}
+
+		t.Run("unsubscribe", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler)
+			require.NoError(t, err)
+			select {
+			case <-subscription.Done():
+				t.Fatal("done before unsubscribing")
+			default:
+			}
+
+			require.NoError(t, subscription.Unsubscribe())
+			<-subscription.Done()
+			assert.NoError(t, subscription.Err())
+		})
+
+		t.Run("context cancelled", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+			ctx, cancel := context.WithCancel(context.Background())
+
+			subscription, err := client.Subscribe(ctx, testSubscriptionID, 100, eventHandler)
+			require.NoError(t, err)
+			cancel()
+			assert.ErrorIs(t, subscription.Wait(), context.Canceled)
+			assert.ErrorIs(t, subscription.Err(), context.Canceled)
+		})
+
+		t.Run("run until cancelled", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+			ctx, cancel := context.WithCancel(context.Background())
+
+			result := make(chan error, 1)
+			go func() {
+				result <- client.Run(ctx, testSubscriptionID, 100, eventHandler)
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+			cancel()
+
+			select {
+			case err := <-result:
+				assert.ErrorIs(t, err, context.Canceled)
+			case <-time.After(5 * time.Second):
+				t.Fatal("run did not return")
+			}
+		})
+
+		t.Run("run until block", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+
+			result := make(chan error, 1)
+			go func() {
+				result <- client.Run(context.Background(), testSubscriptionID, 100, eventHandler, WithUntilBlock(100))
+			}()
+			controlChannel := "query:" + testSubscriptionID + ":control"
+			server.waitSubscribed(controlChannel)
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+
+			select {
+			case err := <-result:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("run did not return")
+			}
+		})
+
+		t.Run("channels are closed", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.rejectConnections(100)
+			client := server.newClient(WithReconnectBackoff(time.Millisecond, time.Millisecond, 1), WithMaxReconnectAttempts(1))
+
+			subscription, err := client.SubscribeChan(context.Background(), testSubscriptionID, 100, WithChanPolicy(ChanPolicyDrop))
+			require.NoError(t, err)
+			assert.ErrorIs(t, subscription.Wait(), ErrMaxReconnectAttempts)
+
+			require.Eventually(t, func() bool {
+				select {
+				case _, ok := <-subscription.Transactions:
+					return !ok
+				default:
+					return false
+				}
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	})
+}
+
+// TestSubscribe_PanicRecovery will test that a panicking handler does not stop the subscription
+func TestSubscribe_PanicRecovery(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		transactions := make(chan *models.TransactionResponse, 1)
+		errs := make(chan error, 1)
+		var calls int
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				calls++
+				if calls == 1 {
+					panic("boom")
+				}
+				transactions <- tx
+			},
+			OnStatus: func(*models.ControlResponse) {},
+			OnError:  func(err error) { errs <- err },
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, "tx-1")
+		server.publishTransaction(mainChannel, "tx-2")
+
+		select {
+		case err = <-errs:
+			var panicErr *PanicError
+			require.ErrorAs(t, err, &panicErr)
+			assert.Equal(t, "OnTransaction", panicErr.Handler)
+			assert.Equal(t, "boom", panicErr.Value)
+			assert.Contains(t, err.Error(), "subscription_test.go")
+		case <-time.After(5 * time.Second):
+			t.Fatal("panic not reported")
+		}
+
+		select {
+		case tx := <-transactions:
+			assert.Equal(t, "tx-2", tx.Id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction after the panic not received")
+		}
+	})
+}
+
+// TestSubscribe_WithQueueSize will test the overflow policies of the queue between the connection and the handler
+func TestSubscribe_WithQueueSize(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		tests := []struct {
+			name     string
+			policy   OverflowPolicy
+			expected []string
+		}{
+			{name: "drop newest", policy: OverflowPolicyDropNewest, expected: []string{"tx-1", "tx-2", "tx-3"}},
+			{name: "drop oldest", policy: OverflowPolicyDropOldest, expected: []string{"tx-1", "tx-4", "tx-5"}},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				server := newFakeServer(t)
+				client := server.newClient()
+
+				started := make(chan struct{})
+				release := make(chan struct{})
+				var mu sync.Mutex
+				var handled []string
+				dropped := make(chan *models.ControlResponse, 1)
+				subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+					OnTransaction: func(tx *models.TransactionResponse) {
+						if tx.Id == "tx-1" {
+							close(started)
+							<-release
+						}
+						mu.Lock()
+						handled = append(handled, tx.Id)
+						mu.Unlock()
+					},
+					OnStatus: func(status *models.ControlResponse) {
+						if status.StatusCode == uint32(StatusDropped) {
+							dropped <- status
+						}
+					},
+					OnError: func(error) {},
+				}, WithQueueSize(2), WithOverflowPolicy(test.policy))
+				require.NoError(t, err)
+				defer func() {
+					_ = subscription.Unsubscribe()
+				}()
+
+				mainChannel := "query:" + testSubscriptionID + ":100"
+				server.waitSubscribed(mainChannel)
+				server.publishTransaction(mainChannel, "tx-1")
+				<-started
+				for _, id := range []string{"tx-2", "tx-3", "tx-4", "tx-5"} {
+					server.publishTransaction(mainChannel, id)
+				}
+
+				require.Eventually(t, func() bool {
+					return subscription.Stats().DroppedMessages == 2
+				}, 5*time.Second, 10*time.Millisecond)
+				assert.Equal(t, 2, subscription.Stats().QueueDepth)
+				close(release)
+
+				select {
+				case status := <-dropped:
+					assert.Contains(t, status.Message, "Dropped 2 messages")
+				case <-time.After(5 * time.Second):
+					t.Fatal("dropped status not received")
+				}
+				require.Eventually(t, func() bool {
+					mu.Lock()
+					defer mu.Unlock()
+					return len(handled) == len(test.expected)
+				}, 5*time.Second, 10*time.Millisecond)
+				mu.Lock()
+				assert.Equal(t, test.expected, handled)
+				mu.Unlock()
+			})
+		}
+	})
+}
+
+// TestSubscribe_WithQueueSizeControl will test that the overflow policy does not drop control messages
+func TestSubscribe_WithQueueSizeControl(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		for _, policy := range []OverflowPolicy{OverflowPolicyDropOldest, OverflowPolicyDropNewest} {
+			server := newFakeServer(t)
+			started := make(chan struct{})
+			release := make(chan struct{})
+			blocks := make(chan uint32, 1)
+			subscription, err := server.newClient().Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(tx *models.TransactionResponse) {
+					if tx.Id == "tx-1" {
+						close(started)
+						<-release
+					}
+				},
+				OnBlockDone: func(height uint32, _ uint64) {
+					blocks <- height
+				},
+			}, WithQueueSize(2), WithOverflowPolicy(policy))
+			require.NoError(t, err)
+
+			mainChannel := "query:" + testSubscriptionID + ":100"
+			controlChannel := "query:" + testSubscriptionID + ":control"
+			server.waitSubscribed(mainChannel)
+			server.waitSubscribed(controlChannel)
+			server.publishTransaction(mainChannel, "tx-1")
+			<-started
+			server.publishTransaction(mainChannel, "tx-2")
+			server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100})
+			require.Eventually(t, func() bool {
+				return subscription.Stats().ControlReceived == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			for _, id := range []string{"tx-3", "tx-4", "tx-5"} {
+				server.publishTransaction(mainChannel, id)
+			}
+			require.Eventually(t, func() bool {
+				return subscription.Stats().DroppedMessages == 3
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, 2, subscription.Stats().QueueDepth)
+			close(release)
+
+			select {
+			case height := <-blocks:
+				assert.Equal(t, uint32(100), height)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("block done dropped by policy %d", policy)
+			}
+			_ = subscription.Unsubscribe()
+		}
+	})
+}
+
+// publishBlock publishes the transactions of a block on the main channel followed by its block done message
+func (f *fakeServer) publishBlock(subscriptionID string, fromBlock uint64, height uint32, transactions int) {
+	mainChannel := "query:" + subscriptionID + ":" + strconv.FormatUint(fromBlock, 10)
+	for i := 0; i < transactions; i++ {
+		f.publishMessage(mainChannel, &models.TransactionResponse{Id: "tx-" + strconv.Itoa(i), BlockHeight: height})
+	}
+	f.publishMessage("query:"+subscriptionID+":control", &models.ControlResponse{
+		StatusCode:   uint32(SubscriptionBlockDone),
+		Block:        height,
+		Transactions: uint64(transactions),
+	})
+}
+
+// TestSubscribe_WithHandlerConcurrency will test that a block is only done after all its transactions were handled,
+// with OnTransaction and with OnTransactionCtx
+func TestSubscribe_WithHandlerConcurrency(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		const transactions = 50
+
+		for _, withContext := range []bool{false, true} {
+			name := "OnTransaction"
+			if withContext {
+				name = "OnTransactionCtx"
+			}
+			t.Run(name, func(t *testing.T) {
+				server := newFakeServer(t)
+				client := server.newClient()
+
+				var handled, running, maxRunning, wrongBlock int32
+				handle := func(*models.TransactionResponse) {
+					current := atomic.AddInt32(&running, 1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					atomic.AddInt32(&handled, 1)
+				}
+				blocks := make(chan int32, 2)
+				eventHandler := EventHandler{
+					OnStatus:    func(*models.ControlResponse) {},
+					OnBlockDone: func(uint32, uint64) { blocks <- atomic.LoadInt32(&handled) },
+					OnError:     func(error) {},
+				}
+				if withContext {
+					eventHandler.OnTransactionCtx = func(ctx MessageContext, tx *models.TransactionResponse) {
+						if ctx.Block != tx.BlockHeight {
+							atomic.AddInt32(&wrongBlock, 1)
+						}
+						handle(tx)
+					}
+				} else {
+					eventHandler.OnTransaction = handle
+				}
+				subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler,
+					WithHandlerConcurrency(8))
+				require.NoError(t, err)
+				defer func() {
+					_ = subscription.Unsubscribe()
+				}()
+
+				server.waitSubscribed("query:" + testSubscriptionID + ":100")
+				server.waitSubscribed("query:" + testSubscriptionID + ":control")
+				server.publishBlock(testSubscriptionID, 100, 100, transactions)
+				server.publishBlock(testSubscriptionID, 100, 101, transactions)
+
+				for _, expected := range []int32{transactions, 2 * transactions} {
+					select {
+					case count := <-blocks:
+						assert.Equal(t, expected, count)
+					case <-time.After(5 * time.Second):
+						t.Fatal("block done not received")
+					}
+				}
+				assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1))
+				assert.Zero(t, atomic.LoadInt32(&wrongBlock))
+			})
+		}
+	})
+}
+
+// BenchmarkSubscribe_HandlerConcurrency will compare the throughput of a handler doing I/O with and without
+// concurrent handling
+func BenchmarkSubscribe_HandlerConcurrency(b *testing.B) {
+	for _, concurrency := range []int{1, 16} {
+		b.Run("concurrency "+strconv.Itoa(concurrency), func(b *testing.B) {
+			server := newFakeServer(b)
+			client := server.newClient()
+
+			blockDone := make(chan struct{})
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {
+					time.Sleep(100 * time.Microsecond)
+				},
+				OnStatus:    func(*models.ControlResponse) {},
+				OnBlockDone: func(uint32, uint64) { close(blockDone) },
+				OnError:     func(error) {},
+			}, WithHandlerConcurrency(concurrency))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":control")
+
+			b.ResetTimer()
+			server.publishBlock(testSubscriptionID, 100, 100, b.N)
+			<-blockDone
+		})
+	}
+}
+
+// TestSubscribe_OnBlock will test receiving the transactions batched per block
+func TestSubscribe_OnBlock(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		type batch struct {
+			height uint32
+			size   int
+		}
+		batches := make(chan batch, 10)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnBlock: func(height uint32, transactions []*models.TransactionResponse) {
+				batches <- batch{height: height, size: len(transactions)}
+			},
+			OnStatus: func(*models.ControlResponse) {},
+			OnError:  func(error) {},
+		}, WithMaxBatchSize(4))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		server.waitSubscribed("query:" + testSubscriptionID + ":100")
+		server.waitSubscribed("query:" + testSubscriptionID + ":control")
+		server.publishBlock(testSubscriptionID, 100, 100, 10)
+		server.publishBlock(testSubscriptionID, 100, 101, 3)
+
+		for _, expected := range []batch{{100, 4}, {100, 4}, {100, 2}, {101, 3}} {
+			select {
+			case received := <-batches:
+				assert.Equal(t, expected, received)
+			case <-time.After(5 * time.Second):
+				t.Fatal("batch not received")
+			}
+		}
+	})
+}
+
+// TestSubscription_Stats will test the statistics of a subscription across a reconnect
+func TestSubscription_Stats(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(10*time.Millisecond, 10*time.Millisecond, 1))
+
+		blocks := make(chan uint32, 2)
+		mempool := make(chan struct{}, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnMempool:     func(*models.TransactionResponse) { mempool <- struct{}{} },
+			OnStatus:      func(*models.ControlResponse) {},
+			OnBlockDone:   func(height uint32, _ uint64) { blocks <- height },
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		before := time.Now()
+		server.waitSubscribed("query:" + testSubscriptionID + ":100")
+		server.waitSubscribed("query:" + testSubscriptionID + ":mempool")
+		server.publishBlock(testSubscriptionID, 100, 100, 3)
+		server.publishTransaction("query:"+testSubscriptionID+":mempool", "mempool-1")
+		select {
+		case <-blocks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("block done not received")
+		}
+		select {
+		case <-mempool:
+		case <-time.After(5 * time.Second):
+			t.Fatal("mempool transaction not received")
+		}
+
+		server.disconnectAll()
+		require.Eventually(t, func() bool {
+			return len(server.dialTimes()) == 2 && server.Connections() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		server.waitSubscribed("query:" + testSubscriptionID + ":100")
+		server.publishBlock(testSubscriptionID, 100, 101, 2)
+		select {
+		case <-blocks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("block done not received after reconnecting")
+		}
+
+		stats := subscription.Stats()
+		assert.Equal(t, uint64(5), stats.TransactionsReceived)
+		assert.Equal(t, uint64(1), stats.MempoolReceived)
+		assert.Equal(t, uint64(2), stats.ControlReceived)
+		assert.Equal(t, uint64(101), stats.LastBlock)
+		assert.False(t, stats.LastBlockTime.Before(before))
+		assert.Equal(t, uint64(1), stats.Reconnects)
+		assert.Equal(t, uint64(0), stats.DroppedMessages)
+	})
+}
+
+// TestUnsubscribe_Errors will test that Unsubscribe reports the failures of all channels
+func TestUnsubscribe_Errors(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		t.Run("not subscribed", func(t *testing.T) {
+			assert.ErrorIs(t, client.Unsubscribe(), ErrNotSubscribed)
+			assert.ErrorIs(t, client.Unsubscribe("unknown"), ErrNotSubscribed)
+			assert.ErrorIs(t, (*Client)(nil).Unsubscribe(), ErrNotSubscribed)
+			assert.ErrorIs(t, (*Subscription)(nil).Unsubscribe(), ErrNotSubscribed)
+
+			// the errors of several subscriptions are matched before Go 1.20 too
+			err := client.Unsubscribe("unknown", "other")
+			var multiErr *multiError
+			require.ErrorAs(t, err, &multiErr)
+			assert.Len(t, multiErr.errs, 2)
+			assert.True(t, multiErr.Is(ErrNotSubscribed))
+			assert.False(t, multiErr.Is(ErrNotFound))
+			var itemErr *BatchItemError
+			require.True(t, joinErrors([]error{err, &BatchItemError{TxID: "tx", Err: ErrNotFound}}).(*multiError).As(&itemErr))
+			assert.Equal(t, "tx", itemErr.TxID)
+		})
+
+		t.Run("all channels", func(t *testing.T) {
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnMempool:     func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			})
+			require.NoError(t, err)
+			server.waitSubscribed("query:" + testSubscriptionID + ":mempool")
+
+			// unsubscribing fails for every channel once the connection is closed
+			subscription.mu.Lock()
+			subscription.centrifugeClient.Close()
+			subscription.mu.Unlock()
+
+			err = client.Unsubscribe(testSubscriptionID, "unknown")
+			require.ErrorIs(t, err, centrifuge.ErrClientClosed)
+			require.ErrorIs(t, err, ErrNotSubscribed)
+			for _, channel := range []string{channelMain, channelMempool, channelControl} {
+				assert.Contains(t, err.Error(), "failed to unsubscribe from "+channel+" channel")
+			}
+			assert.Nil(t, client.GetSubscription(testSubscriptionID))
+			select {
+			case <-subscription.Done():
+			default:
+				t.Fatal("subscription was not torn down")
+			}
+		})
+	})
+}
+
+// TestSubscribe_InProgress will test subscribing to an ID while another Subscribe of it has not returned
+func TestSubscribe_InProgress(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		entered := make(chan struct{})
+		release := make(chan struct{})
+		client := server.newClient(WithTokenProvider(TokenProviderFunc(func(context.Context, string) (string, error) {
+			close(entered)
+			<-release
+			return testToken, nil
+		})))
+		handler := EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		}
+
+		subscribed := make(chan error, 1)
+		go func() {
+			_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, handler)
+			subscribed <- err
+		}()
+		<-entered
+
+		_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, handler)
+		assert.ErrorIs(t, err, ErrSubscriptionInProgress)
+
+		close(release)
+		require.NoError(t, <-subscribed)
+		_, err = client.Subscribe(context.Background(), testSubscriptionID, 100, handler)
+		assert.ErrorIs(t, err, ErrAlreadySubscribed)
+		require.NoError(t, client.Unsubscribe())
+	})
+}
+
+// TestSubscribe_ConcurrentUse will test racing Subscribe and Unsubscribe from many goroutines
+func TestSubscribe_ConcurrentUse(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/get/"+testTxID, http.StatusOK, `{"id":"`+testTxID+`"}`)
+		httpClient := &http.Client{Transport: &http.Transport{}}
+		timeouts := DefaultWebsocketTimeouts
+		timeouts.Read = time.Second
+		client := server.newClient(WithHTTPClient(server.URL, httpClient), WithWebsocketTimeouts(timeouts))
+		// the first request starts the background goroutines of the runtime that outlive it
+		_, err := client.GetTransaction(context.Background(), testTxID)
+		require.NoError(t, err)
+		httpClient.CloseIdleConnections()
+		goroutines := runtime.NumGoroutine()
+
+		handler := EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					subscriptionID := "subscription-" + strconv.Itoa((i+j)%3)
+					subscription, err := client.Subscribe(context.Background(), subscriptionID, 100, handler)
+					if err != nil {
+						assert.True(t, errors.Is(err, ErrAlreadySubscribed) || errors.Is(err, ErrSubscriptionInProgress), err)
+					}
+					switch j % 3 {
+					case 0:
+						if subscription != nil {
+							_ = subscription.Unsubscribe()
+						}
+					case 1:
+						err = client.Unsubscribe(subscriptionID)
+						if err != nil {
+							assert.ErrorIs(t, err, ErrNotSubscribed)
+						}
+					default:
+						_, err = client.GetTransaction(context.Background(), testTxID)
+						assert.NoError(t, err)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		err = client.Unsubscribe()
+		if err != nil {
+			assert.ErrorIs(t, err, ErrNotSubscribed)
+		}
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, client.GetSubscription("subscription-"+strconv.Itoa(i)))
+		}
+		require.Eventually(t, func() bool {
+			return server.Connections() == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		httpClient.CloseIdleConnections()
+		// commands still in flight when a connection closes are only dropped by centrifuge after its read timeout
+		assert.Eventually(t, func() bool {
+			return runtime.NumGoroutine() <= goroutines
+		}, 5*time.Second, 50*time.Millisecond, "goroutines leaked")
+	})
+}
+
+// TestSubscription_channelName will test routing channels by their exact name
+func TestSubscription_channelName(t *testing.T) {
+	tests := []struct {
+		subscriptionID string
+		channel        string
+		name           string
+		ok             bool
+	}{
+		{"sub", "query:sub:control", channelControl, true},
+		{"sub", "query:sub:mempool", channelMempool, true},
+		{"sub", "query:sub:100", channelMain, true},
+		{"sub", "query:sub:100:2", channelMain, true},
+		{"control", "query:control:100", channelMain, true},
+		{"mempool", "query:mempool:100", channelMain, true},
+		{"my:control", "query:my:control:100", channelMain, true},
+		{"my:control", "query:my:control:mempool", channelMempool, true},
+		{"sub", "query:sub:control:1", "", false},
+		{"sub", "query:sub:100:2:3", "", false},
+		{"sub", "query:sub:block", "", false},
+		{"sub", "query:sub:", "", false},
+		{"sub", "query:sub", "", false},
+		{"sub", "query:other:control", "", false},
+		{"sub", "query:subscription:control", "", false},
+		{"sub", "sub:control", "", false},
+		{"control", "query:mempool:control", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.subscriptionID+"/"+test.channel, func(t *testing.T) {
+			s := &Subscription{SubscriptionID: test.subscriptionID}
+			name, ok := s.channelName(test.channel)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.name, name)
+		})
+	}
+}
+
+// TestSubscription_onServerPublication will test routing server-side publications of adversarially named subscriptions
+func TestSubscription_onServerPublication(t *testing.T) {
+	for _, subscriptionID := range []string{"control", "mempool", "mempool:control", "x:mempool"} {
+		t.Run(subscriptionID, func(t *testing.T) {
+			var routed []string
+			recorder := &statusRecorder{}
+			handler := EventHandler{
+				OnTransaction: func(tx *models.TransactionResponse) {
+					routed = append(routed, "transaction "+tx.Id)
+				},
+				OnMempool: func(tx *models.TransactionResponse) {
+					routed = append(routed, "mempool "+tx.Id)
+				},
+				OnStatus: func(response *models.ControlResponse) {
+					routed = append(routed, "status "+response.Message)
+				},
+				OnError: recorder.onError,
+			}
+			client, err := New(WithHTTP("localhost"))
+			require.NoError(t, err)
+			s := &Subscription{SubscriptionID: subscriptionID, EventHandler: handler, client: client}
+
+			s.onServerPublication(handler, "query:"+subscriptionID+":100", 0, []byte(`{"id":"mined"}`))
+			s.onServerPublication(handler, "query:"+subscriptionID+":100:2", 0, []byte(`{"id":"paged"}`))
+			s.onServerPublication(handler, "query:"+subscriptionID+":mempool", 0, []byte(`{"id":"pending"}`))
+			s.onServerPublication(handler, "query:"+subscriptionID+":control", 0, []byte(`{"message":"done"}`))
+			s.onServerPublication(handler, "query:other:control", 0, []byte(`{"message":"other"}`))
+			s.onServerPublication(handler, "query:"+subscriptionID+":control:1", 0, []byte(`{"message":"nested"}`))
+
+			assert.Equal(t, []string{"transaction mined", "transaction paged", "mempool pending", "status done"}, routed)
+			require.Len(t, recorder.errors, 2)
+			var unknownErr *UnknownChannelError
+			require.ErrorAs(t, recorder.errors[0], &unknownErr)
+			assert.ErrorIs(t, unknownErr, ErrUnknownChannel)
+			assert.Equal(t, "query:other:control", unknownErr.Channel)
+			assert.Equal(t, `{"message":"other"}`, string(unknownErr.Data))
+			assert.ErrorIs(t, recorder.errors[1], ErrUnknownChannel)
+		})
+	}
+}
+
+// TestSubscribe_OnUnknownChannel will test passing publications on unknown channels to OnUnknownChannel
+func TestSubscribe_OnUnknownChannel(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		server.SubscribeServerSide("query:" + testSubscriptionID + ":control:extra")
+		client := server.newClient()
+
+		recorder := &statusRecorder{}
+		received := make(chan string, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+			OnUnknownChannel: func(channel string, data []byte) {
+				received <- channel + " " + string(data)
+			},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed("query:" + testSubscriptionID + ":control")
+
+		server.publish("query:"+testSubscriptionID+":control:extra", []byte(`{"raw":true}`))
+		select {
+		case got := <-received:
+			assert.Equal(t, "query:"+testSubscriptionID+":control:extra {\"raw\":true}", got)
+		case <-time.After(5 * time.Second):
+			t.Fatal("publication on unknown channel not received")
+		}
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		assert.Empty(t, recorder.errors)
+	})
+}
+
+// TestSubscription_onServerPublicationEncodings will test decoding server-side publications encoded as protobuf and JSON
+func TestSubscription_onServerPublicationEncodings(t *testing.T) {
+	client, err := New(WithHTTP("localhost"))
+	require.NoError(t, err)
+	expected := &models.TransactionResponse{}
+	fixture, err := os.ReadFile("testdata/publication_transaction.pb")
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(fixture, expected))
+	require.Equal(t, "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", expected.Id)
+
+	for _, encoding := range []string{"pb", "json"} {
+		data, err := os.ReadFile("testdata/publication_transaction." + encoding)
+		require.NoError(t, err)
+		for _, channel := range []string{"100", "mempool"} {
+			t.Run(encoding+"/"+channel, func(t *testing.T) {
+				var transactions, mempool []*models.TransactionResponse
+				recorder := &statusRecorder{}
+				handler := EventHandler{
+					OnTransaction: func(tx *models.TransactionResponse) {
+						transactions = append(transactions, tx)
+					},
+					OnMempool: func(tx *models.TransactionResponse) {
+						mempool = append(mempool, tx)
+					},
+					OnStatus: recorder.onStatus,
+					OnError:  recorder.onError,
+				}
+				s := &Subscription{SubscriptionID: testSubscriptionID, EventHandler: handler, client: client}
+
+				s.onServerPublication(handler, "query:"+testSubscriptionID+":"+channel, 0, data)
+
+				assert.Empty(t, recorder.errors)
+				received := transactions
+				if channel == channelMempool {
+					assert.Empty(t, transactions)
+					received = mempool
+				}
+				require.Len(t, received, 1)
+				assert.True(t, proto.Equal(expected, received[0]), "got %v", received[0])
+			})
+		}
+	}
+
+	t.Run("control", func(t *testing.T) {
+		control := &models.ControlResponse{StatusCode: uint32(SubscriptionBlockDone), Block: 100, Transactions: 3}
+		protoData, err := proto.Marshal(control)
+		require.NoError(t, err)
+		recorder := &statusRecorder{}
+		handler := EventHandler{OnStatus: recorder.onStatus, OnError: recorder.onError}
+		s := &Subscription{SubscriptionID: testSubscriptionID, EventHandler: handler, client: client}
+
+		s.onServerPublication(handler, "query:"+testSubscriptionID+":control", 0, protoData)
+		s.onServerPublication(handler, "query:"+testSubscriptionID+":control", 0,
+			[]byte(`{"statusCode":200,"block":100,"transactions":3}`))
+
+		assert.Empty(t, recorder.errors)
+		require.Len(t, recorder.statuses, 2)
+		for _, status := range recorder.statuses {
+			assert.True(t, proto.Equal(control, status), "got %v", status)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		recorder := &statusRecorder{}
+		handler := EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {
+				t.Error("invalid publication passed on")
+			},
+			OnError: recorder.onError,
+		}
+		s := &Subscription{SubscriptionID: testSubscriptionID, EventHandler: handler, client: client}
+
+		s.onServerPublication(handler, "query:"+testSubscriptionID+":100", 7, []byte{0xff, 0xff})
+
+		require.Len(t, recorder.errors, 1)
+		var decodeErr *DecodeError
+		require.ErrorAs(t, recorder.errors[0], &decodeErr)
+		assert.Equal(t, "query:"+testSubscriptionID+":100", decodeErr.Channel)
+		assert.Equal(t, uint64(7), decodeErr.Offset)
+		assert.Equal(t, []byte{0xff, 0xff}, decodeErr.Data)
+		assert.Error(t, decodeErr.Err)
+	})
+}
+
+// TestSubscribe_ServerSidePublication will test receiving a publication on a server-side channel
+func TestSubscribe_ServerSidePublication(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		channel := "query:" + testSubscriptionID + ":200:1"
+		server := newFakeServer(t)
+		server.SubscribeServerSide(channel)
+		client := server.newClient()
+
+		recorder := &statusRecorder{}
+		received := make(chan *models.TransactionResponse, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				received <- tx
+			},
+			OnStatus: recorder.onStatus,
+			OnError:  recorder.onError,
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(channel)
+
+		fixture := "testdata/publication_transaction.pb"
+		if server.json {
+			fixture = "testdata/publication_transaction.json"
+		}
+		data, err := os.ReadFile(fixture)
+		require.NoError(t, err)
+		server.publish(channel, data)
+		select {
+		case tx := <-received:
+			assert.Equal(t, "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", tx.Id)
+			assert.Equal(t, uint32(1), tx.BlockHeight)
+		case <-time.After(5 * time.Second):
+			t.Fatal("server-side publication not received")
+		}
+		assert.Equal(t, uint64(1), subscription.Stats().TransactionsReceived)
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		assert.Empty(t, recorder.errors)
+	})
+}
+
+// TestSubscribe_ServerSideUntilBlock will test bounding server-side publications by WithUntilBlock
+func TestSubscribe_ServerSideUntilBlock(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		channel := "query:" + testSubscriptionID + ":90"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server := newFakeServer(t)
+		server.SubscribeServerSide(channel)
+		client := server.newClient()
+
+		received := make(chan uint32, 3)
+		doneBlocks := make(chan uint32, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				received <- tx.BlockHeight
+			},
+			OnBlockDone: func(block uint32, _ uint64) {
+				doneBlocks <- block
+			},
+			OnStatus: func(*models.ControlResponse) {},
+			OnError:  func(error) {},
+		}, WithUntilBlock(101))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(channel)
+		server.waitSubscribed(controlChannel)
+
+		// the transaction above the bound is published first, it is handled before the others arrive
+		for _, height := range []uint32{102, 100, 101} {
+			server.publishMessage(channel, &models.TransactionResponse{Id: "tx-" + strconv.Itoa(int(height)), BlockHeight: height})
+		}
+		for _, height := range []uint32{100, 101} {
+			select {
+			case received := <-received:
+				assert.Equal(t, height, received)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("transaction of block %d not received", height)
+			}
+		}
+
+		// the fake server pushes the control channel to the client-side subscription, the server-side one is called
+		// directly
+		subscription.onServerPublication(subscription.dispatched(), controlChannel, 0, []byte(`{"statusCode":200,"block":101}`))
+
+		select {
+		case <-subscription.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscription not done")
+		}
+		assert.Equal(t, uint32(101), <-doneBlocks)
+		assert.Empty(t, received)
+		stats := subscription.Stats()
+		assert.Equal(t, uint64(3), stats.TransactionsReceived)
+		assert.Equal(t, uint64(1), stats.ControlReceived)
+	})
+}
+
+// TestSubscribe_DecodeError will test passing the raw payload and channel of publications that fail to decode
+func TestSubscribe_DecodeError(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+
+		type raw struct {
+			channel string
+			offset  uint64
+			data    []byte
+		}
+		var mu sync.Mutex
+		var raws []raw
+		var transactions []string
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				transactions = append(transactions, tx.Id)
+			},
+			OnStatus: recorder.onStatus,
+			OnError:  recorder.onError,
+			OnRawPublication: func(channel string, offset uint64, data []byte) {
+				mu.Lock()
+				defer mu.Unlock()
+				raws = append(raws, raw{channel: channel, offset: offset, data: data})
+			},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(controlChannel)
+
+		corrupt := server.invalidPublication()
+		server.publish(mainChannel, corrupt)
+		server.publishTransaction(mainChannel, "tx-1")
+		server.publish(controlChannel, corrupt)
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			return len(raws) == 3 && len(transactions) == 1 && len(recorder.errors) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+
+		mu.Lock()
+		assert.Equal(t, []string{"tx-1"}, transactions)
+		assert.Equal(t, mainChannel, raws[0].channel)
+		assert.Equal(t, corrupt, raws[0].data)
+		assert.Equal(t, mainChannel, raws[1].channel)
+		assert.Equal(t, controlChannel, raws[2].channel)
+		assert.Equal(t, corrupt, raws[2].data)
+		mu.Unlock()
+
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		for i, channel := range []string{mainChannel, controlChannel} {
+			var decodeErr *DecodeError
+			require.ErrorAs(t, recorder.errors[i], &decodeErr)
+			assert.Equal(t, channel, decodeErr.Channel)
+			assert.Equal(t, corrupt, decodeErr.Data)
+			assert.NotZero(t, decodeErr.Offset)
+			assert.NotNil(t, decodeErr.Err)
+			assert.Contains(t, decodeErr.Error(), channel)
+		}
+	})
+}
+
+// TestSubscribe_NilEventHandler will test subscribing without any callback, through connecting, publications,
+// reconnecting and cancelling
+func TestSubscribe_NilEventHandler(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		for name, opts := range map[string][]SubscribeOption{
+			"default":     nil,
+			"no recovery": {WithPanicRecovery(false)},
+			"queue":       {WithQueueSize(10), WithOverflowPolicy(OverflowPolicyDropNewest)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				server := newFakeServer(t)
+				client := server.newClient(WithReconnectBackoff(10*time.Millisecond, 10*time.Millisecond, 2))
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				subscription, err := client.Subscribe(ctx, testSubscriptionID, 100, EventHandler{
+					OnTransaction: func(*models.TransactionResponse) {},
+				}, opts...)
+				require.NoError(t, err)
+
+				controlChannel := "query:" + testSubscriptionID + ":control"
+				server.waitSubscribed(controlChannel)
+				server.publishMessage(controlChannel, &models.ControlResponse{
+					StatusCode: uint32(SubscriptionBlockDone), Block: 100,
+				})
+				server.publish(controlChannel, server.invalidPublication())
+				require.Eventually(t, func() bool {
+					return subscription.Stats().Errors == 1
+				}, 5*time.Second, 10*time.Millisecond)
+
+				server.disconnectAll()
+				require.Eventually(t, func() bool {
+					return subscription.Stats().Reconnects > 0 && server.subscribed(controlChannel)
+				}, 5*time.Second, 10*time.Millisecond)
+
+				cancel()
+				assert.ErrorIs(t, subscription.Wait(), context.Canceled)
+			})
+		}
+	})
+}
+
+// TestSubscribe_PartialEventHandler will test the handlers an event handler needs and the channels they subscribe to
+func TestSubscribe_PartialEventHandler(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		onTransaction := func(*models.TransactionResponse) {}
+		onTransactionE := func(*models.TransactionResponse) error { return nil }
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+
+		tests := []struct {
+			name         string
+			eventHandler EventHandler
+			opts         []SubscribeOption
+			channels     []string
+		}{
+			{name: "transactions", eventHandler: EventHandler{OnTransaction: onTransaction}, channels: []string{mainChannel}},
+			{
+				name:         "blocks",
+				eventHandler: EventHandler{OnBlock: func(uint32, []*models.TransactionResponse) {}},
+				channels:     []string{mainChannel},
+			},
+			{name: "mempool", eventHandler: EventHandler{OnMempool: onTransaction}, channels: []string{mempoolChannel}},
+			{
+				name:         "both",
+				eventHandler: EventHandler{OnTransaction: onTransaction, OnMempool: onTransaction},
+				channels:     []string{mainChannel, mempoolChannel},
+			},
+			{
+				name:         "error returning",
+				eventHandler: EventHandler{OnTransactionE: onTransactionE, OnMempoolE: onTransactionE},
+				channels:     []string{mainChannel, mempoolChannel},
+			},
+			{
+				name:         "mempool tracking",
+				eventHandler: EventHandler{OnConfirmed: func(*models.TransactionResponse, time.Time) {}},
+				opts:         []SubscribeOption{WithMempoolTracking(100, time.Minute)},
+				channels:     []string{mainChannel, mempoolChannel},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				server := newFakeServer(t)
+				client := server.newClient()
+				subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, test.eventHandler,
+					test.opts...)
+				require.NoError(t, err)
+				defer func() {
+					_ = subscription.Unsubscribe()
+				}()
+				for _, channel := range test.channels {
+					server.waitSubscribed(channel)
+				}
+			})
+		}
+
+		t.Run("no transaction handlers", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient()
+			for name, eventHandler := range map[string]EventHandler{
+				"empty": {},
+				"callbacks only": {
+					OnStatus:    func(*models.ControlResponse) {},
+					OnError:     func(error) {},
+					OnBlockDone: func(uint32, uint64) {},
+				},
+			} {
+				_, err := client.Subscribe(context.Background(), testSubscriptionID, 100, eventHandler)
+				assert.ErrorIs(t, err, ErrNoHandlers, name)
+			}
+			assert.Empty(t, server.dialTimes())
+			assert.Nil(t, client.GetSubscription(testSubscriptionID))
+		})
+
+		t.Run("errors are logged without OnError", func(t *testing.T) {
+			logger := &testLogger{}
+			server := newFakeServer(t)
+			client := server.newClient(WithLogger(logger))
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: onTransaction,
+			})
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+
+			server.waitSubscribed(mainChannel)
+			server.publish(mainChannel, server.invalidPublication())
+			require.Eventually(t, func() bool {
+				logger.mu.Lock()
+				defer logger.mu.Unlock()
+				for _, line := range logger.lines {
+					if strings.Contains(line, "subscription error") {
+						return true
+					}
+				}
+				return false
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, uint64(1), subscription.Stats().Errors)
+		})
+	})
+}
+
+// BenchmarkSubscription_sendStatus builds the connection statuses with and without an OnStatus callback
+func BenchmarkSubscription_sendStatus(b *testing.B) {
+	for _, reportStatus := range []bool{false, true} {
+		b.Run("on status "+strconv.FormatBool(reportStatus), func(b *testing.B) {
+			s := &Subscription{reportStatus: reportStatus}
+			onStatus := func(*models.ControlResponse) {}
+			channel := "query:" + testSubscriptionID + ":100"
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.sendStatus(onStatus, StatusSubscribed, "subscribed", func() string {
+					return "Subscribed to " + channel
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkSubscription_onServerPublication handles publications with loggers at different levels
+func BenchmarkSubscription_onServerPublication(b *testing.B) {
+	data, err := proto.Marshal(&models.TransactionResponse{Id: testTxID, BlockHeight: 100})
+	require.NoError(b, err)
+	channel := "query:" + testSubscriptionID + ":100"
+
+	for name, logger := range map[string]Logger{
+		"nop logger":     transports.NopLogger{},
+		"debug disabled": debugLogger{debug: false},
+		"debug enabled":  debugLogger{debug: true},
+	} {
+		b.Run(name, func(b *testing.B) {
+			client, err := New(WithHTTP("localhost"), WithLogger(logger))
+			require.NoError(b, err)
+			handler := EventHandler{OnTransaction: func(*models.TransactionResponse) {}}
+			s := &Subscription{SubscriptionID: testSubscriptionID, EventHandler: handler, client: client}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.onServerPublication(handler, channel, uint64(i), data)
+			}
+		})
+	}
+}
+
+// debugLogger is a LevelLogger discarding everything
+type debugLogger struct {
+	debug bool
+}
+
+func (l debugLogger) DebugEnabled() bool          { return l.debug }
+func (debugLogger) Debugf(string, ...interface{}) {}
+func (debugLogger) Infof(string, ...interface{})  {}
+func (debugLogger) Errorf(string, ...interface{}) {}
+
+// TestClient_SubscribeMempool will test subscribing to only the mempool, across reconnects
+func TestClient_SubscribeMempool(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server := newFakeServer(t)
+		client := server.newClient(WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+		mempool := make(chan string, 2)
+		subscription, err := client.SubscribeMempool(context.Background(), testSubscriptionID,
+			func(tx *models.TransactionResponse) {
+				mempool <- tx.Id
+			})
+		require.NoError(t, err)
+		server.waitSubscribed(mempoolChannel)
+		server.waitSubscribed(controlChannel)
+		assert.False(t, server.subscribed("query:"+testSubscriptionID+":0"))
+		assert.False(t, subscription.streamsBlocks())
+
+		server.publishTransaction(mempoolChannel, "tx-1")
+		assert.Equal(t, "tx-1", <-mempool)
+
+		server.DisconnectAll()
+		require.Eventually(t, func() bool {
+			return subscription.Stats().Reconnects > 0 && subscription.IsConnected() && server.subscribed(mempoolChannel)
+		}, 5*time.Second, 10*time.Millisecond)
+		server.publishTransaction(mempoolChannel, "tx-2")
+		assert.Equal(t, "tx-2", <-mempool)
+
+		require.NoError(t, client.Unsubscribe(testSubscriptionID))
+		require.Eventually(t, func() bool {
+			return !server.subscribed(mempoolChannel)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		_, err = client.SubscribeMempool(context.Background(), testSubscriptionID, nil)
+		assert.ErrorIs(t, err, ErrNoHandlers)
+		_, err = client.SubscribeMempool(context.Background(), testSubscriptionID, func(*models.TransactionResponse) {},
+			WithUntilBlock(100))
+		assert.ErrorIs(t, err, ErrInvalidUntilBlock)
+	})
+}
@@ -0,0 +1,2 @@
+
+@0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098@00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048(
\ No newline at end of file
@@ -0,0 +1,191 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/centrifugal/centrifuge-go"
+)
+
+// DefaultTokenRefreshLeeway is how long before it expires a token is refreshed at the latest, see
+// WithTokenRefreshLeeway
+const DefaultTokenRefreshLeeway = 30 * time.Second
+
+// tokenRefreshPoint is the part of the lifetime of a token after which it is refreshed
+const tokenRefreshPoint = 0.8
+
+// TokenProvider provides the tokens of subscriptions, for the first connection and every time the connection
+// asks for a new token, see WithTokenProvider
+type TokenProvider interface {
+	Token(ctx context.Context, subscriptionID string) (string, error)
+}
+
+// TokenProviderFunc is a function used as TokenProvider
+type TokenProviderFunc func(ctx context.Context, subscriptionID string) (string, error)
+
+// Token calls the function
+func (f TokenProviderFunc) Token(ctx context.Context, subscriptionID string) (string, error) {
+	return f(ctx, subscriptionID)
+}
+
+// transportTokenProvider is the default TokenProvider, getting a subscription token from JungleBus when the
+// transport has no token yet and refreshing the token of the transport otherwise
+type transportTokenProvider struct {
+	transport transports.Transport
+}
+
+// Token gets a subscription token or refreshes the token of the transport
+func (p transportTokenProvider) Token(ctx context.Context, subscriptionID string) (string, error) {
+	if p.transport.GetToken() == "" {
+		return p.transport.GetSubscriptionToken(ctx, subscriptionID)
+	}
+	return p.transport.RefreshToken(ctx)
+}
+
+// getTokenProvider returns the token provider of the client, the transport when none was set
+func (jb *Client) getTokenProvider() TokenProvider {
+	if jb.tokenProvider != nil {
+		return jb.tokenProvider
+	}
+	return transportTokenProvider{transport: jb.transport}
+}
+
+// tokenRefreshDelay returns how long to wait before refreshing the JWT, at 80% of its lifetime but at least the
+// leeway before it expires to allow for clock skew. False is returned when the token is not a JWT with an exp
+// claim or expires within the leeway. The signature is not verified.
+func tokenRefreshDelay(token string, now time.Time, leeway time.Duration) (time.Duration, bool) {
+	issued, expires, ok := tokenLifetime(token)
+	if !ok {
+		return 0, false
+	}
+	if issued.IsZero() {
+		issued = now
+	}
+	refreshAt := issued.Add(time.Duration(float64(expires.Sub(issued)) * tokenRefreshPoint))
+	if latest := expires.Add(-leeway); refreshAt.After(latest) {
+		refreshAt = latest
+	}
+	if !refreshAt.After(now) {
+		return 0, false
+	}
+	return refreshAt.Sub(now), true
+}
+
+// tokenExpired returns whether the JWT expires within the leeway, tokens that are not a JWT never expire
+func tokenExpired(token string, now time.Time, leeway time.Duration) bool {
+	_, expires, ok := tokenLifetime(token)
+	return ok && !expires.After(now.Add(leeway))
+}
+
+// tokenLifetime returns the iat and exp claims of the JWT, issued is zero when the token has no iat claim. False
+// is returned when the token is not a JWT with an exp claim. The signature is not verified.
+func tokenLifetime(token string) (issued, expires time.Time, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return issued, expires, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return issued, expires, false
+	}
+	var claims struct {
+		IssuedAt  float64 `json:"iat"`
+		ExpiresAt float64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt <= 0 {
+		return issued, expires, false
+	}
+
+	expires = time.Unix(int64(claims.ExpiresAt), 0)
+	if claims.IssuedAt > 0 && claims.IssuedAt < claims.ExpiresAt {
+		issued = time.Unix(int64(claims.IssuedAt), 0)
+	}
+	return issued, expires, true
+}
+
+// subscriptionToken returns the stored token of the subscription when it did not expire yet, a new token of the
+// token provider otherwise
+func (s *Subscription) subscriptionToken() (string, error) {
+	if store := s.client.tokenStore; store != nil {
+		token, err := store.Load(s.SubscriptionID)
+		switch {
+		case err == nil && token != "" && !tokenExpired(token, time.Now(), s.client.tokenRefreshLeeway):
+			return token, nil
+		case err != nil && !errors.Is(err, ErrTokenNotFound):
+			s.log(levelError, "failed to load token", "error", err)
+		}
+	}
+
+	token, err := s.client.getTokenProvider().Token(s.ctx, s.SubscriptionID)
+	if err != nil {
+		return "", err
+	}
+	s.saveToken(token)
+	return token, nil
+}
+
+// saveToken stores the token of the subscription in the token store, failing saves are sent to OnError
+func (s *Subscription) saveToken(token string) {
+	store := s.client.tokenStore
+	if store == nil || token == "" {
+		return
+	}
+	if err := store.Save(s.SubscriptionID, token); err != nil {
+		s.log(levelError, "failed to save token", "error", err)
+		s.dispatched().OnError(err)
+	}
+}
+
+// scheduleTokenRefresh refreshes the token of the connection before it expires, replacing the connection with
+// one using the new token. Tokens that are not a JWT are only refreshed when the connection asks for it.
+func (s *Subscription) scheduleTokenRefresh(centrifugeClient *centrifuge.Client) {
+	delay, ok := tokenRefreshDelay(s.token(), time.Now(), s.client.tokenRefreshLeeway)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokenTimer != nil {
+		s.tokenTimer.Stop()
+		s.tokenTimer = nil
+	}
+	if ok {
+		s.tokenTimer = time.AfterFunc(delay, func() {
+			s.renewConnection(centrifugeClient)
+		})
+	}
+}
+
+// renewConnection replaces the connection with one using a new token of the token provider
+func (s *Subscription) renewConnection(centrifugeClient *centrifuge.Client) {
+	if s.isStopped() || !s.isCurrent(centrifugeClient) {
+		return
+	}
+	eventHandler := s.dispatched()
+	if _, err := s.refreshToken(); err != nil {
+		// the connection is kept, it asks for a new token once the current one expires
+		eventHandler.OnError(err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.centrifugeClient != centrifugeClient || s.isStopped() {
+		s.mu.Unlock()
+		return
+	}
+	s.centrifugeClient = nil
+	s.mu.Unlock()
+	centrifugeClient.Close()
+
+	s.log(levelInfo, "token refreshed", "block", s.LastBlock())
+	s.sendStatus(eventHandler.OnStatus, StatusTokenRefreshed, "token refreshed", func() string {
+		return "Reconnecting with a refreshed token"
+	})
+	if err := s.connect(); err != nil {
+		eventHandler.OnError(err)
+		go s.resubscribe()
+	}
+}
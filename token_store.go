@@ -0,0 +1,76 @@
+package junglebus
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TokenStore persists the tokens of subscriptions across restarts, see WithTokenStore
+type TokenStore interface {
+	// Load returns the stored token, or ErrTokenNotFound when nothing was stored yet
+	Load(subscriptionID string) (string, error)
+	// Save stores the token of the subscription
+	Save(subscriptionID, token string) error
+}
+
+// FileTokenStore is a TokenStore keeping a file per subscription in a directory, readable by the owner only
+type FileTokenStore struct {
+	dir string
+}
+
+// NewFileTokenStore creates a token store in the given directory, creating it if needed
+func NewFileTokenStore(dir string) (*FileTokenStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{dir: dir}, nil
+}
+
+// Save writes the token to a temporary file first, so a crash never leaves a partial token behind
+func (f *FileTokenStore) Save(subscriptionID, token string) error {
+	tmp, err := os.CreateTemp(f.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err = tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err = tmp.WriteString(token); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(subscriptionID))
+}
+
+// Load reads the token of the subscription
+func (f *FileTokenStore) Load(subscriptionID string) (string, error) {
+	data, err := os.ReadFile(f.path(subscriptionID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = ErrTokenNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// path returns the file of the subscription, the ID is escaped to always stay inside the directory
+func (f *FileTokenStore) path(subscriptionID string) string {
+	return filepath.Join(f.dir, url.PathEscape(subscriptionID)+".token")
+}
@@ -0,0 +1,115 @@
+package junglebus
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileTokenStore will test saving and loading tokens from files
+func TestFileTokenStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tokens")
+	store, err := NewFileTokenStore(dir)
+	require.NoError(t, err)
+
+	t.Run("not found", func(t *testing.T) {
+		_, err = store.Load(testSubscriptionID)
+		assert.ErrorIs(t, err, ErrTokenNotFound)
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		require.NoError(t, store.Save(testSubscriptionID, "token-1"))
+		require.NoError(t, store.Save(testSubscriptionID, "token-2"))
+
+		token, loadErr := store.Load(testSubscriptionID)
+		require.NoError(t, loadErr)
+		assert.Equal(t, "token-2", token)
+	})
+
+	t.Run("readable by the owner only", func(t *testing.T) {
+		entries, readErr := os.ReadDir(dir)
+		require.NoError(t, readErr)
+		require.Len(t, entries, 1)
+		assert.Equal(t, testSubscriptionID+".token", entries[0].Name())
+
+		info, statErr := os.Stat(filepath.Join(dir, entries[0].Name()))
+		require.NoError(t, statErr)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("subscription ids are escaped", func(t *testing.T) {
+		require.NoError(t, store.Save("../escape", "token"))
+		token, loadErr := store.Load("../escape")
+		require.NoError(t, loadErr)
+		assert.Equal(t, "token", token)
+		_, statErr := os.Stat(filepath.Join(filepath.Dir(dir), "escape.token"))
+		assert.True(t, os.IsNotExist(statErr))
+	})
+}
+
+// TestWithTokenStore will test reusing stored tokens when subscribing
+func TestWithTokenStore(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		subscribe := func(t *testing.T, server *fakeServer, client *Client) {
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      func(*models.ControlResponse) {},
+				OnError:       func(error) {},
+			})
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = subscription.Unsubscribe()
+			})
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+		}
+
+		t.Run("stored token", func(t *testing.T) {
+			store, err := NewFileTokenStore(t.TempDir())
+			require.NoError(t, err)
+			stored := testJWT(time.Now(), time.Now().Add(time.Hour))
+			require.NoError(t, store.Save(testSubscriptionID, stored))
+
+			server := newFakeServer(t)
+			subscribe(t, server, server.newClient(WithTokenStore(store)))
+
+			assert.Equal(t, []string{stored}, server.ConnectTokens())
+			assert.Nil(t, server.requestHeaders("/v1/user/subscription-token"))
+		})
+
+		t.Run("expired token", func(t *testing.T) {
+			store, err := NewFileTokenStore(t.TempDir())
+			require.NoError(t, err)
+			require.NoError(t, store.Save(testSubscriptionID, testJWT(time.Now().Add(-time.Hour), time.Now().Add(-time.Minute))))
+
+			server := newFakeServer(t)
+			subscribe(t, server, server.newClient(WithTokenStore(store)))
+
+			assert.Equal(t, []string{testToken}, server.ConnectTokens())
+			token, err := store.Load(testSubscriptionID)
+			require.NoError(t, err)
+			assert.Equal(t, testToken, token)
+		})
+
+		t.Run("refreshed token", func(t *testing.T) {
+			store, err := NewFileTokenStore(t.TempDir())
+			require.NoError(t, err)
+			require.NoError(t, store.Save(testSubscriptionID, "rejected"))
+
+			server := newFakeServer(t)
+			server.ExpireToken("rejected")
+			subscribe(t, server, server.newClient(WithTokenStore(store), WithTokenProvider(&countingProvider{}),
+				WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2)))
+
+			assert.Equal(t, []string{"rejected", "provided-1"}, server.ConnectTokens())
+			token, err := store.Load(testSubscriptionID)
+			require.NoError(t, err)
+			assert.Equal(t, "provided-1", token)
+		})
+	})
+}
@@ -0,0 +1,269 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider provides the tokens "provided-1", "provided-2", ..., failing once fail is set
+type countingProvider struct {
+	mu    sync.Mutex
+	calls int
+	fail  bool
+}
+
+func (p *countingProvider) Token(_ context.Context, subscriptionID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if subscriptionID != testSubscriptionID {
+		return "", errors.New("unexpected subscription " + subscriptionID)
+	}
+	if p.fail {
+		return "", errors.New("auth service unavailable")
+	}
+	p.calls++
+	return "provided-" + strconv.Itoa(p.calls), nil
+}
+
+func (p *countingProvider) setFail(fail bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = fail
+}
+
+// TestWithTokenProvider will test getting the tokens of a subscription from a token provider
+func TestWithTokenProvider(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		newHandler := func(recorder *statusRecorder) EventHandler {
+			return EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      recorder.onStatus,
+				OnError:       recorder.onError,
+			}
+		}
+		mainChannel := "query:" + testSubscriptionID + ":100"
+
+		t.Run("first connection", func(t *testing.T) {
+			server := newFakeServer(t)
+			provider := &countingProvider{}
+			client := server.newClient(WithTokenProvider(provider), WithToken("static"))
+
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(&statusRecorder{}))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed(mainChannel)
+
+			assert.Equal(t, []string{"provided-1"}, server.ConnectTokens())
+			assert.Nil(t, server.requestHeaders("/v1/user/subscription-token"))
+		})
+
+		t.Run("expired token", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.ExpireToken("provided-1")
+			client := server.newClient(WithTokenProvider(&countingProvider{}),
+				WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(&statusRecorder{}))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed(mainChannel)
+
+			assert.Equal(t, []string{"provided-1", "provided-2"}, server.ConnectTokens())
+		})
+
+		t.Run("refresh failure", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.ExpireToken("provided-1")
+			provider := &countingProvider{}
+			client := server.newClient(WithTokenProvider(provider),
+				WithReconnectBackoff(time.Millisecond, 10*time.Millisecond, 2))
+
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(recorder))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			provider.setFail(true)
+
+			require.Eventually(t, func() bool {
+				return recorder.has(StatusTokenRefreshFailed)
+			}, 5*time.Second, 10*time.Millisecond)
+			recorder.mu.Lock()
+			var refreshErr error
+			for _, err := range recorder.errors {
+				if errors.Is(err, ErrTokenRefresh) {
+					refreshErr = err
+				}
+			}
+			recorder.mu.Unlock()
+			require.Error(t, refreshErr)
+			assert.Contains(t, refreshErr.Error(), "auth service unavailable")
+
+			provider.setFail(false)
+			server.waitSubscribed(mainChannel)
+			tokens := server.ConnectTokens()
+			assert.Equal(t, "provided-2", tokens[len(tokens)-1])
+		})
+	})
+}
+
+// TestTransportTokenProvider will test that the default token provider gets a subscription token
+func TestTransportTokenProvider(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed("query:" + testSubscriptionID + ":100")
+
+		assert.NotNil(t, server.requestHeaders("/v1/user/subscription-token"))
+		assert.Equal(t, []string{testToken}, server.ConnectTokens())
+	})
+}
+
+// testJWT returns an unsigned JWT issued and expiring at the times
+func testJWT(issued, expires time.Time) string {
+	payload := fmt.Sprintf(`{"sub":"test","iat":%d,"exp":%d}`, issued.Unix(), expires.Unix())
+	return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+}
+
+// TestTokenRefreshDelay will test when tokens are refreshed
+func TestTokenRefreshDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for name, test := range map[string]struct {
+		token  string
+		leeway time.Duration
+		delay  time.Duration
+		ok     bool
+	}{
+		"80% of the lifetime":   {testJWT(now, now.Add(time.Hour)), 30 * time.Second, 48 * time.Minute, true},
+		"issued earlier":        {testJWT(now.Add(-30*time.Minute), now.Add(30*time.Minute)), 0, 18 * time.Minute, true},
+		"leeway":                {testJWT(now, now.Add(time.Minute)), 30 * time.Second, 30 * time.Second, true},
+		"expires within leeway": {testJWT(now, now.Add(10*time.Second)), 30 * time.Second, 0, false},
+		"expired":               {testJWT(now.Add(-time.Hour), now.Add(-time.Minute)), 0, 0, false},
+		"no issued at":          {"e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700000100}`)) + ".c2ln", 0, 80 * time.Second, true},
+		"no expiry":             {"e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test"}`)) + ".c2ln", 0, 0, false},
+		"not a jwt":             {testToken, 0, 0, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			delay, ok := tokenRefreshDelay(test.token, now, test.leeway)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.delay, delay)
+		})
+	}
+}
+
+// TestSubscribe_TokenRefresh will test replacing the connection before the token expires
+func TestSubscribe_TokenRefresh(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		var mu sync.Mutex
+		var tokens []string
+		provider := TokenProviderFunc(func(context.Context, string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			// the first token is refreshed at 80% of 2 seconds
+			now := time.Now()
+			token := testJWT(now, now.Add(2*time.Second))
+			if len(tokens) > 0 {
+				token = testJWT(now, now.Add(time.Hour))
+			}
+			tokens = append(tokens, token)
+			return token, nil
+		})
+		client := server.newClient(WithTokenProvider(provider), WithTokenRefreshLeeway(100*time.Millisecond))
+
+		recorder := &statusRecorder{}
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      recorder.onStatus,
+			OnError:       recorder.onError,
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		require.Eventually(t, func() bool {
+			return recorder.has(StatusTokenRefreshed) && len(server.ConnectTokens()) == 2 &&
+				server.subscribed("query:"+testSubscriptionID+":100")
+		}, 5*time.Second, 10*time.Millisecond)
+		mu.Lock()
+		assert.Equal(t, tokens, server.ConnectTokens())
+		mu.Unlock()
+		assert.Equal(t, 1, server.Connections())
+	})
+}
+
+// TestWithNoAuth will test connecting without a token
+func TestWithNoAuth(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		newHandler := func(recorder *statusRecorder) EventHandler {
+			return EventHandler{
+				OnTransaction: func(*models.TransactionResponse) {},
+				OnStatus:      recorder.onStatus,
+				OnError:       recorder.onError,
+			}
+		}
+
+		t.Run("anonymous access", func(t *testing.T) {
+			server := newFakeServer(t)
+			client := server.newClient(WithNoAuth())
+
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(&statusRecorder{}))
+			require.NoError(t, err)
+			defer func() {
+				_ = subscription.Unsubscribe()
+			}()
+			server.waitSubscribed("query:" + testSubscriptionID + ":100")
+
+			assert.Equal(t, []string{""}, server.ConnectTokens())
+			assert.Nil(t, server.requestHeaders("/v1/user/subscription-token"))
+		})
+
+		t.Run("auth required", func(t *testing.T) {
+			server := newFakeServer(t)
+			server.RequireAuth(true)
+			client := server.newClient(WithNoAuth())
+
+			recorder := &statusRecorder{}
+			subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, newHandler(recorder))
+			require.NoError(t, err)
+
+			select {
+			case <-subscription.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("subscription did not stop")
+			}
+			assert.ErrorIs(t, subscription.Err(), ErrAuthRequired)
+			assert.Len(t, server.dialTimes(), 1)
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			require.NotEmpty(t, recorder.errors)
+			assert.ErrorIs(t, recorder.errors[len(recorder.errors)-1], ErrAuthRequired)
+		})
+	})
+}
@@ -0,0 +1,113 @@
+package junglebus
+
+import (
+	"context"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+)
+
+// withTracing returns the event handler with a span around every call of its callbacks, the spans are
+// children of the span in the context the subscription was started with. With middlewares the span of a transaction
+// is started around them, they and OnTransactionCtx and OnMempoolCtx are given its context, see TxContext.Context.
+func (s *Subscription) withTracing(eventHandler EventHandler) EventHandler {
+	tracer := s.client.tracer
+	if _, ok := tracer.(transports.NopTracer); ok {
+		return eventHandler
+	}
+
+	start := func(ctx context.Context, handler, channel string, attributes ...transports.Attribute) (context.Context,
+		transports.Span) {
+
+		attributes = append([]transports.Attribute{{Key: "junglebus.subscription_id", Value: s.SubscriptionID}},
+			attributes...)
+		if channel != "" {
+			attributes = append(attributes, transports.Attribute{Key: "junglebus.channel", Value: channel})
+		}
+		return tracer.Start(ctx, "junglebus "+handler, attributes...)
+	}
+	// span starts the span of a callback in the context of the subscription
+	span := func(handler, channel string, attributes ...transports.Attribute) transports.Span {
+		_, span := start(s.ctx, handler, channel, attributes...)
+		return span
+	}
+	transaction := func(tx *models.TransactionResponse) []transports.Attribute {
+		return []transports.Attribute{
+			{Key: "junglebus.txid", Value: tx.Id},
+			{Key: "junglebus.block_height", Value: tx.BlockHeight},
+		}
+	}
+
+	traced := eventHandler
+	if len(s.txMiddleware) > 0 {
+		s.txMiddleware = append([]TxMiddleware{func(next TxHandler) TxHandler {
+			return func(ctx TxContext, tx *models.TransactionResponse) {
+				handler, channel := "OnTransaction", channelMain
+				if ctx.Mempool {
+					handler, channel = "OnMempool", channelMempool
+				}
+				var span transports.Span
+				ctx.ctx, span = start(ctx.Context(), handler, channel, transaction(tx)...)
+				defer span.End()
+				next(ctx, tx)
+			}
+		}}, s.txMiddleware...)
+	} else {
+		if eventHandler.OnTransaction != nil {
+			traced.OnTransaction = func(tx *models.TransactionResponse) {
+				defer span("OnTransaction", channelMain, transaction(tx)...).End()
+				eventHandler.OnTransaction(tx)
+			}
+		}
+		if eventHandler.OnMempool != nil {
+			traced.OnMempool = func(tx *models.TransactionResponse) {
+				defer span("OnMempool", channelMempool, transaction(tx)...).End()
+				eventHandler.OnMempool(tx)
+			}
+		}
+	}
+	if eventHandler.OnBlock != nil {
+		traced.OnBlock = func(height uint32, transactions []*models.TransactionResponse) {
+			defer span("OnBlock", channelMain,
+				transports.Attribute{Key: "junglebus.block_height", Value: height},
+				transports.Attribute{Key: "junglebus.transactions", Value: len(transactions)},
+			).End()
+			eventHandler.OnBlock(height, transactions)
+		}
+	}
+	if eventHandler.OnBlockDone != nil {
+		traced.OnBlockDone = func(height uint32, transactions uint64) {
+			defer span("OnBlockDone", channelControl,
+				transports.Attribute{Key: "junglebus.block_height", Value: height},
+			).End()
+			eventHandler.OnBlockDone(height, transactions)
+		}
+	}
+	if eventHandler.OnStatus != nil {
+		traced.OnStatus = func(response *models.ControlResponse) {
+			defer span("OnStatus", channelControl,
+				transports.Attribute{Key: "junglebus.status_code", Value: response.StatusCode},
+				transports.Attribute{Key: "junglebus.block_height", Value: response.Block},
+			).End()
+			eventHandler.OnStatus(response)
+		}
+	}
+	if eventHandler.OnReorg != nil {
+		traced.OnReorg = func(height uint32) {
+			defer span("OnReorg", channelControl,
+				transports.Attribute{Key: "junglebus.block_height", Value: height},
+			).End()
+			eventHandler.OnReorg(height)
+		}
+	}
+	if eventHandler.OnError != nil {
+		traced.OnError = func(err error) {
+			errorSpan := span("OnError", "")
+			defer errorSpan.End()
+			errorSpan.RecordError(err)
+			eventHandler.OnError(err)
+		}
+	}
+
+	return traced
+}
@@ -6,6 +6,24 @@ import (
 	"github.com/GorillaPool/go-junglebus/models"
 )
 
+// GetTransaction get a transaction by its txid from JungleBus, ErrNotFound is returned for unknown transactions
+// The context controls the deadline of the request
 func (jb *Client) GetTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
 	return jb.transport.GetTransaction(ctx, txID)
 }
+
+// BroadcastTransaction broadcasts the raw transaction through JungleBus, returning its txid
+// Rejected transactions return a *BroadcastError wrapping ErrMalformedTransaction, ErrFeeTooLow, ErrAlreadyKnown
+// or ErrDoubleSpend, failures to reach the server are returned as is.
+func (jb *Client) BroadcastTransaction(ctx context.Context, rawTx []byte) (string, error) {
+	if len(rawTx) == 0 {
+		return "", ErrMalformedTransaction
+	}
+	return jb.transport.BroadcastTransaction(ctx, rawTx)
+}
+
+// GetRawTransaction get the raw bytes of a transaction by its txid from JungleBus
+// ErrChecksumMismatch is returned when the bytes do not hash to the txid
+func (jb *Client) GetRawTransaction(ctx context.Context, txID string) ([]byte, error) {
+	return jb.transport.GetRawTransaction(ctx, txID)
+}
@@ -0,0 +1,218 @@
+package junglebus
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTxID = "0c0be35b72ac0de35a02d34615be5b7e53b7b5aa2045de912add9ad356749898"
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestClient_GetTransaction will test decoding the transaction response of testdata/transaction.json
+func TestClient_GetTransaction(t *testing.T) {
+	response, err := os.ReadFile("testdata/transaction.json")
+	require.NoError(t, err)
+
+	server := newFakeServer(t)
+	server.handleJSON("/v1/transaction/get/"+testTxID, http.StatusOK, string(response))
+	server.handleJSON("/v1/transaction/get/unknown", http.StatusNotFound, `{"message":"not found"}`)
+	server.handleJSON("/v1/transaction/get/empty", http.StatusOK, `null`)
+	server.handleJSON("/v1/transaction/get/blank", http.StatusOK, ``)
+	client := server.newClient(WithRetryPolicy(transports.NoRetryPolicy))
+
+	t.Run("decode", func(t *testing.T) {
+		transaction, err := client.GetTransaction(context.Background(), testTxID)
+		require.NoError(t, err)
+		assert.Equal(t, &models.Transaction{
+			ID: testTxID,
+			Transaction: mustDecodeHex("0100000001111111111111111111111111111111111111111111111111111111111111" +
+				"11110000000000ffffffff01000000000000000006006a03666f6f00000000"),
+			BlockHash:   "0000000000000000051f4fb2b9b3e2d4ea7a1bd8d1a1dc42f9dbdc3b1d5f4a3b",
+			BlockHeight: 800000,
+			BlockTime:   1689000000,
+			BlockIndex:  5,
+			Addresses:   []string{},
+			Inputs:      []string{},
+			Outputs:     []string{"006a03666f6f"},
+			InputTypes:  []string{},
+			OutputTypes: []string{"opreturn"},
+			Contexts:    []string{"foo"},
+			SubContexts: []string{},
+			Data:        []string{"foo"},
+			MerkleProof: mustDecodeHex("0005222222222222222222222222222222222222222222222222222222222222222233333333" +
+				"33333333333333333333333333333333333333333333333333333333"),
+		}, transaction)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.GetTransaction(context.Background(), "unknown")
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = client.GetTransaction(context.Background(), "empty")
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = client.GetTransaction(context.Background(), "blank")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		server.HandleFunc("/v1/transaction/get/slow", func(http.ResponseWriter, *http.Request) {
+			<-release
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := client.GetTransaction(ctx, "slow")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// TestClient_GetRawTransaction will test getting the raw bytes of a transaction
+func TestClient_GetRawTransaction(t *testing.T) {
+	rawTx := mustDecodeHex("0100000001111111111111111111111111111111111111111111111111111111111111" +
+		"11110000000000ffffffff01000000000000000006006a03666f6f00000000")
+
+	t.Run("binary endpoint", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.HandleFunc("/v1/transaction/get/"+testTxID+"/bin", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(rawTx)
+		})
+
+		transaction, err := server.newClient().GetRawTransaction(context.Background(), testTxID)
+		require.NoError(t, err)
+		assert.Equal(t, rawTx, transaction)
+	})
+
+	t.Run("json fallback", func(t *testing.T) {
+		response, err := os.ReadFile("testdata/transaction.json")
+		require.NoError(t, err)
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/get/"+testTxID, http.StatusOK, string(response))
+
+		transaction, err := server.newClient().GetRawTransaction(context.Background(), testTxID)
+		require.NoError(t, err)
+		assert.Equal(t, rawTx, transaction)
+
+		_, err = server.newClient().GetRawTransaction(context.Background(), txID)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.HandleFunc("/v1/transaction/get/"+testTxID+"/bin", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(rawTx[1:])
+		})
+
+		_, err := server.newClient().GetRawTransaction(context.Background(), testTxID)
+		require.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+}
+
+// TestClient_BroadcastTransaction will test broadcasting a transaction and the errors of rejected transactions
+func TestClient_BroadcastTransaction(t *testing.T) {
+	rawTx := mustDecodeHex("0100000001111111111111111111111111111111111111111111111111111111111111" +
+		"11110000000000ffffffff01000000000000000006006a03666f6f00000000")
+
+	t.Run("binary endpoint", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.HandleFunc("/v1/transaction/broadcast/bin", func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			if req.Header.Get("Content-Type") != "application/octet-stream" || string(body) != string(rawTx) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"txid":"`+testTxID+`"}`)
+		})
+
+		txID, err := server.newClient().BroadcastTransaction(context.Background(), rawTx)
+		require.NoError(t, err)
+		assert.Equal(t, testTxID, txID)
+	})
+
+	t.Run("hex fallback", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.HandleFunc("/v1/transaction/broadcast", func(w http.ResponseWriter, req *http.Request) {
+			var request map[string]string
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil || request["transaction"] != hex.EncodeToString(rawTx) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"txid":"`+testTxID+`"}`)
+		})
+
+		txID, err := server.newClient().BroadcastTransaction(context.Background(), rawTx)
+		require.NoError(t, err)
+		assert.Equal(t, testTxID, txID)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			status   int
+			response string
+			err      error
+		}{
+			"double spend":   {http.StatusBadRequest, `{"message":"258: txn-mempool-conflict"}`, ErrDoubleSpend},
+			"malformed":      {http.StatusBadRequest, `{"error":"TX decode failed"}`, ErrMalformedTransaction},
+			"fee too low":    {http.StatusUnprocessableEntity, `{"title":"Fee too low","status":465}`, ErrFeeTooLow},
+			"already known":  {http.StatusConflict, `{"message":"257: txn-already-known"}`, ErrAlreadyKnown},
+			"unknown reason": {http.StatusBadRequest, `{"message":"something else"}`, nil},
+		} {
+			t.Run(name, func(t *testing.T) {
+				server := newFakeServer(t)
+				server.handleJSON("/v1/transaction/broadcast/bin", test.status, test.response)
+
+				_, err := server.newClient().BroadcastTransaction(context.Background(), rawTx)
+				var broadcastErr *BroadcastError
+				require.True(t, errors.As(err, &broadcastErr), err)
+				assert.Equal(t, test.status, broadcastErr.StatusCode)
+				if test.err != nil {
+					assert.ErrorIs(t, err, test.err)
+					// the matching of errors.Is before Go 1.20
+					assert.True(t, broadcastErr.Is(test.err))
+				}
+				var apiErr *APIError
+				require.True(t, broadcastErr.As(&apiErr))
+				assert.Equal(t, test.status, apiErr.StatusCode)
+			})
+		}
+	})
+
+	t.Run("server failure", func(t *testing.T) {
+		server := newFakeServer(t)
+		server.handleJSON("/v1/transaction/broadcast/bin", http.StatusBadGateway, `{"message":"upstream unavailable"}`)
+
+		_, err := server.newClient(WithRetryPolicy(transports.NoRetryPolicy)).BroadcastTransaction(context.Background(), rawTx)
+		var broadcastErr *BroadcastError
+		assert.False(t, errors.As(err, &broadcastErr))
+		var apiErr *APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := newFakeServer(t).newClient().BroadcastTransaction(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrMalformedTransaction)
+	})
+}
@@ -0,0 +1,159 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker
+type CircuitState uint8
+
+const (
+	// CircuitClosed lets all requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all requests with ErrCircuitOpen until the cooldown passed
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through, closing the circuit when it succeeds
+	CircuitHalfOpen
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops sending requests to the server after consecutive failures
+//
+// A request failed when no response was received or the server responded with a 5xx status code. After
+// Failures consecutive failures the circuit opens and requests fail with ErrCircuitOpen. Once the cooldown
+// passed the circuit is half-open and a single probe request is let through, closing the circuit again
+// when it succeeds or opening it for another cooldown when it fails.
+type CircuitBreaker struct {
+	Failures      int                         // consecutive failures opening the circuit
+	Cooldown      time.Duration               // how long the circuit stays open before a probe request
+	OnStateChange func(from, to CircuitState) // called on every state transition, optional
+}
+
+// circuitBreaker tracks the state of a CircuitBreaker
+type circuitBreaker struct {
+	CircuitBreaker
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool // a probe request of the half-open circuit is in flight
+}
+
+// newCircuitBreaker returns the circuit breaker, nil when Failures is zero or negative
+func newCircuitBreaker(breaker CircuitBreaker) *circuitBreaker {
+	if breaker.Failures <= 0 {
+		return nil
+	}
+	return &circuitBreaker{CircuitBreaker: breaker}
+}
+
+// allow returns ErrCircuitOpen when the request cannot be sent, otherwise done must be called with the result
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+	case CircuitHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.changed(from, to)
+	return nil
+}
+
+// done records the result of a request that was allowed
+func (b *circuitBreaker) done(statusCode int, err error) {
+	if b == nil {
+		return
+	}
+
+	// requests cancelled by the caller or a middleware say nothing about the server
+	var middlewareErr *middlewareError
+	cancelled := statusCode == 0 && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &middlewareErr))
+	failed := !cancelled && (statusCode >= http.StatusInternalServerError || (statusCode == 0 && err != nil))
+
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case CircuitClosed:
+		if !failed {
+			if !cancelled {
+				b.failures = 0
+			}
+			break
+		}
+		b.failures++
+		if b.failures >= b.Failures {
+			b.open()
+		}
+	case CircuitHalfOpen:
+		b.probing = false
+		if failed {
+			b.open()
+		} else if !cancelled {
+			b.state = CircuitClosed
+			b.failures = 0
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.changed(from, to)
+}
+
+// open opens the circuit, b.mu must be held
+func (b *circuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = time.Now()
+}
+
+// changed calls OnStateChange when the state changed
+func (b *circuitBreaker) changed(from, to CircuitState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
+
+// State returns the current state of the circuit
+func (b *circuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,107 @@
+package transports
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithCircuitBreaker will test driving the circuit breaker through its states
+func TestWithCircuitBreaker(t *testing.T) {
+	var failing, requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var transitions []string
+	c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(NoRetryPolicy), WithCircuitBreaker(CircuitBreaker{
+		Failures: 2,
+		Cooldown: 50 * time.Millisecond,
+		OnStateChange: func(from, to CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, from.String()+" -> "+to.String())
+		},
+	}))
+	require.NoError(t, err)
+
+	request := func() error {
+		_, err := c.GetChainTip(context.Background())
+		return err
+	}
+
+	// closed, a success resets the consecutive failures
+	atomic.StoreInt32(&failing, 1)
+	require.Error(t, request())
+	atomic.StoreInt32(&failing, 0)
+	require.NoError(t, request())
+	atomic.StoreInt32(&failing, 1)
+	require.Error(t, request())
+	assert.Equal(t, CircuitClosed, c.CircuitState())
+
+	// open, requests fail fast
+	require.Error(t, request())
+	assert.Equal(t, CircuitOpen, c.CircuitState())
+	sent := atomic.LoadInt32(&requests)
+	require.ErrorIs(t, request(), ErrCircuitOpen)
+	assert.Equal(t, sent, atomic.LoadInt32(&requests))
+
+	// half-open, a failing probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	require.Error(t, request())
+	assert.Equal(t, CircuitOpen, c.CircuitState())
+	require.ErrorIs(t, request(), ErrCircuitOpen)
+
+	// half-open, a succeeding probe closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&failing, 0)
+	require.NoError(t, request())
+	assert.Equal(t, CircuitClosed, c.CircuitState())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"closed -> open",
+		"open -> half-open",
+		"half-open -> open",
+		"open -> half-open",
+		"half-open -> closed",
+	}, transitions)
+}
+
+// TestCircuitBreaker_Probe will test letting a single probe request through the half-open circuit
+func TestCircuitBreaker_Probe(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreaker{Failures: 1, Cooldown: time.Millisecond})
+	require.NoError(t, b.allow())
+	b.done(0, assert.AnError)
+	assert.Equal(t, CircuitOpen, b.State())
+
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, b.allow())
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// a cancelled probe does not close the circuit
+	b.done(0, context.Canceled)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	require.NoError(t, b.allow())
+	b.done(http.StatusNotFound, nil)
+	assert.Equal(t, CircuitClosed, b.State())
+
+	assert.Nil(t, newCircuitBreaker(CircuitBreaker{}))
+}
@@ -0,0 +1,110 @@
+package transports
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// ErrMalformedTransaction is when a broadcast transaction could not be decoded or is invalid
+var ErrMalformedTransaction = models.ErrMalformedTransaction
+
+// ErrFeeTooLow is when a broadcast transaction was rejected because its fee is below the policy of the node
+var ErrFeeTooLow = errors.New("transaction fee too low")
+
+// ErrAlreadyKnown is when a broadcast transaction is already in the mempool or mined
+var ErrAlreadyKnown = errors.New("transaction already known")
+
+// ErrDoubleSpend is when a broadcast transaction spends outputs that are already spent or do not exist
+var ErrDoubleSpend = errors.New("transaction double spend")
+
+// BroadcastError is when the server rejected a broadcast transaction, it wraps ErrMalformedTransaction,
+// ErrFeeTooLow, ErrAlreadyKnown or ErrDoubleSpend depending on the reason. Failures to reach the server are
+// not a BroadcastError.
+type BroadcastError struct {
+	*APIError
+	Reason string // reason of the rejection as returned by the server
+	kind   error
+}
+
+func (e *BroadcastError) Error() string {
+	if e.kind == nil {
+		return "broadcast rejected: " + e.APIError.Error()
+	}
+	return "broadcast rejected: " + e.kind.Error() + ": " + e.Reason
+}
+
+// Unwrap returns the kind of rejection and the APIError of the response
+func (e *BroadcastError) Unwrap() []error {
+	if e.kind == nil {
+		return []error{e.APIError}
+	}
+	return []error{e.kind, e.APIError}
+}
+
+// Is reports whether the kind of rejection or the APIError matches the target, errors.Is only walks
+// Unwrap() []error from Go 1.20
+func (e *BroadcastError) Is(target error) bool {
+	for _, err := range e.Unwrap() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the kind of rejection and the APIError that matches the target, errors.As only walks
+// Unwrap() []error from Go 1.20
+func (e *BroadcastError) As(target interface{}) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastRejections maps the reasons of rejections, as returned by the nodes, to the kind of rejection
+var broadcastRejections = []struct {
+	kind    error
+	reasons []string
+}{
+	{ErrAlreadyKnown, []string{"txn-already-known", "txn-already-in-mempool", "already known", "already in the mempool", "already mined"}},
+	{ErrDoubleSpend, []string{"txn-mempool-conflict", "double spend", "double-spend", "missing inputs", "missingorspent", "inputs-spent"}},
+	{ErrFeeTooLow, []string{"min fee not met", "fee too low", "insufficient fee", "insufficient priority"}},
+	{ErrMalformedTransaction, []string{"decode failed", "malformed", "invalid transaction", "bad-txns", "non-mandatory-script-verify", "mandatory-script-verify"}},
+}
+
+// newBroadcastError returns the BroadcastError of a rejected transaction, nil when the request failed for another
+// reason, like the server being unavailable
+func newBroadcastError(apiErr *APIError) *BroadcastError {
+	var reason string
+	for _, key := range []string{"message", "error", "detail", "title"} {
+		if message, ok := apiErr.Body[key].(string); ok && message != "" {
+			reason = message
+			break
+		}
+	}
+
+	lower := strings.ToLower(reason)
+	for _, rejection := range broadcastRejections {
+		for _, match := range rejection.reasons {
+			if strings.Contains(lower, match) {
+				return &BroadcastError{APIError: apiErr, Reason: reason, kind: rejection.kind}
+			}
+		}
+	}
+
+	// an unknown reason is only a rejection when the status is not about the request itself
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusUnsupportedMediaType, http.StatusTooManyRequests:
+		return nil
+	}
+	if apiErr.StatusCode >= http.StatusInternalServerError {
+		return nil
+	}
+	return &BroadcastError{APIError: apiErr, Reason: reason}
+}
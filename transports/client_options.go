@@ -1,20 +1,22 @@
 package transports
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
-	"regexp"
+	"net/url"
+	"strings"
 )
 
-var regexHTTP = regexp.MustCompile(`^http://`)
-var regexWS = regexp.MustCompile(`^ws://`)
-var regexReplaceHTTPS = regexp.MustCompile(`^https?://`)
-var regexReplaceWSS = regexp.MustCompile(`^wss?://`)
-
 // WithHTTP will overwrite the default client with a custom client
 func WithHTTP(serverURL string) ClientOps {
 	return func(c *Client) {
 		if c != nil {
-			initHTTPTransport(c, serverURL, &http.Client{})
+			httpClient := c.httpClient
+			if httpClient == nil {
+				httpClient = &http.Client{}
+			}
+			initHTTPTransport(c, serverURL, httpClient)
 		}
 	}
 }
@@ -23,28 +25,237 @@ func WithHTTP(serverURL string) ClientOps {
 func WithHTTPClient(serverURL string, httpClient *http.Client) ClientOps {
 	return func(c *Client) {
 		if c != nil {
+			c.httpClient = httpClient
 			initHTTPTransport(c, serverURL, httpClient)
 		}
 	}
 }
 
-func initHTTPTransport(c *Client, serverURL string, httpClient *http.Client) {
-	useSSL := true
-	if regexHTTP.MatchString(serverURL) || regexWS.MatchString(serverURL) {
-		useSSL = false // turn off SSL if server url contains http:// or ws://
+// WithCustomHTTPClient will set the client used for all requests, keeping the server url. Its transport,
+// redirect policy and timeout are used as is.
+func WithCustomHTTPClient(httpClient *http.Client) ClientOps {
+	return func(c *Client) {
+		if c != nil && httpClient != nil {
+			c.httpClient = httpClient
+			if c.transport != nil {
+				c.transport.SetHTTPClient(httpClient)
+				c.configureTransport()
+			}
+		}
 	}
+}
 
-	// remove prefix if applicable
-	serverURL = regexReplaceHTTPS.ReplaceAllString(serverURL, "")
-	serverURL = regexReplaceWSS.ReplaceAllString(serverURL, "")
-
+func initHTTPTransport(c *Client, serverURL string, httpClient *http.Client) {
+	serverURL, useSSL, err := ParseServerURL(serverURL)
+	if err != nil {
+		if c.optionErr == nil {
+			c.optionErr = err
+		}
+		return
+	}
 	c.transport = NewTransportService(&TransportHTTP{
-		debug:      c.debug,
-		server:     serverURL,
-		httpClient: httpClient,
-		useSSL:     useSSL,
-		version:    "v1",
+		debug:         c.debug,
+		logger:        c.logger,
+		tracer:        c.tracer,
+		headers:       c.headers,
+		userAgent:     c.userAgent,
+		retryPolicy:   c.retryPolicy,
+		autoRateLimit: c.autoRateLimit,
+		rateLimiter:   c.rateLimiter,
+		maxSize:       c.maxSize,
+		breaker:       c.breaker,
+		middleware:    c.middleware,
+		hooks:         c.hooks,
+		server:        serverURL,
+		httpClient:    httpClient,
+		useSSL:        useSSL,
+		version:       "v1",
 	})
+	c.configureTransport()
+}
+
+// ParseServerURL returns the server url normalized to its host and base path, without a scheme or trailing slashes,
+// and whether SSL is used for it. The url may leave out the scheme, SSL is used unless it is http:// or ws://. Urls
+// that can not be parsed, have another scheme, no host or a query return an error wrapping ErrInvalidServerURL.
+func ParseServerURL(serverURL string) (string, bool, error) {
+	raw := strings.TrimSpace(serverURL)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false, fmt.Errorf("%w %q: %v", ErrInvalidServerURL, serverURL, err)
+	}
+
+	var useSSL bool
+	switch strings.ToLower(u.Scheme) {
+	case "https", "wss":
+		useSSL = true
+	case "http", "ws":
+	default:
+		return "", false, fmt.Errorf("%w %q: unsupported scheme %s", ErrInvalidServerURL, serverURL, u.Scheme)
+	}
+	switch {
+	case u.Host == "":
+		return "", false, fmt.Errorf("%w %q: missing host", ErrInvalidServerURL, serverURL)
+	case u.RawQuery != "" || u.Fragment != "":
+		return "", false, fmt.Errorf("%w %q: unexpected query", ErrInvalidServerURL, serverURL)
+	}
+	return u.Host + strings.TrimRight(u.EscapedPath(), "/"), useSSL, nil
+}
+
+// configureTransport applies the TLS configuration and proxy to a new http client of the transport
+func (c *Client) configureTransport() {
+	if c.tlsConfig != nil {
+		c.transport.SetTLSConfig(c.tlsConfig)
+	}
+	if c.proxy != nil {
+		c.transport.SetProxy(c.proxy)
+	}
+}
+
+// WithTLSConfig will set the TLS configuration of all requests
+//
+// It is applied to the http.Transport of the http client, a client with a custom round tripper has to
+// configure TLS itself.
+func WithTLSConfig(tlsConfig *tls.Config) ClientOps {
+	return func(c *Client) {
+		if c != nil && tlsConfig != nil {
+			c.tlsConfig = tlsConfig
+			if c.transport != nil {
+				c.transport.SetTLSConfig(tlsConfig)
+			}
+		}
+	}
+}
+
+// WithProxy will set the proxy of all requests, like http.ProxyFromEnvironment which is used by default
+//
+// It is applied to the http.Transport of the http client, a client with a custom round tripper has to
+// configure its proxy itself.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOps {
+	return func(c *Client) {
+		if c != nil && proxy != nil {
+			c.proxy = proxy
+			if c.transport != nil {
+				c.transport.SetProxy(proxy)
+			}
+		}
+	}
+}
+
+// WithHeaders will add the headers to all requests, a token header is only used when no token is set
+func WithHeaders(headers map[string]string) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			if c.headers == nil {
+				c.headers = http.Header{}
+			}
+			for key, value := range headers {
+				c.headers.Set(key, value)
+			}
+			if c.transport != nil {
+				c.transport.SetHeaders(c.headers)
+			}
+		}
+	}
+}
+
+// WithUserAgent will set the user agent of all requests (JungleBusUserAgent is default)
+func WithUserAgent(userAgent string) ClientOps {
+	return func(c *Client) {
+		if c != nil && userAgent != "" {
+			c.userAgent = userAgent
+			if c.transport != nil {
+				c.transport.SetUserAgent(userAgent)
+			}
+		}
+	}
+}
+
+// WithRetryPolicy will set how failing requests are retried (DefaultRetryPolicy is default)
+func WithRetryPolicy(retryPolicy RetryPolicy) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.retryPolicy = retryPolicy
+			if c.transport != nil {
+				c.transport.SetRetryPolicy(retryPolicy)
+			}
+		}
+	}
+}
+
+// WithAutoRateLimit will make rate limited requests wait for the Retry-After duration and send them again
+func WithAutoRateLimit(autoRateLimit bool) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.autoRateLimit = autoRateLimit
+			if c.transport != nil {
+				c.transport.SetAutoRateLimit(autoRateLimit)
+			}
+		}
+	}
+}
+
+// WithRateLimit will limit the requests to rps requests per second with bursts of burst requests
+func WithRateLimit(rps float64, burst int) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.rateLimiter = newRateLimiter(rps, burst)
+			if c.transport != nil {
+				c.transport.SetRateLimit(rps, burst)
+			}
+		}
+	}
+}
+
+// WithMaxResponseSize will limit the size of response bodies once decompressed, larger responses fail with a
+// *MessageTooLargeError
+func WithMaxResponseSize(size int64) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.maxSize = size
+			if c.transport != nil {
+				c.transport.SetMaxResponseSize(size)
+			}
+		}
+	}
+}
+
+// WithCircuitBreaker will stop sending requests after consecutive failures, see CircuitBreaker
+func WithCircuitBreaker(breaker CircuitBreaker) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.breaker = newCircuitBreaker(breaker)
+			if c.transport != nil {
+				c.transport.SetCircuitBreaker(breaker)
+			}
+		}
+	}
+}
+
+// WithMiddleware will add middlewares wrapping every request, see Middleware
+func WithMiddleware(middleware ...Middleware) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.middleware = append(c.middleware, middleware...)
+			if c.transport != nil {
+				c.transport.Use(middleware...)
+			}
+		}
+	}
+}
+
+// WithRequestHooks will set the hooks called around every request
+func WithRequestHooks(hooks RequestHooks) ClientOps {
+	return func(c *Client) {
+		if c != nil {
+			c.hooks = hooks
+			if c.transport != nil {
+				c.transport.SetRequestHooks(hooks)
+			}
+		}
+	}
 }
 
 // WithToken will set the token to use in all requests
@@ -70,6 +281,30 @@ func WithDebugging(debug bool) ClientOps {
 	}
 }
 
+// WithLogger will set the logger used for debug output (NopLogger is default)
+func WithLogger(logger Logger) ClientOps {
+	return func(c *Client) {
+		if c != nil && logger != nil {
+			c.logger = logger
+			if c.transport != nil {
+				c.transport.SetLogger(logger)
+			}
+		}
+	}
+}
+
+// WithTracer will set the tracer starting a span for every request (NopTracer is default)
+func WithTracer(tracer Tracer) ClientOps {
+	return func(c *Client) {
+		if c != nil && tracer != nil {
+			c.tracer = tracer
+			if c.transport != nil {
+				c.transport.SetTracer(tracer)
+			}
+		}
+	}
+}
+
 // WithSSL will set whether to use SSL in all communications
 func WithSSL(useSSL bool) ClientOps {
 	return func(c *Client) {
@@ -0,0 +1,58 @@
+package transports
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// limitedReader fails with a *MessageTooLargeError once more than limit bytes were read
+type limitedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, &MessageTooLargeError{Limit: l.limit}
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = -1
+		return n, &MessageTooLargeError{Limit: l.limit}
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// limitReader returns a reader failing with a *MessageTooLargeError once more than limit bytes were read,
+// r itself when limit is zero or negative
+func limitReader(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, limit: limit, remaining: limit}
+}
+
+// responseBody returns the body of the response decompressed when it is gzip encoded, and its size when known.
+// Reading more than limit bytes fails with a *MessageTooLargeError, zero or negative is no limit.
+func responseBody(resp *http.Response, limit int64) (io.Reader, int64, error) {
+	var body io.Reader = resp.Body
+	size := resp.ContentLength
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, 0, err
+		}
+		body, size = reader, -1
+	}
+	if limit > 0 && size > limit {
+		return nil, 0, &MessageTooLargeError{Limit: limit}
+	}
+	return limitReader(body, limit), size, nil
+}
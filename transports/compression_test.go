@@ -0,0 +1,126 @@
+package transports
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gzipped returns the data gzip compressed
+func gzipped(t *testing.T, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// TestCompressedResponses will test decompressing gzip encoded responses within the maximum size
+func TestCompressedResponses(t *testing.T) {
+	header := `{"hash":"0000000000000000000000000000000000000000000000000000000000000000","height":100}`
+	rawTx := []byte("raw transaction")
+	txID := transactionID(rawTx)
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		acceptEncoding = req.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", "gzip")
+		switch req.URL.Path {
+		case "/v1/block_header/tip":
+			_, _ = w.Write(gzipped(t, []byte(header)))
+		case "/v1/transaction/get/" + txID + "/bin":
+			_, _ = w.Write(gzipped(t, rawTx))
+		case "/v1/block_header/get/bomb":
+			_, _ = w.Write(gzipped(t, []byte(`{"hash":"`+strings.Repeat("0", 1<<20)+`"}`)))
+		case "/v1/block_header/get/invalid":
+			_, _ = w.Write([]byte("not gzip"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write(gzipped(t, []byte(`{"error":"missing"}`)))
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewTransport(WithHTTP(server.URL), WithMaxResponseSize(1<<10))
+	require.NoError(t, err)
+
+	tip, err := c.GetChainTip(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(100), tip.Height)
+	assert.Equal(t, "gzip", acceptEncoding)
+
+	raw, err := c.GetRawTransaction(context.Background(), txID)
+	require.NoError(t, err)
+	assert.Equal(t, rawTx, raw)
+
+	_, err = c.GetBlockHeader(context.Background(), "unknown")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "missing", apiErr.Body["error"])
+
+	_, err = c.GetBlockHeader(context.Background(), "bomb")
+	var tooLarge *MessageTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, int64(1<<10), tooLarge.Limit)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+
+	_, err = c.GetBlockHeader(context.Background(), "invalid")
+	require.Error(t, err)
+
+	t.Run("no limit", func(t *testing.T) {
+		c, err := NewTransport(WithHTTP(server.URL))
+		require.NoError(t, err)
+		_, err = c.GetBlockHeader(context.Background(), "bomb")
+		require.NoError(t, err)
+	})
+
+	t.Run("content length", func(t *testing.T) {
+		large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"hash":"` + strings.Repeat("0", 2<<10) + `"}`))
+		}))
+		defer large.Close()
+
+		c, err := NewTransport(WithHTTP(large.URL), WithMaxResponseSize(1<<10))
+		require.NoError(t, err)
+		_, err = c.GetChainTip(context.Background())
+		require.ErrorIs(t, err, ErrMessageTooLarge)
+	})
+}
+
+func TestLimitReader(t *testing.T) {
+	data, err := readAll(limitReader(strings.NewReader("0123456789"), 10))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", data)
+
+	data, err = readAll(limitReader(strings.NewReader("0123456789"), 4))
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Equal(t, "0123", data)
+
+	data, err = readAll(limitReader(strings.NewReader("0123456789"), 0))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", data)
+}
+
+// readAll reads r in small pieces
+func readAll(r io.Reader) (string, error) {
+	var out []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err != nil {
+			if err == io.EOF {
+				return string(out), nil
+			}
+			return string(out), err
+		}
+	}
+}
@@ -30,6 +30,9 @@ const (
 	// FieldSubscriptionID is the subscription id field
 	FieldSubscriptionID = "id"
 
+	// FieldTransaction is the hex encoded transaction field for broadcasting
+	FieldTransaction = "transaction"
+
 	// FieldUserAgent is the field for storing the user agent
 	FieldUserAgent = "user_agent"
 )
@@ -1,7 +1,97 @@
 package transports
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 // ErrNoClientSet is when no client is set
 var ErrNoClientSet = errors.New("no transport client set")
 var ErrFailedLogin = errors.New("failed to login to server")
+
+// ErrInvalidServerURL is when a server url can not be parsed or has an unsupported scheme, see ParseServerURL
+var ErrInvalidServerURL = errors.New("invalid server url")
+
+// ErrChecksumMismatch is when the hash of a raw transaction does not match the requested txid
+var ErrChecksumMismatch = errors.New("transaction hash does not match txid")
+
+// ErrCircuitOpen is when a request was not sent because the circuit breaker is open, see CircuitBreaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrNotFound is when the server responded with 404 Not Found
+var ErrNotFound = errors.New("not found")
+
+// ErrUnauthorized is when the server responded with 401 Unauthorized or 403 Forbidden
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrRateLimited is when the server responded with 429 Too Many Requests
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrMessageTooLarge is when a response or message is larger than the maximum size, see MessageTooLargeError
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MessageTooLargeError is when a response body or message is larger than the maximum size once decompressed,
+// it wraps ErrMessageTooLarge
+type MessageTooLargeError struct {
+	Limit int64 // the maximum size in bytes
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return "message larger than " + strconv.FormatInt(e.Limit, 10) + " bytes"
+}
+
+func (e *MessageTooLargeError) Unwrap() error {
+	return ErrMessageTooLarge
+}
+
+// APIError is when the server responded with a 4xx or 5xx status code
+// It wraps ErrNotFound, ErrUnauthorized or ErrRateLimited depending on the status code
+type APIError struct {
+	StatusCode int                    // status code of the response
+	Status     string                 // status of the response, like "404 Not Found"
+	Method     string                 // method of the request
+	Endpoint   string                 // path of the request, like "/v1/transaction/get/<txid>"
+	Body       map[string]interface{} // error body of the response, nil when it was not a JSON object
+}
+
+func (e *APIError) Error() string {
+	msg := "server error: " + strconv.Itoa(e.StatusCode) + " - " + e.Status
+	for _, key := range []string{"message", "error"} {
+		if message, ok := e.Body[key].(string); ok && message != "" {
+			return msg + ": " + message
+		}
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// RateLimitError is when the server responded with 429 Too Many Requests, it wraps the APIError
+type RateLimitError struct {
+	*APIError
+	RetryAfter time.Duration // how long to wait before the next request, zero when the server did not say
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return e.APIError.Error() + ", retry after " + e.RetryAfter.String()
+	}
+	return e.APIError.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.APIError
+}
@@ -0,0 +1,86 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPIError will test the errors returned for the status codes of the server
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		sentinel error
+		message  string
+	}{
+		{"not found", http.StatusNotFound, `{"message":"transaction not found"}`, ErrNotFound, "transaction not found"},
+		{"unauthorized", http.StatusUnauthorized, `{"error":"invalid token"}`, ErrUnauthorized, "invalid token"},
+		{"forbidden", http.StatusForbidden, ``, ErrUnauthorized, ""},
+		{"rate limited", http.StatusTooManyRequests, `{}`, ErrRateLimited, ""},
+		{"bad request", http.StatusBadRequest, `invalid`, nil, ""},
+		{"server down", http.StatusServiceUnavailable, `<html></html>`, nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(NoRetryPolicy))
+			require.NoError(t, err)
+
+			_, err = c.GetTransaction(context.Background(), "txid")
+			require.Error(t, err)
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, test.status, apiErr.StatusCode)
+			assert.Equal(t, http.MethodGet, apiErr.Method)
+			assert.Equal(t, "/v1/transaction/get/txid", apiErr.Endpoint)
+			if test.message != "" {
+				assert.Contains(t, err.Error(), test.message)
+			}
+
+			for _, sentinel := range []error{ErrNotFound, ErrUnauthorized, ErrRateLimited} {
+				assert.Equal(t, sentinel == test.sentinel, errors.Is(err, sentinel), sentinel.Error())
+			}
+		})
+	}
+
+	t.Run("connection error", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(NoRetryPolicy))
+		require.NoError(t, err)
+
+		_, err = c.GetChainTip(context.Background())
+		require.Error(t, err)
+		var apiErr *APIError
+		assert.False(t, errors.As(err, &apiErr))
+	})
+}
+
+// TestTransportHTTP_LoginError will test matching failed logins with ErrFailedLogin
+func TestTransportHTTP_LoginError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid password"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(NoRetryPolicy))
+	require.NoError(t, err)
+
+	err = c.Login(context.Background(), "user", "password")
+	require.ErrorIs(t, err, ErrFailedLogin)
+	assert.Contains(t, err.Error(), "invalid password")
+}
@@ -3,24 +3,51 @@ package transports
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
-	"strconv"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/GorillaPool/go-junglebus/models"
+	"golang.org/x/time/rate"
 )
 
+// maxErrorBodySize is the maximum number of bytes read from the error body of a response
+const maxErrorBodySize = 64 << 10
+
+// rawResponse receives the body of a response as is, instead of it being decoded as JSON
+type rawResponse func(body io.Reader, size int64) error
+
 // TransportHTTP is the struct for HTTP
 type TransportHTTP struct {
-	debug      bool
-	httpClient *http.Client
-	server     string
-	token      string
-	useSSL     bool
-	version    string
+	debug         bool
+	httpClient    *http.Client
+	logger        Logger
+	tracer        Tracer
+	headers       http.Header
+	userAgent     string
+	server        string
+	useSSL        bool
+	serverMu      sync.RWMutex // the server is replaced while requests are made, see SetServerURL
+	token         string
+	tokenMu       sync.RWMutex // the token is replaced while requests are made
+	version       string
+	retryPolicy   RetryPolicy
+	retries       uint64 // retried requests, only accessed atomically
+	autoRateLimit bool
+	rateLimiter   *rate.Limiter
+	breaker       *circuitBreaker
+	middleware    []Middleware
+	hooks         RequestHooks
+	maxSize       int64 // maximum size of a decompressed response body, 0 for no limit
 }
 
 // SetDebug turn the debugging on or off
@@ -33,23 +60,140 @@ func (h *TransportHTTP) IsDebug() bool {
 	return h.debug
 }
 
+// SetHTTPClient sets the client used for all requests
+func (h *TransportHTTP) SetHTTPClient(httpClient *http.Client) {
+	h.httpClient = httpClient
+}
+
+// SetTLSConfig sets the TLS configuration of the http.Transport of the client
+func (h *TransportHTTP) SetTLSConfig(tlsConfig *tls.Config) {
+	h.configureTransport(func(transport *http.Transport) {
+		transport.TLSClientConfig = tlsConfig
+	})
+}
+
+// SetProxy sets the proxy function of the http.Transport of the client
+func (h *TransportHTTP) SetProxy(proxy func(*http.Request) (*url.URL, error)) {
+	h.configureTransport(func(transport *http.Transport) {
+		transport.Proxy = proxy
+	})
+}
+
+// configureTransport changes a copy of the http.Transport of the client, the client is copied as well so a
+// client shared with other code is not changed. A client with a custom round tripper is left as is.
+func (h *TransportHTTP) configureTransport(configure func(transport *http.Transport)) {
+	base, ok := h.httpClient.Transport.(*http.Transport)
+	if h.httpClient.Transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return
+	}
+
+	transport := base.Clone()
+	configure(transport)
+	httpClient := *h.httpClient
+	httpClient.Transport = transport
+	h.httpClient = &httpClient
+}
+
+// SetHeaders adds the headers to all requests
+func (h *TransportHTTP) SetHeaders(headers http.Header) {
+	h.headers = headers
+}
+
+// SetUserAgent sets the user agent of all requests
+func (h *TransportHTTP) SetUserAgent(userAgent string) {
+	h.userAgent = userAgent
+}
+
+// SetRetryPolicy sets how failing requests are retried
+func (h *TransportHTTP) SetRetryPolicy(retryPolicy RetryPolicy) {
+	h.retryPolicy = retryPolicy
+}
+
+// SetAutoRateLimit sets whether rate limited requests wait for the Retry-After duration and are sent again
+func (h *TransportHTTP) SetAutoRateLimit(autoRateLimit bool) {
+	h.autoRateLimit = autoRateLimit
+}
+
+// SetRateLimit limits the requests to rps requests per second with bursts of burst requests,
+// zero or a negative rps removes the limit
+func (h *TransportHTTP) SetRateLimit(rps float64, burst int) {
+	h.rateLimiter = newRateLimiter(rps, burst)
+}
+
+// SetMaxResponseSize limits the size of response bodies once decompressed, zero or negative removes the limit
+func (h *TransportHTTP) SetMaxResponseSize(size int64) {
+	h.maxSize = size
+}
+
+// SetCircuitBreaker sets the circuit breaker of the requests, zero Failures removes it
+func (h *TransportHTTP) SetCircuitBreaker(breaker CircuitBreaker) {
+	h.breaker = newCircuitBreaker(breaker)
+}
+
+// CircuitState returns the state of the circuit breaker, CircuitClosed when there is none
+func (h *TransportHTTP) CircuitState() CircuitState {
+	return h.breaker.State()
+}
+
+// Use adds the middlewares wrapping every request, after the middlewares added before
+func (h *TransportHTTP) Use(middleware ...Middleware) {
+	h.middleware = append(h.middleware, middleware...)
+}
+
+// SetRequestHooks sets the hooks called around every request
+func (h *TransportHTTP) SetRequestHooks(hooks RequestHooks) {
+	h.hooks = hooks
+}
+
+// Retries returns the number of times a failed request was retried
+func (h *TransportHTTP) Retries() uint64 {
+	return atomic.LoadUint64(&h.retries)
+}
+
+// SetLogger sets the logger used for debug output, nil discards it
+func (h *TransportHTTP) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	h.logger = logger
+}
+
+// SetTracer sets the tracer starting a span for every request, nil disables tracing
+func (h *TransportHTTP) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = NopTracer{}
+	}
+	h.tracer = tracer
+}
+
 // UseSSL turn the SSL on or off
 func (h *TransportHTTP) UseSSL(useSSL bool) {
+	h.serverMu.Lock()
 	h.useSSL = useSSL
+	h.serverMu.Unlock()
 }
 
 // IsSSL return the SSL status
 func (h *TransportHTTP) IsSSL() bool {
+	h.serverMu.RLock()
+	defer h.serverMu.RUnlock()
 	return h.useSSL
 }
 
 // SetToken sets the token to use for all requests manually
 func (h *TransportHTTP) SetToken(token string) {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
 	h.token = token
 }
 
 // GetToken gets the token to use for all requests
 func (h *TransportHTTP) GetToken() string {
+	h.tokenMu.RLock()
+	defer h.tokenMu.RUnlock()
 	return h.token
 }
 
@@ -64,22 +208,37 @@ func (h *TransportHTTP) GetSubscriptionToken(ctx context.Context, subscriptionID
 	}
 
 	var response LoginResponse
-	if err = h.doHTTPRequest(
-		ctx, http.MethodPost, `/user/subscription-token`, jsonStr, &response,
+	// fetching a token has no side effects, it is retried like a GET request
+	if err = h.doHTTPRequestWithRetries(
+		ctx, "GetSubscriptionToken", http.MethodPost, `/user/subscription-token`, jsonStr, &response, true,
 	); err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to get subscription token: %w", err)
 	}
 
 	return response.Token, nil
 }
 
+// GetSubscriptionDetails gets the subscription and the queries it matches transactions with
+func (h *TransportHTTP) GetSubscriptionDetails(ctx context.Context, subscriptionID string) (details *models.SubscriptionDetails, err error) {
+	if err = h.doHTTPRequest(
+		ctx, "GetSubscriptionDetails", http.MethodGet, "/subscription/"+subscriptionID, nil, &details,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get subscription %s: %w", subscriptionID, err)
+	}
+	if details == nil || details.ID == "" {
+		return nil, fmt.Errorf("failed to get subscription %s: %w", subscriptionID, ErrNotFound)
+	}
+
+	return details, nil
+}
+
 // RefreshToken gets a new  token to use for all requests
 func (h *TransportHTTP) RefreshToken(ctx context.Context) (string, error) {
 	var response LoginResponse
 	if err := h.doHTTPRequest(
-		ctx, http.MethodGet, `/user/refresh-token`, nil, &response,
+		ctx, "RefreshToken", http.MethodGet, `/user/refresh-token`, nil, &response,
 	); err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to refresh token: %w", err)
 	}
 
 	return response.Token, nil
@@ -92,9 +251,24 @@ func (h *TransportHTTP) SetVersion(version string) {
 
 // GetServerURL get the server URL for this transport
 func (h *TransportHTTP) GetServerURL() string {
+	h.serverMu.RLock()
+	defer h.serverMu.RUnlock()
 	return h.server
 }
 
+// SetServerURL sends the next requests to another server, SSL is turned off for a url starting with http:// or ws://.
+// An invalid url is ignored, see ParseServerURL.
+func (h *TransportHTTP) SetServerURL(serverURL string) {
+	server, useSSL, err := ParseServerURL(serverURL)
+	if err != nil {
+		return
+	}
+	h.serverMu.Lock()
+	h.server = server
+	h.useSSL = useSSL
+	h.serverMu.Unlock()
+}
+
 func (h *TransportHTTP) Login(ctx context.Context, username string, password string) error {
 
 	jsonStr, err := json.Marshal(map[string]interface{}{
@@ -107,12 +281,12 @@ func (h *TransportHTTP) Login(ctx context.Context, username string, password str
 
 	var loginResponse map[string]interface{}
 	if err = h.doHTTPRequest(
-		ctx, http.MethodGet, `/user/login`, jsonStr, &loginResponse,
+		ctx, "Login", http.MethodGet, `/user/login`, jsonStr, &loginResponse,
 	); err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrFailedLogin, err)
 	}
 	if h.debug {
-		log.Printf("Login: %v\n", loginResponse)
+		h.logger.Debugf("Login: %v", loginResponse)
 	}
 
 	if token, ok := loginResponse["token"]; ok {
@@ -126,58 +300,203 @@ func (h *TransportHTTP) Login(ctx context.Context, username string, password str
 // GetTransaction will get a transaction by ID
 func (h *TransportHTTP) GetTransaction(ctx context.Context, txID string) (transaction *models.Transaction, err error) {
 
+	// an empty body is not a JSON document, it is decoded to io.EOF and means not found like null
 	if err = h.doHTTPRequest(
-		ctx, http.MethodGet, "/transaction/get/"+txID, nil, &transaction,
-	); err != nil {
-		return nil, err
+		ctx, "GetTransaction", http.MethodGet, "/transaction/get/"+txID, nil, &transaction,
+	); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", txID, err)
+	}
+	if transaction == nil || transaction.ID == "" {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", txID, ErrNotFound)
 	}
 	if h.debug {
-		log.Printf("Transaction: %v\n", transaction)
+		h.logger.Debugf("Transaction: %v", transaction)
+	}
+
+	return transaction, nil
+}
+
+// GetRawTransaction will get the raw bytes of a transaction by ID, verifying that they hash to the ID
+// The binary endpoint is used, falling back to the JSON endpoint when the server does not have it
+func (h *TransportHTTP) GetRawTransaction(ctx context.Context, txID string) ([]byte, error) {
+	var transaction []byte
+	err := h.doHTTPRequest(
+		ctx, "GetRawTransaction", http.MethodGet, "/transaction/get/"+txID+"/bin", nil,
+		rawResponse(func(body io.Reader, size int64) (err error) {
+			transaction, err = readTransaction(body, size, txID)
+			return err
+		}),
+	)
+	if errors.Is(err, ErrNotFound) {
+		var tx *models.Transaction
+		if tx, err = h.GetTransaction(ctx, txID); err != nil {
+			return nil, err
+		}
+		transaction, err = readTransaction(bytes.NewReader(tx.Transaction), int64(len(tx.Transaction)), txID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw transaction %s: %w", txID, err)
 	}
 
 	return transaction, nil
 }
 
+// BroadcastTransaction will broadcast the raw transaction, returning its txid
+// The raw bytes are posted to the binary endpoint, falling back to the hex encoded transaction in JSON when the
+// server does not have it. Transactions rejected by the server return a *BroadcastError.
+func (h *TransportHTTP) BroadcastTransaction(ctx context.Context, rawTx []byte) (string, error) {
+	txID := transactionID(rawTx)
+
+	var response struct {
+		TxID string `json:"txid"`
+	}
+	err := h.doHTTPRequest(
+		context.WithValue(ctx, contentTypeKey{}, "application/octet-stream"), "BroadcastTransaction",
+		http.MethodPost, "/transaction/broadcast/bin", rawTx, &response,
+	)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound ||
+		apiErr.StatusCode == http.StatusMethodNotAllowed || apiErr.StatusCode == http.StatusUnsupportedMediaType) {
+		var jsonStr []byte
+		if jsonStr, err = json.Marshal(map[string]interface{}{
+			FieldTransaction: hex.EncodeToString(rawTx),
+		}); err != nil {
+			return "", err
+		}
+		err = h.doHTTPRequest(ctx, "BroadcastTransaction", http.MethodPost, "/transaction/broadcast", jsonStr, &response)
+	}
+	if err != nil {
+		if errors.As(err, &apiErr) {
+			if broadcastErr := newBroadcastError(apiErr); broadcastErr != nil {
+				err = broadcastErr
+			}
+		}
+		return "", fmt.Errorf("failed to broadcast transaction %s: %w", txID, err)
+	}
+	if h.debug {
+		h.logger.Debugf("Broadcast transaction: %s", txID)
+	}
+
+	if response.TxID != "" && !strings.EqualFold(response.TxID, txID) {
+		return "", fmt.Errorf("failed to broadcast transaction %s: server returned txid %s", txID, response.TxID)
+	}
+	return txID, nil
+}
+
+// GetMerkleProof will get the merkle proof of a mined transaction by ID
+func (h *TransportHTTP) GetMerkleProof(ctx context.Context, txID string) (proof *models.MerkleProof, err error) {
+
+	if err = h.doHTTPRequest(
+		ctx, "GetMerkleProof", http.MethodGet, "/transaction/proof/"+txID, nil, &proof,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get merkle proof of transaction %s: %w", txID, err)
+	}
+	if proof == nil || len(proof.Path) == 0 {
+		return nil, fmt.Errorf("failed to get merkle proof of transaction %s: %w", txID, ErrNotFound)
+	}
+
+	return proof, nil
+}
+
+// GetBlockTransactions will get a page of the transactions of the subscription in the block at the height
+func (h *TransportHTTP) GetBlockTransactions(ctx context.Context, subscriptionID string, height uint32,
+	page uint64) (transactions *models.BlockTransactionsPage, err error) {
+
+	if err = h.doHTTPRequest(
+		ctx, "GetBlockTransactions", http.MethodGet,
+		fmt.Sprintf("/subscription/%s/block/%d/%d", subscriptionID, height, page), nil, &transactions,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get transactions of subscription %s in block %d: %w", subscriptionID, height, err)
+	}
+	if transactions == nil {
+		transactions = &models.BlockTransactionsPage{}
+	}
+	if h.debug {
+		h.logger.Debugf("Block transactions: %d, next page %d", len(transactions.Transactions), transactions.NextPage)
+	}
+
+	return transactions, nil
+}
+
 // GetAddressTransactions will get the metadata of all transaction related to the given address
 func (h *TransportHTTP) GetAddressTransactions(ctx context.Context, address string) (addr []*models.Address, err error) {
 	if err = h.doHTTPRequest(
-		ctx, http.MethodGet, "/address/get/"+address, nil, &addr,
+		ctx, "GetAddressTransactions", http.MethodGet, "/address/get/"+address, nil, &addr,
 	); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get address %s: %w", address, err)
 	}
 	if h.debug {
-		log.Printf("Address transactions: %v\n", addr)
+		h.logger.Debugf("Address transactions: %v", addr)
 	}
 
 	return addr, nil
 }
 
+// GetAddressTransactionsFrom will get up to limit transactions of the address from the block height,
+// ordered by block height and block index
+func (h *TransportHTTP) GetAddressTransactionsFrom(ctx context.Context, address string, fromHeight uint32,
+	limit uint) (transactions []*models.AddressTx, err error) {
+
+	if err = h.doHTTPRequest(
+		ctx, "GetAddressTransactionsFrom", http.MethodGet,
+		fmt.Sprintf("/address/get/%s?from_height=%d&limit=%d", address, fromHeight, limit), nil, &transactions,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get address %s from height %d: %w", address, fromHeight, err)
+	}
+	if h.debug {
+		h.logger.Debugf("Address transactions: %d", len(transactions))
+	}
+
+	return transactions, nil
+}
+
 // GetAddressTransactionDetails will get all transactions related to the given address
 func (h *TransportHTTP) GetAddressTransactionDetails(ctx context.Context, address string) (transactions []*models.Transaction, err error) {
 
 	if err = h.doHTTPRequest(
-		ctx, http.MethodGet, "/address/transactions/"+address, nil, &transactions,
+		ctx, "GetAddressTransactionDetails", http.MethodGet, "/address/transactions/"+address, nil, &transactions,
 	); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get transactions of address %s: %w", address, err)
 	}
 	if h.debug {
-		log.Printf("transactions: %d\n", len(transactions))
+		h.logger.Debugf("transactions: %d", len(transactions))
 	}
 
 	return transactions, nil
 }
 
+// GetAddressDetails will get the state of the given address
+func (h *TransportHTTP) GetAddressDetails(ctx context.Context, address string) (details *models.AddressDetails, err error) {
+
+	if err = h.doHTTPRequest(
+		ctx, "GetAddressDetails", http.MethodGet, "/address/details/"+address, nil, &details,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get details of address %s: %w", address, err)
+	}
+	if details == nil || details.Address == "" {
+		return nil, fmt.Errorf("failed to get details of address %s: %w", address, ErrNotFound)
+	}
+	if h.debug {
+		h.logger.Debugf("Address details: %v", details)
+	}
+
+	return details, nil
+}
+
 // GetBlockHeader will get the given block header details
 // Can pass either the block hash or the block height (as a string)
 func (h *TransportHTTP) GetBlockHeader(ctx context.Context, block string) (blockHeader *models.BlockHeader, err error) {
 
 	if err = h.doHTTPRequest(
-		ctx, http.MethodGet, "/block_header/get/"+block, nil, &blockHeader,
+		ctx, "GetBlockHeader", http.MethodGet, "/block_header/get/"+block, nil, &blockHeader,
 	); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get block header %s: %w", block, err)
+	}
+	if blockHeader == nil || blockHeader.Hash == "" {
+		return nil, fmt.Errorf("failed to get block header %s: %w", block, ErrNotFound)
 	}
 	if h.debug {
-		log.Printf("transactions: %v\n", blockHeader)
+		h.logger.Debugf("transactions: %v", blockHeader)
 	}
 
 	return blockHeader, nil
@@ -188,44 +507,191 @@ func (h *TransportHTTP) GetBlockHeader(ctx context.Context, block string) (block
 func (h *TransportHTTP) GetBlockHeaders(ctx context.Context, fromBlock string, limit uint) (blockHeaders []*models.BlockHeader, err error) {
 
 	if err = h.doHTTPRequest(
-		ctx, http.MethodGet, fmt.Sprintf("/block_header/list/%s?limit=%d", fromBlock, limit), nil, &blockHeaders,
+		ctx, "GetBlockHeaders", http.MethodGet, fmt.Sprintf("/block_header/list/%s?limit=%d", fromBlock, limit), nil, &blockHeaders,
 	); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get block headers from %s: %w", fromBlock, err)
 	}
 	if h.debug {
-		log.Printf("transactions: %v\n", blockHeaders)
+		h.logger.Debugf("transactions: %v", blockHeaders)
 	}
 
 	return blockHeaders, nil
 }
 
-// doHTTPRequest will create and submit the HTTP request
-func (h *TransportHTTP) doHTTPRequest(ctx context.Context, method string, path string, rawJSON []byte, responseJSON interface{}) error {
+// GetChainTip will get the block header of the current best block
+func (h *TransportHTTP) GetChainTip(ctx context.Context) (blockHeader *models.BlockHeader, err error) {
+
+	if err = h.doHTTPRequest(
+		ctx, "GetChainTip", http.MethodGet, "/block_header/tip", nil, &blockHeader,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get chain tip: %w", err)
+	}
+	if h.debug {
+		h.logger.Debugf("chain tip: %v", blockHeader)
+	}
+
+	return blockHeader, nil
+}
+
+// doHTTPRequest will create and submit the HTTP request, retrying it following the retry policy
+// The endpoint is the name of the calling method, it is passed to the request hooks and middlewares
+func (h *TransportHTTP) doHTTPRequest(ctx context.Context, endpoint string, method string, path string, rawJSON []byte,
+	responseJSON interface{}) error {
+	return h.doHTTPRequestWithRetries(ctx, endpoint, method, path, rawJSON, responseJSON, isIdempotent(method))
+}
+
+// doHTTPRequestWithRetries will create and submit the HTTP request, retrying it following the retry policy
+// A request that is not idempotent is only retried when the retry policy allows it
+func (h *TransportHTTP) doHTTPRequestWithRetries(ctx context.Context, endpoint string, method string, path string,
+	rawJSON []byte, responseJSON interface{}, idempotent bool) (err error) {
+
+	ctx = context.WithValue(ctx, endpointKey{}, endpoint)
+	if h.hooks.Before != nil {
+		h.hooks.Before(ctx, endpoint)
+	}
+	if h.hooks.After != nil {
+		start := time.Now()
+		defer func() {
+			h.hooks.After(ctx, endpoint, time.Since(start), err)
+		}()
+	}
 
 	protocol := "https"
-	if !h.useSSL {
+	if !h.IsSSL() {
 		protocol = "http"
 	}
-	serverRequest := fmt.Sprintf("%s://%s/%s%s", protocol, h.server, h.version, path)
+	serverRequest := fmt.Sprintf("%s://%s/%s%s", protocol, h.GetServerURL(), h.version, path)
+
+	ctx, span := h.tracer.Start(ctx, "junglebus "+method,
+		Attribute{Key: "http.method", Value: method},
+		Attribute{Key: "http.url", Value: serverRequest},
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
+	policy := h.retryPolicy
+	var attempt, rateLimited int
+	for {
+		var statusCode int
+		statusCode, err = h.doHTTPAttempt(ctx, method, serverRequest, rawJSON, responseJSON)
+		if statusCode > 0 {
+			span.SetAttributes(Attribute{Key: "http.status_code", Value: statusCode})
+		}
+
+		var delay time.Duration
+		var rateLimitErr *RateLimitError
+		isRateLimited := errors.As(err, &rateLimitErr)
+		switch {
+		case isRateLimited && h.autoRateLimit && idempotent:
+			// waiting for the rate limit does not count as an attempt of the retry policy
+			delay = rateLimitErr.RetryAfter
+			if delay == 0 {
+				delay = policy.delay(rateLimited)
+			}
+			rateLimited++
+		case attempt+1 < policy.MaxAttempts && (idempotent || policy.RetryNonIdempotent) && retryable(err, statusCode):
+			delay = policy.delay(attempt)
+			if isRateLimited && rateLimitErr.RetryAfter > delay {
+				delay = rateLimitErr.RetryAfter
+			}
+			attempt++
+		default:
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// the request cannot be sent again in time
+			return err
+		}
+
+		atomic.AddUint64(&h.retries, 1)
+		h.logger.Infof("retrying %s %s in %s: %v", method, serverRequest, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// doHTTPAttempt will submit the HTTP request once, returning the status code when a response was received
+func (h *TransportHTTP) doHTTPAttempt(ctx context.Context, method string, serverRequest string, rawJSON []byte,
+	responseJSON interface{}) (statusCode int, err error) {
+
+	if err = waitRateLimit(ctx, h.rateLimiter); err != nil {
+		return 0, err
+	}
+	if err = h.breaker.allow(); err != nil {
+		return 0, err
+	}
+	defer func() {
+		h.breaker.done(statusCode, err)
+	}()
+
 	req, err := http.NewRequestWithContext(ctx, method, serverRequest, bytes.NewBuffer(rawJSON))
 	if err != nil {
-		return err
+		return 0, err
+	}
+	for key, values := range h.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	contentType, _ := ctx.Value(contentTypeKey{}).(string)
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	// a token header of the custom headers is only used when the transport has no token
+	if token := h.GetToken(); token != "" || req.Header.Get("token") == "" {
+		req.Header.Set("token", token)
+	}
+	req.Header.Set("User-Agent", h.userAgent)
+	// compressed responses are decompressed here rather than by the http.Transport, within the size limit
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("token", h.token)
 
 	var resp *http.Response
 	defer func() {
-		if resp.Body != nil {
+		if resp != nil && resp.Body != nil {
 			_ = resp.Body.Close()
 		}
 	}()
-	if resp, err = h.httpClient.Do(req); err != nil {
-		return err
+	if resp, err = h.roundTrip(req); err != nil {
+		return 0, err
+	}
+	body, size, err := responseBody(resp, h.maxSize)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read response of %s %s: %w", method, req.URL.Path, err)
 	}
 	if resp.StatusCode >= http.StatusBadRequest {
-		return errors.New("server error: " + strconv.Itoa(resp.StatusCode) + " - " + resp.Status)
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Method:     method,
+			Endpoint:   req.URL.Path,
+		}
+		// the error body is optional, it is only kept when it is a JSON object
+		_ = json.NewDecoder(io.LimitReader(body, maxErrorBodySize)).Decode(&apiErr.Body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return resp.StatusCode, &RateLimitError{
+				APIError:   apiErr,
+				RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			}
+		}
+		return resp.StatusCode, apiErr
 	}
 
-	return json.NewDecoder(resp.Body).Decode(&responseJSON)
+	if raw, ok := responseJSON.(rawResponse); ok {
+		return resp.StatusCode, raw(body, size)
+	}
+	if err = json.NewDecoder(body).Decode(&responseJSON); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode response of %s %s: %w", method, req.URL.Path, err)
+	}
+	return resp.StatusCode, nil
 }
@@ -2,6 +2,9 @@ package transports
 
 import (
 	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
 
 	"github.com/GorillaPool/go-junglebus/models"
 )
@@ -10,37 +13,68 @@ import (
 type AddressService interface {
 	GetAddressTransactions(ctx context.Context, address string) ([]*models.Address, error)
 	GetAddressTransactionDetails(ctx context.Context, address string) ([]*models.Transaction, error)
+	GetAddressTransactionsFrom(ctx context.Context, address string, fromHeight uint32, limit uint) ([]*models.AddressTx, error)
+	GetAddressDetails(ctx context.Context, address string) (*models.AddressDetails, error)
 }
 
 // BlockHeaderService is the block header related requests
 type BlockHeaderService interface {
 	GetBlockHeader(ctx context.Context, block string) (*models.BlockHeader, error)
 	GetBlockHeaders(ctx context.Context, fromBlock string, limit uint) ([]*models.BlockHeader, error)
+	GetChainTip(ctx context.Context) (*models.BlockHeader, error)
 }
 
 // TransactionService is the transaction related requests
 type TransactionService interface {
 	GetTransaction(ctx context.Context, txID string) (*models.Transaction, error)
+	GetRawTransaction(ctx context.Context, txID string) ([]byte, error)
+	GetBlockTransactions(ctx context.Context, subscriptionID string, height uint32, page uint64) (*models.BlockTransactionsPage, error)
+	GetMerkleProof(ctx context.Context, txID string) (*models.MerkleProof, error)
+	BroadcastTransaction(ctx context.Context, rawTx []byte) (string, error)
 }
 
-// TransportService the transport service interface
-type TransportService interface {
+// Transport is the transport used by the junglebus client for the REST requests and the subscription tokens,
+// see Mock for testing without a server
+type Transport interface {
 	AddressService
 	BlockHeaderService
 	TransactionService
-	Login(ctx context.Context, username string, password string) error
-	IsDebug() bool
-	SetDebug(debug bool)
 	GetToken() string
+	SetToken(token string)
 	GetSubscriptionToken(ctx context.Context, subscriptionID string) (string, error)
+	GetSubscriptionDetails(ctx context.Context, subscriptionID string) (*models.SubscriptionDetails, error)
 	RefreshToken(ctx context.Context) (string, error)
-	SetToken(token string)
-	SetVersion(version string)
-	UseSSL(useSSL bool)
 	IsSSL() bool
 	GetServerURL() string
 }
 
+// TransportService the transport service interface
+type TransportService interface {
+	Transport
+	Login(ctx context.Context, username string, password string) error
+	IsDebug() bool
+	SetDebug(debug bool)
+	SetLogger(logger Logger)
+	SetHTTPClient(httpClient *http.Client)
+	SetTLSConfig(tlsConfig *tls.Config)
+	SetProxy(proxy func(*http.Request) (*url.URL, error))
+	SetHeaders(headers http.Header)
+	SetUserAgent(userAgent string)
+	SetRetryPolicy(retryPolicy RetryPolicy)
+	SetAutoRateLimit(autoRateLimit bool)
+	SetRateLimit(rps float64, burst int)
+	SetMaxResponseSize(size int64)
+	SetCircuitBreaker(breaker CircuitBreaker)
+	CircuitState() CircuitState
+	Use(middleware ...Middleware)
+	SetRequestHooks(hooks RequestHooks)
+	Retries() uint64
+	SetTracer(tracer Tracer)
+	SetVersion(version string)
+	UseSSL(useSSL bool)
+	SetServerURL(serverURL string)
+}
+
 // LoginResponse response from server on login or token refresh
 type LoginResponse struct {
 	Token string `json:"token"`
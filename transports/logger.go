@@ -0,0 +1,20 @@
+package transports
+
+// Logger is used for the internal logging of the client
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NopLogger is a Logger discarding everything, it is the default logger
+type NopLogger struct{}
+
+// Debugf discards the message
+func (NopLogger) Debugf(string, ...interface{}) {}
+
+// Infof discards the message
+func (NopLogger) Infof(string, ...interface{}) {}
+
+// Errorf discards the message
+func (NopLogger) Errorf(string, ...interface{}) {}
@@ -0,0 +1,71 @@
+package transports
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RoundTripperFunc sends a request and returns the response, like http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of every REST request, including the subscription token requests
+//
+// Middlewares are called in the order they were added, the first one wraps all the others. A middleware can
+// return an error without calling next to stop the request, such an error is returned as is and the request
+// is not retried. Every attempt of the retry policy passes through the middlewares.
+type Middleware func(next RoundTripperFunc) RoundTripperFunc
+
+// RequestHooks are called around every REST request, including all its attempts
+type RequestHooks struct {
+	Before func(ctx context.Context, endpoint string)                                    // optional
+	After  func(ctx context.Context, endpoint string, duration time.Duration, err error) // optional
+}
+
+type endpointKey struct{}
+
+// contentTypeKey is the context key of the content type of a request body, JSON when not set
+type contentTypeKey struct{}
+
+// EndpointFromContext returns the name of the endpoint being requested, like "GetTransaction", from the
+// context of a request passing through the middlewares
+func EndpointFromContext(ctx context.Context) string {
+	endpoint, _ := ctx.Value(endpointKey{}).(string)
+	return endpoint
+}
+
+// middlewareError is an error returned by a middleware without sending the request
+type middlewareError struct {
+	err error
+}
+
+func (e *middlewareError) Error() string {
+	return e.err.Error()
+}
+
+func (e *middlewareError) Unwrap() error {
+	return e.err
+}
+
+// roundTrip sends the request through the middlewares, errors of middlewares that did not send the request
+// are returned as *middlewareError
+func (h *TransportHTTP) roundTrip(req *http.Request) (*http.Response, error) {
+	if len(h.middleware) == 0 {
+		return h.httpClient.Do(req)
+	}
+
+	var sent bool
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = true
+		return h.httpClient.Do(req)
+	})
+	for i := len(h.middleware) - 1; i >= 0; i-- {
+		next = h.middleware[i](next)
+	}
+
+	resp, err := next(req)
+	if err != nil && !sent {
+		return resp, &middlewareError{err: err}
+	}
+	return resp, err
+}
@@ -0,0 +1,91 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithMiddleware will test wrapping the requests with middlewares
+func TestWithMiddleware(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"` + req.Header.Get("X-Signature") + `"}`))
+	}))
+	defer server.Close()
+
+	t.Run("registration order", func(t *testing.T) {
+		var calls []string
+		middleware := func(name string) Middleware {
+			return func(next RoundTripperFunc) RoundTripperFunc {
+				return func(req *http.Request) (*http.Response, error) {
+					calls = append(calls, name+" "+EndpointFromContext(req.Context()))
+					req.Header.Set("X-Signature", req.Header.Get("X-Signature")+name)
+					return next(req)
+				}
+			}
+		}
+		c, err := NewTransport(WithHTTP(server.URL), WithMiddleware(middleware("a"), middleware("b")),
+			WithMiddleware(middleware("c")))
+		require.NoError(t, err)
+
+		token, err := c.GetSubscriptionToken(context.Background(), "test-subscription")
+		require.NoError(t, err)
+		assert.Equal(t, "abc", token)
+		assert.Equal(t, []string{"a GetSubscriptionToken", "b GetSubscriptionToken", "c GetSubscriptionToken"}, calls)
+	})
+
+	t.Run("short circuit", func(t *testing.T) {
+		errBlocked := errors.New("blocked")
+		c, err := NewTransport(WithHTTP(server.URL), WithMiddleware(func(RoundTripperFunc) RoundTripperFunc {
+			return func(*http.Request) (*http.Response, error) {
+				return nil, errBlocked
+			}
+		}))
+		require.NoError(t, err)
+
+		atomic.StoreInt32(&requests, 0)
+		_, err = c.GetChainTip(context.Background())
+		require.ErrorIs(t, err, errBlocked)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+		assert.Equal(t, uint64(0), c.Retries())
+	})
+}
+
+// TestWithRequestHooks will test the hooks called around the requests
+func TestWithRequestHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var before, after string
+	var duration time.Duration
+	var afterErr error
+	c, err := NewTransport(WithHTTP(server.URL), WithRequestHooks(RequestHooks{
+		Before: func(_ context.Context, endpoint string) {
+			before = endpoint
+		},
+		After: func(_ context.Context, endpoint string, d time.Duration, err error) {
+			after, duration, afterErr = endpoint, d, err
+		},
+	}))
+	require.NoError(t, err)
+
+	_, err = c.GetTransaction(context.Background(), "txid")
+	require.Error(t, err)
+	assert.Equal(t, "GetTransaction", before)
+	assert.Equal(t, "GetTransaction", after)
+	assert.GreaterOrEqual(t, duration, 10*time.Millisecond)
+	assert.ErrorIs(t, afterErr, ErrNotFound)
+}
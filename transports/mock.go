@@ -0,0 +1,230 @@
+package transports
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// MockCall is a call recorded by Mock
+type MockCall struct {
+	Method string        // name of the method, like "GetSubscriptionToken"
+	Args   []interface{} // arguments of the call, without the context
+}
+
+// Mock is a Transport with programmable responses that records its calls, for testing without a server
+//
+// The responses are programmed by setting the func fields, methods without a func return zero values
+// and no error. The func fields must be set before the mock is used.
+type Mock struct {
+	ServerURL string // returned by GetServerURL, the websocket of subscriptions connects to it
+	SSL       bool   // returned by IsSSL
+
+	GetAddressTransactionsFunc       func(ctx context.Context, address string) ([]*models.Address, error)
+	GetAddressTransactionDetailsFunc func(ctx context.Context, address string) ([]*models.Transaction, error)
+	GetAddressTransactionsFromFunc   func(ctx context.Context, address string, fromHeight uint32, limit uint) ([]*models.AddressTx, error)
+	GetAddressDetailsFunc            func(ctx context.Context, address string) (*models.AddressDetails, error)
+	GetBlockHeaderFunc               func(ctx context.Context, block string) (*models.BlockHeader, error)
+	GetBlockHeadersFunc              func(ctx context.Context, fromBlock string, limit uint) ([]*models.BlockHeader, error)
+	GetChainTipFunc                  func(ctx context.Context) (*models.BlockHeader, error)
+	GetTransactionFunc               func(ctx context.Context, txID string) (*models.Transaction, error)
+	GetRawTransactionFunc            func(ctx context.Context, txID string) ([]byte, error)
+	BroadcastTransactionFunc         func(ctx context.Context, rawTx []byte) (string, error)
+	GetMerkleProofFunc               func(ctx context.Context, txID string) (*models.MerkleProof, error)
+	GetBlockTransactionsFunc         func(ctx context.Context, subscriptionID string, height uint32, page uint64) (*models.BlockTransactionsPage, error)
+	GetSubscriptionTokenFunc         func(ctx context.Context, subscriptionID string) (string, error)
+	GetSubscriptionDetailsFunc       func(ctx context.Context, subscriptionID string) (*models.SubscriptionDetails, error)
+	RefreshTokenFunc                 func(ctx context.Context) (string, error)
+
+	mu    sync.Mutex
+	token string
+	calls []MockCall
+}
+
+// record records a call to the method
+func (m *Mock) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, MockCall{Method: method, Args: args})
+}
+
+// Calls returns the recorded calls in the order they were made
+func (m *Mock) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+// CallsTo returns the recorded calls to the method
+func (m *Mock) CallsTo(method string) []MockCall {
+	var calls []MockCall
+	for _, call := range m.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// GetAddressTransactions calls GetAddressTransactionsFunc
+func (m *Mock) GetAddressTransactions(ctx context.Context, address string) ([]*models.Address, error) {
+	m.record("GetAddressTransactions", address)
+	if m.GetAddressTransactionsFunc == nil {
+		return nil, nil
+	}
+	return m.GetAddressTransactionsFunc(ctx, address)
+}
+
+// GetAddressTransactionDetails calls GetAddressTransactionDetailsFunc
+func (m *Mock) GetAddressTransactionDetails(ctx context.Context, address string) ([]*models.Transaction, error) {
+	m.record("GetAddressTransactionDetails", address)
+	if m.GetAddressTransactionDetailsFunc == nil {
+		return nil, nil
+	}
+	return m.GetAddressTransactionDetailsFunc(ctx, address)
+}
+
+// GetAddressTransactionsFrom calls GetAddressTransactionsFromFunc
+func (m *Mock) GetAddressTransactionsFrom(ctx context.Context, address string, fromHeight uint32,
+	limit uint) ([]*models.AddressTx, error) {
+	m.record("GetAddressTransactionsFrom", address, fromHeight, limit)
+	if m.GetAddressTransactionsFromFunc == nil {
+		return nil, nil
+	}
+	return m.GetAddressTransactionsFromFunc(ctx, address, fromHeight, limit)
+}
+
+// GetAddressDetails calls GetAddressDetailsFunc
+func (m *Mock) GetAddressDetails(ctx context.Context, address string) (*models.AddressDetails, error) {
+	m.record("GetAddressDetails", address)
+	if m.GetAddressDetailsFunc == nil {
+		return nil, nil
+	}
+	return m.GetAddressDetailsFunc(ctx, address)
+}
+
+// GetBlockTransactions calls GetBlockTransactionsFunc
+func (m *Mock) GetBlockTransactions(ctx context.Context, subscriptionID string, height uint32,
+	page uint64) (*models.BlockTransactionsPage, error) {
+	m.record("GetBlockTransactions", subscriptionID, height, page)
+	if m.GetBlockTransactionsFunc == nil {
+		return &models.BlockTransactionsPage{}, nil
+	}
+	return m.GetBlockTransactionsFunc(ctx, subscriptionID, height, page)
+}
+
+// BroadcastTransaction calls BroadcastTransactionFunc
+func (m *Mock) BroadcastTransaction(ctx context.Context, rawTx []byte) (string, error) {
+	m.record("BroadcastTransaction", rawTx)
+	if m.BroadcastTransactionFunc == nil {
+		return "", nil
+	}
+	return m.BroadcastTransactionFunc(ctx, rawTx)
+}
+
+// GetMerkleProof calls GetMerkleProofFunc
+func (m *Mock) GetMerkleProof(ctx context.Context, txID string) (*models.MerkleProof, error) {
+	m.record("GetMerkleProof", txID)
+	if m.GetMerkleProofFunc == nil {
+		return nil, nil
+	}
+	return m.GetMerkleProofFunc(ctx, txID)
+}
+
+// GetBlockHeader calls GetBlockHeaderFunc
+func (m *Mock) GetBlockHeader(ctx context.Context, block string) (*models.BlockHeader, error) {
+	m.record("GetBlockHeader", block)
+	if m.GetBlockHeaderFunc == nil {
+		return nil, nil
+	}
+	return m.GetBlockHeaderFunc(ctx, block)
+}
+
+// GetBlockHeaders calls GetBlockHeadersFunc
+func (m *Mock) GetBlockHeaders(ctx context.Context, fromBlock string, limit uint) ([]*models.BlockHeader, error) {
+	m.record("GetBlockHeaders", fromBlock, limit)
+	if m.GetBlockHeadersFunc == nil {
+		return nil, nil
+	}
+	return m.GetBlockHeadersFunc(ctx, fromBlock, limit)
+}
+
+// GetChainTip calls GetChainTipFunc
+func (m *Mock) GetChainTip(ctx context.Context) (*models.BlockHeader, error) {
+	m.record("GetChainTip")
+	if m.GetChainTipFunc == nil {
+		return nil, nil
+	}
+	return m.GetChainTipFunc(ctx)
+}
+
+// GetTransaction calls GetTransactionFunc
+func (m *Mock) GetTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	m.record("GetTransaction", txID)
+	if m.GetTransactionFunc == nil {
+		return nil, nil
+	}
+	return m.GetTransactionFunc(ctx, txID)
+}
+
+// GetRawTransaction calls GetRawTransactionFunc
+func (m *Mock) GetRawTransaction(ctx context.Context, txID string) ([]byte, error) {
+	m.record("GetRawTransaction", txID)
+	if m.GetRawTransactionFunc == nil {
+		return nil, nil
+	}
+	return m.GetRawTransactionFunc(ctx, txID)
+}
+
+// GetSubscriptionDetails calls GetSubscriptionDetailsFunc, returning an active subscription when it is not set
+func (m *Mock) GetSubscriptionDetails(ctx context.Context, subscriptionID string) (*models.SubscriptionDetails, error) {
+	m.record("GetSubscriptionDetails", subscriptionID)
+	if m.GetSubscriptionDetailsFunc == nil {
+		return &models.SubscriptionDetails{ID: subscriptionID, Status: "active"}, nil
+	}
+	return m.GetSubscriptionDetailsFunc(ctx, subscriptionID)
+}
+
+// GetSubscriptionToken calls GetSubscriptionTokenFunc
+func (m *Mock) GetSubscriptionToken(ctx context.Context, subscriptionID string) (string, error) {
+	m.record("GetSubscriptionToken", subscriptionID)
+	if m.GetSubscriptionTokenFunc == nil {
+		return "", nil
+	}
+	return m.GetSubscriptionTokenFunc(ctx, subscriptionID)
+}
+
+// RefreshToken calls RefreshTokenFunc
+func (m *Mock) RefreshToken(ctx context.Context) (string, error) {
+	m.record("RefreshToken")
+	if m.RefreshTokenFunc == nil {
+		return "", nil
+	}
+	return m.RefreshTokenFunc(ctx)
+}
+
+// GetToken returns the token set with SetToken
+func (m *Mock) GetToken() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token
+}
+
+// SetToken sets the token returned by GetToken
+func (m *Mock) SetToken(token string) {
+	m.record("SetToken", token)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = token
+}
+
+// IsSSL returns SSL
+func (m *Mock) IsSSL() bool {
+	return m.SSL
+}
+
+// GetServerURL returns ServerURL
+func (m *Mock) GetServerURL() string {
+	return m.ServerURL
+}
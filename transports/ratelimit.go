@@ -0,0 +1,34 @@
+package transports
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// newRateLimiter returns a rate limiter allowing rps requests per second with bursts of burst requests,
+// nil when rps is zero or negative
+func newRateLimiter(rps float64, burst int) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// waitRateLimit blocks until a request is allowed by the limiter, it returns context.DeadlineExceeded right away
+// when the request would not be allowed before the deadline of the context
+func waitRateLimit(ctx context.Context, limiter *rate.Limiter) error {
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return context.DeadlineExceeded
+	}
+	return nil
+}
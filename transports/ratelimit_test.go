@@ -0,0 +1,70 @@
+package transports
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithRateLimit will test limiting the rate of requests
+func TestWithRateLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	t.Run("burst and rate", func(t *testing.T) {
+		c, err := NewTransport(WithHTTP(server.URL), WithRateLimit(20, 2))
+		require.NoError(t, err)
+
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			_, err = c.GetChainTip(context.Background())
+			require.NoError(t, err)
+		}
+		// the first two requests use the burst, the others wait 50ms each
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		c, err := NewTransport(WithHTTP(server.URL), WithRateLimit(0.1, 1))
+		require.NoError(t, err)
+		_, err = c.GetChainTip(context.Background())
+		require.NoError(t, err)
+
+		atomic.StoreInt32(&requests, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		start := time.Now()
+		_, err = c.GetChainTip(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		c, err := NewTransport(WithHTTP(server.URL), WithRateLimit(1, 1))
+		require.NoError(t, err)
+		_, err = c.GetChainTip(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		_, err = c.GetChainTip(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, newRateLimiter(0, 10))
+		assert.NoError(t, waitRateLimit(context.Background(), newRateLimiter(0, 10)))
+	})
+}
@@ -0,0 +1,79 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jpillora/backoff"
+)
+
+// RetryPolicy defines how requests failing with a connection error, a 5xx or a 429 status are retried
+type RetryPolicy struct {
+	MaxAttempts        int           // attempts including the first one, 1 or less disables retrying
+	MinDelay           time.Duration // delay before the first retry, doubling for every next retry
+	MaxDelay           time.Duration // maximum delay between attempts
+	RetryNonIdempotent bool          // also retry requests that are not idempotent, like most POST requests
+}
+
+// DefaultRetryPolicy is the default retry policy of the transport
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinDelay:    100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// NoRetryPolicy disables retrying requests
+var NoRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// delay returns the jittered delay before the retry following the given attempt, attempt 0 is the first
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	b := &backoff.Backoff{
+		Min:    p.MinDelay,
+		Max:    p.MaxDelay,
+		Factor: 2,
+		Jitter: true,
+	}
+	return b.ForAttempt(float64(attempt))
+}
+
+// retryable returns whether a request that failed with err and the status code, if a response was received, can be retried
+func retryable(err error, statusCode int) bool {
+	if statusCode > 0 {
+		return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+	}
+	// no response was received, unless the request was cancelled by the caller, the circuit breaker or a
+	// middleware the connection failed
+	var middlewareErr *middlewareError
+	return err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrCircuitOpen) && !errors.As(err, &middlewareErr)
+}
+
+// parseRetryAfter returns the duration of the Retry-After header in seconds or HTTP-date form, zero when invalid
+func parseRetryAfter(retryAfter string, now time.Time) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// isIdempotent returns whether requests with the method can be sent more than once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
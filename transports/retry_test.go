@@ -0,0 +1,150 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetryPolicy will test which failing requests are retried
+func TestRetryPolicy(t *testing.T) {
+	retryPolicy := RetryPolicy{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	newTransport := func(t *testing.T, status int, retryPolicy RetryPolicy) (*TransportHTTP, *int32) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+
+		c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(retryPolicy))
+		require.NoError(t, err)
+		return c.(*TransportHTTP), &requests
+	}
+
+	t.Run("server errors", func(t *testing.T) {
+		for _, status := range []int{http.StatusBadGateway, http.StatusTooManyRequests} {
+			h, requests := newTransport(t, status, retryPolicy)
+			err := h.doHTTPRequest(context.Background(), "test", http.MethodGet, "/block_header/tip", nil, nil)
+			require.Error(t, err)
+			assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+			assert.Equal(t, uint64(2), h.Retries())
+		}
+	})
+
+	t.Run("client errors", func(t *testing.T) {
+		h, requests := newTransport(t, http.StatusNotFound, retryPolicy)
+		err := h.doHTTPRequest(context.Background(), "test", http.MethodGet, "/block_header/tip", nil, nil)
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		h, requests := newTransport(t, http.StatusBadGateway, retryPolicy)
+		err := h.doHTTPRequest(context.Background(), "test", http.MethodPost, "/transaction/send", []byte("{}"), nil)
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+		optIn := retryPolicy
+		optIn.RetryNonIdempotent = true
+		h, requests = newTransport(t, http.StatusBadGateway, optIn)
+		err = h.doHTTPRequest(context.Background(), "test", http.MethodPost, "/transaction/send", []byte("{}"), nil)
+		require.Error(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+	})
+
+	t.Run("connection errors", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(retryPolicy))
+		require.NoError(t, err)
+		h := c.(*TransportHTTP)
+
+		err = h.doHTTPRequest(context.Background(), "test", http.MethodGet, "/block_header/tip", nil, nil)
+		require.Error(t, err)
+		assert.Equal(t, uint64(2), h.Retries())
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		h, requests := newTransport(t, http.StatusBadGateway,
+			RetryPolicy{MaxAttempts: 3, MinDelay: time.Minute, MaxDelay: time.Minute})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := h.doHTTPRequest(ctx, "test", http.MethodGet, "/block_header/tip", nil, nil)
+		require.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "502"))
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+}
+
+// TestParseRetryAfter will test parsing the Retry-After header
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
+
+// TestAutoRateLimit will test waiting for the Retry-After duration of rate limited requests
+func TestAutoRateLimit(t *testing.T) {
+	newTransport := func(t *testing.T, retryAfter string, limited int32) (*TransportHTTP, *int32) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= limited {
+				w.Header().Set("Retry-After", retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		t.Cleanup(server.Close)
+
+		c, err := NewTransport(WithHTTP(server.URL), WithRetryPolicy(NoRetryPolicy), WithAutoRateLimit(true))
+		require.NoError(t, err)
+		return c.(*TransportHTTP), &requests
+	}
+
+	t.Run("waits and retries", func(t *testing.T) {
+		h, requests := newTransport(t, "0", 2)
+		_, err := h.GetChainTip(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+	})
+
+	t.Run("deadline before retry after", func(t *testing.T) {
+		h, requests := newTransport(t, "60", 1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := h.GetChainTip(ctx)
+		var rateLimitErr *RateLimitError
+		require.True(t, errors.As(err, &rateLimitErr))
+		assert.Equal(t, time.Minute, rateLimitErr.RetryAfter)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h, requests := newTransport(t, "0", 1)
+		h.SetAutoRateLimit(false)
+		_, err := h.GetChainTip(context.Background())
+		var rateLimitErr *RateLimitError
+		require.True(t, errors.As(err, &rateLimitErr))
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+}
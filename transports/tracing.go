@@ -0,0 +1,38 @@
+package transports
+
+import "context"
+
+// Tracer starts the spans of the client, see WithTracer
+//
+// It is implemented by an adapter for the tracing library of the application, like OpenTelemetry (see the otel
+// package), so the client does not depend on any tracing library. Spans have to be children of the span in ctx, if any.
+type Tracer interface {
+	Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key value pair describing a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// NopTracer is a Tracer not tracing anything, it is the default tracer
+type NopTracer struct{}
+
+// Start returns ctx and a span doing nothing
+func (NopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
@@ -0,0 +1,45 @@
+package transports
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// maxPreallocSize is the maximum number of bytes allocated up front for a raw transaction of known size
+const maxPreallocSize = 32 << 20
+
+// readTransaction reads the raw transaction while hashing it, returning ErrChecksumMismatch when
+// the double SHA-256 of the bytes is not the txid
+func readTransaction(body io.Reader, size int64, txID string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if size > 0 && size <= maxPreallocSize {
+		buf.Grow(int(size))
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(buf, hash), body); err != nil {
+		return nil, err
+	}
+
+	if hashToTxID(hash.Sum(nil)) != strings.ToLower(txID) {
+		return nil, ErrChecksumMismatch
+	}
+	return buf.Bytes(), nil
+}
+
+// transactionID returns the txid of the raw transaction
+func transactionID(transaction []byte) string {
+	hash := sha256.Sum256(transaction)
+	return hashToTxID(hash[:])
+}
+
+// hashToTxID returns the txid of the SHA-256 hash of a raw transaction, its double SHA-256 in reverse byte order
+func hashToTxID(hash []byte) string {
+	txHash := sha256.Sum256(hash)
+	for i, j := 0, len(txHash)-1; i < j; i, j = i+1, j-1 {
+		txHash[i], txHash[j] = txHash[j], txHash[i]
+	}
+	return hex.EncodeToString(txHash[:])
+}
@@ -1,9 +1,32 @@
 package transports
 
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/time/rate"
+)
+
 // Client is the transport client
 type Client struct {
-	debug     bool
-	transport TransportService
+	debug         bool
+	httpClient    *http.Client
+	tlsConfig     *tls.Config
+	proxy         func(*http.Request) (*url.URL, error)
+	headers       http.Header
+	userAgent     string
+	retryPolicy   RetryPolicy
+	autoRateLimit bool
+	rateLimiter   *rate.Limiter
+	maxSize       int64
+	breaker       *circuitBreaker
+	middleware    []Middleware
+	hooks         RequestHooks
+	logger        Logger
+	tracer        Tracer
+	transport     TransportService
+	optionErr     error // the first invalid option, returned by NewTransport
 }
 
 // ClientOps are the client options functions
@@ -11,11 +34,19 @@ type ClientOps func(c *Client)
 
 // NewTransport create a new transport service object
 func NewTransport(opts ...ClientOps) (TransportService, error) {
-	client := Client{}
+	client := Client{
+		logger:      NopLogger{},
+		tracer:      NopTracer{},
+		userAgent:   JungleBusUserAgent,
+		retryPolicy: DefaultRetryPolicy,
+	}
 
 	for _, opt := range opts {
 		opt(&client)
 	}
+	if client.optionErr != nil {
+		return nil, client.optionErr
+	}
 
 	if client.transport == nil {
 		return nil, ErrNoClientSet
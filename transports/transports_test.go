@@ -18,7 +18,7 @@ func TestWithDebugging(t *testing.T) {
 	t.Run("debug false", func(t *testing.T) {
 		opts := []ClientOps{
 			WithDebugging(false),
-			WithHTTP(""),
+			WithHTTP("localhost"),
 		}
 		c, err := NewTransport(opts...)
 		require.NoError(t, err)
@@ -30,7 +30,7 @@ func TestWithDebugging(t *testing.T) {
 	t.Run("debug true", func(t *testing.T) {
 		opts := []ClientOps{
 			WithDebugging(true),
-			WithHTTP(""),
+			WithHTTP("localhost"),
 		}
 		c, err := NewTransport(opts...)
 		require.NoError(t, err)
@@ -39,3 +39,47 @@ func TestWithDebugging(t *testing.T) {
 		assert.Equal(t, true, c.IsDebug())
 	})
 }
+
+// TestParseServerURL will test normalizing the server url
+func TestParseServerURL(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		server    string
+		useSSL    bool
+	}{
+		{"junglebus.gorillapool.io", "junglebus.gorillapool.io", true},
+		{"junglebus.gorillapool.io/", "junglebus.gorillapool.io", true},
+		{"https://junglebus.gorillapool.io", "junglebus.gorillapool.io", true},
+		{"https://junglebus.gorillapool.io/", "junglebus.gorillapool.io", true},
+		{"http://localhost:8080", "localhost:8080", false},
+		{"localhost:8080", "localhost:8080", true},
+		{"ws://localhost:8080//", "localhost:8080", false},
+		{"wss://junglebus.gorillapool.io", "junglebus.gorillapool.io", true},
+		{"HTTPS://junglebus.gorillapool.io", "junglebus.gorillapool.io", true},
+		{"https://example.com/junglebus", "example.com/junglebus", true},
+		{"http://example.com/api/junglebus/", "example.com/api/junglebus", false},
+		{"  http://127.0.0.1:3000  ", "127.0.0.1:3000", false},
+		{"http://[::1]:3000/", "[::1]:3000", false},
+	}
+	for _, test := range tests {
+		t.Run(test.serverURL, func(t *testing.T) {
+			server, useSSL, err := ParseServerURL(test.serverURL)
+			require.NoError(t, err)
+			assert.Equal(t, test.server, server)
+			assert.Equal(t, test.useSSL, useSSL)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, serverURL := range []string{"", "https://", "ftp://example.com", "http://example.com:port",
+			"https://example.com/?key=value", "http://exa mple.com"} {
+			_, _, err := ParseServerURL(serverURL)
+			assert.ErrorIs(t, err, ErrInvalidServerURL, serverURL)
+		}
+	})
+
+	t.Run("NewTransport", func(t *testing.T) {
+		_, err := NewTransport(WithHTTP("ftp://example.com"))
+		assert.ErrorIs(t, err, ErrInvalidServerURL)
+	})
+}
@@ -0,0 +1,141 @@
+package junglebus
+
+import (
+	"context"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// TxContext describes how a transaction was received, it is passed to the middlewares of WithTxMiddleware
+type TxContext struct {
+	Channel      string          // the full name of the channel, like query:<subscription id>:mempool
+	Mempool      bool            // whether the transaction is passed on to OnMempool instead of OnTransaction
+	Block        uint32          // the block of the transaction, 0 in the mempool
+	Page         uint64          // the page of the block reported by the last control message, or 0
+	Offset       uint64          // the offset of the publication in the channel
+	Epoch        string          // the epoch of the stream of the channel, see StreamPosition
+	ReceivedAt   time.Time       // when the publication arrived, before it waited in the queue
+	IsHistorical bool            // whether the subscription had not reached the chain tip yet, see MessageContext
+	cache        *txCache        // the decoded forms of the transaction, see DecodeTx
+	onError      func(err error) // OnError of the subscription
+	ctx          context.Context // see Context
+}
+
+// Context returns the context the transaction is handled in: the context of its span with WithTracer, or the context
+// the subscription was started with
+func (c TxContext) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// TxHandler handles a transaction, see TxMiddleware
+type TxHandler func(ctx TxContext, tx *models.TransactionResponse)
+
+// TxMiddleware wraps the handling of every transaction, calling next passes the transaction on. A middleware not
+// calling next drops the transaction.
+type TxMiddleware func(next TxHandler) TxHandler
+
+// withTxMiddleware returns the handler calling OnTransaction or OnMempool through the middlewares, the first one
+// wraps all the others
+func (s *Subscription) withTxMiddleware(middleware []TxMiddleware) TxHandler {
+	handler := func(ctx TxContext, tx *models.TransactionResponse) {
+		if ctx.Mempool {
+			s.EventHandler.OnMempool(tx)
+		} else {
+			s.EventHandler.OnTransaction(tx)
+		}
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	if !s.panicRecovery {
+		return handler
+	}
+	onError := s.EventHandler.OnError
+	return func(ctx TxContext, tx *models.TransactionResponse) {
+		defer s.recoverPanic("TxMiddleware", onError)
+		handler(ctx, tx)
+	}
+}
+
+// handleTransaction passes a transaction on to the event handler, through the middlewares of WithTxMiddleware
+func (s *Subscription) handleTransaction(eventHandler EventHandler, ctx TxContext, tx *models.TransactionResponse) {
+	s.fanOut(func(c *Consumer) { c.transaction(ctx, tx) })
+	if !ctx.Mempool {
+		s.lag.received(ctx.Block, ctx.Offset)
+	}
+	if s.sink != nil {
+		s.sinkTransaction(tx, ctx.Mempool, eventHandler.OnError)
+	}
+	if s.spool != nil {
+		s.spoolTransaction(ctx, tx, func() { s.callTxHandler(ctx, tx) })
+		return
+	}
+	connection := s.currentConnection()
+	s.dispatchTransaction(func() {
+		// the main channel resumes from the checkpoint on the connection of Reconnect
+		if !ctx.Mempool && s.replacedConnection(connection) {
+			s.release(tx)
+			return
+		}
+		s.callTxHandler(ctx, tx)
+	})
+}
+
+// callTxHandler calls the middlewares of WithTxMiddleware, or OnTransaction or OnMempool without middlewares, from
+// the queue worker
+func (s *Subscription) callTxHandler(ctx TxContext, tx *models.TransactionResponse) {
+	switch {
+	case s.txHandler != nil:
+		ctx.cache = &txCache{}
+		ctx.onError = s.EventHandler.OnError
+		ctx.ctx = s.ctx
+		s.txHandler(ctx, tx)
+	case ctx.Mempool:
+		s.EventHandler.OnMempool(tx)
+	default:
+		s.EventHandler.OnTransaction(tx)
+	}
+	if !ctx.Mempool {
+		s.lag.processed(ctx.Block, ctx.Offset)
+	}
+}
+
+// DedupTxMiddleware drops transactions that were already handled, remembering the last size txids. A transaction
+// is handled once from the mempool and once mined, like after resuming a block when reconnecting.
+func DedupTxMiddleware(size int) TxMiddleware {
+	cache := newDedupCache(size, 0)
+	return func(next TxHandler) TxHandler {
+		return func(ctx TxContext, tx *models.TransactionResponse) {
+			channel := channelMain
+			if ctx.Mempool {
+				channel = channelMempool
+			}
+			if !cache.seen(channel, tx.Id) {
+				next(ctx, tx)
+			}
+		}
+	}
+}
+
+// LatencyTxMiddleware logs how long every transaction waited before it was handled and how long handling took, at
+// debug level. Transactions taking at least slow from arriving until handled are logged as a warning, 0 never warns.
+func LatencyTxMiddleware(logger Logger, slow time.Duration) TxMiddleware {
+	return func(next TxHandler) TxHandler {
+		return func(ctx TxContext, tx *models.TransactionResponse) {
+			start := time.Now()
+			txID := tx.Id // the transaction may be released by the handler
+			next(ctx, tx)
+			duration := time.Since(start)
+			level := levelDebug
+			if slow > 0 && time.Since(ctx.ReceivedAt) >= slow {
+				level = levelWarn
+			}
+			logEvent(logger, level, "handled transaction", "txid", txID, "channel", ctx.Channel,
+				"block", ctx.Block, "wait", start.Sub(ctx.ReceivedAt), "duration", duration)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscribe_WithTxMiddleware will test calling the middlewares in order with the context of the transactions
+func TestSubscribe_WithTxMiddleware(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		var mu sync.Mutex
+		var calls []string
+		var contexts []TxContext
+		record := func(name string) TxMiddleware {
+			return func(next TxHandler) TxHandler {
+				return func(ctx TxContext, tx *models.TransactionResponse) {
+					mu.Lock()
+					calls = append(calls, name+" "+tx.Id)
+					if name == "first" {
+						contexts = append(contexts, ctx)
+					}
+					mu.Unlock()
+					next(ctx, tx)
+				}
+			}
+		}
+		drop := func(next TxHandler) TxHandler {
+			return func(ctx TxContext, tx *models.TransactionResponse) {
+				if tx.Id != "dropped" {
+					next(ctx, tx)
+				}
+			}
+		}
+
+		handled := make(chan string, 3)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(tx *models.TransactionResponse) { handled <- "mined " + tx.Id },
+			OnMempool:     func(tx *models.TransactionResponse) { handled <- "mempool " + tx.Id },
+			OnStatus:      func(*models.ControlResponse) {},
+		}, WithTxMiddleware(record("first"), drop), WithTxMiddleware(record("second")), WithQueueSize(10))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+		before := time.Now()
+		require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{Id: "tx", BlockHeight: 101}))
+		server.publishTransaction(mainChannel, "dropped")
+		server.publishTransaction(mempoolChannel, "mempool")
+
+		for _, expected := range []string{"mined tx", "mempool mempool"} {
+			select {
+			case id := <-handled:
+				assert.Equal(t, expected, id)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s not handled", expected)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"first tx", "second tx", "first dropped", "first mempool", "second mempool"}, calls)
+		require.Len(t, contexts, 3)
+		assert.Equal(t, mainChannel, contexts[0].Channel)
+		assert.False(t, contexts[0].Mempool)
+		assert.Equal(t, uint32(101), contexts[0].Block)
+		assert.NotZero(t, contexts[0].Offset)
+		assert.False(t, contexts[0].ReceivedAt.Before(before))
+		assert.Equal(t, mempoolChannel, contexts[2].Channel)
+		assert.True(t, contexts[2].Mempool)
+	})
+}
+
+// TestSubscribe_TxMiddlewarePanic will test recovering from a panic of a middleware
+func TestSubscribe_TxMiddlewarePanic(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		errs := make(chan error, 1)
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(err error) { errs <- err },
+		}, WithTxMiddleware(func(TxHandler) TxHandler {
+			return func(TxContext, *models.TransactionResponse) { panic("boom") }
+		}))
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		server.waitSubscribed(mainChannel)
+		server.publishTransaction(mainChannel, "tx")
+		select {
+		case err := <-errs:
+			var panicErr *PanicError
+			require.True(t, errors.As(err, &panicErr))
+			assert.Equal(t, "TxMiddleware", panicErr.Handler)
+		case <-time.After(5 * time.Second):
+			t.Fatal("panic not reported")
+		}
+	})
+}
+
+func TestDedupTxMiddleware(t *testing.T) {
+	var handled []string
+	handler := DedupTxMiddleware(2)(func(ctx TxContext, tx *models.TransactionResponse) {
+		handled = append(handled, tx.Id)
+	})
+	mined := TxContext{}
+	mempool := TxContext{Mempool: true}
+
+	handler(mempool, &models.TransactionResponse{Id: "a"})
+	handler(mined, &models.TransactionResponse{Id: "a"})
+	handler(mined, &models.TransactionResponse{Id: "a"})
+	assert.Equal(t, []string{"a", "a"}, handled, "a transaction is handled once from the mempool and once mined")
+
+	handler(mined, &models.TransactionResponse{Id: "b"})
+	handler(mined, &models.TransactionResponse{Id: "a"}) // a is now the most recent
+	handler(mined, &models.TransactionResponse{Id: "c"}) // evicts b
+	handler(mined, &models.TransactionResponse{Id: "a"})
+	handler(mined, &models.TransactionResponse{Id: "b"})
+	assert.Equal(t, []string{"a", "a", "b", "c", "b"}, handled)
+}
+
+func TestLatencyTxMiddleware(t *testing.T) {
+	logger := &testLogger{}
+	handler := LatencyTxMiddleware(logger, time.Hour)(func(TxContext, *models.TransactionResponse) {})
+	handler(TxContext{Channel: "query:id:100", Block: 100, ReceivedAt: time.Now()}, &models.TransactionResponse{Id: "tx"})
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	require.Len(t, logger.lines, 1)
+	line := logger.lines[0]
+	assert.True(t, strings.HasPrefix(line, "handled transaction"), line)
+	for _, field := range []string{"txid=tx", "channel=query:id:100", "block=100", "wait=", "duration="} {
+		assert.Contains(t, line, field)
+	}
+}
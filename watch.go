@@ -0,0 +1,302 @@
+package junglebus
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+)
+
+// Defaults of polling a watched transaction, see WithWatchPollBackoff
+const (
+	DefaultWatchPollMinDelay = time.Second
+	DefaultWatchPollMaxDelay = time.Minute
+)
+
+// TxPhase is the phase of a watched transaction, see WatchTransaction
+type TxPhase int
+
+const (
+	// TxSeen is when the transaction is in the mempool
+	TxSeen TxPhase = iota + 1
+	// TxConfirmed is when the transaction is mined in a block
+	TxConfirmed
+	// TxReorged is when the block of the transaction was reorged out, or the transaction was dropped
+	TxReorged
+)
+
+// String returns the name of the phase, like "confirmed"
+func (p TxPhase) String() string {
+	switch p {
+	case TxSeen:
+		return "seen"
+	case TxConfirmed:
+		return "confirmed"
+	case TxReorged:
+		return "reorged"
+	default:
+		return "TxPhase(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// TxEvent is a change of the phase of a watched transaction, the block is set for TxConfirmed and is the block the
+// transaction was mined in for TxReorged
+type TxEvent struct {
+	TxID        string
+	Phase       TxPhase
+	BlockHash   string
+	BlockHeight uint32
+	BlockTime   uint32 // unix timestamp of the block
+	BlockIndex  uint64 // position of the transaction in the block
+}
+
+// WatchTransaction sends an event on the returned channel when the transaction is seen in the mempool, is mined in a
+// block and when its block is reorged out, until ctx is done and the channel is closed. The transaction is looked up
+// first, an already mined transaction is confirmed right away and the error of the lookup is returned, except for
+// ErrNotFound.
+//
+// The transaction is looked up again with a growing delay, see WithWatchPollBackoff, the delay starts over every time
+// the transaction changes phase. The transaction is followed in the mempool and block streams of the subscriptions of
+// the client too: once it was received on one of them, it is only followed in the streams while the client has
+// subscriptions. Events are not dropped, the channel should be read until it is closed.
+func (jb *Client) WatchTransaction(ctx context.Context, txID string) (<-chan TxEvent, error) {
+	w := &txWatcher{txID: txID, wake: make(chan struct{}, 1)}
+	jb.addWatcher(w)
+	if _, err := jb.pollTransaction(ctx, w); err != nil {
+		jb.removeWatcher(w)
+		return nil, err
+	}
+
+	events := make(chan TxEvent)
+	go jb.watch(ctx, w, events)
+	return events, nil
+}
+
+// watch sends the events of the watcher, polling the transaction until it is followed in the streams of a subscription
+func (jb *Client) watch(ctx context.Context, w *txWatcher, events chan<- TxEvent) {
+	defer close(events)
+	defer jb.removeWatcher(w)
+
+	attempt := 0
+	timer := time.NewTimer(jb.watchPolicy.delay(attempt))
+	defer timer.Stop()
+	for {
+		for _, event := range w.take() {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-timer.C:
+			changed := false
+			if !w.isObserved() || !jb.hasSubscriptions() {
+				var err error
+				if changed, err = jb.pollTransaction(ctx, w); err != nil {
+					logEvent(jb.logger, levelDebug, "watching transaction failed", "txid", w.txID, "error", err)
+				}
+			}
+			if changed {
+				attempt = 0
+			} else {
+				attempt++
+			}
+			timer.Reset(jb.watchPolicy.delay(attempt))
+		}
+	}
+}
+
+// pollTransaction looks up the transaction of the watcher, returning whether its phase changed
+func (jb *Client) pollTransaction(ctx context.Context, w *txWatcher) (bool, error) {
+	transaction, err := jb.transport.GetTransaction(ctx, w.txID)
+	switch {
+	case errors.Is(err, ErrNotFound), err == nil && transaction == nil:
+		return w.reorged(0), nil
+	case err != nil:
+		return false, err
+	case transaction.BlockHash == "":
+		// a mined transaction that is back in the mempool was reorged out first
+		changed := w.reorged(0)
+		return w.seen() || changed, nil
+	default:
+		return w.confirmed(TxEvent{
+			TxID:        w.txID,
+			Phase:       TxConfirmed,
+			BlockHash:   transaction.BlockHash,
+			BlockHeight: transaction.BlockHeight,
+			BlockTime:   transaction.BlockTime,
+			BlockIndex:  transaction.BlockIndex,
+		}), nil
+	}
+}
+
+// hasSubscriptions returns whether the client has subscriptions, observed transactions follow their streams
+func (jb *Client) hasSubscriptions() bool {
+	jb.subscriptionsMu.Lock()
+	defer jb.subscriptionsMu.Unlock()
+	return len(jb.subscriptions) > 0
+}
+
+func (jb *Client) addWatcher(w *txWatcher) {
+	jb.watchersMu.Lock()
+	defer jb.watchersMu.Unlock()
+	if jb.watchers == nil {
+		jb.watchers = map[string]map[*txWatcher]struct{}{}
+	}
+	if jb.watchers[w.txID] == nil {
+		jb.watchers[w.txID] = map[*txWatcher]struct{}{}
+	}
+	jb.watchers[w.txID][w] = struct{}{}
+}
+
+func (jb *Client) removeWatcher(w *txWatcher) {
+	jb.watchersMu.Lock()
+	defer jb.watchersMu.Unlock()
+	delete(jb.watchers[w.txID], w)
+	if len(jb.watchers[w.txID]) == 0 {
+		delete(jb.watchers, w.txID)
+	}
+}
+
+// watching returns the watchers of the transaction, or of all transactions for an empty txid
+func (jb *Client) watching(txID string) []*txWatcher {
+	jb.watchersMu.Lock()
+	defer jb.watchersMu.Unlock()
+	var watchers []*txWatcher
+	for id, byTx := range jb.watchers {
+		if txID != "" && id != txID {
+			continue
+		}
+		for w := range byTx {
+			watchers = append(watchers, w)
+		}
+	}
+	return watchers
+}
+
+// observeTransaction passes a transaction of the streams of a subscription to its watchers
+func (jb *Client) observeTransaction(transaction *models.TransactionResponse, mempool bool) {
+	if transaction.Id == "" {
+		return
+	}
+	for _, w := range jb.watching(transaction.Id) {
+		w.observe()
+		if mempool {
+			w.seen()
+			continue
+		}
+		w.confirmed(TxEvent{
+			TxID:        transaction.Id,
+			Phase:       TxConfirmed,
+			BlockHash:   transaction.BlockHash,
+			BlockHeight: transaction.BlockHeight,
+			BlockTime:   transaction.BlockTime,
+			BlockIndex:  transaction.BlockIndex,
+		})
+	}
+}
+
+// observeReorg un-confirms the watched transactions mined at or above the height
+func (jb *Client) observeReorg(height uint32) {
+	if height == 0 {
+		return
+	}
+	for _, w := range jb.watching("") {
+		w.reorged(height)
+	}
+}
+
+// txWatcher keeps the phase of a watched transaction and the events that were not sent yet
+type txWatcher struct {
+	txID     string
+	mu       sync.Mutex
+	phase    TxPhase // 0 until the transaction is seen
+	block    TxEvent // the confirmation, while confirmed
+	observed bool    // received on the streams of a subscription, which follow it from then on
+	pending  []TxEvent
+	wake     chan struct{}
+}
+
+// observe marks the transaction as received on the streams of a subscription
+func (w *txWatcher) observe() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.observed = true
+}
+
+// isObserved returns whether the transaction was received on the streams of a subscription
+func (w *txWatcher) isObserved() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.observed
+}
+
+// seen moves a transaction that was not seen yet, or was reorged out, to TxSeen
+func (w *txWatcher) seen() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.phase == TxSeen || w.phase == TxConfirmed {
+		return false
+	}
+	w.emit(TxEvent{TxID: w.txID, Phase: TxSeen})
+	return true
+}
+
+// confirmed moves the transaction to TxConfirmed, a confirmation in another block reorgs out the previous one first
+func (w *txWatcher) confirmed(event TxEvent) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.phase == TxConfirmed {
+		if w.block.BlockHash == event.BlockHash && w.block.BlockHeight == event.BlockHeight {
+			return false
+		}
+		w.reorg()
+	}
+	w.block = event
+	w.emit(event)
+	return true
+}
+
+// reorged moves a transaction confirmed at or above the height to TxReorged, 0 reorgs out any confirmation
+func (w *txWatcher) reorged(height uint32) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.phase != TxConfirmed || w.block.BlockHeight < height {
+		return false
+	}
+	w.reorg()
+	return true
+}
+
+func (w *txWatcher) reorg() {
+	event := w.block
+	event.Phase = TxReorged
+	w.block = TxEvent{}
+	w.emit(event)
+}
+
+func (w *txWatcher) emit(event TxEvent) {
+	w.phase = event.Phase
+	w.pending = append(w.pending, event)
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the events that were not sent yet
+func (w *txWatcher) take() []TxEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.pending
+	w.pending = nil
+	return pending
+}
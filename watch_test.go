@@ -0,0 +1,210 @@
+package junglebus
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GorillaPool/go-junglebus/models"
+	"github.com/GorillaPool/go-junglebus/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextTxEvent returns the next event of a watched transaction, failing the test when none arrives in time
+func nextTxEvent(t *testing.T, events <-chan TxEvent) TxEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "events closed")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event of the watched transaction")
+		return TxEvent{}
+	}
+}
+
+// TestClient_WatchTransaction will test the phases of a watched transaction without subscriptions
+func TestClient_WatchTransaction(t *testing.T) {
+	const txID = "7bd2b5d5b8b0e6a4a5d4d0a6d7e8f0e1c2b3a4958677869504132231405f6e7d"
+
+	t.Run("already confirmed", func(t *testing.T) {
+		client, err := New(WithTransport(&transports.Mock{
+			GetTransactionFunc: func(context.Context, string) (*models.Transaction, error) {
+				return &models.Transaction{ID: txID, BlockHash: "hash", BlockHeight: 100, BlockIndex: 3}, nil
+			},
+		}))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := client.WatchTransaction(ctx, txID)
+		require.NoError(t, err)
+		assert.Equal(t, TxEvent{TxID: txID, Phase: TxConfirmed, BlockHash: "hash", BlockHeight: 100, BlockIndex: 3},
+			nextTxEvent(t, events))
+
+		cancel()
+		for range events {
+		}
+	})
+
+	t.Run("lookup fails", func(t *testing.T) {
+		client, err := New(WithTransport(&transports.Mock{
+			GetTransactionFunc: func(context.Context, string) (*models.Transaction, error) {
+				return nil, ErrUnauthorized
+			},
+		}))
+		require.NoError(t, err)
+
+		_, err = client.WatchTransaction(context.Background(), txID)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		assert.Empty(t, client.watching(txID))
+	})
+
+	t.Run("polling", func(t *testing.T) {
+		// the transaction is unknown, in the mempool, mined, back in the mempool after a reorg and mined again
+		responses := []*models.Transaction{
+			nil,
+			{ID: txID},
+			{ID: txID, BlockHash: "first", BlockHeight: 100},
+			{ID: txID},
+			{ID: txID, BlockHash: "second", BlockHeight: 101},
+		}
+		var mu sync.Mutex
+		client, err := New(
+			WithTransport(&transports.Mock{
+				GetTransactionFunc: func(context.Context, string) (*models.Transaction, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					response := responses[0]
+					if len(responses) > 1 {
+						responses = responses[1:]
+					}
+					if response == nil {
+						return nil, ErrNotFound
+					}
+					return response, nil
+				},
+			}),
+			WithWatchPollBackoff(time.Millisecond, 5*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := client.WatchTransaction(ctx, txID)
+		require.NoError(t, err)
+
+		expected := []TxEvent{
+			{TxID: txID, Phase: TxSeen},
+			{TxID: txID, Phase: TxConfirmed, BlockHash: "first", BlockHeight: 100},
+			{TxID: txID, Phase: TxReorged, BlockHash: "first", BlockHeight: 100},
+			{TxID: txID, Phase: TxSeen},
+			{TxID: txID, Phase: TxConfirmed, BlockHash: "second", BlockHeight: 101},
+		}
+		for _, event := range expected {
+			assert.Equal(t, event, nextTxEvent(t, events))
+		}
+
+		cancel()
+		for range events {
+		}
+		assert.Empty(t, client.watching(txID), "the watcher is removed")
+	})
+}
+
+// TestClient_WatchTransactionStreams will test following a watched transaction in the streams of a subscription
+func TestClient_WatchTransactionStreams(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		client := server.newClient()
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnMempool:     func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		mainChannel := "query:" + testSubscriptionID + ":100"
+		mempoolChannel := "query:" + testSubscriptionID + ":mempool"
+		controlChannel := "query:" + testSubscriptionID + ":control"
+		server.waitSubscribed(mainChannel)
+		server.waitSubscribed(mempoolChannel)
+		server.waitSubscribed(controlChannel)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := client.WatchTransaction(ctx, "tx")
+		require.NoError(t, err, "an unknown transaction is watched")
+
+		server.publishTransaction(mempoolChannel, "other")
+		server.publishTransaction(mempoolChannel, "tx")
+		assert.Equal(t, TxEvent{TxID: "tx", Phase: TxSeen}, nextTxEvent(t, events))
+
+		require.NoError(t, server.PublishTransaction(mainChannel, &models.TransactionResponse{
+			Id: "tx", BlockHash: "hash", BlockHeight: 105, BlockIndex: 2,
+		}))
+		assert.Equal(t, TxEvent{TxID: "tx", Phase: TxConfirmed, BlockHash: "hash", BlockHeight: 105, BlockIndex: 2},
+			nextTxEvent(t, events))
+
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionReorg), Block: 106})
+		server.publishMessage(controlChannel, &models.ControlResponse{StatusCode: uint32(SubscriptionReorg), Block: 104})
+		assert.Equal(t, TxEvent{TxID: "tx", Phase: TxReorged, BlockHash: "hash", BlockHeight: 105, BlockIndex: 2},
+			nextTxEvent(t, events), "only a reorg at or below the block of the transaction un-confirms it")
+	})
+}
+
+// TestClient_WatchTransactionNotStreamed will test polling a watched transaction the subscriptions do not receive
+func TestClient_WatchTransactionNotStreamed(t *testing.T) {
+	forEachProtocol(t, func(t *testing.T) {
+		server := newFakeServer(t)
+		var mu sync.Mutex
+		mined := false
+		server.HandleFunc("/v1/transaction/get/tx", func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			if !mined {
+				mustWrite(w, `{"id":"tx"}`)
+				return
+			}
+			mustWrite(w, `{"id":"tx","block_hash":"hash","block_height":105,"block_index":2}`)
+		})
+		client := server.newClient(WithWatchPollBackoff(time.Millisecond, 5*time.Millisecond))
+
+		subscription, err := client.Subscribe(context.Background(), testSubscriptionID, 100, EventHandler{
+			OnTransaction: func(*models.TransactionResponse) {},
+			OnStatus:      func(*models.ControlResponse) {},
+			OnError:       func(error) {},
+		})
+		require.NoError(t, err)
+		defer func() {
+			_ = subscription.Unsubscribe()
+		}()
+		server.waitSubscribed("query:" + testSubscriptionID + ":100")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := client.WatchTransaction(ctx, "tx")
+		require.NoError(t, err)
+		assert.Equal(t, TxEvent{TxID: "tx", Phase: TxSeen}, nextTxEvent(t, events))
+
+		mu.Lock()
+		mined = true
+		mu.Unlock()
+		assert.Equal(t, TxEvent{TxID: "tx", Phase: TxConfirmed, BlockHash: "hash", BlockHeight: 105, BlockIndex: 2},
+			nextTxEvent(t, events), "the transaction is polled while the subscription does not receive it")
+	})
+}
+
+func TestTxPhase_String(t *testing.T) {
+	assert.Equal(t, "seen", TxSeen.String())
+	assert.Equal(t, "confirmed", TxConfirmed.String())
+	assert.Equal(t, "reorged", TxReorged.String())
+	assert.Equal(t, "TxPhase(9)", TxPhase(9).String())
+}
@@ -0,0 +1,98 @@
+package junglebus
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebsocketTimeouts are the timeouts of the websocket connections of subscriptions
+type WebsocketTimeouts struct {
+	Handshake          time.Duration // to open the connection
+	Read               time.Duration // to read a message
+	Write              time.Duration // to write a message
+	MaxServerPingDelay time.Duration // between pings of the server before the connection is considered lost
+}
+
+// DefaultWebsocketTimeouts are the default timeouts of the websocket connections
+var DefaultWebsocketTimeouts = WebsocketTimeouts{
+	Handshake:          30 * time.Second,
+	Read:               30 * time.Second,
+	Write:              2 * time.Second,
+	MaxServerPingDelay: 30 * time.Second,
+}
+
+// validate returns an ErrInvalidTimeout error for the first timeout that is not positive
+func (t WebsocketTimeouts) validate() error {
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"handshake", t.Handshake},
+		{"read", t.Read},
+		{"write", t.Write},
+		{"max server ping delay", t.MaxServerPingDelay},
+	} {
+		if timeout.value <= 0 {
+			return fmt.Errorf("%w: websocket %s timeout is %s", ErrInvalidTimeout, timeout.name, timeout.value)
+		}
+	}
+	return nil
+}
+
+// websocketPath is the path of the websocket endpoint of the server
+const websocketPath = "/connection/websocket"
+
+// parseWebsocketURL parses the URL given to WithWebsocketURL, a URL without scheme is returned with an empty scheme
+func parseWebsocketURL(websocketURL string) (*url.URL, error) {
+	if !strings.Contains(websocketURL, "://") {
+		websocketURL = "//" + websocketURL
+	}
+	u, err := url.Parse(websocketURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidWebsocketURL, err)
+	}
+	switch u.Scheme {
+	case "", "ws", "wss":
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidWebsocketURL, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: missing host in %q", ErrInvalidWebsocketURL, websocketURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = websocketPath
+	}
+	return u, nil
+}
+
+// websocketEndpoint returns the URL the subscriptions connect to, from WithWebsocketURL or the server URL
+func (jb *Client) websocketEndpoint() string {
+	scheme := "wss"
+	if !jb.transport.IsSSL() {
+		scheme = "ws"
+	}
+	if jb.websocketURL == nil {
+		endpoint := scheme + "://" + jb.transport.GetServerURL() + websocketPath
+		if !jb.jsonProtocol {
+			endpoint += "?format=protobuf"
+		}
+		return endpoint
+	}
+
+	u := *jb.websocketURL
+	if u.Scheme == "" {
+		u.Scheme = scheme
+	}
+	if !jb.jsonProtocol {
+		query := u.Query()
+		query.Set("format", "protobuf")
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}